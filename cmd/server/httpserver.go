@@ -0,0 +1,21 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/actuallystonmai/recommendation-service/internal/config"
+)
+
+// HTTP server on the configured address and timeouts. The header timeout
+// bounds slow-loris clients that never finish sending headers; the write
+// timeout also caps how long a streamed response may run.
+func newHTTPServer(cfg *config.Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr(),
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/config"
+)
+
+func TestNewHTTPServerUsesConfiguredTimeouts(t *testing.T) {
+	cfg := &config.Config{
+		Port:                  9090,
+		HTTPReadHeaderTimeout: 2 * time.Second,
+		HTTPReadTimeout:       7 * time.Second,
+		HTTPWriteTimeout:      11 * time.Second,
+		HTTPIdleTimeout:       90 * time.Second,
+	}
+	handler := http.NewServeMux()
+
+	srv := newHTTPServer(cfg, handler)
+
+	if srv.Addr != cfg.Addr() || srv.Handler != handler {
+		t.Errorf("expected address %s and the given handler, got %s and %v", cfg.Addr(), srv.Addr, srv.Handler)
+	}
+	if srv.ReadHeaderTimeout != 2*time.Second || srv.ReadTimeout != 7*time.Second ||
+		srv.WriteTimeout != 11*time.Second || srv.IdleTimeout != 90*time.Second {
+		t.Errorf("expected timeouts 2s/7s/11s/1m30s, got %s/%s/%s/%s",
+			srv.ReadHeaderTimeout, srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+}
+
+func TestHTTPTimeoutDefaults(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	srv := newHTTPServer(cfg, http.NewServeMux())
+	if srv.ReadHeaderTimeout != 5*time.Second || srv.ReadTimeout != 15*time.Second ||
+		srv.WriteTimeout != 30*time.Second || srv.IdleTimeout != 60*time.Second {
+		t.Errorf("expected default timeouts 5s/15s/30s/1m0s, got %s/%s/%s/%s",
+			srv.ReadHeaderTimeout, srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+}
+
+func TestHTTPTimeoutsFromEnv(t *testing.T) {
+	t.Setenv("HTTP_READ_HEADER_TIMEOUT", "1s")
+	t.Setenv("HTTP_WRITE_TIMEOUT", "0s")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	srv := newHTTPServer(cfg, http.NewServeMux())
+	if srv.ReadHeaderTimeout != time.Second || srv.WriteTimeout != 0 {
+		t.Errorf("expected a 1s header timeout and no write timeout, got %s and %s", srv.ReadHeaderTimeout, srv.WriteTimeout)
+	}
+
+	t.Setenv("HTTP_IDLE_TIMEOUT", "-1s")
+	if _, err := config.Load(); err == nil {
+		t.Error("expected a negative timeout to be rejected")
+	}
+}
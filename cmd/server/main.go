@@ -13,13 +13,14 @@ import (
 	"github.com/actuallystonmai/recommendation-service/internal/cache"
 	"github.com/actuallystonmai/recommendation-service/internal/config"
 	"github.com/actuallystonmai/recommendation-service/internal/handler"
+	"github.com/actuallystonmai/recommendation-service/internal/metrics"
 	"github.com/actuallystonmai/recommendation-service/internal/model"
 	"github.com/actuallystonmai/recommendation-service/internal/repository"
 	"github.com/actuallystonmai/recommendation-service/internal/router"
 	"github.com/actuallystonmai/recommendation-service/internal/service"
 	"github.com/actuallystonmai/recommendation-service/seeds"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/redis/go-redis/v9"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func main() {
@@ -42,11 +43,46 @@ func main() {
 		log.Fatalf("failed to connect to database %v", err)
 	}
 	defer pool.Close()
+	deps := []dependency{{name: "postgres", ping: pool.Ping}}
 
-	if err := waitForDB(ctx, pool); err != nil {
-		log.Fatalf("fail to connect to database: %v", err)
+	// Optional read replica for batch user paging
+	var replicaPool *pgxpool.Pool
+	if cfg.DatabaseReplicaURL != "" {
+		replicaConfig, err := pgxpool.ParseConfig(cfg.DatabaseReplicaURL)
+		if err != nil {
+			log.Fatalf("failed to parse database replica config %v", err)
+		}
+		replicaConfig.MaxConns = int32(cfg.DBPoolSize)
+		replicaPool, err = pgxpool.NewWithConfig(ctx, replicaConfig)
+		if err != nil {
+			log.Fatalf("failed to connect to database replica %v", err)
+		}
+		defer replicaPool.Close()
+		deps = append(deps, dependency{name: "postgres replica", ping: replicaPool.Ping})
+	}
+
+	// ------------ Setup Redis -------------------
+	redisClient, err := cache.NewClient(cache.ClientConfig{
+		URL:          cfg.RedisURL,
+		PoolSize:     cfg.RedisPoolSize,
+		DialTimeout:  cfg.RedisDialTimeout,
+		ReadTimeout:  cfg.RedisReadTimeout,
+		WriteTimeout: cfg.RedisWriteTimeout,
+		MaxRetries:   cfg.RedisMaxRetries,
+	})
+	if err != nil {
+		log.Fatalf("failed to create redis client: %v", err)
+	}
+	defer redisClient.Close()
+	deps = append(deps, dependency{name: "redis", ping: func(ctx context.Context) error {
+		return redisClient.Ping(ctx).Err()
+	}})
+
+	retry := retryConfig{MaxAttempts: cfg.StartupMaxAttempts, BaseDelay: cfg.StartupBaseDelay}
+	if err := waitForDependencies(ctx, retry, deps...); err != nil {
+		log.Fatalf("fail to connect to dependencies: %v", err)
 	}
-	log.Println("connected to PostgreSQL")
+	log.Println("connected to PostgreSQL and redis")
 
 	// Run migrations
 	// for migrate-down using CLI command
@@ -63,46 +99,96 @@ func main() {
 	}
 
 	// ------------ Setup Seed Data ---------------
-	if err := checkSeed(ctx, pool); err != nil {
+	if err := checkSeed(ctx, pool, cfg); err != nil {
 		log.Fatalf("failed to check seed %v", err)
 	}
 
-	// ------------ Setup Redis -------------------
-	redisOpts, err := redis.ParseURL(cfg.RedisURL)
-	if err != nil {
-		log.Fatalf("failed to parse redis URL: %v", err)
+	// -------------- Setup Server -------------------
+	repo := repository.NewRepository(pool, replicaPool, cfg.DBQueryTimeout, cfg.SlowQueryThreshold)
+	supersetMaxLimit := 0
+	if cfg.CacheSupersetReuse {
+		supersetMaxLimit = service.MaxLimit
+	}
+	cacheLayer := cache.NewCache(redisClient, cfg.CacheTTL, cfg.IdempotencyTTL, cfg.CacheKeyVersion, supersetMaxLimit)
+	var modelClient model.Scorer = model.NewClient(model.ModelConfig{
+		GenreFallback:       cfg.GenreFallback,
+		GenreCap:            cfg.GenreCap,
+		GenreSimilarity:     cfg.GenreSimilarity,
+		MinLatency:          cfg.ModelLatencyMin,
+		MaxLatency:          cfg.ModelLatencyMax,
+		FailureRate:         cfg.ModelFailureRate,
+		RecencyHalfLifeDays: cfg.RecencyHalfLifeDays,
+		DisableNoise:        !cfg.ScoreNoiseEnabled,
+	})
+	if cfg.ModelCBThreshold > 0 {
+		modelClient = model.NewBreakerScorer(modelClient, uint32(cfg.ModelCBThreshold), cfg.ModelCBTimeout)
+	}
+	cacheMetrics := metrics.NewCacheMetrics()
+	prometheus.MustRegister(cacheMetrics)
+	service := service.NewService(repo, cacheLayer, modelClient, service.Config{
+		BatchConcurrency: cfg.BatchConcurrency,
+		Experiment: cfg.ExperimentSplit,
+		SeedDefaults: seedConfig(cfg),
+		MinRegenInterval: cfg.MinRegenInterval,
+		RatingPolicy: cfg.RatingPolicy,
+		MinPopularity: cfg.MinPopularity,
+		MaxUserID: cfg.MaxUserID,
+		CacheMetrics: cacheMetrics,
+		BatchFailureThreshold: cfg.BatchFailureThreshold,
+		BatchFailureWindow: cfg.BatchFailureWindow,
+		ScoringTimeout: cfg.ScoringTimeout,
+	})
+	// Stops the batch workers on the way out, after the server has drained
+	defer service.Close()
+	// Pre-generate every user's recommendations using CLI command, then exit
+	if len(os.Args) > 1 && os.Args[1] == "warmup" {
+		if err := warmup(ctx, service); err != nil {
+			log.Fatalf("failed to warm up cache: %v", err)
+		}
+		return
 	}
-	redisClient := redis.NewClient(redisOpts)
-	defer redisClient.Close()
 
-	if err := waitForRedis(ctx, redisClient); err != nil {
-		log.Fatalf("fail to connect to redis: %v", err)
+	// Batch rate limits are shared across replicas through Redis
+	var batchLimiter handler.RateLimiter
+	if cfg.BatchRateLimit > 0 {
+		batchLimiter = cache.NewRateLimiter(redisClient, "batch", cfg.BatchRateLimit, time.Minute)
 	}
-	log.Println("connected to redis")
+	handler := handler.NewHandler(service, handler.Config{
+		MaxBodyBytes: cfg.MaxBodyBytes,
+		Envelope:     cfg.ResponseEnvelope,
+		DebugEnabled: cfg.DebugEndpointsEnabled,
+	})
 
-	// -------------- Setup Server -------------------
-	repo := repository.NewRepository(pool)
-	cacheLayer := cache.NewCache(redisClient, cfg.CacheTTL)
-	modelClient := model.NewClient()
-	service := service.NewService(repo, cacheLayer, modelClient)
-	handler := handler.NewHandler(service)
+	// Background workers, stopped on shutdown
+	stopWorkers := make(chan struct{})
+	if cfg.WarmCacheEnabled {
+		log.Printf("cache warmer enabled: top %d users every %s", cfg.WarmCacheTopN, cfg.WarmCacheInterval)
+		go service.RunCacheWarmer(stopWorkers, cfg.WarmCacheInterval, cfg.WarmCacheTopN)
+	}
 
-	r := router.Setup(handler)
-	
-	srv := &http.Server{
-		Addr:         cfg.Addr(),
-		Handler:      r,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  60 * time.Second,
+	// Pool metrics for /metrics, plus a warning log on sustained saturation
+	poolStats := metrics.PgxPoolStats(pool)
+	prometheus.MustRegister(metrics.NewPoolCollector("primary", poolStats))
+	go metrics.RunPoolMonitor(stopWorkers, "primary", poolStats)
+	if replicaPool != nil {
+		replicaStats := metrics.PgxPoolStats(replicaPool)
+		prometheus.MustRegister(metrics.NewPoolCollector("replica", replicaStats))
+		go metrics.RunPoolMonitor(stopWorkers, "replica", replicaStats)
 	}
 
+	r := router.Setup(handler, cfg, batchLimiter)
+	
+	srv := newHTTPServer(cfg, r)
+
 	// shutdown
+	shutdownDone := make(chan struct{})
 	go func() {
+		defer close(shutdownDone)
 		sigCh := make(chan os.Signal, 1)
 		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 		<-sigCh
 		log.Println("shutting down server...")
+		close(stopWorkers)
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 		srv.Shutdown(shutdownCtx)
@@ -112,18 +198,30 @@ func main() {
 	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
 		log.Fatalf("server error: %v", err)
 	}
+	// ListenAndServe returns as shutdown starts; wait for in-flight requests
+	// before the deferred cleanup runs
+	<-shutdownDone
 	log.Println("server stopped")
 }
 
-func waitForDB(ctx context.Context, pool *pgxpool.Pool) error {
-	for i := range 30 {
-		if err := pool.Ping(ctx); err == nil {
-			return nil
-		}
-		log.Printf("waiting for database... (%d/30)", i+1)
-		time.Sleep(1 * time.Second)
+// Users per warmup page, matching the batch endpoint's maximum page size
+const warmupPageSize = 100
+
+func warmup(ctx context.Context, svc *service.Service) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	start := time.Now()
+	result, err := svc.WarmAllUsers(ctx, warmupPageSize, func(p service.WarmupProgress) {
+		log.Printf("warmup: page %d done, %d/%d users (%d failed) after %s",
+			p.Page, p.Processed, p.TotalUsers, p.Failed, time.Since(start).Round(time.Millisecond))
+	})
+	if err != nil {
+		return fmt.Errorf("after %d users: %w", result.Processed, err)
 	}
-	return fmt.Errorf("database connection timeout after 30s")
+	log.Printf("warmup complete: %d users (%d failed) in %s",
+		result.Processed, result.Failed, time.Since(start).Round(time.Millisecond))
+	return nil
 }
 
 func migrateDown(ctx context.Context, pool *pgxpool.Pool) error {
@@ -150,18 +248,7 @@ func migrateUp(ctx context.Context, pool *pgxpool.Pool) error {
 	return nil
 }
 
-func waitForRedis(ctx context.Context, client *redis.Client) error {
-	for i := range 30 {
-		if err := client.Ping(ctx).Err(); err == nil {
-			return nil
-		}
-		log.Printf("waiting for redis... (%d/30)", i+1)
-		time.Sleep(1 * time.Second)
-	}
-	return fmt.Errorf("redis connection timeout after 30s")
-}
-
-func checkSeed(ctx context.Context, pool *pgxpool.Pool) error {
+func checkSeed(ctx context.Context, pool *pgxpool.Pool, cfg *config.Config) error {
 	var count int
 	if err := pool.QueryRow(ctx, "SELECT COUNT(*) FROM users").Scan(&count); err != nil {
 		return fmt.Errorf("check users count: %w", err)
@@ -170,5 +257,15 @@ func checkSeed(ctx context.Context, pool *pgxpool.Pool) error {
 		log.Printf("database already seeded (%d users), skipping", count)
 		return nil
 	}
-	return seeds.Setup(ctx, pool)
+	_, err := seeds.Setup(ctx, pool, seedConfig(cfg))
+	return err
+}
+
+func seedConfig(cfg *config.Config) seeds.SeedConfig {
+	return seeds.SeedConfig{
+		Users:       cfg.SeedUsers,
+		Content:     cfg.SeedContent,
+		WatchEvents: cfg.SeedWatchEvents,
+		Seed:        cfg.SeedRandom,
+	}
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Longest wait between two connection attempts
+const maxStartupDelay = 5 * time.Second
+
+// Exponential backoff for startup connection attempts
+type retryConfig struct {
+	MaxAttempts int
+	// Wait after the first failed attempt, doubling after each further one
+	BaseDelay time.Duration
+	// Waits for d or until ctx is done; nil sleeps on a timer
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// An external service the server needs before it can start
+type dependency struct {
+	name string
+	ping func(ctx context.Context) error
+}
+
+// Delay before the attempt after the given failed one (1-based)
+func (c retryConfig) delay(attempt int) time.Duration {
+	d := c.BaseDelay
+	for i := 1; i < attempt && d < maxStartupDelay; i++ {
+		d *= 2
+	}
+	return min(d, maxStartupDelay)
+}
+
+// Ping until it succeeds, backing off exponentially between attempts. Fails
+// with the last ping error once MaxAttempts attempts have failed.
+func waitForDependency(ctx context.Context, name string, ping func(ctx context.Context) error, cfg retryConfig) error {
+	sleep := cfg.sleep
+	if sleep == nil {
+		sleep = sleepContext
+	}
+
+	var err error
+	for attempt := 1; attempt <= cfg.MaxAttempts; attempt++ {
+		if err = ping(ctx); err == nil {
+			return nil
+		}
+		if attempt == cfg.MaxAttempts {
+			break
+		}
+		d := cfg.delay(attempt)
+		log.Printf("waiting for %s... (%d/%d, retrying in %s): %v", name, attempt, cfg.MaxAttempts, d, err)
+		if serr := sleep(ctx, d); serr != nil {
+			return fmt.Errorf("%s: %w", name, serr)
+		}
+	}
+	return fmt.Errorf("%s did not come up after %d attempts: %w", name, cfg.MaxAttempts, err)
+}
+
+// Wait for every dependency at once. The error joins one error per dependency
+// that never came up.
+func waitForDependencies(ctx context.Context, cfg retryConfig, deps ...dependency) error {
+	errs := make([]error, len(deps))
+	var wg sync.WaitGroup
+	for i, dep := range deps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = waitForDependency(ctx, dep.name, dep.ping, cfg)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
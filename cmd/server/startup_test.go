@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Ping that fails until its nth call
+func pingSucceedingOn(n int) (func(context.Context) error, *int) {
+	calls := 0
+	return func(context.Context) error {
+		calls++
+		if calls < n {
+			return errors.New("connection refused")
+		}
+		return nil
+	}, &calls
+}
+
+// Retry config recording its waits instead of sleeping
+func recordingRetry(maxAttempts int, baseDelay time.Duration) (retryConfig, *[]time.Duration) {
+	var waits []time.Duration
+	return retryConfig{
+		MaxAttempts: maxAttempts,
+		BaseDelay:   baseDelay,
+		sleep: func(_ context.Context, d time.Duration) error {
+			waits = append(waits, d)
+			return nil
+		},
+	}, &waits
+}
+
+func TestWaitForDependencyBacksOff(t *testing.T) {
+	ping, calls := pingSucceedingOn(4)
+	cfg, waits := recordingRetry(10, 100*time.Millisecond)
+
+	if err := waitForDependency(context.Background(), "postgres", ping, cfg); err != nil {
+		t.Fatalf("expected success on the 4th attempt, got %v", err)
+	}
+	if *calls != 4 {
+		t.Errorf("expected 4 pings, got %d", *calls)
+	}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}
+	if !reflect.DeepEqual(*waits, want) {
+		t.Errorf("expected waits %v, got %v", want, *waits)
+	}
+}
+
+func TestWaitForDependencyCapsDelay(t *testing.T) {
+	cfg := retryConfig{BaseDelay: time.Second}
+	var got []time.Duration
+	for attempt := 1; attempt <= 5; attempt++ {
+		got = append(got, cfg.delay(attempt))
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, maxStartupDelay, maxStartupDelay}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected delays %v, got %v", want, got)
+	}
+}
+
+func TestWaitForDependencyGivesUp(t *testing.T) {
+	ping, calls := pingSucceedingOn(100)
+	cfg, waits := recordingRetry(3, 10*time.Millisecond)
+
+	err := waitForDependency(context.Background(), "redis", ping, cfg)
+	if err == nil || !strings.Contains(err.Error(), "redis did not come up after 3 attempts: connection refused") {
+		t.Fatalf("expected an error naming redis, got %v", err)
+	}
+	// No wait after the last attempt
+	if *calls != 3 || len(*waits) != 2 {
+		t.Errorf("expected 3 pings and 2 waits, got %d and %d", *calls, len(*waits))
+	}
+}
+
+func TestWaitForDependencyStopsOnCancel(t *testing.T) {
+	ping, calls := pingSucceedingOn(100)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := waitForDependency(ctx, "postgres", ping, retryConfig{MaxAttempts: 5, BaseDelay: time.Hour})
+	if !errors.Is(err, context.Canceled) || *calls != 1 {
+		t.Errorf("expected to stop after one ping on cancel, got %v after %d pings", err, *calls)
+	}
+}
+
+func TestWaitForDependenciesNamesEachFailure(t *testing.T) {
+	up, _ := pingSucceedingOn(2)
+	down, _ := pingSucceedingOn(100)
+	replicaDown, _ := pingSucceedingOn(100)
+	cfg := retryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	err := waitForDependencies(context.Background(), cfg,
+		dependency{name: "postgres", ping: up},
+		dependency{name: "postgres replica", ping: replicaDown},
+		dependency{name: "redis", ping: down},
+	)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	msg := err.Error()
+	if strings.Contains(msg, "postgres did not") || !strings.Contains(msg, "postgres replica did not come up") || !strings.Contains(msg, "redis did not come up") {
+		t.Errorf("expected only the replica and redis to be named, got %q", msg)
+	}
+
+	if err := waitForDependencies(context.Background(), cfg); err != nil {
+		t.Errorf("expected no error without dependencies, got %v", err)
+	}
+}
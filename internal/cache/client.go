@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Connection settings applied on top of the Redis URL
+type ClientConfig struct {
+	URL string
+	// Connections per pool; 0 keeps the URL's pool_size or the go-redis default
+	// of 10 per CPU
+	PoolSize     int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// Retries of a failed command; 0 disables retrying
+	MaxRetries int
+}
+
+// Build a Redis client from cfg.URL with the configured pool and timeouts,
+// which take precedence over the same settings given as URL parameters
+func NewClient(cfg ClientConfig) (*redis.Client, error) {
+	opts, err := redis.ParseURL(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse redis URL: %w", err)
+	}
+	if cfg.PoolSize > 0 {
+		opts.PoolSize = cfg.PoolSize
+	}
+	opts.DialTimeout = cfg.DialTimeout
+	opts.ReadTimeout = cfg.ReadTimeout
+	opts.WriteTimeout = cfg.WriteTimeout
+	// go-redis reads 0 as its default of 3 retries and -1 as none
+	opts.MaxRetries = cfg.MaxRetries
+	if cfg.MaxRetries == 0 {
+		opts.MaxRetries = -1
+	}
+	return redis.NewClient(opts), nil
+}
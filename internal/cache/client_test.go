@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestNewClientAppliesConfig(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, err := NewClient(ClientConfig{
+		URL:          "redis://" + mr.Addr() + "/0?pool_size=5",
+		PoolSize:     25,
+		DialTimeout:  2 * time.Second,
+		ReadTimeout:  500 * time.Millisecond,
+		WriteTimeout: 700 * time.Millisecond,
+		MaxRetries:   4,
+	})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+
+	opts := client.Options()
+	if opts.PoolSize != 25 {
+		t.Errorf("expected configured pool size 25 to win over the URL's, got %d", opts.PoolSize)
+	}
+	if opts.DialTimeout != 2*time.Second || opts.ReadTimeout != 500*time.Millisecond || opts.WriteTimeout != 700*time.Millisecond {
+		t.Errorf("expected configured timeouts, got dial %s read %s write %s", opts.DialTimeout, opts.ReadTimeout, opts.WriteTimeout)
+	}
+	if opts.MaxRetries != 4 {
+		t.Errorf("expected 4 retries, got %d", opts.MaxRetries)
+	}
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Errorf("ping: %v", err)
+	}
+}
+
+func TestNewClientDefaults(t *testing.T) {
+	client, err := NewClient(ClientConfig{URL: "redis://localhost:6379/0?pool_size=7"})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer client.Close()
+
+	opts := client.Options()
+	if opts.PoolSize != 7 {
+		t.Errorf("expected an unset pool size to keep the URL's 7, got %d", opts.PoolSize)
+	}
+	// Disabled rather than go-redis's default of 3
+	if opts.MaxRetries != 0 {
+		t.Errorf("expected 0 retries to disable retrying, got MaxRetries %d", opts.MaxRetries)
+	}
+}
+
+func TestNewClientInvalidURL(t *testing.T) {
+	if _, err := NewClient(ClientConfig{URL: "http://localhost:6379"}); err == nil {
+		t.Error("expected an error for a non-redis URL")
+	}
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Sliding-window rate limiter shared by every instance through Redis. Each
+// window is an INCR counter with an expiry; the previous window's count is
+// weighted by how much of it still overlaps the sliding window. Rejected
+// requests count towards the limit too.
+type RateLimiter struct {
+	client *redis.Client
+	prefix string
+	limit  int
+	window time.Duration
+	now    func() time.Time
+}
+
+func NewRateLimiter(client *redis.Client, prefix string, limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		client: client,
+		prefix: prefix,
+		limit:  limit,
+		window: window,
+		now:    time.Now,
+	}
+}
+
+func (l *RateLimiter) windowKey(key string, window int64) string {
+	return fmt.Sprintf("ratelimit:%s:%s:%d", l.prefix, key, window)
+}
+
+// Count a request for key and report whether it is within the limit. When it
+// is not, retryAfter is how long until another request would be allowed.
+func (l *RateLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	now := l.now()
+	window := now.UnixNano() / int64(l.window)
+	elapsed := time.Duration(now.UnixNano() % int64(l.window))
+	current := l.windowKey(key, window)
+
+	pipe := l.client.TxPipeline()
+	incr := pipe.Incr(ctx, current)
+	// Kept for a second window so it can be weighted as the previous one
+	pipe.Expire(ctx, current, 2*l.window)
+	prev := pipe.Get(ctx, l.windowKey(key, window-1))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return false, 0, fmt.Errorf("rate limit %s: %w", current, err)
+	}
+
+	prevCount, err := prev.Int()
+	if err != nil && err != redis.Nil {
+		return false, 0, fmt.Errorf("rate limit previous window %s: %w", key, err)
+	}
+	overlap := 1 - float64(elapsed)/float64(l.window)
+	estimate := float64(prevCount)*overlap + float64(incr.Val())
+	if estimate <= float64(l.limit) {
+		return true, 0, nil
+	}
+	return false, l.retryAfter(float64(prevCount), float64(incr.Val()), elapsed), nil
+}
+
+// Time until the weighted count leaves room for one more request, given the
+// previous and current window counts
+func (l *RateLimiter) retryAfter(prev, current float64, elapsed time.Duration) time.Duration {
+	room := float64(l.limit - 1)
+	w := float64(l.window)
+
+	// Within this window, once enough of the previous one has slid out
+	if current <= room && prev > 0 {
+		at := time.Duration(w * (1 - (room-current)/prev))
+		if at < l.window {
+			return max(at-elapsed, 0)
+		}
+	}
+	// Otherwise in the next window, once enough of this one has slid out
+	at := l.window - elapsed
+	if current > room {
+		at += time.Duration(w * (1 - room/current))
+	}
+	return at
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Limiter backed by miniredis with a controllable clock
+func newTestRateLimiter(t *testing.T, limit int) (*RateLimiter, *time.Time) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	now := time.Unix(0, 0).Add(100 * time.Hour)
+	l := NewRateLimiter(client, "batch", limit, time.Minute)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestRateLimiterWindowResets(t *testing.T) {
+	l, now := newTestRateLimiter(t, 3)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		allowed, _, err := l.Allow(ctx, "client")
+		if err != nil || !allowed {
+			t.Fatalf("request %d: expected allowed, got allowed=%v err=%v", i+1, allowed, err)
+		}
+	}
+	allowed, retryAfter, err := l.Allow(ctx, "client")
+	if err != nil || allowed {
+		t.Fatalf("expected 4th request to be limited, got allowed=%v err=%v", allowed, err)
+	}
+	if retryAfter <= 0 || retryAfter > 2*time.Minute {
+		t.Errorf("expected retry after within two windows, got %s", retryAfter)
+	}
+
+	// Other clients have their own quota
+	if allowed, _, _ := l.Allow(ctx, "other"); !allowed {
+		t.Error("expected a different client to be allowed")
+	}
+
+	// Once both windows have slid past, the quota is back
+	*now = now.Add(2 * time.Minute)
+	if allowed, _, err := l.Allow(ctx, "client"); err != nil || !allowed {
+		t.Errorf("expected allowed after the window reset, got allowed=%v err=%v", allowed, err)
+	}
+}
+
+func TestRateLimiterSlidesPreviousWindow(t *testing.T) {
+	l, now := newTestRateLimiter(t, 4)
+	ctx := context.Background()
+
+	// Fill the quota at the end of one window
+	*now = now.Add(59 * time.Second)
+	for i := 0; i < 4; i++ {
+		if allowed, _, _ := l.Allow(ctx, "client"); !allowed {
+			t.Fatalf("request %d: expected allowed", i+1)
+		}
+	}
+
+	// Just into the next window most of the previous count still applies
+	*now = now.Add(2 * time.Second)
+	allowed, retryAfter, err := l.Allow(ctx, "client")
+	if err != nil || allowed {
+		t.Fatalf("expected limited at the window boundary, got allowed=%v err=%v", allowed, err)
+	}
+
+	// Retry-After points at when the weighted count leaves room again
+	*now = now.Add(retryAfter)
+	if allowed, _, err := l.Allow(ctx, "client"); err != nil || !allowed {
+		t.Errorf("expected allowed after %s, got allowed=%v err=%v", retryAfter, allowed, err)
+	}
+}
+
+func TestRateLimiterRedisError(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	l := NewRateLimiter(client, "batch", 1, time.Minute)
+
+	mr.Close()
+	if _, _, err := l.Allow(context.Background(), "client"); err == nil {
+		t.Error("expected an error when redis is unreachable")
+	}
+}
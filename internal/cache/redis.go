@@ -4,33 +4,87 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/actuallystonmai/recommendation-service/internal/domain"
 	"github.com/redis/go-redis/v9"
 )
 
+// Keys requested per SCAN page when clearing cached keys
+const clearScanCount = 100
+
+const (
+	statsKey = "stats:summary"
+	// Short TTL: stats are aggregated over whole tables but needn't be exact
+	statsTTL = time.Minute
+	// Trending lists shift slowly enough to serve a minute-old result
+	trendingTTL = time.Minute
+	// An idempotency key reserved by a request that never finished, e.g.
+	// after a crash, frees up after this long
+	idempotencyReserveTTL = time.Minute
+	// Users flagged by RecordBatchFailure, scored by when they were flagged.
+	// Kept until cleared, or failedUsersRetention after their last flag
+	failedUsersKey       = "failed_users"
+	failedUsersRetention = 7 * 24 * time.Hour
+	// Most flagged users kept; past this the longest unflagged are dropped
+	maxFailedUsers = 1000
+	// When anything feeding a user's recommendations, or the whole catalog,
+	// last changed. A stamp unseen this long is dropped and, like an evicted
+	// one, reads as changed now
+	catalogChangedKey = "changed:catalog"
+	changeStampTTL    = 30 * 24 * time.Hour
+)
+
 type Cache struct {
 	client *redis.Client
 	ttl time.Duration
+	idempotencyTTL time.Duration
+	// Embedded in recommendation keys; changing it orphans every older entry
+	keyVersion string
+	// Serve a miss from an entry cached for a larger limit, truncated, up to
+	// this limit. 0 disables superset reuse
+	supersetMaxLimit int
 }
 
-func NewCache(client *redis.Client, ttl time.Duration) *Cache {
+// An empty keyVersion keeps the unversioned rec:user: keys. supersetMaxLimit
+// is the largest limit the service caches, or 0 to disable superset reuse
+func NewCache(client *redis.Client, ttl, idempotencyTTL time.Duration, keyVersion string, supersetMaxLimit int) *Cache {
 	return &Cache{
-		client: client,
-		ttl:    ttl,
+		client:           client,
+		ttl:              ttl,
+		idempotencyTTL:   idempotencyTTL,
+		keyVersion:       keyVersion,
+		supersetMaxLimit: supersetMaxLimit,
+	}
+}
+
+// Prefix shared by every recommendation key of a user
+func (c *Cache) userKeyPrefix(userID int64) string {
+	if c.keyVersion == "" {
+		return fmt.Sprintf("rec:user:%d", userID)
 	}
+	return fmt.Sprintf("rec:v%s:user:%d", c.keyVersion, userID)
 }
 
-func buildKey(userID int64, limit int) string {
-	return fmt.Sprintf("rec:user:%d:limit:%d", userID, limit)
+func (c *Cache) buildKey(userID int64, limit int, variant string) string {
+	key := fmt.Sprintf("%s:limit:%d", c.userKeyPrefix(userID), limit)
+	if variant != "" {
+		key += ":" + variant
+	}
+	return key
 }
 
-// Get recommendations from cache
-func (c *Cache) Get(ctx context.Context, userID int64, limit int) ([]domain.ScoredRecommendation, bool, error) {
-	key := buildKey(userID, limit)
+// Get recommendations from cache. With superset reuse, a miss falls back to
+// the smallest larger-limit entry for the same variant, truncated to limit.
+func (c *Cache) Get(ctx context.Context, userID int64, limit int, variant string) (*domain.CachedRecommendations, bool, error) {
+	key := c.buildKey(userID, limit, variant)
 	val, err := c.client.Get(ctx, key).Result()
 	if err == redis.Nil {
+		if c.supersetMaxLimit > 0 {
+			return c.getSuperset(ctx, userID, limit, variant)
+		}
 		return nil, false, nil
 	}
 	
@@ -38,18 +92,63 @@ func (c *Cache) Get(ctx context.Context, userID int64, limit int) ([]domain.Scor
 		return nil, false, fmt.Errorf("failed to get recommendations from cache: %w", err)
 	}
 	
-	var recs []domain.ScoredRecommendation
-	if err := json.Unmarshal([]byte(val), &recs); err != nil {
+	return decodeEntry(key, val)
+}
+
+// Check every larger limit in one MGET. Lists are sorted, so the prefix of a
+// larger one is the smaller list.
+func (c *Cache) getSuperset(ctx context.Context, userID int64, limit int, variant string) (*domain.CachedRecommendations, bool, error) {
+	if limit >= c.supersetMaxLimit {
+		return nil, false, nil
+	}
+	keys := make([]string, 0, c.supersetMaxLimit-limit)
+	for l := limit + 1; l <= c.supersetMaxLimit; l++ {
+		keys = append(keys, c.buildKey(userID, l, variant))
+	}
+	vals, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get superset recommendations from cache: %w", err)
+	}
+
+	for i, v := range vals {
+		val, ok := v.(string)
+		if !ok {
+			continue
+		}
+		entry, found, err := decodeEntry(keys[i], val)
+		if err != nil {
+			return nil, false, err
+		}
+		if !found {
+			continue
+		}
+		if len(entry.Recommendations) > limit {
+			entry.Recommendations = entry.Recommendations[:limit]
+		}
+		return entry, true, nil
+	}
+	return nil, false, nil
+}
+
+func decodeEntry(key, val string) (*domain.CachedRecommendations, bool, error) {
+	// Bare lists were written before entries carried a model version; treat
+	// them as a miss so they are regenerated
+	if strings.HasPrefix(val, "[") {
+		return nil, false, nil
+	}
+
+	var entry domain.CachedRecommendations
+	if err := json.Unmarshal([]byte(val), &entry); err != nil {
 		return nil, false, fmt.Errorf("cache unmarshal %s: %w", key, err)
 	}
 	
-	return recs, true, nil
+	return &entry, true, nil
 }
 
 // Store recommendations in cache
-func (c *Cache) Set(ctx context.Context, userID int64, limit int, recs []domain.ScoredRecommendation) error {
-	key := buildKey(userID, limit)
-	val, err := json.Marshal(recs)
+func (c *Cache) Set(ctx context.Context, userID int64, limit int, variant string, entry domain.CachedRecommendations) error {
+	key := c.buildKey(userID, limit, variant)
+	val, err := json.Marshal(entry)
 	if err != nil {
 		return fmt.Errorf("failed to marshal recommendations: %w", err)
 	}
@@ -61,16 +160,362 @@ func (c *Cache) Set(ctx context.Context, userID int64, limit int, recs []domain.
 	return nil
 }
 
-// Clear user cache: used when watch history changes
-func (c *Cache) ClearUserCache(ctx context.Context, userID int64) error {
-	pattern := fmt.Sprintf("rec:user:%d:limit:*", userID)
-	iter := c.client.Scan(ctx, 0, pattern, 100).Iterator()
-	for iter.Next(ctx) {
-		if err := c.client.Del(ctx, iter.Val()).Err(); err != nil {
-			return fmt.Errorf("cache delete %s: %w", iter.Val(), err)
+func (c *Cache) buildLastGeneratedKey(userID int64, variant string) string {
+	key := c.userKeyPrefix(userID) + ":last"
+	if variant != "" {
+		key += ":" + variant
+	}
+	return key
+}
+
+// Get the user's most recent generation for an option variant
+func (c *Cache) GetLastGenerated(ctx context.Context, userID int64, variant string) (*domain.Generation, bool, error) {
+	key := c.buildLastGeneratedKey(userID, variant)
+	val, err := c.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get last generation from cache: %w", err)
+	}
+
+	var gen domain.Generation
+	if err := json.Unmarshal([]byte(val), &gen); err != nil {
+		return nil, false, fmt.Errorf("cache unmarshal %s: %w", key, err)
+	}
+	return &gen, true, nil
+}
+
+// Store the user's most recent generation for an option variant, kept for ttl
+func (c *Cache) SetLastGenerated(ctx context.Context, userID int64, variant string, gen domain.Generation, ttl time.Duration) error {
+	val, err := json.Marshal(gen)
+	if err != nil {
+		return fmt.Errorf("failed to marshal last generation: %w", err)
+	}
+	if err := c.client.Set(ctx, c.buildLastGeneratedKey(userID, variant), val, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set last generation in cache: %w", err)
+	}
+	return nil
+}
+
+// Get cached catalog stats
+func (c *Cache) GetStats(ctx context.Context) (*domain.Stats, bool, error) {
+	val, err := c.client.Get(ctx, statsKey).Result()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get stats from cache: %w", err)
+	}
+
+	var stats domain.Stats
+	if err := json.Unmarshal([]byte(val), &stats); err != nil {
+		return nil, false, fmt.Errorf("cache unmarshal %s: %w", statsKey, err)
+	}
+	return &stats, true, nil
+}
+
+// Store catalog stats in cache
+func (c *Cache) SetStats(ctx context.Context, stats *domain.Stats) error {
+	val, err := json.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stats: %w", err)
+	}
+	if err := c.client.Set(ctx, statsKey, val, statsTTL).Err(); err != nil {
+		return fmt.Errorf("failed to set stats in cache: %w", err)
+	}
+	return nil
+}
+
+// Clear user cache, including the last generation: used when watch history
+// changes. Returns the number of keys deleted.
+func (c *Cache) ClearUserCache(ctx context.Context, userID int64) (int, error) {
+	return c.unlinkMatching(ctx, c.userKeyPrefix(userID)+":*")
+}
+
+// Clear every user's recommendations and last generations, under any key
+// version, along with trending lists and stats: used when the catalog is
+// replaced. Returns the number of keys deleted.
+func (c *Cache) ClearCatalogCache(ctx context.Context) (int, error) {
+	deleted := 0
+	for _, pattern := range []string{"rec:*", "trending:*", statsKey} {
+		n, err := c.unlinkMatching(ctx, pattern)
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// Delete the keys matching pattern, each SCAN page with a single
+// non-blocking UNLINK. Returns the number of keys deleted.
+func (c *Cache) unlinkMatching(ctx context.Context, pattern string) (int, error) {
+	deleted := 0
+	var cursor uint64
+	for {
+		keys, next, err := c.client.Scan(ctx, cursor, pattern, clearScanCount).Result()
+		if err != nil {
+			return deleted, fmt.Errorf("cache scan %s: %w", pattern, err)
+		}
+		if len(keys) > 0 {
+			n, err := c.client.Unlink(ctx, keys...).Result()
+			if err != nil {
+				return deleted, fmt.Errorf("cache unlink %d keys: %w", len(keys), err)
+			}
+			deleted += int(n)
+		}
+		if next == 0 {
+			return deleted, nil
+		}
+		cursor = next
+	}
+}
+
+func buildUserChangedKey(userID int64) string {
+	return fmt.Sprintf("changed:user:%d", userID)
+}
+
+// Record that a user's history or blocklist changed
+func (c *Cache) MarkUserChanged(ctx context.Context, userID int64) error {
+	return c.markChanged(ctx, buildUserChangedKey(userID))
+}
+
+// Record that content was added or removed, changing every user's candidates
+func (c *Cache) MarkCatalogChanged(ctx context.Context) error {
+	return c.markChanged(ctx, catalogChangedKey)
+}
+
+func (c *Cache) markChanged(ctx context.Context, key string) error {
+	if err := c.client.Set(ctx, key, time.Now().UnixMilli(), changeStampTTL).Err(); err != nil {
+		return fmt.Errorf("failed to set change stamp %s: %w", key, err)
+	}
+	return nil
+}
+
+// Latest change stamp among the catalog and the given users. A missing stamp
+// may have been evicted after a change, so it is stored as now and counts as
+// a change now.
+func (c *Cache) LastChanged(ctx context.Context, userIDs []int64) (time.Time, error) {
+	keys := make([]string, 0, len(userIDs)+1)
+	keys = append(keys, catalogChangedKey)
+	for _, userID := range userIDs {
+		keys = append(keys, buildUserChangedKey(userID))
+	}
+	vals, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get change stamps: %w", err)
+	}
+
+	var latest time.Time
+	var missing []string
+	for i, v := range vals {
+		val, ok := v.(string)
+		if !ok {
+			missing = append(missing, keys[i])
+			continue
+		}
+		ms, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse change stamp %s: %w", keys[i], err)
+		}
+		if stamp := time.UnixMilli(ms); stamp.After(latest) {
+			latest = stamp
+		}
+	}
+	if len(missing) == 0 {
+		return latest, nil
+	}
+
+	// Stamps have millisecond precision; match what later reads return
+	now := time.UnixMilli(time.Now().UnixMilli())
+	pipe := c.client.Pipeline()
+	for _, key := range missing {
+		pipe.SetNX(ctx, key, now.UnixMilli(), changeStampTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return time.Time{}, fmt.Errorf("failed to set %d missing change stamps: %w", len(missing), err)
+	}
+	return now, nil
+}
+
+func buildTrendingKey(window time.Duration, limit int) string {
+	return fmt.Sprintf("trending:window:%d:limit:%d", int64(window.Seconds()), limit)
+}
+
+// Get a cached trending list
+func (c *Cache) GetTrending(ctx context.Context, window time.Duration, limit int) ([]domain.TrendingContent, bool, error) {
+	key := buildTrendingKey(window, limit)
+	val, err := c.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get trending content from cache: %w", err)
+	}
+
+	var items []domain.TrendingContent
+	if err := json.Unmarshal([]byte(val), &items); err != nil {
+		return nil, false, fmt.Errorf("cache unmarshal %s: %w", key, err)
+	}
+	return items, true, nil
+}
+
+// Store a trending list in cache
+func (c *Cache) SetTrending(ctx context.Context, window time.Duration, limit int, items []domain.TrendingContent) error {
+	val, err := json.Marshal(items)
+	if err != nil {
+		return fmt.Errorf("failed to marshal trending content: %w", err)
+	}
+	if err := c.client.Set(ctx, buildTrendingKey(window, limit), val, trendingTTL).Err(); err != nil {
+		return fmt.Errorf("failed to set trending content in cache: %w", err)
+	}
+	return nil
+}
+
+func buildIdempotencyKey(userID int64, key string) string {
+	return fmt.Sprintf("idem:watch:user:%d:%s", userID, key)
+}
+
+// Atomically reserve an idempotency key for the request with fingerprint.
+// reserved is true when the key was free; otherwise the record already stored
+// under it is returned.
+func (c *Cache) ReserveIdempotency(ctx context.Context, userID int64, key, fingerprint string) (domain.IdempotencyRecord, bool, error) {
+	redisKey := buildIdempotencyKey(userID, key)
+	pending := domain.IdempotencyRecord{Fingerprint: fingerprint}
+	val, err := json.Marshal(pending)
+	if err != nil {
+		return domain.IdempotencyRecord{}, false, fmt.Errorf("failed to marshal idempotency record: %w", err)
+	}
+
+	// The holder may release the key between SETNX and GET, so try twice
+	for range 2 {
+		reserved, err := c.client.SetNX(ctx, redisKey, val, idempotencyReserveTTL).Result()
+		if err != nil {
+			return domain.IdempotencyRecord{}, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+		}
+		if reserved {
+			return pending, true, nil
+		}
+
+		raw, err := c.client.Get(ctx, redisKey).Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return domain.IdempotencyRecord{}, false, fmt.Errorf("failed to get idempotency key: %w", err)
+		}
+		var record domain.IdempotencyRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return domain.IdempotencyRecord{}, false, fmt.Errorf("failed to unmarshal idempotency record: %w", err)
+		}
+		return record, false, nil
+	}
+	return domain.IdempotencyRecord{}, false, fmt.Errorf("idempotency key %q changed while reserving it", key)
+}
+
+// Store the finished record for a reserved idempotency key
+func (c *Cache) StoreIdempotency(ctx context.Context, userID int64, key string, record domain.IdempotencyRecord) error {
+	val, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal idempotency record: %w", err)
+	}
+	if err := c.client.Set(ctx, buildIdempotencyKey(userID, key), val, c.idempotencyTTL).Err(); err != nil {
+		return fmt.Errorf("failed to set idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Free a reserved idempotency key so the request can be retried with it
+func (c *Cache) ReleaseIdempotency(ctx context.Context, userID int64, key string) error {
+	if err := c.client.Del(ctx, buildIdempotencyKey(userID, key)).Err(); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func buildBatchFailureKey(userID int64) string {
+	return fmt.Sprintf("batchfail:user:%d", userID)
+}
+
+// Count a batch failure for the user in a fixed window starting at its first
+// failure. Once the count exceeds threshold the user is added to the failed
+// users list, or its flag time refreshed; reports whether that happened.
+func (c *Cache) RecordBatchFailure(ctx context.Context, userID int64, threshold int, window time.Duration) (bool, error) {
+	key := buildBatchFailureKey(userID)
+	pipe := c.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	// Only the first failure starts the window
+	pipe.ExpireNX(ctx, key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("failed to count batch failure for user %d: %w", userID, err)
+	}
+	if incr.Val() <= int64(threshold) {
+		return false, nil
+	}
+
+	now := time.Now()
+	pipe = c.client.TxPipeline()
+	pipe.ZAdd(ctx, failedUsersKey, redis.Z{Score: float64(now.Unix()), Member: strconv.FormatInt(userID, 10)})
+	cutoff := now.Add(-failedUsersRetention).Unix()
+	pipe.ZRemRangeByScore(ctx, failedUsersKey, "-inf", "("+strconv.FormatInt(cutoff, 10))
+	pipe.ZRemRangeByRank(ctx, failedUsersKey, 0, -maxFailedUsers-1)
+	pipe.Expire(ctx, failedUsersKey, failedUsersRetention)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("failed to flag user %d as failing: %w", userID, err)
+	}
+	return true, nil
+}
+
+// Remove a user from the failed users list and reset its failure count, e.g.
+// once the cause is fixed. Reports whether the user was flagged.
+func (c *Cache) ClearFailedUser(ctx context.Context, userID int64) (bool, error) {
+	pipe := c.client.TxPipeline()
+	removed := pipe.ZRem(ctx, failedUsersKey, strconv.FormatInt(userID, 10))
+	pipe.Del(ctx, buildBatchFailureKey(userID))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("failed to clear failed user %d: %w", userID, err)
+	}
+	return removed.Val() > 0, nil
+}
+
+// Users flagged by RecordBatchFailure within the retention period, most
+// recently flagged first
+func (c *Cache) GetFailedUsers(ctx context.Context) ([]domain.FailedUser, error) {
+	cutoff := time.Now().Add(-failedUsersRetention).Unix()
+	flagged, err := c.client.ZRevRangeByScoreWithScores(ctx, failedUsersKey, &redis.ZRangeBy{
+		Min: strconv.FormatInt(cutoff, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get failed users: %w", err)
+	}
+	if len(flagged) == 0 {
+		return []domain.FailedUser{}, nil
+	}
+
+	users := make([]domain.FailedUser, len(flagged))
+	keys := make([]string, len(flagged))
+	for i, z := range flagged {
+		userID, err := strconv.ParseInt(z.Member.(string), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse failed user %q: %w", z.Member, err)
+		}
+		users[i] = domain.FailedUser{UserID: userID, FlaggedAt: time.Unix(int64(z.Score), 0).UTC()}
+		keys[i] = buildBatchFailureKey(userID)
+	}
+
+	counts, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch failure counts: %w", err)
+	}
+	for i, v := range counts {
+		// Expired windows come back nil
+		if s, ok := v.(string); ok {
+			users[i].RecentFailures, _ = strconv.Atoi(s)
 		}
 	}
-	return iter.Err()
+	return users, nil
 }
 
 // Ping connectivity
@@ -0,0 +1,601 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache backed by an in-process miniredis server
+func newTestCache(t *testing.T) (*Cache, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return NewCache(client, 10*time.Minute, time.Hour, "", 0), mr
+}
+
+func TestIdempotencyRoundTrip(t *testing.T) {
+	c, _ := newTestCache(t)
+	ctx := context.Background()
+
+	// Unknown key is reserved for the request
+	if _, reserved, err := c.ReserveIdempotency(ctx, 1, "key-1", "content_id=7"); err != nil || !reserved {
+		t.Fatalf("expected the key to be reserved, got reserved=%v err=%v", reserved, err)
+	}
+
+	// A second request sees the pending reservation
+	record, reserved, err := c.ReserveIdempotency(ctx, 1, "key-1", "content_id=7")
+	if err != nil {
+		t.Fatalf("reserve idempotency: %v", err)
+	}
+	if reserved || record.Fingerprint != "content_id=7" || record.Outcome != "" {
+		t.Errorf("expected the pending record, got reserved=%v record=%+v", reserved, record)
+	}
+
+	finished := domain.IdempotencyRecord{Fingerprint: "content_id=7", Outcome: domain.WatchDuplicate}
+	if err := c.StoreIdempotency(ctx, 1, "key-1", finished); err != nil {
+		t.Fatalf("store idempotency: %v", err)
+	}
+
+	// Replayed key returns the stored record
+	record, reserved, err = c.ReserveIdempotency(ctx, 1, "key-1", "content_id=8")
+	if err != nil {
+		t.Fatalf("reserve idempotency: %v", err)
+	}
+	if reserved || record != finished {
+		t.Errorf("expected stored record %+v, got reserved=%v record=%+v", finished, reserved, record)
+	}
+
+	// Keys are scoped per user
+	if _, reserved, _ := c.ReserveIdempotency(ctx, 2, "key-1", "content_id=7"); !reserved {
+		t.Error("expected key to be scoped to user 1")
+	}
+}
+
+func TestIdempotencyRelease(t *testing.T) {
+	c, _ := newTestCache(t)
+	ctx := context.Background()
+
+	c.ReserveIdempotency(ctx, 1, "key-1", "content_id=7")
+	if err := c.ReleaseIdempotency(ctx, 1, "key-1"); err != nil {
+		t.Fatalf("release idempotency: %v", err)
+	}
+	if _, reserved, _ := c.ReserveIdempotency(ctx, 1, "key-1", "content_id=7"); !reserved {
+		t.Error("expected a released key to be reservable again")
+	}
+}
+
+func TestIdempotencyExpires(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+
+	// An abandoned reservation frees up quickly
+	c.ReserveIdempotency(ctx, 1, "key-1", "content_id=7")
+	mr.FastForward(2 * idempotencyReserveTTL)
+	if _, reserved, _ := c.ReserveIdempotency(ctx, 1, "key-1", "content_id=7"); !reserved {
+		t.Error("expected an abandoned reservation to expire")
+	}
+
+	if err := c.StoreIdempotency(ctx, 1, "key-1", domain.IdempotencyRecord{Fingerprint: "content_id=7", Outcome: domain.WatchRecorded}); err != nil {
+		t.Fatalf("store idempotency: %v", err)
+	}
+	mr.FastForward(30 * time.Minute)
+	if _, reserved, _ := c.ReserveIdempotency(ctx, 1, "key-1", "content_id=7"); reserved {
+		t.Error("expected a finished key to outlive the reservation TTL")
+	}
+	c.ReleaseIdempotency(ctx, 1, "key-1")
+
+	c.StoreIdempotency(ctx, 1, "key-1", domain.IdempotencyRecord{Fingerprint: "content_id=7", Outcome: domain.WatchRecorded})
+	mr.FastForward(2 * time.Hour)
+	if _, reserved, _ := c.ReserveIdempotency(ctx, 1, "key-1", "content_id=7"); !reserved {
+		t.Error("expected idempotency key to expire after TTL")
+	}
+}
+
+func TestVariantKeysDoNotCollide(t *testing.T) {
+	c, _ := newTestCache(t)
+	ctx := context.Background()
+
+	raw := domain.CachedRecommendations{Recommendations: []domain.ScoredRecommendation{{ContentID: 1, Score: 0.42}}}
+	normalized := domain.CachedRecommendations{Recommendations: []domain.ScoredRecommendation{{ContentID: 1, Score: 1.0}}}
+	if err := c.Set(ctx, 1, 10, "", raw); err != nil {
+		t.Fatalf("set raw: %v", err)
+	}
+	if err := c.Set(ctx, 1, 10, "norm", normalized); err != nil {
+		t.Fatalf("set normalized: %v", err)
+	}
+
+	got, found, err := c.Get(ctx, 1, 10, "norm")
+	if err != nil || !found || got.Recommendations[0].Score != 1.0 {
+		t.Errorf("expected normalized entry, got %+v found=%v err=%v", got, found, err)
+	}
+	got, found, err = c.Get(ctx, 1, 10, "")
+	if err != nil || !found || got.Recommendations[0].Score != 0.42 {
+		t.Errorf("expected raw entry, got %+v found=%v err=%v", got, found, err)
+	}
+
+	// Clearing the user removes every variant
+	if _, err := c.ClearUserCache(ctx, 1); err != nil {
+		t.Fatalf("clear user cache: %v", err)
+	}
+	if _, found, _ := c.Get(ctx, 1, 10, "norm"); found {
+		t.Error("expected variant entry to be cleared")
+	}
+}
+
+func TestClearUserCacheReportsCount(t *testing.T) {
+	c, _ := newTestCache(t)
+	ctx := context.Background()
+
+	recs := domain.CachedRecommendations{Recommendations: []domain.ScoredRecommendation{{ContentID: 1, Score: 0.5}}}
+	for _, limit := range []int{5, 10, 20} {
+		if err := c.Set(ctx, 1, limit, "", recs); err != nil {
+			t.Fatalf("set limit %d: %v", limit, err)
+		}
+	}
+	if err := c.Set(ctx, 1, 10, "norm", recs); err != nil {
+		t.Fatalf("set variant: %v", err)
+	}
+	// Another user's entry is left alone
+	if err := c.Set(ctx, 2, 10, "", recs); err != nil {
+		t.Fatalf("set other user: %v", err)
+	}
+
+	deleted, err := c.ClearUserCache(ctx, 1)
+	if err != nil {
+		t.Fatalf("clear user cache: %v", err)
+	}
+	if deleted != 4 {
+		t.Errorf("expected 4 deleted keys, got %d", deleted)
+	}
+	if _, found, _ := c.Get(ctx, 2, 10, ""); !found {
+		t.Error("expected other user's entry to remain")
+	}
+
+	// Nothing left to delete
+	if deleted, _ := c.ClearUserCache(ctx, 1); deleted != 0 {
+		t.Errorf("expected 0 deleted keys on second clear, got %d", deleted)
+	}
+}
+
+func TestClearUserCacheBatchesDeletes(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+
+	recs := domain.CachedRecommendations{Recommendations: []domain.ScoredRecommendation{{ContentID: 1, Score: 0.5}}}
+	const keys = 25
+	for limit := 1; limit <= keys; limit++ {
+		if err := c.Set(ctx, 1, limit, "", recs); err != nil {
+			t.Fatalf("set limit %d: %v", limit, err)
+		}
+	}
+
+	before := mr.CommandCount()
+	deleted, err := c.ClearUserCache(ctx, 1)
+	if err != nil {
+		t.Fatalf("clear user cache: %v", err)
+	}
+	if deleted != keys {
+		t.Errorf("expected %d deleted keys, got %d", keys, deleted)
+	}
+
+	// One SCAN page and one UNLINK instead of a DEL per key
+	if commands := mr.CommandCount() - before; commands > 2 {
+		t.Errorf("expected at most 2 redis commands, got %d", commands)
+	}
+
+	for limit := 1; limit <= keys; limit++ {
+		if _, found, _ := c.Get(ctx, 1, limit, ""); found {
+			t.Errorf("expected limit %d to be cleared", limit)
+		}
+	}
+}
+
+func TestStatsRoundTrip(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+
+	if _, found, err := c.GetStats(ctx); err != nil || found {
+		t.Fatalf("expected miss on empty cache, got found=%v err=%v", found, err)
+	}
+
+	stats := &domain.Stats{TotalUsers: 20, GenreCounts: map[string]int{"action": 10}}
+	if err := c.SetStats(ctx, stats); err != nil {
+		t.Fatalf("set stats: %v", err)
+	}
+	got, found, err := c.GetStats(ctx)
+	if err != nil || !found || got.TotalUsers != 20 || got.GenreCounts["action"] != 10 {
+		t.Errorf("expected cached stats, got %+v found=%v err=%v", got, found, err)
+	}
+	if ttl := mr.TTL(statsKey); ttl != statsTTL {
+		t.Errorf("expected TTL %s, got %s", statsTTL, ttl)
+	}
+}
+
+func TestRecommendationsKeepModelVersion(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+
+	entry := domain.CachedRecommendations{
+		ModelVersion:    "0.9.0",
+		Recommendations: []domain.ScoredRecommendation{{ContentID: 1, Score: 0.5}},
+	}
+	if err := c.Set(ctx, 1, 10, "", entry); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	got, found, err := c.Get(ctx, 1, 10, "")
+	if err != nil || !found {
+		t.Fatalf("expected cached entry, found=%v err=%v", found, err)
+	}
+	if got.ModelVersion != "0.9.0" || len(got.Recommendations) != 1 {
+		t.Errorf("expected version 0.9.0 with 1 recommendation, got %+v", got)
+	}
+
+	// Bare lists from before versioning are a miss, not an error
+	mr.Set(c.buildKey(2, 10, ""), `[{"content_id":1,"score":0.5}]`)
+	if _, found, err := c.Get(ctx, 2, 10, ""); found || err != nil {
+		t.Errorf("expected legacy entry to be a miss, found=%v err=%v", found, err)
+	}
+}
+
+func TestLastGeneratedRoundTrip(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+
+	if _, found, err := c.GetLastGenerated(ctx, 1, ""); found || err != nil {
+		t.Fatalf("expected miss on empty cache, found=%v err=%v", found, err)
+	}
+
+	gen := domain.Generation{
+		GeneratedAt:     time.Now().UTC().Truncate(time.Second),
+		ModelVersion:    "1.0.0",
+		Recommendations: []domain.ScoredRecommendation{{ContentID: 3, Score: 0.7}},
+	}
+	if err := c.SetLastGenerated(ctx, 1, "norm", gen, 5*time.Minute); err != nil {
+		t.Fatalf("set last generated: %v", err)
+	}
+	got, found, err := c.GetLastGenerated(ctx, 1, "norm")
+	if err != nil || !found {
+		t.Fatalf("expected stored generation, found=%v err=%v", found, err)
+	}
+	if !got.GeneratedAt.Equal(gen.GeneratedAt) || got.ModelVersion != "1.0.0" || got.Recommendations[0].ContentID != 3 {
+		t.Errorf("unexpected generation %+v", got)
+	}
+	if ttl := mr.TTL(c.buildLastGeneratedKey(1, "norm")); ttl != 5*time.Minute {
+		t.Errorf("expected 5m TTL, got %s", ttl)
+	}
+	// Variants are kept apart
+	if _, found, _ := c.GetLastGenerated(ctx, 1, ""); found {
+		t.Error("expected default variant to be a miss")
+	}
+
+	// Clearing the user removes the generation with the cached lists
+	if _, err := c.ClearUserCache(ctx, 1); err != nil {
+		t.Fatalf("clear user cache: %v", err)
+	}
+	if _, found, _ := c.GetLastGenerated(ctx, 1, "norm"); found {
+		t.Error("expected generation to be cleared")
+	}
+}
+
+func TestKeyVersionsDoNotCollide(t *testing.T) {
+	c, mr := newTestCache(t)
+	v2 := NewCache(c.client, 10*time.Minute, time.Hour, "2", 0)
+	ctx := context.Background()
+
+	if key := v2.buildKey(1, 10, "norm"); key != "rec:v2:user:1:limit:10:norm" {
+		t.Errorf("unexpected versioned key %s", key)
+	}
+	if key := v2.buildLastGeneratedKey(1, ""); key != "rec:v2:user:1:last" {
+		t.Errorf("unexpected versioned last generation key %s", key)
+	}
+	if c.buildKey(1, 10, "") == v2.buildKey(1, 10, "") {
+		t.Error("expected versions to produce different keys")
+	}
+
+	// An entry written under one version is invisible to another
+	recs := domain.CachedRecommendations{Recommendations: []domain.ScoredRecommendation{{ContentID: 1, Score: 0.5}}}
+	if err := c.Set(ctx, 1, 10, "", recs); err != nil {
+		t.Fatalf("set unversioned: %v", err)
+	}
+	if _, found, _ := v2.Get(ctx, 1, 10, ""); found {
+		t.Error("expected the v2 cache to miss an unversioned entry")
+	}
+	if err := v2.Set(ctx, 1, 10, "", recs); err != nil {
+		t.Fatalf("set v2: %v", err)
+	}
+	if len(mr.Keys()) != 2 {
+		t.Errorf("expected separate keys per version, got %v", mr.Keys())
+	}
+}
+
+func TestClearUserCacheMatchesKeyVersion(t *testing.T) {
+	c, mr := newTestCache(t)
+	v2 := NewCache(c.client, 10*time.Minute, time.Hour, "2", 0)
+	ctx := context.Background()
+
+	recs := domain.CachedRecommendations{Recommendations: []domain.ScoredRecommendation{{ContentID: 1, Score: 0.5}}}
+	for _, limit := range []int{5, 10} {
+		if err := v2.Set(ctx, 1, limit, "", recs); err != nil {
+			t.Fatalf("set v2 limit %d: %v", limit, err)
+		}
+	}
+	if err := v2.SetLastGenerated(ctx, 1, "", domain.Generation{}, time.Minute); err != nil {
+		t.Fatalf("set v2 last generation: %v", err)
+	}
+	// Older versions and other users are left to expire on their own
+	if err := c.Set(ctx, 1, 10, "", recs); err != nil {
+		t.Fatalf("set unversioned: %v", err)
+	}
+	if err := v2.Set(ctx, 12, 10, "", recs); err != nil {
+		t.Fatalf("set other user: %v", err)
+	}
+
+	deleted, err := v2.ClearUserCache(ctx, 1)
+	if err != nil {
+		t.Fatalf("clear user cache: %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("expected 3 deleted keys, got %d", deleted)
+	}
+	if !mr.Exists(c.buildKey(1, 10, "")) || !mr.Exists(v2.buildKey(12, 10, "")) {
+		t.Errorf("expected unversioned and other-user keys to remain, got %v", mr.Keys())
+	}
+}
+
+func TestClearCatalogCache(t *testing.T) {
+	c, mr := newTestCache(t)
+	v2 := NewCache(c.client, 10*time.Minute, time.Hour, "2", 0)
+	ctx := context.Background()
+
+	recs := domain.CachedRecommendations{Recommendations: []domain.ScoredRecommendation{{ContentID: 1, Score: 0.5}}}
+	if err := c.Set(ctx, 1, 10, "", recs); err != nil {
+		t.Fatalf("set unversioned: %v", err)
+	}
+	if err := v2.Set(ctx, 2, 10, "", recs); err != nil {
+		t.Fatalf("set v2: %v", err)
+	}
+	if err := v2.SetLastGenerated(ctx, 2, "", domain.Generation{}, time.Minute); err != nil {
+		t.Fatalf("set v2 last generation: %v", err)
+	}
+	if err := c.SetTrending(ctx, time.Hour, 10, []domain.TrendingContent{{RecentWatches: 3}}); err != nil {
+		t.Fatalf("set trending: %v", err)
+	}
+	if err := c.SetStats(ctx, &domain.Stats{}); err != nil {
+		t.Fatalf("set stats: %v", err)
+	}
+	// Idempotency keys aren't catalog data
+	if _, _, err := c.ReserveIdempotency(ctx, 1, "key-1", "content_id=7"); err != nil {
+		t.Fatalf("reserve idempotency: %v", err)
+	}
+
+	deleted, err := c.ClearCatalogCache(ctx)
+	if err != nil {
+		t.Fatalf("clear catalog cache: %v", err)
+	}
+	if deleted != 5 {
+		t.Errorf("expected 5 deleted keys, got %d", deleted)
+	}
+	if keys := mr.Keys(); len(keys) != 1 || keys[0] != buildIdempotencyKey(1, "key-1") {
+		t.Errorf("expected only the idempotency key to remain, got %v", keys)
+	}
+}
+
+func TestLastChanged(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+
+	// Unknown stamps count as changed now, and are kept so later reads agree
+	before := time.Now().Truncate(time.Millisecond)
+	first, err := c.LastChanged(ctx, []int64{1, 2})
+	if err != nil {
+		t.Fatalf("last changed: %v", err)
+	}
+	if first.Before(before) {
+		t.Errorf("expected missing stamps to read as now, got %s", first)
+	}
+	if again, err := c.LastChanged(ctx, []int64{1, 2}); err != nil || !again.Equal(first) {
+		t.Errorf("expected %s again, got %s err=%v", first, again, err)
+	}
+
+	// A change to one user, or to the catalog, moves the page's stamp
+	time.Sleep(2 * time.Millisecond)
+	if err := c.MarkUserChanged(ctx, 2); err != nil {
+		t.Fatalf("mark user changed: %v", err)
+	}
+	userChanged, err := c.LastChanged(ctx, []int64{1, 2})
+	if err != nil || !userChanged.After(first) {
+		t.Errorf("expected a user change to advance %s, got %s err=%v", first, userChanged, err)
+	}
+	if other, _ := c.LastChanged(ctx, []int64{1}); !other.Equal(first) {
+		t.Errorf("expected other users' stamps unchanged at %s, got %s", first, other)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	if err := c.MarkCatalogChanged(ctx); err != nil {
+		t.Fatalf("mark catalog changed: %v", err)
+	}
+	if catalog, _ := c.LastChanged(ctx, []int64{1}); !catalog.After(userChanged) {
+		t.Errorf("expected a catalog change to advance every page past %s, got %s", userChanged, catalog)
+	}
+
+	// An expired stamp is treated as a fresh change rather than forgotten
+	mr.FastForward(changeStampTTL + time.Minute)
+	expired, err := c.LastChanged(ctx, []int64{1})
+	if err != nil || expired.Before(before) {
+		t.Errorf("expected an expired stamp to read as now, got %s err=%v", expired, err)
+	}
+}
+
+func TestSupersetReuseTruncatesLargerEntry(t *testing.T) {
+	c, _ := newTestCache(t)
+	reuse := NewCache(c.client, 10*time.Minute, time.Hour, "", 50)
+	ctx := context.Background()
+
+	entry := domain.CachedRecommendations{ModelVersion: "1.0.0"}
+	for id := int64(10); id >= 1; id-- {
+		entry.Recommendations = append(entry.Recommendations, domain.ScoredRecommendation{ContentID: id, Score: float64(id) / 10})
+	}
+	if err := c.Set(ctx, 1, 10, "", entry); err != nil {
+		t.Fatalf("set limit 10: %v", err)
+	}
+	// A larger entry is only used when no closer one exists
+	if err := c.Set(ctx, 1, 20, "", domain.CachedRecommendations{Recommendations: make([]domain.ScoredRecommendation, 20)}); err != nil {
+		t.Fatalf("set limit 20: %v", err)
+	}
+
+	got, found, err := reuse.Get(ctx, 1, 3, "")
+	if err != nil || !found {
+		t.Fatalf("expected a superset hit, found=%v err=%v", found, err)
+	}
+	if len(got.Recommendations) != 3 || got.ModelVersion != "1.0.0" {
+		t.Fatalf("expected the limit 10 entry truncated to 3, got %+v", got)
+	}
+	for i, rec := range got.Recommendations {
+		if rec.ContentID != int64(10-i) {
+			t.Errorf("position %d: expected content %d, got %d", i, 10-i, rec.ContentID)
+		}
+	}
+
+	// Other variants and smaller entries don't count
+	if _, found, _ := reuse.Get(ctx, 1, 3, "norm"); found {
+		t.Error("expected another variant to miss")
+	}
+	if err := c.Set(ctx, 2, 2, "", entry); err != nil {
+		t.Fatalf("set smaller entry: %v", err)
+	}
+	if _, found, _ := reuse.Get(ctx, 2, 5, ""); found {
+		t.Error("expected a smaller entry not to serve a larger limit")
+	}
+	// Disabled by default
+	if _, found, _ := c.Get(ctx, 1, 3, ""); found {
+		t.Error("expected no superset reuse when disabled")
+	}
+}
+
+func TestRecordBatchFailureFlagsPastThreshold(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		flagged, err := c.RecordBatchFailure(ctx, 7, 3, time.Hour)
+		if err != nil {
+			t.Fatalf("record failure %d: %v", i, err)
+		}
+		if flagged {
+			t.Fatalf("failure %d: expected no flag up to the threshold", i)
+		}
+	}
+	users, err := c.GetFailedUsers(ctx)
+	if err != nil || len(users) != 0 {
+		t.Fatalf("expected no failed users yet, got %+v, %v", users, err)
+	}
+
+	flagged, err := c.RecordBatchFailure(ctx, 7, 3, time.Hour)
+	if err != nil || !flagged {
+		t.Fatalf("expected the fourth failure to flag the user, got %v, %v", flagged, err)
+	}
+	users, err = c.GetFailedUsers(ctx)
+	if err != nil {
+		t.Fatalf("get failed users: %v", err)
+	}
+	if len(users) != 1 || users[0].UserID != 7 || users[0].RecentFailures != 4 || users[0].FlaggedAt.IsZero() {
+		t.Errorf("expected user 7 flagged with 4 recent failures, got %+v", users)
+	}
+
+	// The window restarts once it expires; the flag stays
+	mr.FastForward(2 * time.Hour)
+	if flagged, _ := c.RecordBatchFailure(ctx, 7, 3, time.Hour); flagged {
+		t.Error("expected the count to restart after the window")
+	}
+	users, _ = c.GetFailedUsers(ctx)
+	if len(users) != 1 || users[0].RecentFailures != 1 {
+		t.Errorf("expected user 7 still flagged with 1 recent failure, got %+v", users)
+	}
+}
+
+func TestRecordBatchFailureSetsWindowOnce(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+
+	if _, err := c.RecordBatchFailure(ctx, 7, 3, time.Hour); err != nil {
+		t.Fatalf("record failure: %v", err)
+	}
+	key := buildBatchFailureKey(7)
+	if ttl := mr.TTL(key); ttl != time.Hour {
+		t.Fatalf("expected the window set with the first failure, got ttl %v", ttl)
+	}
+	// Later failures don't push the window back
+	mr.FastForward(10 * time.Minute)
+	if _, err := c.RecordBatchFailure(ctx, 7, 3, time.Hour); err != nil {
+		t.Fatalf("record failure: %v", err)
+	}
+	if ttl := mr.TTL(key); ttl != 50*time.Minute {
+		t.Errorf("expected the window kept from the first failure, got ttl %v", ttl)
+	}
+}
+
+func TestFailedUsersBounded(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+
+	// Flagged longer ago than the retention
+	stale := float64(time.Now().Add(-failedUsersRetention - time.Hour).Unix())
+	mr.ZAdd(failedUsersKey, stale, "99")
+	for i := 0; i < maxFailedUsers+1; i++ {
+		mr.ZAdd(failedUsersKey, float64(time.Now().Unix()-1), strconv.Itoa(1000+i))
+	}
+
+	if flagged, err := c.RecordBatchFailure(ctx, 7, 0, time.Hour); err != nil || !flagged {
+		t.Fatalf("expected user 7 flagged, got %v, %v", flagged, err)
+	}
+	members, err := mr.ZMembers(failedUsersKey)
+	if err != nil {
+		t.Fatalf("failed users: %v", err)
+	}
+	if len(members) != maxFailedUsers {
+		t.Errorf("expected the list capped at %d, got %d", maxFailedUsers, len(members))
+	}
+	if slices.Contains(members, "99") {
+		t.Error("expected the stale user dropped")
+	}
+	if !slices.Contains(members, "7") {
+		t.Error("expected the newly flagged user kept")
+	}
+	if ttl := mr.TTL(failedUsersKey); ttl != failedUsersRetention {
+		t.Errorf("expected the list to expire after %v, got %v", failedUsersRetention, ttl)
+	}
+}
+
+func TestClearFailedUser(t *testing.T) {
+	c, _ := newTestCache(t)
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := c.RecordBatchFailure(ctx, 7, 1, time.Hour); err != nil {
+			t.Fatalf("record failure: %v", err)
+		}
+	}
+	if cleared, err := c.ClearFailedUser(ctx, 7); err != nil || !cleared {
+		t.Fatalf("expected user 7 cleared, got %v, %v", cleared, err)
+	}
+	if users, err := c.GetFailedUsers(ctx); err != nil || len(users) != 0 {
+		t.Errorf("expected no failed users, got %+v, %v", users, err)
+	}
+	// The count restarts too
+	if flagged, _ := c.RecordBatchFailure(ctx, 7, 1, time.Hour); flagged {
+		t.Error("expected the failure count reset")
+	}
+	if cleared, err := c.ClearFailedUser(ctx, 7); err != nil || cleared {
+		t.Errorf("expected nothing to clear, got %v, %v", cleared, err)
+	}
+}
@@ -1,11 +1,124 @@
 package domain
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 type Content struct {
-	ID              int64     `json:"id"`
-	Title           string    `json:"title"`
-	Genre           string    `json:"genre"`
-	PopularityScore float64   `json:"popularity_score"`
-	CreatedAt       time.Time `json:"created_at"`
-}
\ No newline at end of file
+	ID              int64      `json:"id"`
+	Title           string     `json:"title"`
+	Genre           string     `json:"genre"`
+	PopularityScore float64    `json:"popularity_score"`
+	CreatedAt       time.Time  `json:"created_at"`
+	AvailableFrom   *time.Time `json:"available_from,omitempty"`
+	AvailableUntil  *time.Time `json:"available_until,omitempty"`
+	// Zero when unknown
+	DurationMinutes int `json:"duration_minutes,omitempty"`
+	ReleaseYear     int `json:"release_year,omitempty"`
+	// One of ContentRatings
+	ContentRating string `json:"content_rating,omitempty"`
+}
+
+// Content item with its number of watches within a trending window
+type TrendingContent struct {
+	Content
+	RecentWatches int `json:"recent_watches"`
+}
+
+// Canonical genres content can be catalogued under, shared by seeds, scoring
+// and request validation
+var Genres = []string{"action", "drama", "comedy", "thriller", "sci-fi"}
+
+// Reports whether genre is in the canonical list; matching is case-sensitive
+func IsValidGenre(genre string) bool {
+	for _, g := range Genres {
+		if g == genre {
+			return true
+		}
+	}
+	return false
+}
+
+// Content ratings, least restrictive first
+var ContentRatings = []string{"G", "PG", "PG-13", "R"}
+
+// Rating given to content added without one, so it is never shown to minors
+const DefaultContentRating = "R"
+
+// Popularity below which content is not recommended by default. Excludes the
+// seeded catalog's 0.01 long tail.
+const DefaultMinPopularity = 0.02
+
+func IsValidContentRating(rating string) bool {
+	for _, r := range ContentRatings {
+		if r == rating {
+			return true
+		}
+	}
+	return false
+}
+
+// Minimum user age for each content rating; unlisted ratings suit every age
+type RatingPolicy map[string]int
+
+// R-rated content is for adults only by default
+func DefaultRatingPolicy() RatingPolicy {
+	return RatingPolicy{"R": 18}
+}
+
+// Parse a JSON object such as {"PG-13": 13, "R": 18}. Ratings must be known
+// and ages positive.
+func ParseRatingPolicy(data string) (RatingPolicy, error) {
+	var p RatingPolicy
+	if err := json.Unmarshal([]byte(data), &p); err != nil {
+		return nil, fmt.Errorf("parse rating policy: %w", err)
+	}
+	for rating, age := range p {
+		if !IsValidContentRating(rating) {
+			return nil, fmt.Errorf("unknown content rating %q", rating)
+		}
+		if age <= 0 {
+			return nil, fmt.Errorf("minimum age for %s must be positive, got %d", rating, age)
+		}
+	}
+	return p, nil
+}
+
+// Ratings a user of the given age may be recommended, in ContentRatings order
+func (p RatingPolicy) AllowedRatings(age int) []string {
+	allowed := make([]string, 0, len(ContentRatings))
+	for _, rating := range ContentRatings {
+		if age >= p[rating] {
+			allowed = append(allowed, rating)
+		}
+	}
+	return allowed
+}
+
+// Sort order for catalog listings
+type ContentSort string
+
+const (
+	ContentSortPopularity ContentSort = "popularity"
+	ContentSortCreatedAt  ContentSort = "created_at"
+)
+
+func (s ContentSort) Valid() bool {
+	switch s {
+	case ContentSortPopularity, ContentSortCreatedAt:
+		return true
+	}
+	return false
+}
+
+// Catalog listing filter; zero values match everything and an empty sort
+// orders by popularity
+type ContentFilter struct {
+	Genre         string
+	MinPopularity float64
+	Sort          ContentSort
+	Page          int
+	Limit         int
+}
@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsValidGenre(t *testing.T) {
+	for _, genre := range Genres {
+		if !IsValidGenre(genre) {
+			t.Errorf("expected canonical genre %q to be valid", genre)
+		}
+	}
+	for _, genre := range []string{"", "western", "Action", " drama", "sci fi"} {
+		if IsValidGenre(genre) {
+			t.Errorf("expected %q to be invalid", genre)
+		}
+	}
+}
+
+func TestRatingPolicyAllowedRatings(t *testing.T) {
+	policy := DefaultRatingPolicy()
+	if got := policy.AllowedRatings(15); !reflect.DeepEqual(got, []string{"G", "PG", "PG-13"}) {
+		t.Errorf("expected a minor to be kept from R content, got %v", got)
+	}
+	if got := policy.AllowedRatings(18); !reflect.DeepEqual(got, ContentRatings) {
+		t.Errorf("expected an adult to be allowed every rating, got %v", got)
+	}
+
+	strict := RatingPolicy{"PG-13": 13, "R": 18}
+	if got := strict.AllowedRatings(12); !reflect.DeepEqual(got, []string{"G", "PG"}) {
+		t.Errorf("expected PG-13 to need age 13, got %v", got)
+	}
+}
+
+func TestParseRatingPolicy(t *testing.T) {
+	policy, err := ParseRatingPolicy(`{"PG-13": 13, "R": 17}`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !reflect.DeepEqual(policy, RatingPolicy{"PG-13": 13, "R": 17}) {
+		t.Errorf("unexpected policy %v", policy)
+	}
+
+	for _, data := range []string{`{"NC-17": 17}`, `{"R": 0}`, `{"R": "18"}`, `[]`} {
+		if _, err := ParseRatingPolicy(data); err == nil {
+			t.Errorf("expected %s to be rejected", data)
+		}
+	}
+}
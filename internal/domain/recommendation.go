@@ -1,35 +1,280 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type BatchStatus string
 
 const (
 	StatusSuccess BatchStatus = "success"
 	StatusFailed  BatchStatus = "failed"
+	// Not processed because the request was cancelled first
+	StatusCancelled BatchStatus = "cancelled"
 )
 
 var ErrUserNotFound     = errors.New("user not found")
+var ErrContentNotFound  = errors.New("content not found")
+var ErrInvalidGenre     = errors.New("invalid genre")
+var ErrAlreadyWatched   = errors.New("content already in watch history")
+var ErrWatchNotFound    = errors.New("watch history record not found")
+var ErrBlockNotFound    = errors.New("blocklist entry not found")
 var ErrModelUnavailable = errors.New("recommendation model unavailable")
+var ErrDatabaseUnavailable = errors.New("database unavailable")
+var ErrInvalidPage = errors.New("invalid page")
+var ErrFailedUserNotFound = errors.New("user is not flagged as failing")
 // var ErrRequestTimeout   = errors.New("request timed out")
 
+// Failed repository operation. When Unavailable is set the database couldn't
+// be reached, and errors.Is reports it as ErrDatabaseUnavailable.
+type RepositoryError struct {
+	Op          string
+	Err         error
+	Unavailable bool
+}
+
+func (e *RepositoryError) Error() string {
+	return e.Op + ": " + e.Err.Error()
+}
+
+func (e *RepositoryError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RepositoryError) Is(target error) bool {
+	return target == ErrDatabaseUnavailable && e.Unavailable
+}
+
+// Scoring algorithm used to rank candidates
+type Strategy string
+
+const (
+	StrategyPopularityOnly Strategy = "popularity_only"
+	StrategyGenreWeighted  Strategy = "genre_weighted"
+	StrategyRecencyFirst   Strategy = "recency_first"
+)
+
+const DefaultStrategy = StrategyGenreWeighted
+
+func (s Strategy) Valid() bool {
+	switch s {
+	case StrategyPopularityOnly, StrategyGenreWeighted, StrategyRecencyFirst:
+		return true
+	}
+	return false
+}
+
+// Order in which candidates are fetched before scoring; it decides which part
+// of the catalog fills the candidate pool
+type CandidateOrder string
+
+const (
+	CandidateOrderPopularity CandidateOrder = "popularity"
+	CandidateOrderRecency    CandidateOrder = "recency"
+	CandidateOrderRandom     CandidateOrder = "random"
+)
+
+const DefaultCandidateOrder = CandidateOrderPopularity
+
+func (o CandidateOrder) Valid() bool {
+	switch o {
+	case CandidateOrderPopularity, CandidateOrderRecency, CandidateOrderRandom:
+		return true
+	}
+	return false
+}
+
+// Per-request options for generating a user's recommendations
+type RecommendationOptions struct {
+	Limit int
+	// Min-max normalize scores to 0-1 across the candidate set
+	Normalize bool
+	// Scoring strategy; empty means DefaultStrategy
+	Strategy Strategy
+	// Score the full catalog, including content the user already watched
+	IncludeWatched bool
+	// Multipliers on the genre component for this request, keyed by genre
+	GenreBoosts map[string]float64
+	// Candidate fetch order; empty means DefaultCandidateOrder
+	CandidateOrder CandidateOrder
+	// Annotate each scored recommendation with why it was picked
+	WithReasons bool
+	// Consider content below the configured popularity floor too
+	IgnorePopularityFloor bool
+	// Most scored items of any one genre in the list; zero means no cap
+	MaxPerGenre int
+	// genre_weighted component weights for this request; nil means the
+	// model's defaults. Only honoured for premium users
+	Weights *ScoreWeights
+	// Serve Limit items from Offset of the user's full scored candidate list,
+	// which is cached so every page comes from the same scoring run
+	Paged  bool
+	Offset int
+}
+
+// Variant identifies the options, other than limit, that change the generated
+// list. It is part of the cache key; empty for default options.
+func (o RecommendationOptions) Variant() string {
+	var parts []string
+	if o.Strategy != "" && o.Strategy != DefaultStrategy {
+		parts = append(parts, string(o.Strategy))
+	}
+	if o.Normalize {
+		parts = append(parts, "norm")
+	}
+	if o.IncludeWatched {
+		parts = append(parts, "watched")
+	}
+	if o.CandidateOrder != "" && o.CandidateOrder != DefaultCandidateOrder {
+		parts = append(parts, "order."+string(o.CandidateOrder))
+	}
+	if o.WithReasons {
+		parts = append(parts, "reasons")
+	}
+	if o.IgnorePopularityFloor {
+		parts = append(parts, "nofloor")
+	}
+	if o.MaxPerGenre > 0 {
+		parts = append(parts, "genrecap="+strconv.Itoa(o.MaxPerGenre))
+	}
+	if w := o.Weights; w != nil {
+		parts = append(parts, "weights="+strconv.FormatFloat(w.Popularity, 'g', -1, 64)+
+			","+strconv.FormatFloat(w.Genre, 'g', -1, 64)+","+strconv.FormatFloat(w.Recency, 'g', -1, 64))
+	}
+	// Sorted so the same boosts always map to the same key
+	genres := make([]string, 0, len(o.GenreBoosts))
+	for genre := range o.GenreBoosts {
+		genres = append(genres, genre)
+	}
+	sort.Strings(genres)
+	for _, genre := range genres {
+		parts = append(parts, "boost."+genre+"="+strconv.FormatFloat(o.GenreBoosts[genre], 'g', -1, 64))
+	}
+	return strings.Join(parts, ":")
+}
+
+// Weights of the popularity, genre preference and recency components of a
+// genre_weighted score
+type ScoreWeights struct {
+	Popularity float64
+	Genre      float64
+	Recency    float64
+}
+
+// How far weight overrides may sum from 1
+const ScoreWeightsTolerance = 0.01
+
+// Whether every weight is in [0,1] and they sum to 1, within ScoreWeightsTolerance
+func (w ScoreWeights) Valid() bool {
+	for _, v := range []float64{w.Popularity, w.Genre, w.Recency} {
+		// Negated so NaN is rejected too
+		if !(v >= 0 && v <= 1) {
+			return false
+		}
+	}
+	return math.Abs(w.Popularity+w.Genre+w.Recency-1) <= ScoreWeightsTolerance
+}
+
 type ScoredRecommendation struct {
 	ContentID       int64   `json:"content_id"`
 	Title           string  `json:"title"`
 	Genre           string  `json:"genre"`
 	PopularityScore float64 `json:"popularity_score"`
+	DurationMinutes int     `json:"duration_minutes,omitempty"`
+	ReleaseYear     int     `json:"release_year,omitempty"`
 	Score           float64 `json:"score"`
+	Watched         bool    `json:"watched,omitempty"`
+	// Editorially pinned ahead of the scored results; not model scored
+	Featured bool `json:"featured,omitempty"`
+	// Why it was recommended, strongest factor first; only when requested
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Individual score components for a single user/content pair
+type ScoreBreakdown struct {
+	PopularityComponent float64 `json:"popularity_component"`
+	GenreBoost          float64 `json:"genre_boost"`
+	RecencyComponent    float64 `json:"recency_component"`
+	Noise               float64 `json:"noise"`
+	// Contributions of custom score components, keyed by component name
+	Extra map[string]float64 `json:"extra,omitempty"`
+	Final float64            `json:"final"`
 }
 
 type RecommendationMeta struct {
 	CacheHit    bool   `json:"cache_hit"`
 	GeneratedAt string `json:"generated_at"`
 	TotalCount  int    `json:"total_count"`
+	Strategy    string `json:"strategy"`
+	// Set when the strategy was assigned by experiment bucket
+	ExperimentBucket *int `json:"experiment_bucket,omitempty"`
+	// Watch history was unavailable, so results are popularity only
+	Degraded bool `json:"degraded,omitempty"`
+	// Scoring ran out of time, so results rank only the candidates scored
+	Truncated bool `json:"truncated,omitempty"`
+	// Scoring algorithm version that produced the recommendations
+	ModelVersion string `json:"model_version"`
+	// The user exists but has no content left to recommend
+	ExhaustedCatalog bool `json:"exhausted_catalog,omitempty"`
 }
 
 type RecommendationResult struct {
 	Recommendations []ScoredRecommendation
 	CacheHit        bool
+	Strategy        Strategy
+	// Set when the strategy was assigned by experiment bucket
+	ExperimentBucket *int
+	// Scored without watch history after it failed to load
+	Degraded bool
+	// Scoring hit its deadline before every candidate was scored
+	Truncated bool
+	ModelVersion string
+	// No candidates were left to score, e.g. the user watched everything
+	ExhaustedCatalog bool
+	// Length of the full scored list a paged request was sliced from; zero
+	// for other requests
+	Total int
+}
+
+// A genre's recommendations with the user's preference weight for it
+type GenreRecommendations struct {
+	Genre string `json:"genre"`
+	// Share of the user's watch history in the genre; 0 when unwatched
+	Weight          float64                `json:"weight"`
+	Recommendations []ScoredRecommendation `json:"recommendations"`
+}
+
+// Recommendations grouped by genre, most preferred genre first
+type GenreRecommendationResult struct {
+	Genres   []GenreRecommendations
+	Strategy Strategy
+	// Set when the strategy was assigned by experiment bucket
+	ExperimentBucket *int
+	Degraded         bool
+	Truncated        bool
+	ModelVersion     string
+	ExhaustedCatalog bool
+}
+
+// Cached recommendation list, stored with the scoring version that generated it
+type CachedRecommendations struct {
+	ModelVersion     string                 `json:"model_version"`
+	Recommendations  []ScoredRecommendation `json:"recommendations"`
+	ExhaustedCatalog bool                   `json:"exhausted_catalog,omitempty"`
+}
+
+// A user's most recent full-length generation for one set of options, reused
+// for any limit until the minimum regenerate interval has passed
+type Generation struct {
+	GeneratedAt      time.Time              `json:"generated_at"`
+	ModelVersion     string                 `json:"model_version"`
+	Recommendations  []ScoredRecommendation `json:"recommendations"`
+	ExhaustedCatalog bool                   `json:"exhausted_catalog,omitempty"`
 }
 
 type BatchUserResult struct {
@@ -38,23 +283,57 @@ type BatchUserResult struct {
 	Status          BatchStatus            `json:"status"`
 	Error           string                 `json:"error,omitempty"`
 	Message         string                 `json:"message,omitempty"`
+	// Genres weighing most in the user's preferences, heaviest first; only
+	// when explanations are requested
+	TopGenres []GenreWeight `json:"top_genres,omitempty"`
+}
+
+// A user who kept failing batch processing, flagged for investigation
+type FailedUser struct {
+	UserID int64 `json:"user_id"`
+	// When the user last went over the failure threshold
+	FlaggedAt time.Time `json:"flagged_at"`
+	// Failures in the current window; zero once it has expired
+	RecentFailures int `json:"recent_failures"`
+}
+
+// A genre's share of a user's watch history
+type GenreWeight struct {
+	Genre  string  `json:"genre"`
+	Weight float64 `json:"weight"`
 }
 
 type BatchSummary struct {
 	SuccessCount     int   `json:"success_count"`
 	FailedCount      int   `json:"failed_count"`
+	CancelledCount   int   `json:"cancelled_count,omitempty"`
 	ProcessingTimeMs int64 `json:"processing_time_ms"`
 }
 
+// Users of a batch page done so far, reported as workers finish
+type BatchProgress struct {
+	Processed int `json:"processed"`
+	Total     int `json:"total"`
+}
+
 type BatchMeta struct {
 	GeneratedAt string `json:"generated_at"`
 }
 
+// Results for POST /recommendations/bulk, one per requested user ID
+type BulkRecommendationResponse struct {
+	Limit    int               `json:"limit"`
+	Results  []BatchUserResult `json:"results"`
+	Summary  BatchSummary      `json:"summary"`
+	Metadata BatchMeta         `json:"metadata"`
+}
+
 type BatchResponse struct {
-	Page       int               `json:"page"`
-	Limit      int               `json:"limit"`
-	TotalUsers int               `json:"total_users"`
-	Results    []BatchUserResult `json:"results"`
-	Summary    BatchSummary      `json:"summary"`
-	Metadata   BatchMeta         `json:"metadata"`
+	Page         int               `json:"page"`
+	Limit        int               `json:"limit"`
+	PerUserLimit int               `json:"per_user_limit"`
+	TotalUsers   int               `json:"total_users"`
+	Results      []BatchUserResult `json:"results"`
+	Summary      BatchSummary      `json:"summary"`
+	Metadata     BatchMeta         `json:"metadata"`
 }
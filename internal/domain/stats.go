@@ -0,0 +1,10 @@
+package domain
+
+// Catalog and user overview for dashboards
+type Stats struct {
+	TotalUsers         int            `json:"total_users"`
+	TotalContent       int            `json:"total_content"`
+	TotalWatchEvents   int            `json:"total_watch_events"`
+	GenreCounts        map[string]int `json:"genre_counts"`
+	SubscriptionCounts map[string]int `json:"subscription_counts"`
+}
@@ -8,4 +8,25 @@ type User struct {
 	Country          string    `json:"country"`
 	SubscriptionType string    `json:"subscription_type"`
 	CreatedAt        time.Time `json:"created_at"`
-}
\ No newline at end of file
+}
+
+// Subscription tiers a user can be on
+var SubscriptionTypes = []string{"free", "basic", "premium"}
+
+func IsKnownSubscriptionType(subscription string) bool {
+	for _, s := range SubscriptionTypes {
+		if s == subscription {
+			return true
+		}
+	}
+	return false
+}
+
+// Criteria for selecting a segment of users; empty fields don't filter
+type UserFilter struct {
+	Country          string
+	SubscriptionType string
+	MinAge           int
+	Page             int
+	Limit            int
+}
@@ -1,9 +1,47 @@
 package domain
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 type WatchHistoryItem struct {
 	ContentID int64     `json:"content_id"`
 	Genre     string    `json:"genre"`
 	WatchedAt time.Time `json:"watched_at"`
-}
\ No newline at end of file
+	// Share of the content watched, in (0,1]; zero when unknown, which
+	// counts as fully watched
+	CompletionRatio float64 `json:"completion_ratio,omitempty"`
+}
+
+// Genre make-up of a returned watch history
+type WatchHistoryBreakdown struct {
+	TotalCount  int            `json:"total_count"`
+	GenreCounts map[string]int `json:"genre_counts"`
+	// Percent of items per genre, to one decimal place
+	GenrePercentages map[string]float64 `json:"genre_percentages"`
+}
+
+type BulkWatchHistoryResult struct {
+	Inserted int `json:"inserted"`
+	Skipped  int `json:"skipped"`
+}
+
+// Outcome of a watch history write, stored for idempotent replays
+type WatchHistoryOutcome string
+
+const (
+	WatchRecorded  WatchHistoryOutcome = "recorded"
+	WatchDuplicate WatchHistoryOutcome = "duplicate"
+)
+
+var ErrIdempotencyMismatch = errors.New("idempotency key reused with a different request")
+var ErrIdempotencyInProgress = errors.New("request with this idempotency key is in progress")
+
+// What an idempotency key was used for. Outcome is empty while the first
+// request with the key is still being processed.
+type IdempotencyRecord struct {
+	// Identifies the request payload, so the key can't replay another request
+	Fingerprint string              `json:"fingerprint"`
+	Outcome     WatchHistoryOutcome `json:"outcome,omitempty"`
+}
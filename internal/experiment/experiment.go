@@ -0,0 +1,90 @@
+package experiment
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+// Buckets are numbered 0 to NumBuckets-1
+const NumBuckets = 100
+
+// Deterministically map a user to an experiment bucket
+func AssignBucket(userID int64) int {
+	h := fnv.New32a()
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(userID))
+	h.Write(buf[:])
+	return int(h.Sum32() % NumBuckets)
+}
+
+type bucketRange struct {
+	// Exclusive upper bound of the range
+	end      int
+	strategy domain.Strategy
+}
+
+// Split maps contiguous bucket ranges to strategies.
+// A nil Split sends every bucket to domain.DefaultStrategy.
+type Split struct {
+	ranges []bucketRange
+}
+
+// Parse a split like "genre_weighted:50,recency_first:50". Percentages must
+// add up to 100. An empty string returns a nil Split.
+func ParseSplit(s string) (*Split, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	split := &Split{}
+	total := 0
+	for _, part := range strings.Split(s, ",") {
+		name, pctStr, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid split entry %q, expected strategy:percent", part)
+		}
+
+		strategy := domain.Strategy(strings.TrimSpace(name))
+		if !strategy.Valid() {
+			return nil, fmt.Errorf("unknown strategy %q", name)
+		}
+
+		pct, err := strconv.Atoi(strings.TrimSpace(pctStr))
+		if err != nil || pct < 1 {
+			return nil, fmt.Errorf("invalid percent %q for strategy %s", pctStr, strategy)
+		}
+
+		total += pct
+		split.ranges = append(split.ranges, bucketRange{end: total, strategy: strategy})
+	}
+
+	if total != NumBuckets {
+		return nil, fmt.Errorf("split percentages must add up to %d, got %d", NumBuckets, total)
+	}
+	return split, nil
+}
+
+// Strategy assigned to a bucket
+func (s *Split) StrategyFor(bucket int) domain.Strategy {
+	if s == nil {
+		return domain.DefaultStrategy
+	}
+	for _, r := range s.ranges {
+		if bucket < r.end {
+			return r.strategy
+		}
+	}
+	return domain.DefaultStrategy
+}
+
+// Bucket and strategy for a user
+func (s *Split) Assign(userID int64) (int, domain.Strategy) {
+	bucket := AssignBucket(userID)
+	return bucket, s.StrategyFor(bucket)
+}
@@ -0,0 +1,83 @@
+package experiment
+
+import (
+	"math"
+	"testing"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+func TestAssignBucketDeterministic(t *testing.T) {
+	for userID := int64(1); userID <= 1000; userID++ {
+		bucket := AssignBucket(userID)
+		if bucket < 0 || bucket >= NumBuckets {
+			t.Fatalf("user %d: bucket %d out of range", userID, bucket)
+		}
+		if again := AssignBucket(userID); again != bucket {
+			t.Fatalf("user %d: bucket changed from %d to %d", userID, bucket, again)
+		}
+	}
+}
+
+func TestSplitHonorsRatios(t *testing.T) {
+	split, err := ParseSplit("genre_weighted:50,popularity_only:30,recency_first:20")
+	if err != nil {
+		t.Fatalf("parse split: %v", err)
+	}
+
+	// Every bucket maps to the configured range
+	for bucket := 0; bucket < NumBuckets; bucket++ {
+		expected := domain.StrategyRecencyFirst
+		if bucket < 50 {
+			expected = domain.StrategyGenreWeighted
+		} else if bucket < 80 {
+			expected = domain.StrategyPopularityOnly
+		}
+		if got := split.StrategyFor(bucket); got != expected {
+			t.Errorf("bucket %d: expected %s, got %s", bucket, expected, got)
+		}
+	}
+
+	// Users spread across strategies close to the configured ratios
+	const users = 10000
+	counts := make(map[domain.Strategy]int)
+	for userID := int64(1); userID <= users; userID++ {
+		_, strategy := split.Assign(userID)
+		counts[strategy]++
+	}
+	ratios := map[domain.Strategy]float64{
+		domain.StrategyGenreWeighted:  0.5,
+		domain.StrategyPopularityOnly: 0.3,
+		domain.StrategyRecencyFirst:   0.2,
+	}
+	for strategy, ratio := range ratios {
+		got := float64(counts[strategy]) / users
+		if math.Abs(got-ratio) > 0.03 {
+			t.Errorf("%s: expected ratio ~%.2f, got %.3f", strategy, ratio, got)
+		}
+	}
+}
+
+func TestParseSplitInvalid(t *testing.T) {
+	for _, s := range []string{
+		"genre_weighted:50",
+		"genre_weighted:50,unknown:50",
+		"genre_weighted",
+		"genre_weighted:abc",
+		"genre_weighted:0,recency_first:100",
+	} {
+		if _, err := ParseSplit(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestNilSplitUsesDefault(t *testing.T) {
+	split, err := ParseSplit("")
+	if err != nil {
+		t.Fatalf("parse empty split: %v", err)
+	}
+	if _, strategy := split.Assign(7); strategy != domain.DefaultStrategy {
+		t.Errorf("expected default strategy, got %s", strategy)
+	}
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/actuallystonmai/recommendation-service/seeds"
+)
+
+// Upper bounds on reseed sizes, so a typo can't tie up the database for hours
+const (
+	maxSeedUsers       = 100000
+	maxSeedContent     = 100000
+	maxSeedWatchEvents = 1000000
+)
+
+// Deadline for a reseed, which the router exempts from its request timeout
+// since the largest allowed sizes take minutes
+const reseedTimeout = 10 * time.Minute
+
+// POST /admin/users/{userID}/cache/invalidate
+func (h *Handler) InvalidateUserCache(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserID(r)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid user_id parameter")
+		return
+	}
+
+	deleted, err := h.service.InvalidateUserCache(r.Context(), userID)
+	if err != nil {
+		h.writeUnexpectedError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, CacheInvalidationResponse{
+		UserID:      userID,
+		KeysDeleted: deleted,
+	})
+}
+
+// GET /admin/failed-users
+func (h *Handler) GetFailedUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.service.GetFailedUsers(r.Context())
+	if err != nil {
+		h.writeUnexpectedError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, FailedUsersResponse{
+		Users: users,
+		Total: len(users),
+	})
+}
+
+// DELETE /admin/failed-users/{userID}
+func (h *Handler) ClearFailedUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserID(r)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid user_id parameter")
+		return
+	}
+
+	if err := h.service.ClearFailedUser(r.Context(), userID); err != nil {
+		if errors.Is(err, domain.ErrFailedUserNotFound) {
+			h.writeError(w, http.StatusNotFound, "failed_user_not_found",
+				fmt.Sprintf("User %d is not flagged as failing", userID))
+			return
+		}
+		h.writeUnexpectedError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /admin/seed
+func (h *Handler) ReseedData(w http.ResponseWriter, r *http.Request) {
+	defaults := h.service.SeedDefaults()
+	req := SeedRequest{
+		Users:       defaults.Users,
+		Content:     defaults.Content,
+		WatchEvents: defaults.WatchEvents,
+		Seed:        defaults.Seed,
+	}
+	// The body is optional; an empty one reseeds with the defaults
+	if !h.decodeOptionalJSON(w, r, &req) {
+		return
+	}
+
+	// Validate sizes
+	if req.Users < 1 || req.Users > maxSeedUsers {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter",
+			fmt.Sprintf("users must be between 1 and %d", maxSeedUsers))
+		return
+	}
+	if req.Content < 1 || req.Content > maxSeedContent {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter",
+			fmt.Sprintf("content must be between 1 and %d", maxSeedContent))
+		return
+	}
+	if req.WatchEvents < 0 || req.WatchEvents > maxSeedWatchEvents {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter",
+			fmt.Sprintf("watch_events must be between 0 and %d", maxSeedWatchEvents))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), reseedTimeout)
+	defer cancel()
+	// Outlast HTTP_WRITE_TIMEOUT too. Not every ResponseWriter supports
+	// deadlines, e.g. in tests, and the server's timeout applies then
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(reseedTimeout))
+
+	summary, err := h.service.Reseed(ctx, seeds.SeedConfig{
+		Users:       req.Users,
+		Content:     req.Content,
+		WatchEvents: req.WatchEvents,
+		Seed:        req.Seed,
+	})
+	if err != nil {
+		h.writeUnexpectedError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, SeedResponse{
+		Users:              summary.Users,
+		Content:            summary.Content,
+		WatchEvents:        summary.WatchEvents,
+		RegionalPopularity: summary.RegionalPopularity,
+	})
+}
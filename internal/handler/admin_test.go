@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/actuallystonmai/recommendation-service/internal/service"
+	"github.com/actuallystonmai/recommendation-service/internal/service/servicetest"
+	"github.com/actuallystonmai/recommendation-service/seeds"
+)
+
+func TestInvalidateUserCache(t *testing.T) {
+	c := servicetest.NewFakeCache()
+	h := newFakeHandler(t, servicetest.NewFakeRepo(), c)
+	ctx := context.Background()
+
+	recs := domain.CachedRecommendations{Recommendations: []domain.ScoredRecommendation{{ContentID: 1, Score: 0.5}}}
+	for _, limit := range []int{5, 10, 20} {
+		if err := c.Set(ctx, 1, limit, "", recs); err != nil {
+			t.Fatalf("seed cache: %v", err)
+		}
+	}
+	if err := c.Set(ctx, 2, 10, "", recs); err != nil {
+		t.Fatalf("seed cache: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.InvalidateUserCache(rec, newUserRequest(http.MethodPost, "/admin/users/1/cache/invalidate", "1", ""))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var resp CacheInvalidationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.UserID != 1 || resp.KeysDeleted != 3 {
+		t.Errorf("expected user 1 with 3 keys deleted, got %+v", resp)
+	}
+	if c.Len() != 1 {
+		t.Errorf("expected only the other user's entry to remain, got %d", c.Len())
+	}
+}
+
+func TestGetFailedUsers(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(2, 5)
+	repo.UserErrors[1] = errors.New("missing profile")
+	h := NewHandler(newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{}, service.Config{
+		BatchFailureThreshold: 1,
+		BatchFailureWindow:    time.Hour,
+	}), Config{})
+
+	for range 2 {
+		rec := httptest.NewRecorder()
+		h.GetBatchRecommendations(rec, httptest.NewRequest(http.MethodGet, "/recommendations/batch?page=1&limit=2", nil))
+		// Partial failure
+		if rec.Code != http.StatusMultiStatus {
+			t.Fatalf("batch: expected 207, got %d", rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.GetFailedUsers(rec, httptest.NewRequest(http.MethodGet, "/admin/failed-users", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var resp FailedUsersResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Total != 1 || len(resp.Users) != 1 || resp.Users[0].UserID != 1 || resp.Users[0].RecentFailures != 2 {
+		t.Errorf("expected user 1 flagged with 2 failures, got %+v", resp)
+	}
+}
+
+func TestClearFailedUser(t *testing.T) {
+	c := servicetest.NewFakeCache()
+	h := newFakeHandler(t, servicetest.NewFakeRepo(), c)
+	if _, err := c.RecordBatchFailure(context.Background(), 1, 0, time.Hour); err != nil {
+		t.Fatalf("record failure: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ClearFailedUser(rec, newUserRequest(http.MethodDelete, "/admin/failed-users/1", "1", ""))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ClearFailedUser(rec, newUserRequest(http.MethodDelete, "/admin/failed-users/1", "1", ""))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "failed_user_not_found") {
+		t.Errorf("expected 404 failed_user_not_found, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestReseedData(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(3, 3)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	body := `{"users": 7, "content": 12}`
+	rec := httptest.NewRecorder()
+	h.ReseedData(rec, httptest.NewRequest(http.MethodPost, "/admin/seed", strings.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp SeedResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Users != 7 || resp.Content != 12 {
+		t.Errorf("expected 7 users and 12 content, got %+v", resp)
+	}
+	if len(repo.Users) != 7 || len(repo.Content) != 12 {
+		t.Errorf("expected reseeded data present, got %d users and %d content", len(repo.Users), len(repo.Content))
+	}
+}
+
+func TestReseedDataDefaults(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+	defaults := seeds.DefaultSeedConfig()
+
+	rec := httptest.NewRecorder()
+	h.ReseedData(rec, httptest.NewRequest(http.MethodPost, "/admin/seed", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(repo.Users) != defaults.Users || len(repo.Content) != defaults.Content {
+		t.Errorf("expected default sizes, got %d users and %d content", len(repo.Users), len(repo.Content))
+	}
+}
+
+func TestReseedDataInvalidInput(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	for _, body := range []string{`{"users":`, `{"users": 0}`, `{"content": 100001}`, `{"watch_events": -1}`} {
+		rec := httptest.NewRecorder()
+		h.ReseedData(rec, httptest.NewRequest(http.MethodPost, "/admin/seed", strings.NewReader(body)))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rec.Code)
+		}
+	}
+	if len(repo.Users) != 0 {
+		t.Error("expected no reseed for invalid input")
+	}
+}
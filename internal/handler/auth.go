@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// Paths reachable without an API key, e.g. for load balancer probes and
+// Prometheus scrapes
+var authExemptPaths = map[string]bool{
+	"/health":       true,
+	"/health/ready": true,
+	"/metrics":      true,
+}
+
+// Require a valid X-API-Key header on every non-exempt request.
+// With no keys configured authentication is disabled.
+func (h *Handler) APIKeyAuth(keys []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(keys) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if authExemptPaths[r.URL.Path] || validAPIKey(keys, r.Header.Get("X-API-Key")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			h.writeError(w, http.StatusUnauthorized, "unauthorized", "Missing or invalid API key")
+		})
+	}
+}
+
+// Constant-time comparison against every configured key
+func validAPIKey(keys []string, provided string) bool {
+	if provided == "" {
+		return false
+	}
+	valid := false
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(provided)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	protected := (&Handler{}).APIKeyAuth([]string{"key-one", "key-two"})(ok)
+
+	tests := []struct {
+		name     string
+		path     string
+		key      string
+		expected int
+	}{
+		{"valid key", "/users/1/recommendations", "key-two", http.StatusOK},
+		{"invalid key", "/users/1/recommendations", "wrong", http.StatusUnauthorized},
+		{"missing key", "/users/1/recommendations", "", http.StatusUnauthorized},
+		{"health exempt", "/health", "", http.StatusOK},
+		{"readiness exempt", "/health/ready", "", http.StatusOK},
+		{"metrics exempt", "/metrics", "", http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.key != "" {
+			req.Header.Set("X-API-Key", tc.key)
+		}
+		rec := httptest.NewRecorder()
+		protected.ServeHTTP(rec, req)
+
+		if rec.Code != tc.expected {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.expected, rec.Code)
+		}
+		if tc.expected == http.StatusUnauthorized && rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: expected JSON error body", tc.name)
+		}
+	}
+}
+
+func TestAPIKeyAuthDisabledWithoutKeys(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	(&Handler{}).APIKeyAuth(nil)(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/1/recommendations", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected auth to be disabled, got %d", rec.Code)
+	}
+}
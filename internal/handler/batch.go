@@ -2,46 +2,258 @@ package handler
 
 import (
 	"context"
-	"errors"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
 )
 
+var defaultBatchPage = BatchPageQuery{Page: 1, Limit: 20, PerUserLimit: 10}
+
+const (
+	maxBulkUsers     = 200
+	defaultBulkLimit = 10
+	maxBulkLimit     = 50
+)
+
+// How long each write of a streamed response may take. Streams push their
+// write deadline this far out before every event, line or user's rows, so a
+// long batch outlives HTTP_WRITE_TIMEOUT while a stalled client is still cut
+// off.
+const streamWriteTimeout = 30 * time.Second
+
 // GET /recommendations/batch
 func (h *Handler) GetBatchRecommendations(w http.ResponseWriter, r *http.Request) {
-	// Parse and validate page
-	page := 1
-	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
-		parsed, err := strconv.Atoi(pageStr)
-		if err != nil || parsed < 1 || parsed > 10000  {
-			writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid page parameter")
+	query := BatchQuery{BatchPageQuery: defaultBatchPage}
+	if !h.decodeAndValidate(w, r, &query) {
+		return
+	}
+
+	w.Header().Set("Vary", "Accept")
+	if h.batchNotModified(w, r, query.Page, query.Limit) {
+		return
+	}
+	if negotiateContentType(r, contentTypeJSON, contentTypeCSV) == contentTypeCSV {
+		// CSV rows have no room for explanations
+		results, err := h.service.StreamBatchRecommendations(r.Context(), query.Page, query.Limit, query.PerUserLimit, false)
+		if err != nil {
+			h.writeUnexpectedError(w, err)
 			return
 		}
-		page = parsed
+		writeBatchCSV(w, results, query.Status)
+		return
+	}
+
+	// Call service
+	result, err := h.service.GetBatchRecommendations(r.Context(), query.Page, query.Limit, query.PerUserLimit, query.Status, query.WithExplanations)
+	// Batch setup failures (pagination, counting, timeout) fail the whole request
+	if err != nil {
+		h.writeUnexpectedError(w, err)
+		return
+	}
+
+	h.writeJSON(w, batchStatusCode(result.Summary), result)
+}
+
+// Set Last-Modified to when the page last changed and write a 304 when the
+// client's If-Modified-Since is no older. HTTP dates have second precision, so
+// a change within the current second gets no Last-Modified: a later change in
+// the same second would carry the same date. An empty page, or a failed
+// lookup, is always served in full.
+func (h *Handler) batchNotModified(w http.ResponseWriter, r *http.Request, page, limit int) bool {
+	lastModified, err := h.service.BatchLastModified(r.Context(), page, limit)
+	if err != nil {
+		log.Printf("[handler] batch last modified lookup failed, serving page %d: %v", page, err)
+		return false
+	}
+	lastModified = lastModified.UTC().Truncate(time.Second)
+	if lastModified.IsZero() || !lastModified.Before(time.Now().Truncate(time.Second)) {
+		return false
+	}
+	w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified.After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// GET /recommendations/batch/stream
+func (h *Handler) StreamBatchRecommendations(w http.ResponseWriter, r *http.Request) {
+	query := defaultBatchPage
+	if !h.decodeAndValidate(w, r, &query) {
+		return
+	}
+
+	results, err := h.service.StreamBatchRecommendations(r.Context(), query.Page, query.Limit, query.PerUserLimit, query.WithExplanations)
+	if err != nil {
+		h.writeUnexpectedError(w, err)
+		return
+	}
+
+	writeNDJSON(w, results)
+}
+
+// GET /recommendations/batch/progress
+func (h *Handler) StreamBatchProgress(w http.ResponseWriter, r *http.Request) {
+	query := defaultBatchPage
+	if !h.decodeAndValidate(w, r, &query) {
+		return
+	}
+
+	// Stopping early, such as on a failed write, cancels the rest of the batch
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	progress, done, err := h.service.GetBatchRecommendationsWithProgress(ctx, query.Page, query.Limit, query.PerUserLimit, query.WithExplanations)
+	if err != nil {
+		h.writeUnexpectedError(w, err)
+		return
 	}
 
-	// Parse and validate limit
-	limit := 20
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		parsed, err := strconv.Atoi(limitStr)
-		if err != nil || parsed < 1 || parsed > 100 {
-			writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid limit parameter")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	for p := range progress {
+		if err := writeSSE(w, rc, "progress", p); err != nil {
+			// Client went away
 			return
 		}
-		limit = parsed
 	}
-	
-	// Call service
-	result, err := h.service.GetBatchRecommendations(r.Context(), page, limit)
+	if result := <-done; result != nil {
+		writeSSE(w, rc, "complete", result.Summary)
+	}
+}
+
+// Write a Server-Sent Event with a JSON payload and flush it
+func writeSSE(w http.ResponseWriter, rc *http.ResponseController, event string, v any) error {
+	data, err := json.Marshal(v)
 	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
-			writeError(w, http.StatusServiceUnavailable, "request_timeout",
-				"Request timed out, please try again")
+		return err
+	}
+	extendWriteDeadline(rc)
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	// Not every ResponseWriter can flush; the event then goes out with the rest
+	rc.Flush()
+	return nil
+}
+
+// Push the write deadline out ahead of the next write of a stream. Writers
+// without deadlines, such as test recorders, keep the server's timeout.
+func extendWriteDeadline(rc *http.ResponseController) {
+	rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+}
+
+// POST /recommendations/bulk
+func (h *Handler) GetBulkRecommendations(w http.ResponseWriter, r *http.Request) {
+	var req BulkRecommendationRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	if len(req.UserIDs) == 0 || len(req.UserIDs) > maxBulkUsers {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter",
+			fmt.Sprintf("user_ids must contain between 1 and %d IDs", maxBulkUsers))
+		return
+	}
+	for _, id := range req.UserIDs {
+		if id <= 0 {
+			h.writeError(w, http.StatusBadRequest, "invalid_parameter", "user_ids must be positive")
 			return
 		}
-		writeError(w, http.StatusInternalServerError, "internal_error", "An unexpected error occurred")
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultBulkLimit
+	}
+	if req.Limit < 1 || req.Limit > maxBulkLimit {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter",
+			fmt.Sprintf("limit must be between 1 and %d", maxBulkLimit))
 		return
 	}
 
-	writeJSON(w, http.StatusOK, result)
-}
\ No newline at end of file
+	result := h.service.GetBulkRecommendations(r.Context(), req.UserIDs, req.Limit)
+	h.writeJSON(w, batchStatusCode(result.Summary), result)
+}
+
+// Write each result as a JSON line, flushing as soon as it is available
+func writeNDJSON(w http.ResponseWriter, results <-chan domain.BatchUserResult) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	for result := range results {
+		extendWriteDeadline(rc)
+		if err := enc.Encode(result); err != nil {
+			// Client went away; the service stops workers via the request context
+			return
+		}
+		rc.Flush()
+	}
+}
+
+var batchCSVHeader = []string{"user_id", "content_id", "title", "genre", "score", "status"}
+
+// Write each result as CSV rows as soon as it is available: one row per
+// recommendation, or a single row with empty content columns for a user with
+// none. A non-empty status keeps only matching users.
+func writeBatchCSV(w http.ResponseWriter, results <-chan domain.BatchUserResult, status domain.BatchStatus) {
+	w.Header().Set("Content-Type", contentTypeCSV+"; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	cw := csv.NewWriter(w)
+	cw.Write(batchCSVHeader)
+	for result := range results {
+		if status != "" && result.Status != status {
+			continue
+		}
+		extendWriteDeadline(rc)
+		userID := strconv.FormatInt(result.UserID, 10)
+		if len(result.Recommendations) == 0 {
+			cw.Write([]string{userID, "", "", "", "", string(result.Status)})
+		}
+		for _, rec := range result.Recommendations {
+			cw.Write([]string{
+				userID,
+				strconv.FormatInt(rec.ContentID, 10),
+				rec.Title,
+				rec.Genre,
+				strconv.FormatFloat(rec.Score, 'f', -1, 64),
+				string(result.Status),
+			})
+		}
+		cw.Flush()
+		if cw.Error() != nil {
+			// Client went away; the service stops workers via the request context
+			return
+		}
+		rc.Flush()
+	}
+	cw.Flush()
+}
+
+// 200 when every user succeeded, 207 Multi-Status when some succeeded and
+// others failed or were cancelled, and 500 when none succeeded: nothing in the
+// page is usable, though the body still lists each user's error
+func batchStatusCode(summary domain.BatchSummary) int {
+	switch {
+	case summary.FailedCount == 0 && summary.CancelledCount == 0:
+		return http.StatusOK
+	case summary.SuccessCount == 0:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusMultiStatus
+	}
+}
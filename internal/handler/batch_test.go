@@ -0,0 +1,581 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/actuallystonmai/recommendation-service/internal/service"
+	"github.com/actuallystonmai/recommendation-service/internal/service/servicetest"
+)
+
+func TestBatchStatusCode(t *testing.T) {
+	cases := []struct {
+		name     string
+		summary  domain.BatchSummary
+		expected int
+	}{
+		{"all success", domain.BatchSummary{SuccessCount: 10}, http.StatusOK},
+		{"mixed", domain.BatchSummary{SuccessCount: 8, FailedCount: 2}, http.StatusMultiStatus},
+		{"mixed with cancellations", domain.BatchSummary{SuccessCount: 8, CancelledCount: 2}, http.StatusMultiStatus},
+		{"all failed", domain.BatchSummary{FailedCount: 10}, http.StatusInternalServerError},
+		{"failed and cancelled", domain.BatchSummary{FailedCount: 2, CancelledCount: 8}, http.StatusInternalServerError},
+		{"empty page", domain.BatchSummary{}, http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		if got := batchStatusCode(tc.summary); got != tc.expected {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestGetBatchRecommendationsAllFailed(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(2, 5)
+	scorer := &servicetest.FakeScorer{FailUsers: map[int64]bool{1: true, 2: true}}
+	h := NewHandler(newTestService(t, repo, servicetest.NewFakeCache(), scorer, service.Config{}), Config{})
+
+	rec := httptest.NewRecorder()
+	h.GetBatchRecommendations(rec, httptest.NewRequest(http.MethodGet, "/recommendations/batch?page=1&limit=2", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 when every user failed, got %d", rec.Code)
+	}
+	// Still the batch body, so clients see why each user failed
+	var resp domain.BatchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Summary.FailedCount != 2 || len(resp.Results) != 2 || resp.Results[0].Error == "" {
+		t.Errorf("expected both users listed as failed, got %+v", resp)
+	}
+}
+
+func TestGetBatchRecommendationsDatabaseUnavailable(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(2, 5)
+	repo.Errors["GetUserIDsPaginated"] = &domain.RepositoryError{
+		Op: "fetch user ids", Err: errors.New("connection refused"), Unavailable: true,
+	}
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	rec := httptest.NewRecorder()
+	h.GetBatchRecommendations(rec, httptest.NewRequest(http.MethodGet, "/recommendations/batch", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Error != "database_unavailable" {
+		t.Errorf("expected database_unavailable, got %q", resp.Error)
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	results := make(chan domain.BatchUserResult, 3)
+	results <- domain.BatchUserResult{UserID: 1, Status: domain.StatusSuccess}
+	results <- domain.BatchUserResult{UserID: 2, Status: domain.StatusFailed, Error: "user_not_found"}
+	results <- domain.BatchUserResult{UserID: 3, Status: domain.StatusSuccess}
+	close(results)
+
+	rec := httptest.NewRecorder()
+	writeNDJSON(rec, results)
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("expected application/x-ndjson, got %s", ct)
+	}
+	if !rec.Flushed {
+		t.Error("expected response to be flushed")
+	}
+
+	// Each line parses into a result
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(lines))
+	}
+	for i, line := range lines {
+		var result domain.BatchUserResult
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("line %d does not parse: %v", i, err)
+		}
+		if result.UserID != int64(i+1) {
+			t.Errorf("line %d: expected user %d, got %d", i, i+1, result.UserID)
+		}
+	}
+}
+
+func TestStreamBatchProgress(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(8, 5)
+	repo.UserErrors[3] = domain.ErrUserNotFound
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{}, service.Config{BatchConcurrency: 3})
+	srv := httptest.NewServer(http.HandlerFunc(NewHandler(svc, Config{}).StreamBatchProgress))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/recommendations/batch/progress?page=1&limit=8")
+	if err != nil {
+		t.Fatalf("request progress stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected text/event-stream, got %s", ct)
+	}
+
+	// Read events as they arrive: "event:" then "data:", ended by a blank line
+	type event struct{ name, data string }
+	var events []event
+	var current event
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			current.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		case line == "":
+			events = append(events, current)
+			current = event{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("read stream: %v", err)
+	}
+
+	if len(events) != 9 {
+		t.Fatalf("expected 8 progress events and a complete event, got %d: %v", len(events), events)
+	}
+	for i, e := range events[:8] {
+		var p domain.BatchProgress
+		if e.name != "progress" || json.Unmarshal([]byte(e.data), &p) != nil {
+			t.Fatalf("event %d: expected a progress event, got %v", i, e)
+		}
+		if p.Processed != i+1 || p.Total != 8 {
+			t.Errorf("event %d: expected %d/8 processed, got %d/%d", i, i+1, p.Processed, p.Total)
+		}
+	}
+
+	complete := events[8]
+	var summary domain.BatchSummary
+	if complete.name != "complete" || json.Unmarshal([]byte(complete.data), &summary) != nil {
+		t.Fatalf("expected a final complete event, got %v", complete)
+	}
+	if summary.SuccessCount != 7 || summary.FailedCount != 1 {
+		t.Errorf("expected 7 succeeded and 1 failed, got %+v", summary)
+	}
+}
+
+func TestSSEOutlivesServerWriteTimeout(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		for i := range 4 {
+			time.Sleep(40 * time.Millisecond)
+			if err := writeSSE(w, rc, "progress", domain.BatchProgress{Processed: i + 1, Total: 4}); err != nil {
+				t.Errorf("write event %d: %v", i, err)
+				return
+			}
+		}
+	}))
+	// Shorter than the whole stream, longer than the gap between events
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request stream: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read stream: %v", err)
+	}
+	if events := strings.Count(string(body), "event: progress"); events != 4 {
+		t.Errorf("expected all 4 events past the write timeout, got %d: %q", events, body)
+	}
+}
+
+func TestBatchStreamsOutliveServerWriteTimeout(t *testing.T) {
+	writers := map[string]func(http.ResponseWriter, <-chan domain.BatchUserResult){
+		"ndjson": writeNDJSON,
+		"csv": func(w http.ResponseWriter, results <-chan domain.BatchUserResult) {
+			writeBatchCSV(w, results, "")
+		},
+	}
+	for name, write := range writers {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				results := make(chan domain.BatchUserResult)
+				go func() {
+					defer close(results)
+					for id := int64(1); id <= 4; id++ {
+						time.Sleep(40 * time.Millisecond)
+						results <- domain.BatchUserResult{UserID: id, Status: domain.StatusSuccess}
+					}
+				}()
+				write(w, results)
+			}))
+			// Shorter than the whole stream, longer than the gap between results
+			srv.Config.WriteTimeout = 100 * time.Millisecond
+			srv.Start()
+			defer srv.Close()
+
+			resp, err := http.Get(srv.URL)
+			if err != nil {
+				t.Fatalf("request stream: %v", err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("read stream: %v", err)
+			}
+			if strings.Count(string(body), "success") != 4 {
+				t.Errorf("expected all 4 users past the write timeout, got %q", body)
+			}
+		})
+	}
+}
+
+func TestGetBulkRecommendationsMixedUsers(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(3, 10)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	body := `{"user_ids": [3, 99, 1, 3], "limit": 4}`
+	rec := httptest.NewRecorder()
+	h.GetBulkRecommendations(rec, httptest.NewRequest(http.MethodPost, "/recommendations/bulk", strings.NewReader(body)))
+
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("expected 207 with an unknown user, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp domain.BulkRecommendationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	// Duplicates are scored once, in order of first appearance
+	if len(resp.Results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(resp.Results))
+	}
+	for i, want := range []int64{3, 99, 1} {
+		if resp.Results[i].UserID != want {
+			t.Errorf("result %d: expected user %d, got %d", i, want, resp.Results[i].UserID)
+		}
+	}
+	if r := resp.Results[1]; r.Status != domain.StatusFailed || r.Error != "user_not_found" {
+		t.Errorf("expected user 99 to fail with user_not_found, got %+v", r)
+	}
+	for _, r := range []domain.BatchUserResult{resp.Results[0], resp.Results[2]} {
+		if r.Status != domain.StatusSuccess || len(r.Recommendations) != 4 {
+			t.Errorf("user %d: expected 4 recommendations, got %+v", r.UserID, r)
+		}
+	}
+	if resp.Summary.SuccessCount != 2 || resp.Summary.FailedCount != 1 || resp.Limit != 4 {
+		t.Errorf("unexpected summary %+v limit %d", resp.Summary, resp.Limit)
+	}
+}
+
+func TestGetBulkRecommendationsValidation(t *testing.T) {
+	h := &Handler{}
+
+	ids := make([]string, maxBulkUsers+1)
+	for i := range ids {
+		ids[i] = fmt.Sprint(i + 1)
+	}
+	tooMany := `{"user_ids": [` + strings.Join(ids, ",") + `]}`
+
+	cases := []struct {
+		name string
+		body string
+	}{
+		{"missing list", `{}`},
+		{"empty list", `{"user_ids": []}`},
+		{"over the cap", tooMany},
+		{"non-positive id", `{"user_ids": [1, 0]}`},
+		{"limit too high", `{"user_ids": [1], "limit": 51}`},
+		{"negative limit", `{"user_ids": [1], "limit": -1}`},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		h.GetBulkRecommendations(rec, httptest.NewRequest(http.MethodPost, "/recommendations/bulk", strings.NewReader(tc.body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", tc.name, rec.Code)
+		}
+	}
+}
+
+func TestGetBatchRecommendationsCSV(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(3, 5)
+	repo.UserErrors[2] = domain.ErrModelUnavailable
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	req := httptest.NewRequest(http.MethodGet, "/recommendations/batch?page=1&limit=3", nil)
+	req.Header.Set("Accept", "text/csv")
+	rec := httptest.NewRecorder()
+	h.GetBatchRecommendations(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("expected text/csv, got %s", ct)
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("response is not valid CSV: %v", err)
+	}
+	if len(rows) == 0 || strings.Join(rows[0], ",") != "user_id,content_id,title,genre,score,status" {
+		t.Fatalf("unexpected header row %v", rows)
+	}
+
+	// Each successful user has a row per recommendation; the failed user one
+	// row with no content
+	perUser := map[string]int{}
+	for _, row := range rows[1:] {
+		perUser[row[0]]++
+		if row[0] == "2" {
+			if row[1] != "" || row[5] != string(domain.StatusFailed) {
+				t.Errorf("expected an empty failed row for user 2, got %v", row)
+			}
+		} else if row[1] == "" || row[5] != string(domain.StatusSuccess) {
+			t.Errorf("expected a recommendation row, got %v", row)
+		}
+	}
+	if perUser["1"] != 5 || perUser["2"] != 1 || perUser["3"] != 5 {
+		t.Errorf("expected 5, 1 and 5 rows for users 1-3, got %v", perUser)
+	}
+}
+
+func TestGetBatchRecommendationsCSVStatusFilter(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(3, 5)
+	repo.UserErrors[2] = domain.ErrModelUnavailable
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	req := httptest.NewRequest(http.MethodGet, "/recommendations/batch?status=failed", nil)
+	req.Header.Set("Accept", "application/json;q=0.5, text/csv")
+	rec := httptest.NewRecorder()
+	h.GetBatchRecommendations(rec, req)
+
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("response is not valid CSV: %v", err)
+	}
+	if len(rows) != 2 || rows[1][0] != "2" {
+		t.Errorf("expected the header and user 2 only, got %v", rows)
+	}
+}
+
+func TestGetBatchRecommendationsDefaultsToJSON(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 3)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	for _, accept := range []string{"", "*/*", "text/html"} {
+		req := httptest.NewRequest(http.MethodGet, "/recommendations/batch", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		h.GetBatchRecommendations(rec, req)
+
+		var resp domain.BatchResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Errorf("Accept %q: expected JSON, got %s", accept, rec.Body.String())
+		}
+	}
+}
+
+func TestGetBatchRecommendationsPerUserLimit(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(3, 60)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	cases := []struct {
+		query string
+		want  int
+	}{
+		{"", 10},
+		{"?per_user_limit=1", 1},
+		{"?per_user_limit=50", 50},
+		// Page size and per-user count are independent
+		{"?limit=2&per_user_limit=4", 4},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		h.GetBatchRecommendations(rec, httptest.NewRequest(http.MethodGet, "/recommendations/batch"+tc.query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: expected 200, got %d: %s", tc.query, rec.Code, rec.Body.String())
+		}
+		var resp domain.BatchResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%q: decode response: %v", tc.query, err)
+		}
+		if resp.PerUserLimit != tc.want || len(resp.Results) == 0 {
+			t.Errorf("%q: expected per_user_limit %d, got %d with %d results", tc.query, tc.want, resp.PerUserLimit, len(resp.Results))
+		}
+		for _, r := range resp.Results {
+			if len(r.Recommendations) != tc.want {
+				t.Errorf("%q: user %d got %d recommendations, expected %d", tc.query, r.UserID, len(r.Recommendations), tc.want)
+			}
+		}
+	}
+
+	for _, bad := range []string{"0", "51", "1000000", "ten"} {
+		rec := httptest.NewRecorder()
+		h.GetBatchRecommendations(rec, httptest.NewRequest(http.MethodGet, "/recommendations/batch?per_user_limit="+bad, nil))
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "per_user_limit") {
+			t.Errorf("per_user_limit=%s: expected 400 naming the parameter, got %d: %s", bad, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestGetBatchRecommendationsWithExplanations(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(2, 10)
+	repo.AddWatchHistory(context.Background(), 1, 1)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	rec := httptest.NewRecorder()
+	h.GetBatchRecommendations(rec, httptest.NewRequest(http.MethodGet, "/recommendations/batch", nil))
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "top_genres") {
+		t.Errorf("expected no explanations by default, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetBatchRecommendations(rec, httptest.NewRequest(http.MethodGet, "/recommendations/batch?with_explanations=true", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp domain.BatchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	for _, r := range resp.Results {
+		if r.UserID == 1 && (len(r.TopGenres) != 1 || r.TopGenres[0].Genre != "action") {
+			t.Errorf("expected user 1 explained by action, got %v", r.TopGenres)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetBatchRecommendations(rec, httptest.NewRequest(http.MethodGet, "/recommendations/batch?with_explanations=maybe", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a malformed flag, got %d", rec.Code)
+	}
+}
+
+func TestGetBatchRecommendationsIfModifiedSince(t *testing.T) {
+	watchedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	changes := []struct {
+		name   string
+		change func(t *testing.T, svc *service.Service, repo *servicetest.FakeRepo)
+	}{
+		{"new watch", func(t *testing.T, _ *service.Service, repo *servicetest.FakeRepo) {
+			if err := repo.AddWatchHistory(context.Background(), 1, 2); err != nil {
+				t.Fatalf("add watch history: %v", err)
+			}
+		}},
+		{"removed watch", func(t *testing.T, svc *service.Service, _ *servicetest.FakeRepo) {
+			// Not the latest watch, so only the change stamp moves
+			if err := svc.RemoveWatchHistory(context.Background(), 2, 2); err != nil {
+				t.Fatalf("remove watch history: %v", err)
+			}
+		}},
+		{"blocked content", func(t *testing.T, svc *service.Service, _ *servicetest.FakeRepo) {
+			if _, err := svc.BlockContent(context.Background(), 1, 3); err != nil {
+				t.Fatalf("block content: %v", err)
+			}
+		}},
+		{"deleted content", func(t *testing.T, svc *service.Service, _ *servicetest.FakeRepo) {
+			if err := svc.DeleteContent(context.Background(), 4); err != nil {
+				t.Fatalf("delete content: %v", err)
+			}
+		}},
+		{"new content", func(t *testing.T, svc *service.Service, _ *servicetest.FakeRepo) {
+			if _, err := svc.CreateContent(context.Background(), "New", "drama", 0.5); err != nil {
+				t.Fatalf("create content: %v", err)
+			}
+		}},
+	}
+
+	for _, tc := range changes {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := servicetest.NewFakeRepoWith(2, 5)
+			repo.History[2] = []domain.WatchHistoryItem{
+				{ContentID: 1, Genre: "action", WatchedAt: watchedAt},
+				{ContentID: 2, Genre: "drama", WatchedAt: watchedAt.Add(-24 * time.Hour)},
+			}
+			scorer := &servicetest.FakeScorer{}
+			svc := newTestService(t, repo, servicetest.NewFakeCache(), scorer, service.Config{})
+			h := NewHandler(svc, Config{})
+
+			get := func(ifModifiedSince string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodGet, "/recommendations/batch?page=1&limit=2", nil)
+				if ifModifiedSince != "" {
+					req.Header.Set("If-Modified-Since", ifModifiedSince)
+				}
+				rec := httptest.NewRecorder()
+				h.GetBatchRecommendations(rec, req)
+				return rec
+			}
+
+			first := get("")
+			if first.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", first.Code)
+			}
+			lastModified := first.Header().Get("Last-Modified")
+			if lastModified != watchedAt.Format(http.TimeFormat) {
+				t.Fatalf("expected Last-Modified %q, got %q", watchedAt.Format(http.TimeFormat), lastModified)
+			}
+
+			// Unchanged: 304 without scoring anyone
+			calls := scorer.Calls()
+			unchanged := get(lastModified)
+			if unchanged.Code != http.StatusNotModified || unchanged.Body.Len() != 0 {
+				t.Errorf("expected an empty 304, got %d %s", unchanged.Code, unchanged.Body.String())
+			}
+			if scorer.Calls() != calls {
+				t.Errorf("expected no model calls for a 304, got %d", scorer.Calls()-calls)
+			}
+
+			tc.change(t, svc, repo)
+			changed := get(lastModified)
+			if changed.Code != http.StatusOK {
+				t.Errorf("expected 200 after the change, got %d", changed.Code)
+			}
+			// The change is in the current second, too recent to validate
+			if got := changed.Header().Get("Last-Modified"); got != "" {
+				t.Errorf("expected no Last-Modified right after a change, got %q", got)
+			}
+		})
+	}
+}
+
+func TestGetBatchRecommendationsLastModifiedUnavailable(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(2, 5)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+	ifModifiedSince := time.Now().UTC().Format(http.TimeFormat)
+
+	// Nothing on the page has changed yet: no validator, always served
+	req := httptest.NewRequest(http.MethodGet, "/recommendations/batch", nil)
+	req.Header.Set("If-Modified-Since", ifModifiedSince)
+	rec := httptest.NewRecorder()
+	h.GetBatchRecommendations(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Last-Modified") != "" {
+		t.Errorf("expected 200 without Last-Modified, got %d %q", rec.Code, rec.Header().Get("Last-Modified"))
+	}
+
+	// A failed lookup serves the page rather than failing it
+	repo.Errors = map[string]error{"MaxWatchedAtForUsers": errors.New("boom")}
+	rec = httptest.NewRecorder()
+	h.GetBatchRecommendations(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 when the lookup fails, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+// POST /users/{userID}/blocklist
+func (h *Handler) BlockContent(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserID(r)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid user_id parameter")
+		return
+	}
+
+	var req BlockContentRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	if req.ContentID <= 0 {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid content_id parameter")
+		return
+	}
+
+	added, err := h.service.BlockContent(r.Context(), userID, req.ContentID)
+	if err != nil {
+		h.writeBlocklistError(w, err, userID)
+		return
+	}
+
+	// Blocking is idempotent; 200 when the content was already blocked
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+	}
+	h.writeJSON(w, status, BlocklistResponse{
+		UserID:    userID,
+		ContentID: req.ContentID,
+	})
+}
+
+// DELETE /users/{userID}/blocklist/{contentID}
+func (h *Handler) UnblockContent(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserID(r)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid user_id parameter")
+		return
+	}
+
+	contentID, err := strconv.ParseInt(chi.URLParam(r, "contentID"), 10, 64)
+	if err != nil || contentID <= 0 {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid content_id parameter")
+		return
+	}
+
+	if err := h.service.UnblockContent(r.Context(), userID, contentID); err != nil {
+		h.writeBlocklistError(w, err, userID)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Map blocklist errors to HTTP responses
+func (h *Handler) writeBlocklistError(w http.ResponseWriter, err error, userID int64) {
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		h.writeError(w, http.StatusNotFound, "user_not_found",
+			fmt.Sprintf("User with ID %d does not exist", userID))
+	case errors.Is(err, domain.ErrContentNotFound):
+		h.writeError(w, http.StatusNotFound, "content_not_found", "Referenced content does not exist")
+	case errors.Is(err, domain.ErrBlockNotFound):
+		h.writeError(w, http.StatusNotFound, "block_not_found", "Content is not on the user's blocklist")
+	default:
+		h.writeUnexpectedError(w, err)
+	}
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/actuallystonmai/recommendation-service/internal/service/servicetest"
+)
+
+func TestBlocklistHidesContentFromRecommendations(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 5)
+	repo.Featured = []int64{2}
+	c := servicetest.NewFakeCache()
+	h := newFakeHandler(t, repo, c)
+
+	r := chi.NewRouter()
+	r.Get("/users/{userID}/recommendations", h.GetRecommendations)
+	r.Post("/users/{userID}/blocklist", h.BlockContent)
+	r.Delete("/users/{userID}/blocklist/{contentID}", h.UnblockContent)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+	recommended := func(query string) map[int64]bool {
+		var resp RecommendationResponse
+		json.Unmarshal(serve(http.MethodGet, "/users/1/recommendations"+query, "").Body.Bytes(), &resp)
+		ids := make(map[int64]bool)
+		for _, rec := range resp.Recommendations {
+			ids[rec.ContentID] = true
+		}
+		return ids
+	}
+
+	// Warm the cache so blocking has to invalidate it
+	if !recommended("")[1] {
+		t.Fatal("expected content 1 before blocking")
+	}
+
+	for _, contentID := range []string{"1", "2"} {
+		if rec := serve(http.MethodPost, "/users/1/blocklist", `{"content_id": `+contentID+`}`); rec.Code != http.StatusCreated {
+			t.Fatalf("block %s: expected 201, got %d", contentID, rec.Code)
+		}
+	}
+	if rec := serve(http.MethodPost, "/users/1/blocklist", `{"content_id": 1}`); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for an already blocked item, got %d", rec.Code)
+	}
+
+	// Neither scored, featured nor include_watched results show blocked items
+	for _, query := range []string{"", "?include_watched=true"} {
+		ids := recommended(query)
+		if ids[1] || ids[2] {
+			t.Errorf("%q: expected blocked content hidden, got %v", query, ids)
+		}
+		if len(ids) != 3 {
+			t.Errorf("%q: expected the 3 unblocked items, got %v", query, ids)
+		}
+	}
+
+	if rec := serve(http.MethodDelete, "/users/1/blocklist/1", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	if !recommended("")[1] {
+		t.Error("expected unblocked content to be recommended again")
+	}
+	if rec := serve(http.MethodDelete, "/users/1/blocklist/1", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing entry, got %d", rec.Code)
+	}
+}
+
+func TestBlockContentErrors(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 2)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	cases := []struct {
+		name   string
+		userID string
+		body   string
+		status int
+		code   string
+	}{
+		{"unknown user", "99", `{"content_id": 1}`, http.StatusNotFound, "user_not_found"},
+		{"unknown content", "1", `{"content_id": 99}`, http.StatusNotFound, "content_not_found"},
+		{"invalid content id", "1", `{"content_id": 0}`, http.StatusBadRequest, "invalid_parameter"},
+		{"invalid user id", "x", `{"content_id": 1}`, http.StatusBadRequest, "invalid_parameter"},
+		{"malformed json", "1", `{`, http.StatusBadRequest, "invalid_body"},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		h.BlockContent(rec, newUserRequest(http.MethodPost, "/users/x/blocklist", tc.userID, tc.body))
+
+		var resp ErrorResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if rec.Code != tc.status || resp.Error != tc.code {
+			t.Errorf("%s: expected %d %s, got %d %s", tc.name, tc.status, tc.code, rec.Code, resp.Error)
+		}
+	}
+
+	if _, blocked := repo.Blocked[1]; blocked {
+		t.Error("expected no blocklist entries after failed requests")
+	}
+}
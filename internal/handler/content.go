@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+const (
+	minSearchQueryLen = 2
+	maxTitleLen       = 255
+	minTrendingWindow = time.Hour
+	maxTrendingWindow = 90 * 24 * time.Hour
+)
+
+var defaultTrendingQuery = TrendingQuery{Window: "7d", Limit: 10}
+
+// GET /content
+func (h *Handler) ListContent(w http.ResponseWriter, r *http.Request) {
+	query := ContentListQuery{Sort: domain.ContentSortPopularity, Page: 1, Limit: 20}
+	if !h.decodeAndValidate(w, r, &query) {
+		return
+	}
+
+	items, total, err := h.service.ListContent(r.Context(), domain.ContentFilter{
+		Genre:         query.Genre,
+		MinPopularity: query.MinPopularity,
+		Sort:          query.Sort,
+		Page:          query.Page,
+		Limit:         query.Limit,
+	})
+	if err != nil {
+		h.writeUnexpectedError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, ContentListResponse{
+		Page:  query.Page,
+		Limit: query.Limit,
+		Total: total,
+		Items: items,
+	})
+}
+
+// GET /content/search
+func (h *Handler) SearchContent(w http.ResponseWriter, r *http.Request) {
+	// Validate query
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len([]rune(query)) < minSearchQueryLen {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Query parameter q must be at least 2 characters")
+		return
+	}
+
+	// Parse and validate limit
+	limit := 10
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 || parsed > 50 {
+			h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid limit parameter")
+			return
+		}
+		limit = parsed
+	}
+
+	items, err := h.service.SearchContent(r.Context(), query, limit)
+	if err != nil {
+		h.writeUnexpectedError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, ContentSearchResponse{
+		Query:   query,
+		Results: items,
+	})
+}
+
+// GET /content/trending
+func (h *Handler) GetTrendingContent(w http.ResponseWriter, r *http.Request) {
+	query := defaultTrendingQuery
+	if !h.decodeAndValidate(w, r, &query) {
+		return
+	}
+	window, ok := parseTrendingWindow(query.Window)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter",
+			"Invalid window parameter, expected a duration such as 7d or 12h between 1h and 90d")
+		return
+	}
+
+	items, err := h.service.GetTrendingContent(r.Context(), window, query.Limit)
+	if err != nil {
+		h.writeUnexpectedError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, TrendingContentResponse{
+		Window:  query.Window,
+		Results: items,
+	})
+}
+
+// Parse a trending window: whole days such as "7d", or a Go duration such as
+// "12h". Must be between 1h and 90d.
+func parseTrendingWindow(raw string) (time.Duration, bool) {
+	var window time.Duration
+	if days, found := strings.CutSuffix(raw, "d"); found {
+		// Bounded before multiplying so large values can't overflow
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 || n > int(maxTrendingWindow/(24*time.Hour)) {
+			return 0, false
+		}
+		window = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return 0, false
+		}
+		window = d
+	}
+	if window < minTrendingWindow || window > maxTrendingWindow {
+		return 0, false
+	}
+	return window, true
+}
+
+// POST /content
+func (h *Handler) CreateContent(w http.ResponseWriter, r *http.Request) {
+	var req CreateContentRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	// Validate fields
+	title := strings.TrimSpace(req.Title)
+	if title == "" || len([]rune(title)) > maxTitleLen {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter",
+			fmt.Sprintf("title must be between 1 and %d characters", maxTitleLen))
+		return
+	}
+	if !domain.IsValidGenre(req.Genre) {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter",
+			fmt.Sprintf("genre must be one of %s", strings.Join(domain.Genres, ", ")))
+		return
+	}
+	if req.PopularityScore == nil || *req.PopularityScore < 0 || *req.PopularityScore > 1 {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "popularity_score must be between 0 and 1")
+		return
+	}
+
+	content, err := h.service.CreateContent(r.Context(), title, req.Genre, *req.PopularityScore)
+	if err != nil {
+		h.writeUnexpectedError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, content)
+}
+
+// DELETE /content/{contentID}
+func (h *Handler) DeleteContent(w http.ResponseWriter, r *http.Request) {
+	contentID, err := strconv.ParseInt(chi.URLParam(r, "contentID"), 10, 64)
+	if err != nil || contentID <= 0 {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid content_id parameter")
+		return
+	}
+
+	if err := h.service.DeleteContent(r.Context(), contentID); err != nil {
+		if errors.Is(err, domain.ErrContentNotFound) {
+			h.writeError(w, http.StatusNotFound, "content_not_found",
+				fmt.Sprintf("Content with ID %d does not exist", contentID))
+			return
+		}
+		h.writeUnexpectedError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
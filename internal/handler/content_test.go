@@ -0,0 +1,279 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/actuallystonmai/recommendation-service/internal/service/servicetest"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestSearchContentInvalidQuery(t *testing.T) {
+	h := &Handler{}
+
+	for _, target := range []string{"/content/search", "/content/search?q=", "/content/search?q=a", "/content/search?q=%20m%20"} {
+		rec := httptest.NewRecorder()
+		h.SearchContent(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestSearchContentNoMatches(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	repo.AddContent(5)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	rec := httptest.NewRecorder()
+	h.SearchContent(rec, httptest.NewRequest(http.MethodGet, "/content/search?q=nothing", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if string(resp["results"]) != "[]" {
+		t.Errorf("expected empty results array, got %s", resp["results"])
+	}
+}
+
+func TestListContent(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	repo.AddContent(10)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	rec := httptest.NewRecorder()
+	h.ListContent(rec, httptest.NewRequest(http.MethodGet, "/content?genre=action&min_popularity=0.5&page=1&limit=1", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp ContentListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Page != 1 || resp.Limit != 1 {
+		t.Errorf("expected page 1 and limit 1, got %d and %d", resp.Page, resp.Limit)
+	}
+	// Action items are 1 and 6; only 1 is above the floor
+	if resp.Total != 1 || len(resp.Items) != 1 || resp.Items[0].ID != 1 {
+		t.Errorf("expected only content 1 with total 1, got %+v total %d", resp.Items, resp.Total)
+	}
+}
+
+func TestListContentInvalidParams(t *testing.T) {
+	h := newFakeHandler(t, servicetest.NewFakeRepo(), servicetest.NewFakeCache())
+
+	for _, target := range []string{
+		"/content?sort=title",
+		"/content?sort=POPULARITY",
+		"/content?genre=horror",
+		"/content?min_popularity=high",
+		"/content?min_popularity=-0.1",
+		"/content?min_popularity=NaN",
+		"/content?page=0",
+		"/content?limit=101",
+	} {
+		rec := httptest.NewRecorder()
+		h.ListContent(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestCreateContent(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	repo.AddContent(3)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	body := `{"title": " New Release ", "genre": "drama", "popularity_score": 0.4}`
+	rec := httptest.NewRecorder()
+	h.CreateContent(rec, httptest.NewRequest(http.MethodPost, "/content", strings.NewReader(body)))
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created domain.Content
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if created.ID != 4 || created.Title != "New Release" || created.Genre != "drama" || created.PopularityScore != 0.4 {
+		t.Errorf("unexpected created content: %+v", created)
+	}
+	if created.CreatedAt.IsZero() {
+		t.Error("expected created_at to be set")
+	}
+	if len(repo.Content) != 4 {
+		t.Errorf("expected content to be stored, got %d items", len(repo.Content))
+	}
+}
+
+func TestCreateContentInvalidInput(t *testing.T) {
+	h := &Handler{}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"malformed json", `{"title":`},
+		{"missing title", `{"genre": "drama", "popularity_score": 0.5}`},
+		{"invalid genre", `{"title": "X", "genre": "western", "popularity_score": 0.5}`},
+		{"popularity above range", `{"title": "X", "genre": "drama", "popularity_score": 1.5}`},
+		{"negative popularity", `{"title": "X", "genre": "drama", "popularity_score": -0.1}`},
+		{"missing popularity", `{"title": "X", "genre": "drama"}`},
+	}
+
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		h.CreateContent(rec, httptest.NewRequest(http.MethodPost, "/content", strings.NewReader(tc.body)))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", tc.name, rec.Code)
+		}
+	}
+}
+
+func TestDeleteContentHidesItEverywhere(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 5)
+	repo.Featured = []int64{1}
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	r := chi.NewRouter()
+	r.Get("/users/{userID}/recommendations", h.GetRecommendations)
+	r.Get("/content", h.ListContent)
+	r.Get("/content/search", h.SearchContent)
+	r.Delete("/content/{contentID}", h.DeleteContent)
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+
+	if rec := serve(http.MethodDelete, "/content/1"); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var recs RecommendationResponse
+	json.Unmarshal(serve(http.MethodGet, "/users/1/recommendations").Body.Bytes(), &recs)
+	if len(recs.Recommendations) != 4 {
+		t.Errorf("expected the 4 remaining items, got %d", len(recs.Recommendations))
+	}
+	for _, rec := range recs.Recommendations {
+		if rec.ContentID == 1 {
+			t.Error("expected deleted content to be left out of recommendations, featured or not")
+		}
+	}
+
+	var search ContentSearchResponse
+	json.Unmarshal(serve(http.MethodGet, "/content/search?q=Title").Body.Bytes(), &search)
+	for _, c := range search.Results {
+		if c.ID == 1 {
+			t.Error("expected deleted content to be left out of search")
+		}
+	}
+
+	var list ContentListResponse
+	json.Unmarshal(serve(http.MethodGet, "/content").Body.Bytes(), &list)
+	if list.Total != 4 {
+		t.Errorf("expected 4 listed items, got %d", list.Total)
+	}
+
+	// Still in the catalog, but no longer deletable
+	if len(repo.Content) != 5 {
+		t.Errorf("expected the item to be kept, got %d items", len(repo.Content))
+	}
+	if rec := serve(http.MethodDelete, "/content/1"); rec.Code != http.StatusNotFound {
+		t.Errorf("deleting twice: expected 404, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodDelete, "/content/99"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown content: expected 404, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodDelete, "/content/x"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid id: expected 400, got %d", rec.Code)
+	}
+}
+
+func TestParseTrendingWindow(t *testing.T) {
+	valid := map[string]time.Duration{
+		"7d":  7 * 24 * time.Hour,
+		"1d":  24 * time.Hour,
+		"90d": 90 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"1h":  time.Hour,
+	}
+	for raw, want := range valid {
+		if got, ok := parseTrendingWindow(raw); !ok || got != want {
+			t.Errorf("%q: expected %s, got %s ok=%v", raw, want, got, ok)
+		}
+	}
+	for _, raw := range []string{"", "d", "0d", "-1d", "91d", "30m", "2160h1s", "1.5d", "7 days", "99999999999d"} {
+		if _, ok := parseTrendingWindow(raw); ok {
+			t.Errorf("%q: expected invalid window", raw)
+		}
+	}
+}
+
+func TestGetTrendingContent(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(3, 5)
+	now := time.Now()
+	watch := func(userID, contentID int64, at time.Time) {
+		repo.History[userID] = append(repo.History[userID], domain.WatchHistoryItem{ContentID: contentID, WatchedAt: at})
+	}
+	watch(1, 3, now)
+	watch(2, 3, now.AddDate(0, 0, -2))
+	watch(3, 5, now.AddDate(0, 0, -1))
+	watch(1, 1, now.AddDate(0, 0, -20))
+	c := servicetest.NewFakeCache()
+	h := newFakeHandler(t, repo, c)
+
+	get := func(query string) (int, TrendingContentResponse) {
+		rec := httptest.NewRecorder()
+		h.GetTrendingContent(rec, httptest.NewRequest(http.MethodGet, "/content/trending"+query, nil))
+		var resp TrendingContentResponse
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+		}
+		return rec.Code, resp
+	}
+
+	code, resp := get("")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if resp.Window != "7d" || len(resp.Results) != 2 {
+		t.Fatalf("expected 2 items in the default 7d window, got %+v", resp)
+	}
+	if resp.Results[0].ID != 3 || resp.Results[0].RecentWatches != 2 || resp.Results[1].ID != 5 {
+		t.Errorf("expected content 3 (2 watches) then 5, got %+v", resp.Results)
+	}
+
+	// Cached briefly: new watches don't show until the entry expires
+	watch(2, 5, now)
+	watch(3, 5, now)
+	if _, resp = get(""); resp.Results[0].ID != 3 {
+		t.Errorf("expected the cached list, got %+v", resp.Results)
+	}
+
+	if _, resp = get("?window=30d&limit=1"); len(resp.Results) != 1 || resp.Results[0].ID != 5 {
+		t.Errorf("expected content 5 first over 30 days, got %+v", resp.Results)
+	}
+
+	for _, query := range []string{"?window=2w", "?window=0d", "?limit=0", "?limit=51"} {
+		if code, _ := get(query); code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, code)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+// GET /debug/score
+func (h *Handler) GetScoreBreakdown(w http.ResponseWriter, r *http.Request) {
+	// Parse and validate user_id
+	userID, err := strconv.ParseInt(r.URL.Query().Get("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid user_id parameter")
+		return
+	}
+
+	// Parse and validate content_id
+	contentID, err := strconv.ParseInt(r.URL.Query().Get("content_id"), 10, 64)
+	if err != nil || contentID <= 0 {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid content_id parameter")
+		return
+	}
+
+	breakdown, err := h.service.GetScoreBreakdown(r.Context(), userID, contentID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			h.writeError(w, http.StatusNotFound, "user_not_found",
+				fmt.Sprintf("User with ID %d does not exist", userID))
+			return
+		}
+		if errors.Is(err, domain.ErrContentNotFound) {
+			h.writeError(w, http.StatusNotFound, "content_not_found",
+				fmt.Sprintf("Content with ID %d does not exist", contentID))
+			return
+		}
+		h.writeUnexpectedError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, breakdown)
+}
+
+// GET /users/{userID}/recommendations/cached
+func (h *Handler) GetCachedRecommendations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserID(r)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid user_id parameter")
+		return
+	}
+
+	// Same options as the recommendations endpoint, which pick the cache entry
+	query := RecommendationQuery{}
+	if !h.decodeAndValidate(w, r, &query) {
+		return
+	}
+	boosts, ok := parseGenreBoosts(r.URL.Query()["boost"])
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter",
+			fmt.Sprintf("Invalid boost parameter, expected genre:multiplier with a multiplier between %g and %g", minGenreBoost, maxGenreBoost))
+		return
+	}
+
+	result, found, err := h.service.GetCachedRecommendations(r.Context(), userID, domain.RecommendationOptions{
+		Limit:          query.Limit,
+		Normalize:      query.Normalize,
+		Strategy:       query.Strategy,
+		IncludeWatched: query.IncludeWatched,
+		GenreBoosts:    boosts,
+		CandidateOrder: query.CandidateOrder,
+		WithReasons:    query.WithReasons,
+		MaxPerGenre:    query.MaxPerGenre,
+	})
+	if err != nil {
+		h.writeUnexpectedError(w, err)
+		return
+	}
+	if !found {
+		h.writeError(w, http.StatusNotFound, "cache_miss",
+			fmt.Sprintf("No cached recommendations for user %d with these options", userID))
+		return
+	}
+
+	recs := result.Recommendations
+	if query.ScoreFormat == scoreFormatPercentage {
+		recs = scoresAsPercentages(recs)
+	}
+	h.writeJSON(w, http.StatusOK, RecommendationResponse{
+		UserID:          userID,
+		Recommendations: recs,
+		Metadata:        recommendationMeta(result, len(recs)),
+	})
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/actuallystonmai/recommendation-service/internal/service/servicetest"
+)
+
+func TestGetCachedRecommendationsHit(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	repo.AddUsers(1)
+	c := servicetest.NewFakeCache()
+	entry := domain.CachedRecommendations{
+		ModelVersion:    "0.9.0",
+		Recommendations: []domain.ScoredRecommendation{{ContentID: 4, Score: 0.8}, {ContentID: 2, Score: 0.6}},
+	}
+	if err := c.Set(context.Background(), 1, 10, "", entry); err != nil {
+		t.Fatalf("seed cache: %v", err)
+	}
+	h := newFakeHandler(t, repo, c)
+
+	rec := httptest.NewRecorder()
+	h.GetCachedRecommendations(rec, newUserRequest(http.MethodGet, "/users/1/recommendations/cached?limit=10", "1", ""))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp RecommendationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Recommendations) != 2 || resp.Recommendations[0].ContentID != 4 {
+		t.Errorf("expected the cached list, got %+v", resp.Recommendations)
+	}
+	if !resp.Metadata.CacheHit || resp.Metadata.ModelVersion != "0.9.0" {
+		t.Errorf("expected a hit reporting the stored version, got %+v", resp.Metadata)
+	}
+}
+
+func TestGetCachedRecommendationsMiss(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 5)
+	c := servicetest.NewFakeCache()
+	h := newFakeHandler(t, repo, c)
+
+	for _, target := range []string{
+		"/users/1/recommendations/cached",
+		// Another limit or variant is a different entry
+		"/users/1/recommendations/cached?limit=5&normalize=true",
+	} {
+		rec := httptest.NewRecorder()
+		h.GetCachedRecommendations(rec, newUserRequest(http.MethodGet, target, "1", ""))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d: %s", target, rec.Code, rec.Body.String())
+		}
+		var resp ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Error != "cache_miss" {
+			t.Errorf("%s: expected cache_miss, got %s", target, rec.Body.String())
+		}
+	}
+	// Nothing was generated and cached
+	if c.Len() != 0 {
+		t.Errorf("expected the cache to stay empty, got %d entries", c.Len())
+	}
+}
@@ -0,0 +1,38 @@
+package handler
+
+// Standard response wrapper: data on success, errors on failure
+type Envelope struct {
+	Data   any             `json:"data"`
+	Meta   any             `json:"meta,omitempty"`
+	Errors []ErrorResponse `json:"errors,omitempty"`
+}
+
+// Responses carrying metadata that belongs in Envelope.Meta
+type enveloper interface {
+	envelope() (data, meta any)
+}
+
+func envelop(v any) Envelope {
+	switch v := v.(type) {
+	case ErrorResponse:
+		return Envelope{Errors: []ErrorResponse{v}}
+	case enveloper:
+		data, meta := v.envelope()
+		return Envelope{Data: data, Meta: meta}
+	}
+	return Envelope{Data: v}
+}
+
+func (r WatchHistoryListResponse) envelope() (data, meta any) {
+	return struct {
+		UserID int64 `json:"user_id"`
+		Items  any   `json:"items"`
+	}{r.UserID, r.Items}, r.Metadata
+}
+
+func (r RecommendationResponse) envelope() (data, meta any) {
+	return struct {
+		UserID          int64 `json:"user_id"`
+		Recommendations any   `json:"recommendations"`
+	}{r.UserID, r.Recommendations}, r.Metadata
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/actuallystonmai/recommendation-service/internal/service"
+	"github.com/actuallystonmai/recommendation-service/internal/service/servicetest"
+)
+
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]json.RawMessage {
+	t.Helper()
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return body
+}
+
+func getRecommendations(t *testing.T, target string, envelope bool) *httptest.ResponseRecorder {
+	t.Helper()
+	repo := servicetest.NewFakeRepoWith(1, 3)
+	h := NewHandler(newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{}, service.Config{}), Config{Envelope: envelope})
+
+	rec := httptest.NewRecorder()
+	h.GetRecommendations(rec, newUserRequest(http.MethodGet, target, "1", ""))
+	return rec
+}
+
+func TestResponseFlatByDefault(t *testing.T) {
+	rec := getRecommendations(t, "/users/1/recommendations", false)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	body := decodeBody(t, rec)
+	for _, key := range []string{"user_id", "recommendations", "metadata"} {
+		if _, ok := body[key]; !ok {
+			t.Errorf("expected top-level %q, got %s", key, rec.Body.String())
+		}
+	}
+	if _, ok := body["data"]; ok {
+		t.Error("expected no envelope by default")
+	}
+
+	rec = getRecommendations(t, "/users/1/recommendations?limit=abc", false)
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Error != "invalid_parameter" {
+		t.Errorf("expected flat invalid_parameter error, got %s", rec.Body.String())
+	}
+}
+
+func TestResponseEnvelopeSuccess(t *testing.T) {
+	rec := getRecommendations(t, "/users/1/recommendations", true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	body := decodeBody(t, rec)
+	if _, ok := body["errors"]; ok {
+		t.Errorf("expected no errors on success, got %s", body["errors"])
+	}
+
+	var data RecommendationResponse
+	if err := json.Unmarshal(body["data"], &data); err != nil {
+		t.Fatalf("decode data: %v", err)
+	}
+	if data.UserID != 1 || len(data.Recommendations) != 3 {
+		t.Errorf("unexpected data: %s", body["data"])
+	}
+	var meta struct {
+		TotalCount int    `json:"total_count"`
+		Strategy   string `json:"strategy"`
+	}
+	if err := json.Unmarshal(body["meta"], &meta); err != nil {
+		t.Fatalf("decode meta: %v", err)
+	}
+	if meta.TotalCount != 3 || meta.Strategy == "" {
+		t.Errorf("expected recommendation metadata in meta, got %s", body["meta"])
+	}
+}
+
+func TestResponseEnvelopeError(t *testing.T) {
+	rec := getRecommendations(t, "/users/1/recommendations?limit=abc", true)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	body := decodeBody(t, rec)
+	if string(body["data"]) != "null" {
+		t.Errorf("expected null data on error, got %s", body["data"])
+	}
+
+	var errs []ErrorResponse
+	if err := json.Unmarshal(body["errors"], &errs); err != nil {
+		t.Fatalf("decode errors: %v", err)
+	}
+	if len(errs) != 1 || errs[0].Error != "invalid_parameter" || errs[0].Message != "Invalid limit parameter" {
+		t.Errorf("unexpected errors: %s", body["errors"])
+	}
+}
+
+func TestResponseEnvelopeMiddlewareError(t *testing.T) {
+	h := NewHandler(nil, Config{Envelope: true})
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	h.APIKeyAuth([]string{"key-one"})(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/1/recommendations", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+	var errs []ErrorResponse
+	if err := json.Unmarshal(decodeBody(t, rec)["errors"], &errs); err != nil {
+		t.Fatalf("decode errors: %v", err)
+	}
+	if len(errs) != 1 || errs[0].Error != "unauthorized" {
+		t.Errorf("unexpected errors: %s", rec.Body.String())
+	}
+}
@@ -1,31 +1,322 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
 	"github.com/actuallystonmai/recommendation-service/internal/service"
 )
 
+// Request body limit used when none is configured
+const defaultMaxBodyBytes = 64 << 10
+
+type Config struct {
+	// Request body limit; <= 0 uses the 64KB default
+	MaxBodyBytes int64
+	// Wrap every JSON and MessagePack response, success or error, in an
+	// Envelope; off keeps the flat response format
+	Envelope bool
+	// Honour debug-only query overrides such as ignore_popularity_floor
+	DebugEnabled bool
+}
+
 type Handler struct {
-	service *service.Service
+	service      *service.Service
+	maxBodyBytes int64
+	envelope     bool
+	debug        bool
 }
 
-func NewHandler(svc *service.Service) *Handler {
-	return &Handler{service: svc}
+func NewHandler(svc *service.Service, cfg Config) *Handler {
+	return &Handler{service: svc, maxBodyBytes: cfg.MaxBodyBytes, envelope: cfg.Envelope, debug: cfg.DebugEnabled}
 }
 
-// write JSON response
-func writeJSON(w http.ResponseWriter, status int, v any) {
+// write JSON response, enveloped when enabled
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, v any) {
+	if h.envelope {
+		v = envelop(v)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(v)
 }
 
+// write MessagePack response, enveloped when enabled. Fields are named by
+// their json tags so both formats carry the same keys.
+func (h *Handler) writeMsgpack(w http.ResponseWriter, status int, v any) {
+	if h.envelope {
+		v = envelop(v)
+	}
+	w.Header().Set("Content-Type", contentTypeMsgpack)
+	w.WriteHeader(status)
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	enc.Encode(v)
+}
+
+// Media types the API can respond with
+const (
+	contentTypeJSON    = "application/json"
+	contentTypeCSV     = "text/csv"
+	contentTypeMsgpack = "application/msgpack"
+)
+
+// Pick the offer the Accept header ranks highest by q-value. Ties go to the
+// more specific media range, then to offer order. No Accept header, or one
+// matching none of the offers, gets the first offer.
+func negotiateContentType(r *http.Request, offers ...string) string {
+	best, bestQ, bestSpecificity := offers[0], -1.0, -1
+	for _, offer := range offers {
+		q, specificity := acceptQuality(r.Header.Values("Accept"), offer)
+		if q > 0 && (q > bestQ || q == bestQ && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity = offer, q, specificity
+		}
+	}
+	return best
+}
+
+// q-value of the most specific Accept media range matching offer, with its
+// specificity: 2 for an exact type, 1 for type/*, 0 for */*. -1 if none match.
+func acceptQuality(accept []string, offer string) (float64, int) {
+	offerType, _, _ := strings.Cut(offer, "/")
+	q, specificity := 0.0, -1
+	for _, header := range accept {
+		for _, mediaRange := range strings.Split(header, ",") {
+			mediaType, params, _ := strings.Cut(mediaRange, ";")
+			mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+			s := -1
+			switch {
+			case mediaType == offer:
+				s = 2
+			case mediaType == offerType+"/*":
+				s = 1
+			case mediaType == "*/*":
+				s = 0
+			}
+			if s <= specificity {
+				continue
+			}
+			q, specificity = mediaRangeQuality(params), s
+		}
+	}
+	return q, specificity
+}
+
+// q parameter of a media range, 1 when absent or malformed
+func mediaRangeQuality(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(param, "=")
+		if strings.TrimSpace(name) != "q" {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || q < 0 || q > 1 {
+			return 1
+		}
+		return q
+	}
+	return 1
+}
+
 // writes JSON error response.
-func writeError(w http.ResponseWriter, status int, errCode, message string) {
-	writeJSON(w, status, ErrorResponse{
+func (h *Handler) writeError(w http.ResponseWriter, status int, errCode, message string) {
+	h.writeJSON(w, status, ErrorResponse{
 		Error:   errCode,
 		Message: message,
 	})
-}
\ No newline at end of file
+}
+
+// Writes errors without a more specific mapping: an unreachable database or a
+// timeout is a 503, a page past the repository's bounds a 400, anything else a 500
+func (h *Handler) writeUnexpectedError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrDatabaseUnavailable):
+		h.writeError(w, http.StatusServiceUnavailable, "database_unavailable",
+			"Database is temporarily unavailable, please try again")
+	case isContextError(err):
+		h.writeError(w, http.StatusServiceUnavailable, "request_timeout",
+			"Request timed out, please try again")
+	case errors.Is(err, domain.ErrInvalidPage):
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Page is out of range")
+	default:
+		h.writeError(w, http.StatusInternalServerError, "internal_error", "An unexpected error occurred")
+	}
+}
+
+// The request timed out or was cancelled
+func isContextError(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
+
+// Strictly decode a single JSON object from the request body into dst. Unknown
+// fields, trailing data and malformed JSON are a 400, a body over the size limit
+// a 413. Writes the error response and returns false on failure.
+func (h *Handler) decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	return h.decodeBody(w, r, dst, false)
+}
+
+// Like decodeJSON, but an empty body leaves dst untouched
+func (h *Handler) decodeOptionalJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	return h.decodeBody(w, r, dst, true)
+}
+
+func (h *Handler) decodeBody(w http.ResponseWriter, r *http.Request, dst any, optional bool) bool {
+	limit := h.maxBodyBytes
+	if limit <= 0 {
+		limit = defaultMaxBodyBytes
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if errors.Is(err, io.EOF) && optional {
+		return true
+	}
+	if err == nil {
+		// Anything after the first value is rejected
+		if dec.Decode(&struct{}{}) != io.EOF {
+			err = errors.New("trailing data")
+		}
+	}
+	if err == nil {
+		return true
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		h.writeError(w, http.StatusRequestEntityTooLarge, "body_too_large",
+			fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		h.writeError(w, http.StatusBadRequest, "invalid_body",
+			"Request body contains unknown field "+strings.TrimPrefix(err.Error(), "json: unknown field "))
+	default:
+		h.writeError(w, http.StatusBadRequest, "invalid_body", "Request body must be valid JSON")
+	}
+	return false
+}
+
+// parse and validate the userID path parameter
+func parseUserID(r *http.Request) (int64, bool) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || userID <= 0 {
+		return 0, false
+	}
+	return userID, true
+}
+
+// Validates bound request structs; errors report the query parameter name
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return field.Tag.Get("query")
+	})
+	v.RegisterValidation("strategy", func(fl validator.FieldLevel) bool {
+		return domain.Strategy(fl.Field().String()).Valid()
+	})
+	v.RegisterValidation("candidate_order", func(fl validator.FieldLevel) bool {
+		return domain.CandidateOrder(fl.Field().String()).Valid()
+	})
+	v.RegisterValidation("subscription", func(fl validator.FieldLevel) bool {
+		return domain.IsKnownSubscriptionType(fl.Field().String())
+	})
+	v.RegisterValidation("genre", func(fl validator.FieldLevel) bool {
+		return domain.IsValidGenre(fl.Field().String())
+	})
+	v.RegisterValidation("content_sort", func(fl validator.FieldLevel) bool {
+		return domain.ContentSort(fl.Field().String()).Valid()
+	})
+	return v
+}
+
+// Bind query parameters into dst (a pointer to a struct with `query` tags),
+// keeping preset values for absent parameters, then validate it. Writes a 400
+// naming the first invalid parameter on failure.
+func (h *Handler) decodeAndValidate(w http.ResponseWriter, r *http.Request, dst any) bool {
+	param, err := bindQuery(r.URL.Query(), reflect.ValueOf(dst).Elem())
+	if err == nil {
+		if verr := validate.Struct(dst); verr != nil {
+			var fieldErrs validator.ValidationErrors
+			if !errors.As(verr, &fieldErrs) {
+				h.writeError(w, http.StatusInternalServerError, "internal_error", "An unexpected error occurred")
+				return false
+			}
+			param, err = fieldErrs[0].Field(), verr
+		}
+	}
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", fmt.Sprintf("Invalid %s parameter", param))
+		return false
+	}
+	return true
+}
+
+// Set struct fields from their `query` tags, recursing into embedded structs.
+// Returns the parameter name that failed to parse.
+func bindQuery(values url.Values, v reflect.Value) (string, error) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if param, err := bindQuery(values, v.Field(i)); err != nil {
+				return param, err
+			}
+			continue
+		}
+
+		name := field.Tag.Get("query")
+		raw := values.Get(name)
+		if name == "" || raw == "" {
+			continue
+		}
+		if err := setQueryField(v.Field(i), raw); err != nil {
+			return name, err
+		}
+	}
+	return "", nil
+}
+
+func setQueryField(fv reflect.Value, raw string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		fv.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported query field kind %s", fv.Kind())
+	}
+	return nil
+}
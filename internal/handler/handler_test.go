@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeAndValidateBoundaries(t *testing.T) {
+	tests := []struct {
+		target string
+		dst    func() any
+		ok     bool
+		param  string
+	}{
+		{"/?limit=0", func() any { return &RecommendationQuery{} }, true, ""},
+		{"/?limit=-1", func() any { return &RecommendationQuery{} }, false, "limit"},
+		{"/?limit=51", func() any { return &RecommendationQuery{} }, false, "limit"},
+		{"/?limit=1", func() any { return &RecommendationQuery{} }, true, ""},
+		{"/?limit=50", func() any { return &RecommendationQuery{} }, true, ""},
+		{"/?limit=abc", func() any { return &RecommendationQuery{} }, false, "limit"},
+		{"/?normalize=maybe", func() any { return &RecommendationQuery{} }, false, "normalize"},
+		{"/?strategy=random", func() any { return &RecommendationQuery{} }, false, "strategy"},
+		{"/?page=10001", func() any { return &BatchQuery{BatchPageQuery: defaultBatchPage} }, false, "page"},
+		{"/?page=10000", func() any { return &BatchQuery{BatchPageQuery: defaultBatchPage} }, true, ""},
+		{"/?page=0", func() any { return &BatchQuery{BatchPageQuery: defaultBatchPage} }, false, "page"},
+		{"/?limit=101", func() any { return &BatchQuery{BatchPageQuery: defaultBatchPage} }, false, "limit"},
+		{"/?limit=100", func() any { return &BatchQuery{BatchPageQuery: defaultBatchPage} }, true, ""},
+		{"/?status=pending", func() any { return &BatchQuery{BatchPageQuery: defaultBatchPage} }, false, "status"},
+		{"/", func() any { return &BatchQuery{BatchPageQuery: defaultBatchPage} }, true, ""},
+	}
+
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		ok := (&Handler{}).decodeAndValidate(rec, httptest.NewRequest(http.MethodGet, tc.target, nil), tc.dst())
+
+		if ok != tc.ok {
+			t.Errorf("%s: expected ok=%v, got %v", tc.target, tc.ok, ok)
+			continue
+		}
+		if ok {
+			continue
+		}
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", tc.target, rec.Code)
+		}
+		var resp ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: decode response: %v", tc.target, err)
+		}
+		if expected := "Invalid " + tc.param + " parameter"; resp.Error != "invalid_parameter" || resp.Message != expected {
+			t.Errorf("%s: expected %q, got %+v", tc.target, expected, resp)
+		}
+	}
+}
+
+func TestDecodeAndValidateDefaults(t *testing.T) {
+	query := BatchQuery{BatchPageQuery: defaultBatchPage}
+	if !(&Handler{}).decodeAndValidate(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/?page=3", nil), &query) {
+		t.Fatal("expected valid query")
+	}
+	if query.Page != 3 || query.Limit != 20 || query.Status != "" {
+		t.Errorf("expected page 3 with default limit, got %+v", query)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	h := NewHandler(nil, Config{MaxBodyBytes: 64})
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"valid", `{"content_id": 3}`, http.StatusOK, ""},
+		{"malformed", `{"content_id": `, http.StatusBadRequest, "invalid_body"},
+		{"unknown field", `{"content_id": 3, "rating": 5}`, http.StatusBadRequest, "invalid_body"},
+		{"trailing garbage", `{"content_id": 3} xyz`, http.StatusBadRequest, "invalid_body"},
+		{"second object", `{"content_id": 3}{"content_id": 4}`, http.StatusBadRequest, "invalid_body"},
+		{"empty", ``, http.StatusBadRequest, "invalid_body"},
+		{"oversized", `{"content_id": 3, "padding": "` + strings.Repeat("x", 100) + `"}`, http.StatusRequestEntityTooLarge, "body_too_large"},
+	}
+
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		var dst AddWatchHistoryRequest
+		ok := h.decodeJSON(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body)), &dst)
+
+		if tc.status == http.StatusOK {
+			if !ok || dst.ContentID != 3 {
+				t.Errorf("%s: expected content_id 3, got ok=%v dst=%+v", tc.name, ok, dst)
+			}
+			continue
+		}
+		if ok || rec.Code != tc.status {
+			t.Errorf("%s: expected %d, got ok=%v status=%d", tc.name, tc.status, ok, rec.Code)
+			continue
+		}
+		var resp ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: decode response: %v", tc.name, err)
+		}
+		if resp.Error != tc.code {
+			t.Errorf("%s: expected %s, got %q", tc.name, tc.code, resp.Error)
+		}
+	}
+}
+
+func TestDecodeOptionalJSONEmptyBody(t *testing.T) {
+	h := NewHandler(nil, Config{})
+	dst := AddWatchHistoryRequest{ContentID: 7}
+
+	rec := httptest.NewRecorder()
+	if !h.decodeOptionalJSON(rec, httptest.NewRequest(http.MethodPost, "/", nil), &dst) {
+		t.Fatalf("expected an empty body to be accepted, got %d", rec.Code)
+	}
+	if dst.ContentID != 7 {
+		t.Errorf("expected preset value to be kept, got %d", dst.ContentID)
+	}
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	cases := []struct {
+		accept string
+		want   string
+	}{
+		{"", contentTypeJSON},
+		{"*/*", contentTypeJSON},
+		{"text/csv", contentTypeCSV},
+		{"TEXT/CSV", contentTypeCSV},
+		{"text/*", contentTypeCSV},
+		{"text/csv, */*", contentTypeCSV},
+		{"text/csv;q=0.5, application/json", contentTypeJSON},
+		{"application/json;q=0.2, text/csv;q=0.8", contentTypeCSV},
+		{"text/csv;q=0", contentTypeJSON},
+		{"text/html", contentTypeJSON},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		if got := negotiateContentType(req, contentTypeJSON, contentTypeCSV); got != tc.want {
+			t.Errorf("Accept %q: expected %s, got %s", tc.accept, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type RateLimiter interface {
+	Allow(ctx context.Context, key string) (bool, time.Duration, error)
+}
+
+// Reject clients over the limiter's quota with 429 and a Retry-After header.
+// With keyByAPIKey, set when APIKeyAuth is enabled and so has checked the
+// key, clients are identified by API key; otherwise by remote IP, since an
+// unchecked header would let a client dodge the limit by varying it.
+// Limiter errors let the request through rather than failing it.
+func (h *Handler) RateLimit(limiter RateLimiter, keyByAPIKey bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, retryAfter, err := limiter.Allow(r.Context(), rateLimitClient(r, keyByAPIKey))
+			if err != nil {
+				log.Printf("[handler] rate limit check failed, allowing request: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(retryAfter.Seconds())))))
+				h.writeError(w, http.StatusTooManyRequests, "rate_limited", "Too many requests, please retry later")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func rateLimitClient(r *http.Request, keyByAPIKey bool) string {
+	// Hashed so API keys are not stored in Redis key names
+	if key := r.Header.Get("X-API-Key"); keyByAPIKey && key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:8])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeLimiter struct {
+	allowed    bool
+	retryAfter time.Duration
+	err        error
+	keys       []string
+}
+
+func (f *fakeLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	f.keys = append(f.keys, key)
+	return f.allowed, f.retryAfter, f.err
+}
+
+func serveRateLimited(limiter RateLimiter, req *http.Request) *httptest.ResponseRecorder {
+	return serveRateLimitedByKey(limiter, req, true)
+}
+
+func serveRateLimitedByKey(limiter RateLimiter, req *http.Request, keyByAPIKey bool) *httptest.ResponseRecorder {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	rec := httptest.NewRecorder()
+	(&Handler{}).RateLimit(limiter, keyByAPIKey)(next).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimitRejectsWithRetryAfter(t *testing.T) {
+	limiter := &fakeLimiter{retryAfter: 1500 * time.Millisecond}
+	rec := serveRateLimited(limiter, httptest.NewRequest(http.MethodGet, "/recommendations/batch", nil))
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("expected Retry-After rounded up to 2, got %q", got)
+	}
+}
+
+func TestRateLimitAllows(t *testing.T) {
+	limiter := &fakeLimiter{allowed: true}
+	req := httptest.NewRequest(http.MethodGet, "/recommendations/batch", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	if rec := serveRateLimited(limiter, req); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	// Clients are keyed by API key when one is sent, without the raw key
+	req.Header.Set("X-API-Key", "secret")
+	serveRateLimited(limiter, req)
+	if limiter.keys[0] != "ip:10.0.0.1" {
+		t.Errorf("expected ip key, got %q", limiter.keys[0])
+	}
+	if limiter.keys[1] == "key:secret" || limiter.keys[1][:4] != "key:" {
+		t.Errorf("expected hashed api key, got %q", limiter.keys[1])
+	}
+}
+
+func TestRateLimitIgnoresAPIKeyWithoutAuth(t *testing.T) {
+	limiter := &fakeLimiter{allowed: true}
+	for _, key := range []string{"a", "b"} {
+		req := httptest.NewRequest(http.MethodGet, "/recommendations/batch", nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		req.Header.Set("X-API-Key", key)
+		serveRateLimitedByKey(limiter, req, false)
+	}
+	// Unchecked keys can't split one client into many
+	for i, key := range limiter.keys {
+		if key != "ip:10.0.0.1" {
+			t.Errorf("request %d: expected ip key, got %q", i, key)
+		}
+	}
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	limiter := &fakeLimiter{err: errors.New("redis down")}
+	if rec := serveRateLimited(limiter, httptest.NewRequest(http.MethodGet, "/", nil)); rec.Code != http.StatusOK {
+		t.Errorf("expected limiter errors to let the request through, got %d", rec.Code)
+	}
+}
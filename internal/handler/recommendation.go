@@ -1,71 +1,274 @@
 package handler
 
 import (
-	"context"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/actuallystonmai/recommendation-service/internal/domain"
-	"github.com/go-chi/chi/v5"
+)
+
+const (
+	minGenreBoost = 0.1
+	maxGenreBoost = 5.0
+	// Most items a Range header may request, matching the limit parameter
+	maxRangeItems = 50
+	// score_format value for whole-number percentage scores
+	scoreFormatPercentage = "percentage"
 )
 
 // GET /users/{userID}/recommendations
 func (h *Handler) GetRecommendations(w http.ResponseWriter, r *http.Request) {
 	// Parse and validate user_id
-	userIDStr := chi.URLParam(r, "userID")
-	userID, err := strconv.ParseInt(userIDStr, 10, 64)
-	if err != nil || userID <= 0 {
-		writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid user_id parameter")
+	userID, ok := parseUserID(r)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid user_id parameter")
+		return
+	}
+
+	// Parse and validate query parameters. When strategy is absent the service
+	// assigns one by experiment bucket
+	query := RecommendationQuery{}
+	if !h.decodeAndValidate(w, r, &query) {
+		return
+	}
+	// Bypassing the floor is for admin tooling, so it needs debug mode like
+	// the debug endpoints
+	if query.IgnorePopularityFloor && !h.debug {
+		h.writeError(w, http.StatusForbidden, "forbidden",
+			"ignore_popularity_floor requires DEBUG_ENDPOINTS_ENABLED")
+		return
+	}
+	boosts, ok := parseGenreBoosts(r.URL.Query()["boost"])
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter",
+			fmt.Sprintf("Invalid boost parameter, expected genre:multiplier with a multiplier between %g and %g", minGenreBoost, maxGenreBoost))
 		return
 	}
 
-	// Parse and validate limit
-	limit := 10
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		parsed, err := strconv.Atoi(limitStr)
-		if err != nil || parsed < 1 || parsed > 50 {
-			writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid limit parameter")
+	weights := scoreWeights(r.URL.Query(), query)
+	if weights != nil && !weights.Valid() {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter",
+			fmt.Sprintf("Invalid weights, w_popularity, w_genre and w_recency must sum to 1 (within %g)", domain.ScoreWeightsTolerance))
+		return
+	}
+
+	// An offset pages through the full scored list
+	paged := r.URL.Query().Has("offset")
+
+	// A Range header selects items when no limit or offset parameter is given.
+	// It pages the full scored list like offset, which gives the real total
+	var itemRange *itemRange
+	if header := r.Header.Get("Range"); header != "" && r.URL.Query().Get("limit") == "" && !paged {
+		parsed, ok := parseItemRange(header)
+		if !ok {
+			h.writeError(w, http.StatusBadRequest, "invalid_range",
+				fmt.Sprintf("Invalid Range header, expected items=<start>-<end> with 0 <= start <= end and at most %d items", maxRangeItems))
 			return
 		}
-		limit = parsed
+		itemRange = &parsed
+		paged = true
+		query.Offset = parsed.start
+		query.Limit = parsed.end - parsed.start + 1
 	}
+	w.Header().Set("Accept-Ranges", "items")
+	w.Header().Set("Vary", "Accept")
 
-	result, err := h.service.GetRecommendations(r.Context(), userID, limit)
+	result, err := h.service.GetRecommendations(r.Context(), userID, domain.RecommendationOptions{
+		Limit:                 query.Limit,
+		Normalize:             query.Normalize,
+		Strategy:              query.Strategy,
+		IncludeWatched:        query.IncludeWatched,
+		GenreBoosts:           boosts,
+		CandidateOrder:        query.CandidateOrder,
+		WithReasons:           query.WithReasons,
+		IgnorePopularityFloor: query.IgnorePopularityFloor,
+		MaxPerGenre:           query.MaxPerGenre,
+		Weights:               weights,
+		Paged:                 paged,
+		Offset:                query.Offset,
+	})
 	if err != nil {
-		// User not found
-		if errors.Is(err, domain.ErrUserNotFound) {
-			writeError(w, http.StatusNotFound, "user_not_found",
-				fmt.Sprintf("User with ID %d does not exist", userID))
-			return
-		}
-		// Model inference failure
-		if errors.Is(err, domain.ErrModelUnavailable) {
-			writeError(w, http.StatusServiceUnavailable, "model_unavailable",
-				"Recommendation model is temporarily unavailable")
-			return
-		}
-		// Request timeout
-		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
-			writeError(w, http.StatusServiceUnavailable, "request_timeout",
-				"Request timed out, please try again")
+		h.writeRecommendationError(w, err, userID)
+		return
+	}
+
+	recs := result.Recommendations
+	status := http.StatusOK
+	if itemRange != nil {
+		if len(recs) == 0 {
+			w.Header().Set("Content-Range", fmt.Sprintf("items */%d", result.Total))
+			h.writeError(w, http.StatusRequestedRangeNotSatisfiable, "range_not_satisfiable",
+				fmt.Sprintf("Range starts past the %d available recommendations", result.Total))
 			return
 		}
-		writeError(w, http.StatusInternalServerError, "internal_error", "An unexpected error occurred")
-		return
+		end := itemRange.start + len(recs) - 1
+		w.Header().Set("Content-Range", fmt.Sprintf("items %d-%d/%d", itemRange.start, end, result.Total))
+		status = http.StatusPartialContent
+	}
+	if query.ScoreFormat == scoreFormatPercentage {
+		recs = scoresAsPercentages(recs)
 	}
 
 	resp := RecommendationResponse{
 		UserID:          userID,
-		Recommendations: result.Recommendations,
+		Recommendations: recs,
+		Metadata:        recommendationMeta(result, len(recs)),
+	}
+	// Errors stay JSON; only a successful body is offered as MessagePack
+	if negotiateContentType(r, contentTypeJSON, contentTypeMsgpack) == contentTypeMsgpack {
+		h.writeMsgpack(w, status, resp)
+		return
+	}
+	h.writeJSON(w, status, resp)
+}
+
+// GET /users/{userID}/recommendations/by-genre
+//
+// Genres come back as an array of {genre, weight, recommendations} ordered by
+// the user's preference, rather than a map of genre to list, since JSON object
+// keys carry no order.
+func (h *Handler) GetRecommendationsByGenre(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserID(r)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid user_id parameter")
+		return
+	}
+	query := GenreRecommendationQuery{PerGenre: 5}
+	if !h.decodeAndValidate(w, r, &query) {
+		return
+	}
+
+	result, err := h.service.GetRecommendationsByGenre(r.Context(), userID, query.PerGenre)
+	if err != nil {
+		h.writeRecommendationError(w, err, userID)
+		return
+	}
+
+	count := 0
+	for _, g := range result.Genres {
+		count += len(g.Recommendations)
+	}
+	h.writeJSON(w, http.StatusOK, GenreRecommendationResponse{
+		UserID: userID,
+		Genres: result.Genres,
 		Metadata: domain.RecommendationMeta{
-			CacheHit:    result.CacheHit,
-			GeneratedAt: time.Now().UTC().Format(time.RFC3339),
-			TotalCount:  len(result.Recommendations),
+			GeneratedAt:      time.Now().UTC().Format(time.RFC3339),
+			TotalCount:       count,
+			Strategy:         string(result.Strategy),
+			ExperimentBucket: result.ExperimentBucket,
+			Degraded:         result.Degraded,
+			Truncated:        result.Truncated,
+			ModelVersion:     result.ModelVersion,
+			ExhaustedCatalog: result.ExhaustedCatalog,
 		},
+	})
+}
+
+// Map recommendation errors to HTTP responses
+func (h *Handler) writeRecommendationError(w http.ResponseWriter, err error, userID int64) {
+	if errors.Is(err, domain.ErrUserNotFound) {
+		h.writeError(w, http.StatusNotFound, "user_not_found",
+			fmt.Sprintf("User with ID %d does not exist", userID))
+		return
+	}
+	// Model inference failure, unless the request itself timed out or was
+	// cancelled
+	if errors.Is(err, domain.ErrModelUnavailable) && !isContextError(err) {
+		h.writeError(w, http.StatusServiceUnavailable, "model_unavailable",
+			"Recommendation model is temporarily unavailable")
+		return
 	}
+	h.writeUnexpectedError(w, err)
+}
+
+// Response metadata for a result of which count items are returned
+func recommendationMeta(result *domain.RecommendationResult, count int) domain.RecommendationMeta {
+	return domain.RecommendationMeta{
+		CacheHit:         result.CacheHit,
+		GeneratedAt:      time.Now().UTC().Format(time.RFC3339),
+		TotalCount:       count,
+		Strategy:         string(result.Strategy),
+		ExperimentBucket: result.ExperimentBucket,
+		Degraded:         result.Degraded,
+		Truncated:        result.Truncated,
+		ModelVersion:     result.ModelVersion,
+		ExhaustedCatalog: result.ExhaustedCatalog,
+	}
+}
 
-	writeJSON(w, http.StatusOK, resp)
+// Copy of recs with each score as a whole percentage of the best possible
+// score of 1.0, clamped to 0-100. The input, which may be a cached payload, is
+// left raw.
+func scoresAsPercentages(recs []domain.ScoredRecommendation) []domain.ScoredRecommendation {
+	formatted := make([]domain.ScoredRecommendation, len(recs))
+	for i, rec := range recs {
+		rec.Score = math.Round(min(max(rec.Score, 0), 1) * 100)
+		formatted[i] = rec
+	}
+	return formatted
+}
+
+// Inclusive item bounds from a Range header
+type itemRange struct {
+	start, end int
+}
+
+// Parse a single "items=<start>-<end>" range of at most maxRangeItems items
+func parseItemRange(header string) (itemRange, bool) {
+	spec, found := strings.CutPrefix(header, "items=")
+	if !found {
+		return itemRange{}, false
+	}
+	rawStart, rawEnd, found := strings.Cut(spec, "-")
+	if !found {
+		return itemRange{}, false
+	}
+	start, err := strconv.Atoi(rawStart)
+	if err != nil {
+		return itemRange{}, false
+	}
+	end, err := strconv.Atoi(rawEnd)
+	if err != nil || start < 0 || end < start || end-start >= maxRangeItems {
+		return itemRange{}, false
+	}
+	return itemRange{start: start, end: end}, true
+}
+
+// Weight overrides from the query, nil when none of them are given
+func scoreWeights(values url.Values, query RecommendationQuery) *domain.ScoreWeights {
+	if !values.Has("w_popularity") && !values.Has("w_genre") && !values.Has("w_recency") {
+		return nil
+	}
+	return &domain.ScoreWeights{Popularity: query.WPopularity, Genre: query.WGenre, Recency: query.WRecency}
+}
+
+// Parse repeated genre:multiplier boost values. Each genre must be known and
+// appear once; returns nil when no boosts are given.
+func parseGenreBoosts(values []string) (map[string]float64, bool) {
+	if len(values) == 0 {
+		return nil, true
+	}
+	boosts := make(map[string]float64, len(values))
+	for _, v := range values {
+		genre, raw, found := strings.Cut(v, ":")
+		if !found || !domain.IsValidGenre(genre) {
+			return nil, false
+		}
+		if _, dup := boosts[genre]; dup {
+			return nil, false
+		}
+		multiplier, err := strconv.ParseFloat(raw, 64)
+		// Negated so NaN is rejected too
+		if err != nil || !(multiplier >= minGenreBoost && multiplier <= maxGenreBoost) {
+			return nil, false
+		}
+		boosts[genre] = multiplier
+	}
+	return boosts, true
 }
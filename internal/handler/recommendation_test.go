@@ -0,0 +1,682 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/actuallystonmai/recommendation-service/internal/service"
+	"github.com/actuallystonmai/recommendation-service/internal/service/servicetest"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestGetRecommendationsQueryTimeout(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	repo.AddUsers(1)
+	repo.Errors["GetUserByID"] = fmt.Errorf("query user id=1: %w", context.DeadlineExceeded)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	rec := httptest.NewRecorder()
+	h.GetRecommendations(rec, newUserRequest(http.MethodGet, "/users/1/recommendations", "1", ""))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Error != "request_timeout" {
+		t.Errorf("expected request_timeout, got %q", resp.Error)
+	}
+}
+
+func TestGetRecommendationsUserIDOutOfRange(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	repo.AddUsers(3)
+	// Any user query fails, so a 404 proves the ID was rejected before one
+	repo.Errors["GetUserByID"] = errors.New("unexpected user query")
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	rec := httptest.NewRecorder()
+	h.GetRecommendations(rec, newUserRequest(http.MethodGet, "/users/9223372036854775807/recommendations", "9223372036854775807", ""))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Error != "user_not_found" {
+		t.Errorf("expected user_not_found, got %q", resp.Error)
+	}
+}
+
+func TestGetRecommendationsStrategy(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 5)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	tests := []struct {
+		query    string
+		code     int
+		strategy string
+	}{
+		{"", http.StatusOK, "genre_weighted"},
+		{"?strategy=recency_first", http.StatusOK, "recency_first"},
+		{"?strategy=popularity_only", http.StatusOK, "popularity_only"},
+		{"?strategy=random", http.StatusBadRequest, ""},
+	}
+
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		h.GetRecommendations(rec, newUserRequest(http.MethodGet, "/users/1/recommendations"+tc.query, "1", ""))
+
+		if rec.Code != tc.code {
+			t.Errorf("%q: expected %d, got %d", tc.query, tc.code, rec.Code)
+			continue
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+		var resp RecommendationResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.Metadata.Strategy != tc.strategy {
+			t.Errorf("%q: expected strategy %q, got %q", tc.query, tc.strategy, resp.Metadata.Strategy)
+		}
+	}
+}
+
+func TestGetRecommendationsIgnorePopularityFloorNeedsDebug(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 5)
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{}, service.Config{MinPopularity: 0.5})
+	target := "/users/1/recommendations?ignore_popularity_floor=true"
+
+	rec := httptest.NewRecorder()
+	NewHandler(svc, Config{}).GetRecommendations(rec, newUserRequest(http.MethodGet, target, "1", ""))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without debug mode, got %d", rec.Code)
+	}
+	var errResp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &errResp); err != nil || errResp.Error != "forbidden" {
+		t.Errorf("expected forbidden, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	NewHandler(svc, Config{DebugEnabled: true}).GetRecommendations(rec, newUserRequest(http.MethodGet, target, "1", ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 in debug mode, got %d", rec.Code)
+	}
+	var resp RecommendationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	// Items 4 and 5 sit below the 0.5 floor
+	if len(resp.Recommendations) != 5 {
+		t.Errorf("expected all 5 items with the floor bypassed, got %d", len(resp.Recommendations))
+	}
+}
+
+func TestGetRecommendationsCandidateOrder(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 5)
+	// Least popular item is also the newest
+	repo.Content[4].CreatedAt = time.Now()
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	tests := []struct {
+		query string
+		code  int
+		first int64
+	}{
+		{"?limit=1", http.StatusOK, 1},
+		{"?limit=1&candidate_order=popularity", http.StatusOK, 1},
+		{"?limit=1&candidate_order=recency", http.StatusOK, 5},
+		{"?limit=1&candidate_order=random", http.StatusOK, 0},
+		{"?limit=1&candidate_order=oldest", http.StatusBadRequest, 0},
+	}
+
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		h.GetRecommendations(rec, newUserRequest(http.MethodGet, "/users/1/recommendations"+tc.query, "1", ""))
+
+		if rec.Code != tc.code {
+			t.Errorf("%q: expected %d, got %d", tc.query, tc.code, rec.Code)
+			continue
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+		var resp RecommendationResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if len(resp.Recommendations) != 1 {
+			t.Fatalf("%q: expected 1 recommendation, got %d", tc.query, len(resp.Recommendations))
+		}
+		if tc.first != 0 && resp.Recommendations[0].ContentID != tc.first {
+			t.Errorf("%q: expected content %d first, got %d", tc.query, tc.first, resp.Recommendations[0].ContentID)
+		}
+	}
+}
+
+func TestParseGenreBoosts(t *testing.T) {
+	boosts, ok := parseGenreBoosts([]string{"sci-fi:1.5", "drama:0.1", "comedy:5"})
+	if !ok || len(boosts) != 3 || boosts["sci-fi"] != 1.5 || boosts["drama"] != 0.1 || boosts["comedy"] != 5 {
+		t.Errorf("unexpected boosts %v (ok=%v)", boosts, ok)
+	}
+
+	if boosts, ok := parseGenreBoosts(nil); !ok || boosts != nil {
+		t.Errorf("expected no boosts, got %v (ok=%v)", boosts, ok)
+	}
+
+	for _, v := range []string{"sci-fi", "western:2", "sci-fi:0.05", "sci-fi:5.1", "sci-fi:abc", "sci-fi:NaN", ":2"} {
+		if _, ok := parseGenreBoosts([]string{v}); ok {
+			t.Errorf("%q: expected invalid boost", v)
+		}
+	}
+	if _, ok := parseGenreBoosts([]string{"drama:2", "drama:3"}); ok {
+		t.Error("expected duplicate genre to be rejected")
+	}
+}
+
+func TestGetRecommendationsInvalidBoost(t *testing.T) {
+	h := &Handler{}
+
+	rec := httptest.NewRecorder()
+	h.GetRecommendations(rec, newUserRequest(http.MethodGet, "/users/1/recommendations?boost=sci-fi:9", "1", ""))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestGetRecommendationsWeights(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 10)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	tests := []struct {
+		query string
+		code  int
+	}{
+		{"?w_popularity=0.2&w_genre=0.6&w_recency=0.2", http.StatusOK},
+		// Within the tolerance of 1
+		{"?w_popularity=0.333&w_genre=0.333&w_recency=0.333", http.StatusOK},
+		{"?w_popularity=1", http.StatusOK},
+		{"?w_popularity=0.5&w_genre=0.6&w_recency=0.2", http.StatusBadRequest},
+		{"?w_genre=0.5", http.StatusBadRequest},
+		{"?w_popularity=-0.2&w_genre=1.2", http.StatusBadRequest},
+		{"?w_popularity=NaN&w_genre=1", http.StatusBadRequest},
+		{"?w_recency=high", http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		h.GetRecommendations(rec, newUserRequest(http.MethodGet, "/users/1/recommendations"+tc.query, "1", ""))
+		if rec.Code != tc.code {
+			t.Errorf("%q: expected %d, got %d: %s", tc.query, tc.code, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestGetRecommendationsLimit(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 60)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	tests := []struct {
+		query string
+		code  int
+		count int
+	}{
+		{"", http.StatusOK, 10},
+		{"?limit=0", http.StatusOK, 10},
+		{"?limit=1", http.StatusOK, 1},
+		{"?limit=50", http.StatusOK, 50},
+		{"?limit=51", http.StatusBadRequest, 0},
+		{"?limit=-1", http.StatusBadRequest, 0},
+	}
+
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		h.GetRecommendations(rec, newUserRequest(http.MethodGet, "/users/1/recommendations"+tc.query, "1", ""))
+
+		if rec.Code != tc.code {
+			t.Errorf("%q: expected %d, got %d", tc.query, tc.code, rec.Code)
+			continue
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+		var resp RecommendationResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if len(resp.Recommendations) != tc.count {
+			t.Errorf("%q: expected %d recommendations, got %d", tc.query, tc.count, len(resp.Recommendations))
+		}
+	}
+}
+
+func TestGetRecommendationsDegradedOnHistoryError(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 5)
+	repo.Errors["GetUserWatchHistoryWithGenres"] = errors.New("connection reset")
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	rec := httptest.NewRecorder()
+	h.GetRecommendations(rec, newUserRequest(http.MethodGet, "/users/1/recommendations", "1", ""))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp RecommendationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !resp.Metadata.Degraded || resp.Metadata.Strategy != "popularity_only" {
+		t.Errorf("expected degraded popularity_only metadata, got %+v", resp.Metadata)
+	}
+	if len(resp.Recommendations) != 5 {
+		t.Errorf("expected 5 recommendations, got %d", len(resp.Recommendations))
+	}
+}
+
+func TestGetRecommendationsDatabaseUnavailable(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	repo.AddUsers(1)
+	repo.Errors["GetUserByID"] = &domain.RepositoryError{
+		Op: "query user id=1", Err: errors.New("connection refused"), Unavailable: true,
+	}
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	rec := httptest.NewRecorder()
+	h.GetRecommendations(rec, newUserRequest(http.MethodGet, "/users/1/recommendations", "1", ""))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Error != "database_unavailable" {
+		t.Errorf("expected database_unavailable, got %q", resp.Error)
+	}
+
+	// Other repository failures stay 500
+	repo.Errors["GetUserByID"] = &domain.RepositoryError{Op: "query user id=1", Err: errors.New("syntax error")}
+	rec = httptest.NewRecorder()
+	h.GetRecommendations(rec, newUserRequest(http.MethodGet, "/users/1/recommendations", "1", ""))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 for a query error, got %d", rec.Code)
+	}
+}
+
+func TestGetRecommendationsContentMetadata(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 3)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	rec := httptest.NewRecorder()
+	h.GetRecommendations(rec, newUserRequest(http.MethodGet, "/users/1/recommendations", "1", ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var resp RecommendationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Recommendations) == 0 {
+		t.Fatal("expected recommendations")
+	}
+	for _, r := range resp.Recommendations {
+		if r.DurationMinutes != 90+int(r.ContentID) || r.ReleaseYear != 2000+int(r.ContentID) {
+			t.Errorf("content %d: expected metadata from the catalog, got %d min / %d", r.ContentID, r.DurationMinutes, r.ReleaseYear)
+		}
+	}
+}
+
+func TestParseItemRange(t *testing.T) {
+	tests := []struct {
+		header string
+		ok     bool
+		start  int
+		end    int
+	}{
+		{"items=0-9", true, 0, 9},
+		{"items=5-5", true, 5, 5},
+		{"items=10-49", true, 10, 49},
+		{"items=100-149", true, 100, 149},
+		{"items=0-50", false, 0, 0},
+		{"items=9-0", false, 0, 0},
+		{"items=-1-5", false, 0, 0},
+		{"items=0-", false, 0, 0},
+		{"items=a-b", false, 0, 0},
+		{"bytes=0-9", false, 0, 0},
+		{"items=0-4,6-9", false, 0, 0},
+	}
+	for _, tc := range tests {
+		got, ok := parseItemRange(tc.header)
+		if ok != tc.ok || (ok && (got.start != tc.start || got.end != tc.end)) {
+			t.Errorf("%q: expected ok=%v %d-%d, got ok=%v %+v", tc.header, tc.ok, tc.start, tc.end, ok, got)
+		}
+	}
+}
+
+func TestGetRecommendationsRange(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 30)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+	get := func(target, rangeHeader string) (*httptest.ResponseRecorder, RecommendationResponse) {
+		req := newUserRequest(http.MethodGet, target, "1", "")
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		rec := httptest.NewRecorder()
+		h.GetRecommendations(rec, req)
+		var resp RecommendationResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp
+	}
+
+	rec, resp := get("/users/1/recommendations", "items=5-9")
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("expected 206, got %d", rec.Code)
+	}
+	// The total is the length of the full scored list, even for a full page
+	if got := rec.Header().Get("Content-Range"); got != "items 5-9/30" {
+		t.Errorf("expected Content-Range items 5-9/30, got %q", got)
+	}
+	// FakeScorer keeps candidate order, so items 5-9 are content 6-10
+	if len(resp.Recommendations) != 5 || resp.Recommendations[0].ContentID != 6 || resp.Metadata.TotalCount != 5 {
+		t.Errorf("expected content 6-10, got %+v", resp.Recommendations)
+	}
+
+	// Later ranges are sliced from the same cached list
+	rec, resp = get("/users/1/recommendations", "items=10-19")
+	if rec.Header().Get("Content-Range") != "items 10-19/30" || !resp.Metadata.CacheHit || resp.Recommendations[0].ContentID != 11 {
+		t.Errorf("expected cached items 10-19/30 from content 11, got %q %+v", rec.Header().Get("Content-Range"), resp)
+	}
+
+	// A range past the available items is truncated and reports the total
+	rec, resp = get("/users/1/recommendations", "items=25-49")
+	if rec.Code != http.StatusPartialContent || rec.Header().Get("Content-Range") != "items 25-29/30" {
+		t.Errorf("expected 206 items 25-29/30, got %d %q", rec.Code, rec.Header().Get("Content-Range"))
+	}
+	if len(resp.Recommendations) != 5 {
+		t.Errorf("expected 5 recommendations, got %d", len(resp.Recommendations))
+	}
+
+	// Nothing left to return
+	rec, _ = get("/users/1/recommendations", "items=40-49")
+	if rec.Code != http.StatusRequestedRangeNotSatisfiable || rec.Header().Get("Content-Range") != "items */30" {
+		t.Errorf("expected 416 items */30, got %d %q", rec.Code, rec.Header().Get("Content-Range"))
+	}
+
+	for _, header := range []string{"items=9-0", "items=0-50", "bytes=0-9"} {
+		rec, _ = get("/users/1/recommendations", header)
+		var errResp ErrorResponse
+		json.Unmarshal(rec.Body.Bytes(), &errResp)
+		if rec.Code != http.StatusBadRequest || errResp.Error != "invalid_range" {
+			t.Errorf("%q: expected 400 invalid_range, got %d %q", header, rec.Code, errResp.Error)
+		}
+	}
+
+	// The limit parameter wins over the header, including an invalid one
+	for _, header := range []string{"items=5-9", "items=9-0"} {
+		rec, resp = get("/users/1/recommendations?limit=3", header)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Range") != "" {
+			t.Errorf("%q: expected 200 without Content-Range, got %d %q", header, rec.Code, rec.Header().Get("Content-Range"))
+		}
+		if len(resp.Recommendations) != 3 || resp.Recommendations[0].ContentID != 1 {
+			t.Errorf("%q: expected the first 3 recommendations, got %+v", header, resp.Recommendations)
+		}
+	}
+}
+
+func TestGetRecommendationsScoreFormat(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 5)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	get := func(query string) (int, []float64) {
+		rec := httptest.NewRecorder()
+		h.GetRecommendations(rec, newUserRequest(http.MethodGet, "/users/1/recommendations"+query, "1", ""))
+		if rec.Code != http.StatusOK {
+			return rec.Code, nil
+		}
+		var resp RecommendationResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		var scores []float64
+		for _, r := range resp.Recommendations {
+			scores = append(scores, r.Score)
+		}
+		return rec.Code, scores
+	}
+
+	// The fake scores 1, 1/2, 1/3, ...; percentages are whole numbers
+	_, percent := get("?limit=4&score_format=percentage")
+	if fmt.Sprint(percent) != "[100 50 33 25]" {
+		t.Errorf("expected percentage scores [100 50 33 25], got %v", percent)
+	}
+
+	// The cached payload stays raw, and raw is the default
+	for _, query := range []string{"?limit=4", "?limit=4&score_format=raw"} {
+		_, raw := get(query)
+		if len(raw) != 4 || raw[0] != 1 || raw[1] != 0.5 || raw[2] != 1.0/3 {
+			t.Errorf("%q: expected raw scores, got %v", query, raw)
+		}
+	}
+
+	if code, _ := get("?score_format=fraction"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown score_format, got %d", code)
+	}
+}
+
+func TestScoresAsPercentagesClamps(t *testing.T) {
+	recs := []domain.ScoredRecommendation{{ContentID: 1, Score: 1.2}, {ContentID: 2, Score: 0.875}, {ContentID: 3, Score: -0.01}}
+	formatted := scoresAsPercentages(recs)
+
+	var scores []float64
+	for _, r := range formatted {
+		scores = append(scores, r.Score)
+	}
+	if fmt.Sprint(scores) != "[100 88 0]" {
+		t.Errorf("expected [100 88 0], got %v", scores)
+	}
+	if recs[1].Score != 0.875 {
+		t.Error("expected the input scores to be left raw")
+	}
+}
+
+func TestGetRecommendationsMsgpack(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 5)
+	c := servicetest.NewFakeCache()
+	h := newFakeHandler(t, repo, c)
+
+	req := newUserRequest(http.MethodGet, "/users/1/recommendations?limit=3", "1", "")
+	req.Header.Set("Accept", "application/msgpack")
+	rec := httptest.NewRecorder()
+	h.GetRecommendations(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/msgpack" {
+		t.Errorf("expected msgpack content type, got %q", ct)
+	}
+	if vary := rec.Header().Get("Vary"); vary != "Accept" {
+		t.Errorf("expected Vary: Accept, got %q", vary)
+	}
+
+	var resp RecommendationResponse
+	dec := msgpack.NewDecoder(rec.Body)
+	dec.SetCustomStructTag("json")
+	if err := dec.Decode(&resp); err != nil {
+		t.Fatalf("decode msgpack: %v", err)
+	}
+	if resp.UserID != 1 || len(resp.Recommendations) != 3 || resp.Metadata.TotalCount != 3 {
+		t.Errorf("unexpected msgpack response: %+v", resp)
+	}
+
+	// Same payload as JSON, now served from the JSON-encoded cache entry
+	rec = httptest.NewRecorder()
+	h.GetRecommendations(rec, newUserRequest(http.MethodGet, "/users/1/recommendations?limit=3", "1", ""))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON by default, got %q", ct)
+	}
+	var jsonResp RecommendationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &jsonResp); err != nil {
+		t.Fatalf("decode json: %v", err)
+	}
+	if !jsonResp.Metadata.CacheHit || !reflect.DeepEqual(jsonResp.Recommendations, resp.Recommendations) {
+		t.Errorf("expected the cached JSON recommendations to match msgpack, got %+v and %+v", jsonResp.Recommendations, resp.Recommendations)
+	}
+	if c.Len() != 1 {
+		t.Errorf("expected one cache entry shared by both formats, got %d", c.Len())
+	}
+}
+
+func TestGetRecommendationsMsgpackErrorsStayJSON(t *testing.T) {
+	h := newFakeHandler(t, servicetest.NewFakeRepo(), servicetest.NewFakeCache())
+
+	req := newUserRequest(http.MethodGet, "/users/9/recommendations", "9", "")
+	req.Header.Set("Accept", "application/msgpack")
+	rec := httptest.NewRecorder()
+	h.GetRecommendations(rec, req)
+
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected a JSON 404, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestGetRecommendationsOffset(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 30)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+	get := func(target string) (*httptest.ResponseRecorder, RecommendationResponse) {
+		req := newUserRequest(http.MethodGet, target, "1", "")
+		// Ignored once an offset is given
+		req.Header.Set("Range", "items=0-1")
+		rec := httptest.NewRecorder()
+		h.GetRecommendations(rec, req)
+		var resp RecommendationResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp
+	}
+
+	rec, first := get("/users/1/recommendations?offset=0&limit=10")
+	if rec.Code != http.StatusOK || len(first.Recommendations) != 10 {
+		t.Fatalf("expected 200 with 10 items, got %d with %d", rec.Code, len(first.Recommendations))
+	}
+	_, second := get("/users/1/recommendations?offset=10&limit=10")
+	// FakeScorer keeps candidate order, so the second page starts at content 11
+	if len(second.Recommendations) != 10 || second.Recommendations[0].ContentID != 11 {
+		t.Fatalf("expected content 11-20 on page 2, got %+v", second.Recommendations)
+	}
+	if !second.Metadata.CacheHit {
+		t.Error("expected page 2 to be sliced from the cached full list")
+	}
+	last := first.Recommendations[len(first.Recommendations)-1]
+	if second.Recommendations[0].Score > last.Score {
+		t.Errorf("expected scores to keep descending across pages, got %f after %f", second.Recommendations[0].Score, last.Score)
+	}
+
+	if rec, _ := get("/users/1/recommendations?offset=-1"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a negative offset, got %d", rec.Code)
+	}
+}
+
+func TestGetRecommendationsByGenre(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 40)
+	// Two comedy watches and one drama
+	for _, contentID := range []int64{3, 8, 2} {
+		if err := repo.AddWatchHistory(context.Background(), 1, contentID); err != nil {
+			t.Fatalf("seed watch history: %v", err)
+		}
+	}
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	rec := httptest.NewRecorder()
+	h.GetRecommendationsByGenre(rec, newUserRequest(http.MethodGet, "/users/1/recommendations/by-genre?per_genre=3", "1", ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp GenreRecommendationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	var order []string
+	for _, g := range resp.Genres {
+		order = append(order, g.Genre)
+		if len(g.Recommendations) != 3 {
+			t.Errorf("%s: expected 3 recommendations, got %d", g.Genre, len(g.Recommendations))
+		}
+		for _, r := range g.Recommendations {
+			if r.Genre != g.Genre {
+				t.Errorf("%s: got content %d of genre %s", g.Genre, r.ContentID, r.Genre)
+			}
+		}
+	}
+	// Watched genres by preference, then the rest by name
+	want := []string{"comedy", "drama", "action", "sci-fi", "thriller"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("expected genres %v, got %v", want, order)
+	}
+	if resp.Metadata.TotalCount != 15 {
+		t.Errorf("expected total_count 15, got %d", resp.Metadata.TotalCount)
+	}
+}
+
+func TestGetRecommendationsByGenreInvalidPerGenre(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	repo.AddUsers(1)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	for _, perGenre := range []string{"0", "51", "abc"} {
+		rec := httptest.NewRecorder()
+		h.GetRecommendationsByGenre(rec, newUserRequest(http.MethodGet, "/users/1/recommendations/by-genre?per_genre="+perGenre, "1", ""))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("per_genre=%s: expected 400, got %d", perGenre, rec.Code)
+		}
+	}
+}
+
+func TestGetRecommendationsByGenreErrorsMatchRecommendations(t *testing.T) {
+	tests := []struct {
+		name     string
+		scoreErr error
+		code     int
+		errCode  string
+	}{
+		{"model failure", errors.New("model down"), http.StatusServiceUnavailable, "model_unavailable"},
+		{"client cancel", context.Canceled, http.StatusServiceUnavailable, "request_timeout"},
+	}
+	for _, tc := range tests {
+		repo := servicetest.NewFakeRepoWith(1, 5)
+		scorer := &servicetest.FakeScorer{Err: tc.scoreErr}
+		h := NewHandler(newTestService(t, repo, servicetest.NewFakeCache(), scorer, service.Config{}), Config{})
+
+		endpoints := map[string]http.HandlerFunc{
+			"/users/1/recommendations":          h.GetRecommendations,
+			"/users/1/recommendations/by-genre": h.GetRecommendationsByGenre,
+		}
+		for target, serve := range endpoints {
+			rec := httptest.NewRecorder()
+			serve(rec, newUserRequest(http.MethodGet, target, "1", ""))
+			var resp ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("%s %s: decode response: %v", tc.name, target, err)
+			}
+			if rec.Code != tc.code || resp.Error != tc.errCode {
+				t.Errorf("%s %s: expected %d %s, got %d %s", tc.name, target, tc.code, tc.errCode, rec.Code, resp.Error)
+			}
+		}
+	}
+}
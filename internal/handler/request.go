@@ -0,0 +1,117 @@
+package handler
+
+import "github.com/actuallystonmai/recommendation-service/internal/domain"
+
+// Query parameters for GET /users/{userID}/recommendations. A zero or absent
+// limit is left for the service to default.
+type RecommendationQuery struct {
+	Limit          int                   `query:"limit" validate:"omitempty,min=1,max=50"`
+	Normalize      bool                  `query:"normalize"`
+	IncludeWatched bool                  `query:"include_watched"`
+	Strategy       domain.Strategy       `query:"strategy" validate:"omitempty,strategy"`
+	CandidateOrder domain.CandidateOrder `query:"candidate_order" validate:"omitempty,candidate_order"`
+	ScoreFormat    string                `query:"score_format" validate:"omitempty,oneof=raw percentage"`
+	WithReasons    bool                  `query:"with_reasons"`
+	// Admin override of the MIN_POPULARITY floor
+	IgnorePopularityFloor bool `query:"ignore_popularity_floor"`
+	// Most items of any one genre in the list
+	MaxPerGenre int `query:"max_per_genre" validate:"omitempty,min=1,max=50"`
+	// Position in the full scored list; its presence pages from that list
+	Offset int `query:"offset" validate:"min=0"`
+	// Score weight overrides for premium users; absent ones count as 0
+	WPopularity float64 `query:"w_popularity" validate:"min=0,max=1"`
+	WGenre      float64 `query:"w_genre" validate:"min=0,max=1"`
+	WRecency    float64 `query:"w_recency" validate:"min=0,max=1"`
+}
+
+// Query parameters for GET /users/{userID}/recommendations/by-genre
+type GenreRecommendationQuery struct {
+	PerGenre int `query:"per_genre" validate:"min=1,max=50"`
+}
+
+// Page selection shared by the batch endpoints
+type BatchPageQuery struct {
+	Page  int `query:"page" validate:"min=1,max=10000"`
+	Limit int `query:"limit" validate:"min=1,max=100"`
+	// Recommendations per user; limit is the number of users in the page
+	PerUserLimit int `query:"per_user_limit" validate:"min=1,max=50"`
+	// Include each user's top genres with their results
+	WithExplanations bool `query:"with_explanations"`
+}
+
+// Query parameters for GET /recommendations/batch
+type BatchQuery struct {
+	BatchPageQuery
+	Status domain.BatchStatus `query:"status" validate:"omitempty,oneof=success failed cancelled"`
+}
+
+// Query parameters for GET /users
+type UserQuery struct {
+	Country          string `query:"country" validate:"omitempty,iso3166_1_alpha2"`
+	SubscriptionType string `query:"subscription_type" validate:"omitempty,subscription"`
+	MinAge           int    `query:"min_age" validate:"omitempty,min=1,max=150"`
+	Page             int    `query:"page" validate:"min=1,max=10000"`
+	Limit            int    `query:"limit" validate:"min=1,max=100"`
+}
+
+// Query parameters for GET /content
+type ContentListQuery struct {
+	Genre         string             `query:"genre" validate:"omitempty,genre"`
+	MinPopularity float64            `query:"min_popularity" validate:"min=0"`
+	Sort          domain.ContentSort `query:"sort" validate:"omitempty,content_sort"`
+	Page          int                `query:"page" validate:"min=1,max=10000"`
+	Limit         int                `query:"limit" validate:"min=1,max=100"`
+}
+
+// Query parameters for GET /content/trending; window is parsed separately
+type TrendingQuery struct {
+	Window string `query:"window"`
+	Limit  int    `query:"limit" validate:"min=1,max=50"`
+}
+
+// Query parameters for GET /users/{userID}/watch-history
+type WatchHistoryQuery struct {
+	Limit int `query:"limit" validate:"min=1,max=200"`
+}
+
+type AddWatchHistoryRequest struct {
+	ContentID int64 `json:"content_id"`
+}
+
+type WatchHistoryItemRequest struct {
+	ContentID int64 `json:"content_id"`
+}
+
+// Body for POST /recommendations/bulk; a zero limit uses the batch default
+type BulkRecommendationRequest struct {
+	UserIDs []int64 `json:"user_ids"`
+	Limit   int     `json:"limit"`
+}
+
+type BlockContentRequest struct {
+	ContentID int64 `json:"content_id"`
+}
+
+type BulkWatchHistoryRequest struct {
+	Items []WatchHistoryItemRequest `json:"items"`
+}
+
+type CreateContentRequest struct {
+	Title           string   `json:"title"`
+	Genre           string   `json:"genre"`
+	PopularityScore *float64 `json:"popularity_score"`
+}
+
+type CreateUserRequest struct {
+	Age              int    `json:"age"`
+	Country          string `json:"country"`
+	SubscriptionType string `json:"subscription_type"`
+}
+
+// Optional body for POST /admin/seed; omitted fields keep the configured defaults
+type SeedRequest struct {
+	Users       int `json:"users"`
+	Content     int `json:"content"`
+	WatchEvents int `json:"watch_events"`
+	Seed        int `json:"seed"`
+}
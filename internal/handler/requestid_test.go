@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestEchoRequestID(t *testing.T) {
+	fail := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		(&Handler{}).writeError(w, http.StatusBadRequest, "bad_request", "nope")
+	})
+	h := middleware.RequestID(EchoRequestID(fail))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1/recommendations", nil)
+	req.Header.Set("X-Request-ID", "client-abc-123")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := rec.Header().Get("X-Request-ID"); got != "client-abc-123" {
+		t.Errorf("expected provided request ID to be echoed, got %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/1/recommendations", nil))
+	if got := rec.Header().Get("X-Request-ID"); got == "" {
+		t.Error("expected a generated request ID when none is provided")
+	}
+}
@@ -8,6 +8,69 @@ type RecommendationResponse struct {
 	Metadata        domain.RecommendationMeta     `json:"metadata"`
 }
 
+type GenreRecommendationResponse struct {
+	UserID   int64                         `json:"user_id"`
+	Genres   []domain.GenreRecommendations `json:"genres"`
+	Metadata domain.RecommendationMeta     `json:"metadata"`
+}
+
+type WatchHistoryResponse struct {
+	UserID    int64 `json:"user_id"`
+	ContentID int64 `json:"content_id"`
+}
+
+type WatchHistoryListResponse struct {
+	UserID   int64                        `json:"user_id"`
+	Items    []domain.WatchHistoryItem    `json:"items"`
+	Metadata domain.WatchHistoryBreakdown `json:"metadata"`
+}
+
+type BlocklistResponse struct {
+	UserID    int64 `json:"user_id"`
+	ContentID int64 `json:"content_id"`
+}
+
+type CacheInvalidationResponse struct {
+	UserID      int64 `json:"user_id"`
+	KeysDeleted int   `json:"keys_deleted"`
+}
+
+type FailedUsersResponse struct {
+	Users []domain.FailedUser `json:"users"`
+	Total int                 `json:"total"`
+}
+
+type ContentSearchResponse struct {
+	Query   string           `json:"query"`
+	Results []domain.Content `json:"results"`
+}
+
+type ContentListResponse struct {
+	Page  int              `json:"page"`
+	Limit int              `json:"limit"`
+	Total int              `json:"total"`
+	Items []domain.Content `json:"items"`
+}
+
+type TrendingContentResponse struct {
+	Window  string                   `json:"window"`
+	Results []domain.TrendingContent `json:"results"`
+}
+
+// Rows inserted by POST /admin/seed
+type SeedResponse struct {
+	Users              int `json:"users"`
+	Content            int `json:"content"`
+	WatchEvents        int `json:"watch_events"`
+	RegionalPopularity int `json:"regional_popularity"`
+}
+
+type UserListResponse struct {
+	Page  int           `json:"page"`
+	Limit int           `json:"limit"`
+	Users []domain.User `json:"users"`
+}
+
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message"`
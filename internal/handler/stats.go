@@ -0,0 +1,16 @@
+package handler
+
+import (
+	"net/http"
+)
+
+// GET /stats
+func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.service.GetStats(r.Context())
+	if err != nil {
+		h.writeUnexpectedError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/actuallystonmai/recommendation-service/internal/service/servicetest"
+)
+
+func TestGetStats(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(3, 7)
+	for _, contentID := range []int64{1, 2} {
+		if err := repo.AddWatchHistory(context.Background(), 1, contentID); err != nil {
+			t.Fatalf("add watch history: %v", err)
+		}
+	}
+	c := servicetest.NewFakeCache()
+	h := newFakeHandler(t, repo, c)
+
+	rec := httptest.NewRecorder()
+	h.GetStats(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var stats domain.Stats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if stats.TotalUsers != 3 || stats.TotalContent != 7 || stats.TotalWatchEvents != 2 {
+		t.Errorf("unexpected totals: %+v", stats)
+	}
+	if stats.GenreCounts["action"] != 2 || stats.GenreCounts["drama"] != 2 || stats.GenreCounts["sci-fi"] != 1 {
+		t.Errorf("unexpected genre counts: %v", stats.GenreCounts)
+	}
+	if stats.SubscriptionCounts["basic"] != 3 {
+		t.Errorf("unexpected subscription counts: %v", stats.SubscriptionCounts)
+	}
+
+	// Served from cache: repository failures no longer matter
+	repo.Errors["CountUsers"] = errors.New("db down")
+	rec = httptest.NewRecorder()
+	h.GetStats(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected cached stats, got %d", rec.Code)
+	}
+}
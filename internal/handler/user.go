@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+// Accepted ages for new users
+const (
+	minUserAge = 13
+	maxUserAge = 120
+)
+
+// GET /users
+func (h *Handler) FindUsers(w http.ResponseWriter, r *http.Request) {
+	query := UserQuery{Page: 1, Limit: 50}
+	if !h.decodeAndValidate(w, r, &query) {
+		return
+	}
+
+	users, err := h.service.FindUsers(r.Context(), domain.UserFilter{
+		Country:          query.Country,
+		SubscriptionType: query.SubscriptionType,
+		MinAge:           query.MinAge,
+		Page:             query.Page,
+		Limit:            query.Limit,
+	})
+	if err != nil {
+		h.writeUnexpectedError(w, err)
+		return
+	}
+	if users == nil {
+		users = []domain.User{}
+	}
+
+	h.writeJSON(w, http.StatusOK, UserListResponse{
+		Page:  query.Page,
+		Limit: query.Limit,
+		Users: users,
+	})
+}
+
+// POST /users
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var req CreateUserRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Age < minUserAge || req.Age > maxUserAge {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter",
+			fmt.Sprintf("age must be between %d and %d", minUserAge, maxUserAge))
+		return
+	}
+	if validate.Var(req.Country, "required,iso3166_1_alpha2") != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter",
+			"country must be an uppercase ISO 3166-1 alpha-2 code")
+		return
+	}
+	if !domain.IsKnownSubscriptionType(req.SubscriptionType) {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter",
+			fmt.Sprintf("subscription_type must be one of %s", strings.Join(domain.SubscriptionTypes, ", ")))
+		return
+	}
+
+	user, err := h.service.CreateUser(r.Context(), req.Age, req.Country, req.SubscriptionType)
+	if err != nil {
+		h.writeUnexpectedError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, user)
+}
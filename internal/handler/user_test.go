@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/actuallystonmai/recommendation-service/internal/service/servicetest"
+)
+
+func TestFindUsers(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	repo.AddUsers(3)
+	repo.Users[2].SubscriptionType = "premium"
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	rec := httptest.NewRecorder()
+	h.FindUsers(rec, httptest.NewRequest(http.MethodGet, "/users?country=US&subscription_type=premium&min_age=25", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp UserListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Users) != 1 || resp.Users[0].ID != 2 {
+		t.Errorf("expected only user 2, got %+v", resp.Users)
+	}
+	if resp.Page != 1 || resp.Limit != 50 {
+		t.Errorf("expected default page 1 and limit 50, got %d and %d", resp.Page, resp.Limit)
+	}
+}
+
+func TestFindUsersInvalidFilters(t *testing.T) {
+	h := &Handler{}
+
+	tests := []struct {
+		query string
+		param string
+	}{
+		{"?subscription_type=gold", "subscription_type"},
+		{"?country=XX", "country"},
+		{"?country=usa", "country"},
+		{"?min_age=-1", "min_age"},
+		{"?limit=101", "limit"},
+	}
+
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		h.FindUsers(rec, httptest.NewRequest(http.MethodGet, "/users"+tc.query, nil))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", tc.query, rec.Code)
+			continue
+		}
+		var resp ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.Message != "Invalid "+tc.param+" parameter" {
+			t.Errorf("%s: unexpected message %q", tc.query, resp.Message)
+		}
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	repo.AddUsers(2)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	body := `{"age": 16, "country": "GB", "subscription_type": "premium"}`
+	rec := httptest.NewRecorder()
+	h.CreateUser(rec, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body)))
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created domain.User
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if created.ID != 3 || created.Age != 16 || created.Country != "GB" || created.SubscriptionType != "premium" {
+		t.Errorf("unexpected created user: %+v", created)
+	}
+	if created.CreatedAt.IsZero() {
+		t.Error("expected created_at to be set")
+	}
+	if stored := repo.Users[3]; stored == nil || stored.Country != "GB" {
+		t.Errorf("expected the user to be stored, got %+v", stored)
+	}
+}
+
+func TestCreateUserInvalidInput(t *testing.T) {
+	h := &Handler{}
+
+	tests := []struct {
+		name  string
+		body  string
+		param string
+	}{
+		{"malformed json", `{"age":`, ""},
+		{"unknown field", `{"age": 30, "country": "US", "subscription_type": "free", "name": "x"}`, "name"},
+		{"missing age", `{"country": "US", "subscription_type": "free"}`, "age"},
+		{"too young", `{"age": 12, "country": "US", "subscription_type": "free"}`, "age"},
+		{"too old", `{"age": 121, "country": "US", "subscription_type": "free"}`, "age"},
+		{"unknown country", `{"age": 30, "country": "XX", "subscription_type": "free"}`, "country"},
+		{"lowercase country", `{"age": 30, "country": "us", "subscription_type": "free"}`, "country"},
+		{"missing country", `{"age": 30, "subscription_type": "free"}`, "country"},
+		{"invalid subscription", `{"age": 30, "country": "US", "subscription_type": "gold"}`, "subscription_type"},
+		{"missing subscription", `{"age": 30, "country": "US"}`, "subscription_type"},
+	}
+
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		h.CreateUser(rec, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tc.body)))
+
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tc.param) {
+			t.Errorf("%s: expected 400 naming %q, got %d: %s", tc.name, tc.param, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxBulkWatchItems    = 500
+	maxIdempotencyKeyLen = 255
+)
+
+// POST /users/{userID}/watch-history
+func (h *Handler) AddWatchHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserID(r)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid user_id parameter")
+		return
+	}
+
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if len(idempotencyKey) > maxIdempotencyKeyLen {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Idempotency-Key header is too long")
+		return
+	}
+
+	var req AddWatchHistoryRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	if req.ContentID <= 0 {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid content_id parameter")
+		return
+	}
+
+	replayed, err := h.service.AddWatchHistoryIdempotent(r.Context(), idempotencyKey, userID, req.ContentID)
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	if err != nil {
+		h.writeWatchHistoryError(w, err, userID)
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, WatchHistoryResponse{
+		UserID:    userID,
+		ContentID: req.ContentID,
+	})
+}
+
+// POST /users/{userID}/watch-history/bulk
+func (h *Handler) AddWatchHistoryBulk(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserID(r)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid user_id parameter")
+		return
+	}
+
+	var req BulkWatchHistoryRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	// Validate items
+	if len(req.Items) == 0 || len(req.Items) > maxBulkWatchItems {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter",
+			fmt.Sprintf("items must contain between 1 and %d entries", maxBulkWatchItems))
+		return
+	}
+	contentIDs := make([]int64, 0, len(req.Items))
+	for _, item := range req.Items {
+		if item.ContentID <= 0 {
+			h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid content_id in items")
+			return
+		}
+		contentIDs = append(contentIDs, item.ContentID)
+	}
+
+	result, err := h.service.AddWatchHistoryBatch(r.Context(), userID, contentIDs)
+	if err != nil {
+		h.writeWatchHistoryError(w, err, userID)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// DELETE /users/{userID}/watch-history/{contentID}
+func (h *Handler) RemoveWatchHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserID(r)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid user_id parameter")
+		return
+	}
+
+	contentID, err := strconv.ParseInt(chi.URLParam(r, "contentID"), 10, 64)
+	if err != nil || contentID <= 0 {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid content_id parameter")
+		return
+	}
+
+	if err := h.service.RemoveWatchHistory(r.Context(), userID, contentID); err != nil {
+		h.writeWatchHistoryError(w, err, userID)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /users/{userID}/watch-history
+func (h *Handler) GetWatchHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserID(r)
+	if !ok {
+		h.writeError(w, http.StatusBadRequest, "invalid_parameter", "Invalid user_id parameter")
+		return
+	}
+
+	query := WatchHistoryQuery{Limit: 50}
+	if !h.decodeAndValidate(w, r, &query) {
+		return
+	}
+
+	items, breakdown, err := h.service.GetWatchHistory(r.Context(), userID, query.Limit)
+	if err != nil {
+		h.writeWatchHistoryError(w, err, userID)
+		return
+	}
+	if items == nil {
+		items = []domain.WatchHistoryItem{}
+	}
+
+	h.writeJSON(w, http.StatusOK, WatchHistoryListResponse{
+		UserID:   userID,
+		Items:    items,
+		Metadata: *breakdown,
+	})
+}
+
+// Map watch history errors to HTTP responses
+func (h *Handler) writeWatchHistoryError(w http.ResponseWriter, err error, userID int64) {
+	if errors.Is(err, domain.ErrUserNotFound) {
+		h.writeError(w, http.StatusNotFound, "user_not_found",
+			fmt.Sprintf("User with ID %d does not exist", userID))
+		return
+	}
+	if errors.Is(err, domain.ErrContentNotFound) {
+		h.writeError(w, http.StatusNotFound, "content_not_found", "Referenced content does not exist")
+		return
+	}
+	if errors.Is(err, domain.ErrWatchNotFound) {
+		h.writeError(w, http.StatusNotFound, "watch_record_not_found", "No such watch history record")
+		return
+	}
+	if errors.Is(err, domain.ErrAlreadyWatched) {
+		h.writeError(w, http.StatusConflict, "already_watched", "Content is already in the user's watch history")
+		return
+	}
+	if errors.Is(err, domain.ErrIdempotencyMismatch) {
+		h.writeError(w, http.StatusUnprocessableEntity, "idempotency_key_mismatch",
+			"Idempotency-Key was already used with a different request")
+		return
+	}
+	if errors.Is(err, domain.ErrIdempotencyInProgress) {
+		h.writeError(w, http.StatusConflict, "idempotency_key_in_progress",
+			"A request with this Idempotency-Key is still being processed")
+		return
+	}
+	h.writeUnexpectedError(w, err)
+}
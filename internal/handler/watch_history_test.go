@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/actuallystonmai/recommendation-service/internal/model"
+	"github.com/actuallystonmai/recommendation-service/internal/service"
+	"github.com/actuallystonmai/recommendation-service/internal/service/servicetest"
+	"github.com/go-chi/chi/v5"
+)
+
+// Build a request with the userID route param set
+func newUserRequest(method, target, userID, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("userID", userID)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestAddWatchHistoryBulkEmptyItems(t *testing.T) {
+	h := &Handler{}
+
+	for _, body := range []string{`{"items":[]}`, `{}`} {
+		rec := httptest.NewRecorder()
+		h.AddWatchHistoryBulk(rec, newUserRequest(http.MethodPost, "/users/1/watch-history/bulk", "1", body))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("body %s: expected 400, got %d", body, rec.Code)
+		}
+	}
+}
+
+func TestAddWatchHistoryBulkInvalidInput(t *testing.T) {
+	h := &Handler{}
+
+	cases := []struct {
+		name   string
+		userID string
+		body   string
+	}{
+		{"invalid user", "abc", `{"items":[{"content_id":1}]}`},
+		{"malformed json", "1", `{"items":`},
+		{"invalid content id", "1", `{"items":[{"content_id":0}]}`},
+	}
+
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		h.AddWatchHistoryBulk(rec, newUserRequest(http.MethodPost, "/users/x/watch-history/bulk", tc.userID, tc.body))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", tc.name, rec.Code)
+		}
+	}
+}
+
+// Handler over a service with fake dependencies
+func newFakeHandler(t *testing.T, repo *servicetest.FakeRepo, c *servicetest.FakeCache) *Handler {
+	return NewHandler(newTestService(t, repo, c, &servicetest.FakeScorer{}, service.Config{}), Config{})
+}
+
+// Service whose batch workers are stopped when the test ends
+func newTestService(t *testing.T, repo service.Repo, c service.RecommendationCache, scorer model.Scorer, cfg service.Config) *service.Service {
+	t.Helper()
+	svc := service.NewService(repo, c, scorer, cfg)
+	t.Cleanup(svc.Close)
+	return svc
+}
+
+func TestRemoveWatchHistory(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 3)
+	if err := repo.AddWatchHistory(context.Background(), 1, 2); err != nil {
+		t.Fatalf("seed watch history: %v", err)
+	}
+	c := servicetest.NewFakeCache()
+	c.Set(context.Background(), 1, 10, "", domain.CachedRecommendations{Recommendations: []domain.ScoredRecommendation{{ContentID: 3}}})
+
+	r := chi.NewRouter()
+	r.Delete("/users/{userID}/watch-history/{contentID}", newFakeHandler(t, repo, c).RemoveWatchHistory)
+
+	// Existing record -> 204 and cache cleared
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/users/1/watch-history/2", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rec.Code)
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected user cache cleared, %d entries left", c.Len())
+	}
+
+	// Same record again -> 404
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/users/1/watch-history/2", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for missing record, got %d", rec.Code)
+	}
+
+	// Invalid content id -> 400
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/users/1/watch-history/abc", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid content id, got %d", rec.Code)
+	}
+}
+
+func TestGetWatchHistory(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	repo.AddUsers(2)
+	repo.AddContent(5) // genres rotate: action, drama, comedy, thriller, sci-fi
+	for _, contentID := range []int64{1, 2, 3} {
+		if err := repo.AddWatchHistory(context.Background(), 1, contentID); err != nil {
+			t.Fatalf("seed watch history: %v", err)
+		}
+	}
+	// Two action watches and one drama
+	repo.History[1][2].Genre = "action"
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	r := chi.NewRouter()
+	r.Get("/users/{userID}/watch-history", h.GetWatchHistory)
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/users/1/watch-history")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var resp WatchHistoryListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Items) != 3 || resp.Metadata.TotalCount != 3 {
+		t.Fatalf("expected 3 items, got %d (total_count %d)", len(resp.Items), resp.Metadata.TotalCount)
+	}
+
+	// The breakdown is computed from the returned items
+	counts := make(map[string]int)
+	for _, item := range resp.Items {
+		counts[item.Genre]++
+	}
+	if len(counts) != len(resp.Metadata.GenreCounts) {
+		t.Errorf("expected genres %v, got %v", counts, resp.Metadata.GenreCounts)
+	}
+	for genre, n := range counts {
+		if resp.Metadata.GenreCounts[genre] != n {
+			t.Errorf("%s: expected count %d, got %d", genre, n, resp.Metadata.GenreCounts[genre])
+		}
+	}
+	if resp.Metadata.GenrePercentages["action"] != 66.7 || resp.Metadata.GenrePercentages["drama"] != 33.3 {
+		t.Errorf("expected 66.7%% action and 33.3%% drama, got %v", resp.Metadata.GenrePercentages)
+	}
+
+	// The limit applies before the breakdown
+	if err := json.Unmarshal(get("/users/1/watch-history?limit=1").Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode limited response: %v", err)
+	}
+	if len(resp.Items) != 1 || resp.Metadata.TotalCount != 1 || resp.Metadata.GenrePercentages[resp.Items[0].Genre] != 100 {
+		t.Errorf("expected a single item at 100%%, got %+v", resp)
+	}
+
+	// A user without history gets an empty list
+	rec = get("/users/2/watch-history")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"items":[]`) {
+		t.Errorf("expected empty items for user 2, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := get("/users/99/watch-history"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown user, got %d", rec.Code)
+	}
+	if rec := get("/users/1/watch-history?limit=201"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for limit above 200, got %d", rec.Code)
+	}
+}
+
+func TestAddWatchHistoryIdempotencyKeyMismatch(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 3)
+	h := newFakeHandler(t, repo, servicetest.NewFakeCache())
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := newUserRequest(http.MethodPost, "/users/1/watch-history", "1", body)
+		req.Header.Set("Idempotency-Key", "key-1")
+		rec := httptest.NewRecorder()
+		h.AddWatchHistory(rec, req)
+		return rec
+	}
+
+	if rec := post(`{"content_id": 1}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := post(`{"content_id": 1}`); rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("expected a replayed 201, got %d", rec.Code)
+	}
+	if rec := post(`{"content_id": 2}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a different body, got %d", rec.Code)
+	}
+}
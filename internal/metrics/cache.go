@@ -0,0 +1,52 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Results a recommendation cache lookup is counted under
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheError = "error"
+)
+
+// Counts recommendation cache lookups by result, so Redis failures show up
+// separately from ordinary misses even though both fall back to scoring
+type CacheMetrics struct {
+	lookups *prometheus.CounterVec
+}
+
+func NewCacheMetrics() *CacheMetrics {
+	lookups := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "recommendation_cache_lookups_total",
+		Help: "Recommendation cache lookups by result: hit, miss or error",
+	}, []string{"result"})
+	// Export every result from the start, not only once it first happens
+	for _, result := range []string{CacheHit, CacheMiss, CacheError} {
+		lookups.WithLabelValues(result)
+	}
+	return &CacheMetrics{lookups: lookups}
+}
+
+// Count a lookup from its found and err results. An error counts as an error
+// only, never as a miss. A nil *CacheMetrics counts nothing.
+func (m *CacheMetrics) ObserveLookup(found bool, err error) {
+	if m == nil {
+		return
+	}
+	result := CacheMiss
+	switch {
+	case err != nil:
+		result = CacheError
+	case found:
+		result = CacheHit
+	}
+	m.lookups.WithLabelValues(result).Inc()
+}
+
+func (m *CacheMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.lookups.Describe(ch)
+}
+
+func (m *CacheMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.lookups.Collect(ch)
+}
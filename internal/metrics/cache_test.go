@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCacheMetricsObserveLookup(t *testing.T) {
+	m := NewCacheMetrics()
+	m.ObserveLookup(true, nil)
+	m.ObserveLookup(false, nil)
+	m.ObserveLookup(false, nil)
+	m.ObserveLookup(false, errors.New("connection refused"))
+
+	want := map[string]float64{CacheHit: 1, CacheMiss: 2, CacheError: 1}
+	for result, count := range want {
+		if got := testutil.ToFloat64(m.lookups.WithLabelValues(result)); got != count {
+			t.Errorf("expected %g %s lookups, got %g", count, result, got)
+		}
+	}
+}
+
+func TestNilCacheMetricsIgnoresLookups(t *testing.T) {
+	var m *CacheMetrics
+	m.ObserveLookup(false, errors.New("connection refused"))
+}
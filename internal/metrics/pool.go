@@ -0,0 +1,134 @@
+// Package metrics exposes service internals as Prometheus metrics.
+package metrics
+
+import (
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// Fraction of MaxConns in use above which the pool counts as saturated
+	poolSaturationThreshold = 0.8
+	poolCheckInterval       = 5 * time.Second
+	// How long the pool must stay saturated before a warning is logged
+	poolSaturationWindow = 30 * time.Second
+)
+
+// Snapshot of database connection pool usage
+type PoolStats struct {
+	AcquiredConns int32
+	IdleConns     int32
+	TotalConns    int32
+	MaxConns      int32
+	// Cumulative connections opened since the pool was created
+	NewConnsCount int64
+}
+
+// Stats source backed by a pgx pool
+func PgxPoolStats(pool *pgxpool.Pool) func() PoolStats {
+	return func() PoolStats {
+		stat := pool.Stat()
+		return PoolStats{
+			AcquiredConns: stat.AcquiredConns(),
+			IdleConns:     stat.IdleConns(),
+			TotalConns:    stat.TotalConns(),
+			MaxConns:      stat.MaxConns(),
+			NewConnsCount: stat.NewConnsCount(),
+		}
+	}
+}
+
+// Reads pool stats on every scrape rather than polling. Every metric carries a
+// pool label naming the pool, so the primary and replica can be told apart.
+type poolCollector struct {
+	stats         func() PoolStats
+	acquiredConns *prometheus.Desc
+	idleConns     *prometheus.Desc
+	totalConns    *prometheus.Desc
+	maxConns      *prometheus.Desc
+	newConns      *prometheus.Desc
+}
+
+func NewPoolCollector(name string, stats func() PoolStats) prometheus.Collector {
+	labels := prometheus.Labels{"pool": name}
+	return &poolCollector{
+		stats: stats,
+		acquiredConns: prometheus.NewDesc("db_pool_acquired_conns",
+			"Connections currently acquired from the pool", nil, labels),
+		idleConns: prometheus.NewDesc("db_pool_idle_conns",
+			"Idle connections in the pool", nil, labels),
+		totalConns: prometheus.NewDesc("db_pool_total_conns",
+			"Total connections in the pool, acquired, idle and constructing", nil, labels),
+		maxConns: prometheus.NewDesc("db_pool_max_conns",
+			"Maximum size of the pool", nil, labels),
+		newConns: prometheus.NewDesc("db_pool_new_conns_total",
+			"Connections opened since the pool was created", nil, labels),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.totalConns
+	ch <- c.maxConns
+	ch <- c.newConns
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats()
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(s.AcquiredConns))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(s.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(s.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(s.MaxConns))
+	// Cumulative, so a counter: rate() then gives connection churn
+	ch <- prometheus.MustNewConstMetric(c.newConns, prometheus.CounterValue, float64(s.NewConnsCount))
+}
+
+// Tracks how long the pool has been saturated
+type saturationMonitor struct {
+	threshold      float64
+	window         time.Duration
+	saturatedSince time.Time
+}
+
+// Record a stats sample and report whether a warning is due. While the pool
+// stays saturated a warning is due once per window.
+func (m *saturationMonitor) observe(s PoolStats, now time.Time) bool {
+	if s.MaxConns <= 0 || float64(s.AcquiredConns) <= m.threshold*float64(s.MaxConns) {
+		m.saturatedSince = time.Time{}
+		return false
+	}
+	if m.saturatedSince.IsZero() {
+		m.saturatedSince = now
+		return false
+	}
+	if now.Sub(m.saturatedSince) < m.window {
+		return false
+	}
+	m.saturatedSince = now
+	return true
+}
+
+// Sample pool stats until stop is closed, logging a warning naming the pool
+// when more than 80% of MaxConns stay acquired for the saturation window
+func RunPoolMonitor(stop <-chan struct{}, name string, stats func() PoolStats) {
+	ticker := time.NewTicker(poolCheckInterval)
+	defer ticker.Stop()
+
+	monitor := &saturationMonitor{threshold: poolSaturationThreshold, window: poolSaturationWindow}
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s := stats()
+			if monitor.observe(s, now) {
+				log.Printf("[metrics] db pool %s saturated: %d/%d connections acquired for over %s",
+					name, s.AcquiredConns, s.MaxConns, poolSaturationWindow)
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Scrape a registry holding only the given pool collectors and parse their
+// samples, keyed by name and labels
+func scrapePool(t *testing.T, collectors ...prometheus.Collector) map[string]float64 {
+	t.Helper()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors...)
+
+	rec := httptest.NewRecorder()
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatalf("read scrape: %v", err)
+	}
+	samples := make(map[string]float64)
+	for _, line := range strings.Split(string(body), "\n") {
+		name, value, ok := strings.Cut(line, " ")
+		if !ok || strings.HasPrefix(line, "#") {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Fatalf("parse sample %q: %v", line, err)
+		}
+		samples[name] = v
+	}
+	return samples
+}
+
+func TestPoolCollectorScrape(t *testing.T) {
+	stats := PoolStats{AcquiredConns: 3, IdleConns: 2, TotalConns: 5, MaxConns: 20, NewConnsCount: 7}
+	samples := scrapePool(t, NewPoolCollector("primary", func() PoolStats { return stats }))
+
+	expected := map[string]float64{
+		`db_pool_acquired_conns{pool="primary"}`:  3,
+		`db_pool_idle_conns{pool="primary"}`:      2,
+		`db_pool_total_conns{pool="primary"}`:     5,
+		`db_pool_max_conns{pool="primary"}`:       20,
+		`db_pool_new_conns_total{pool="primary"}`: 7,
+	}
+	for name, value := range expected {
+		if got, ok := samples[name]; !ok || got != value {
+			t.Errorf("expected %s=%g, got %g (present=%v)", name, value, got, ok)
+		}
+	}
+
+	// Values are read on each scrape
+	stats.AcquiredConns = 9
+	collector := NewPoolCollector("primary", func() PoolStats { return stats })
+	if got := scrapePool(t, collector)[`db_pool_acquired_conns{pool="primary"}`]; got != 9 {
+		t.Errorf("expected updated acquired conns 9, got %g", got)
+	}
+}
+
+func TestPoolCollectorNewConnsIsCounter(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewPoolCollector("primary", func() PoolStats { return PoolStats{NewConnsCount: 7} }))
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	for _, family := range families {
+		expected := dto.MetricType_GAUGE
+		if family.GetName() == "db_pool_new_conns_total" {
+			expected = dto.MetricType_COUNTER
+		}
+		if family.GetType() != expected {
+			t.Errorf("expected %s to be a %s, got %s", family.GetName(), expected, family.GetType())
+		}
+	}
+}
+
+func TestPoolCollectorLabelsEachPool(t *testing.T) {
+	samples := scrapePool(t,
+		NewPoolCollector("primary", func() PoolStats { return PoolStats{AcquiredConns: 3} }),
+		NewPoolCollector("replica", func() PoolStats { return PoolStats{AcquiredConns: 5} }),
+	)
+	if samples[`db_pool_acquired_conns{pool="primary"}`] != 3 || samples[`db_pool_acquired_conns{pool="replica"}`] != 5 {
+		t.Errorf("expected acquired conns per pool, got %v", samples)
+	}
+}
+
+func TestPoolCollectorScrapePgxPool(t *testing.T) {
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL not set, skipping pool metrics test")
+	}
+
+	ctx := context.Background()
+	poolConfig, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		t.Fatalf("parse database config: %v", err)
+	}
+	poolConfig.MaxConns = 4
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		t.Fatalf("connect test database: %v", err)
+	}
+	defer pool.Close()
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("acquire connection: %v", err)
+	}
+	defer conn.Release()
+
+	samples := scrapePool(t, NewPoolCollector("primary", PgxPoolStats(pool)))
+	sample := func(name string) float64 { return samples[name+`{pool="primary"}`] }
+	if sample("db_pool_acquired_conns") != 1 {
+		t.Errorf("expected 1 acquired conn, got %g", sample("db_pool_acquired_conns"))
+	}
+	if sample("db_pool_max_conns") != 4 {
+		t.Errorf("expected max conns 4, got %g", sample("db_pool_max_conns"))
+	}
+	total := sample("db_pool_total_conns")
+	if total < sample("db_pool_acquired_conns")+sample("db_pool_idle_conns") || total > sample("db_pool_max_conns") {
+		t.Errorf("expected acquired+idle <= total <= max, got %v", samples)
+	}
+	if sample("db_pool_new_conns_total") < 1 {
+		t.Errorf("expected at least one new conn, got %g", sample("db_pool_new_conns_total"))
+	}
+}
+
+func TestSaturationMonitor(t *testing.T) {
+	m := &saturationMonitor{threshold: 0.8, window: 30 * time.Second}
+	start := time.Now()
+	saturated := PoolStats{AcquiredConns: 17, MaxConns: 20}
+	healthy := PoolStats{AcquiredConns: 16, MaxConns: 20}
+
+	if m.observe(saturated, start) || m.observe(saturated, start.Add(20*time.Second)) {
+		t.Fatal("expected no warning before the window elapses")
+	}
+	if !m.observe(saturated, start.Add(30*time.Second)) {
+		t.Fatal("expected a warning after sustained saturation")
+	}
+	if m.observe(saturated, start.Add(35*time.Second)) {
+		t.Error("expected at most one warning per window")
+	}
+
+	// Dropping to the threshold resets the window
+	if m.observe(healthy, start.Add(40*time.Second)) {
+		t.Error("expected no warning at 80% usage")
+	}
+	if m.observe(saturated, start.Add(45*time.Second)) || m.observe(saturated, start.Add(70*time.Second)) {
+		t.Error("expected the window to restart after recovering")
+	}
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/sony/gobreaker/v2"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+// Scorer wrapper that stops calling the model after repeated failures. While
+// the breaker is open Score fails immediately with gobreaker.ErrOpenState.
+type BreakerScorer struct {
+	next Scorer
+	cb   *gobreaker.CircuitBreaker[scoreResult]
+}
+
+// Score's results, carried through the breaker as one value
+type scoreResult struct {
+	scored    []domain.ScoredRecommendation
+	truncated bool
+}
+
+var _ Scorer = (*BreakerScorer)(nil)
+
+// Trip after threshold consecutive failures and stay open for timeout before
+// letting a single trial call through
+func NewBreakerScorer(next Scorer, threshold uint32, timeout time.Duration) *BreakerScorer {
+	cb := gobreaker.NewCircuitBreaker[scoreResult](gobreaker.Settings{
+		Name:    "model",
+		Timeout: timeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= threshold
+		},
+		// A caller giving up says nothing about the model's health
+		IsSuccessful: func(err error) bool {
+			return err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+		},
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			log.Printf("[model] circuit breaker %s -> %s", from, to)
+		},
+	})
+	return &BreakerScorer{next: next, cb: cb}
+}
+
+func (b *BreakerScorer) Score(ctx context.Context, input ScoreInput) ([]domain.ScoredRecommendation, bool, error) {
+	res, err := b.cb.Execute(func() (scoreResult, error) {
+		scored, truncated, err := b.next.Score(ctx, input)
+		return scoreResult{scored: scored, truncated: truncated}, err
+	})
+	return res.scored, res.truncated, err
+}
+
+// Scorer behind the breaker
+func (b *BreakerScorer) Unwrap() Scorer {
+	return b.next
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker/v2"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+// Scorer that fails while fail is set, or with err when it is set, sleeping
+// delay per call
+type stubScorer struct {
+	fail  bool
+	err   error
+	delay time.Duration
+	calls int
+}
+
+func (s *stubScorer) Score(context.Context, ScoreInput) ([]domain.ScoredRecommendation, bool, error) {
+	s.calls++
+	time.Sleep(s.delay)
+	if s.err != nil {
+		return nil, false, s.err
+	}
+	if s.fail {
+		return nil, false, &ModelInferenceError{Msg: "model inference failed"}
+	}
+	return []domain.ScoredRecommendation{{ContentID: 1}}, false, nil
+}
+
+func TestBreakerTripsAndRecovers(t *testing.T) {
+	stub := &stubScorer{fail: true, delay: 20 * time.Millisecond}
+	breaker := NewBreakerScorer(stub, 3, 100*time.Millisecond)
+
+	for i := range 3 {
+		var inferenceErr *ModelInferenceError
+		if _, _, err := breaker.Score(context.Background(), ScoreInput{}); !errors.As(err, &inferenceErr) {
+			t.Fatalf("call %d: expected model error, got %v", i+1, err)
+		}
+	}
+
+	// Open: fails without calling the model or waiting on its latency
+	start := time.Now()
+	_, _, err := breaker.Score(context.Background(), ScoreInput{})
+	if !errors.Is(err, gobreaker.ErrOpenState) {
+		t.Fatalf("expected open breaker error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= stub.delay {
+		t.Errorf("expected fast fail, took %s", elapsed)
+	}
+	if stub.calls != 3 {
+		t.Errorf("expected the model to be skipped while open, got %d calls", stub.calls)
+	}
+
+	// After the timeout a successful trial call closes the breaker
+	stub.fail = false
+	time.Sleep(120 * time.Millisecond)
+	if _, _, err := breaker.Score(context.Background(), ScoreInput{}); err != nil {
+		t.Fatalf("expected trial call to succeed, got %v", err)
+	}
+	if _, _, err := breaker.Score(context.Background(), ScoreInput{}); err != nil {
+		t.Errorf("expected closed breaker after recovery, got %v", err)
+	}
+	if stub.calls != 5 {
+		t.Errorf("expected 5 model calls, got %d", stub.calls)
+	}
+}
+
+func TestBreakerResetsOnSuccess(t *testing.T) {
+	stub := &stubScorer{}
+	breaker := NewBreakerScorer(stub, 2, time.Minute)
+
+	// Failures separated by a success are not consecutive
+	for _, fail := range []bool{true, false, true, false} {
+		stub.fail = fail
+		breaker.Score(context.Background(), ScoreInput{})
+	}
+	stub.fail = true
+	if _, _, err := breaker.Score(context.Background(), ScoreInput{}); errors.Is(err, gobreaker.ErrOpenState) {
+		t.Error("expected breaker to stay closed without consecutive failures")
+	}
+}
+
+func TestBreakerIgnoresContextErrors(t *testing.T) {
+	stub := &stubScorer{}
+	breaker := NewBreakerScorer(stub, 2, time.Minute)
+
+	for _, err := range []error{context.Canceled, context.DeadlineExceeded, context.Canceled} {
+		stub.err = err
+		if _, _, got := breaker.Score(context.Background(), ScoreInput{}); !errors.Is(got, err) {
+			t.Fatalf("expected %v passed through, got %v", err, got)
+		}
+	}
+	if stub.calls != 3 {
+		t.Errorf("expected every call to reach the model, got %d", stub.calls)
+	}
+}
+
+func TestAsExplainerLooksThroughBreaker(t *testing.T) {
+	client := NewClient(DefaultModelConfig())
+	explainer, ok := AsExplainer(NewBreakerScorer(client, 3, time.Second))
+	if !ok || explainer != Explainer(client) {
+		t.Errorf("expected the wrapped client as explainer, got %v ok=%v", explainer, ok)
+	}
+
+	// A scorer that only ranks has no breakdown
+	if _, ok := AsExplainer(NewBreakerScorer(&stubScorer{}, 3, time.Second)); ok {
+		t.Error("expected no explainer behind a scorer without Breakdown")
+	}
+}
@@ -1,6 +1,9 @@
 package model
 
 import (
+	"context"
+	"errors"
+	"maps"
 	"math"
 	"math/rand"
 	"sort"
@@ -9,10 +12,81 @@ import (
 	"github.com/actuallystonmai/recommendation-service/internal/domain"
 )
 
-type Client struct {}
+// Scorer ranks candidate content for a user. Once ctx's deadline passes Score
+// stops scoring further candidates and returns a ranking of those already
+// scored, reporting truncated; a cancelled ctx fails with context.Canceled.
+type Scorer interface {
+	Score(ctx context.Context, input ScoreInput) (scored []domain.ScoredRecommendation, truncated bool, err error)
+}
+
+// Explainer splits the score of one content item into its per-signal
+// components. Only the debug score endpoint uses it, so it stays off Scorer.
+type Explainer interface {
+	Breakdown(content domain.Content, history []domain.WatchHistoryItem, regional map[int64]float64) domain.ScoreBreakdown
+}
+
+// The Explainer behind s, looking through wrappers such as BreakerScorer
+func AsExplainer(s Scorer) (Explainer, bool) {
+	for {
+		if e, ok := s.(Explainer); ok {
+			return e, true
+		}
+		wrapper, ok := s.(interface{ Unwrap() Scorer })
+		if !ok {
+			return nil, false
+		}
+		s = wrapper.Unwrap()
+	}
+}
 
-func NewClient() *Client {
-	return &Client{}
+// Tunable scoring parameters
+type ModelConfig struct {
+	// Genre preference used for genres the user hasn't watched, in [0,1]
+	GenreFallback float64
+	// Max preference weight for any single genre, in (0,1]; 0 disables the cap
+	GenreCap float64
+	// Simulated inference latency, drawn uniformly from [MinLatency, MaxLatency]
+	MinLatency time.Duration
+	MaxLatency time.Duration
+	// Probability in [0,1] that a Score call fails
+	FailureRate float64
+	// Content age in days at which the recency factor halves; <= 0 uses 365
+	RecencyHalfLifeDays float64
+	// Partial genre credit between related genres; nil, the default, gives
+	// exact matches only
+	GenreSimilarity GenreSimilarity
+	// Score without exploration noise so the same inputs always score the same
+	DisableNoise bool
+}
+
+func DefaultModelConfig() ModelConfig {
+	return ModelConfig{
+		GenreFallback:       0.1,
+		MinLatency:          30 * time.Millisecond,
+		MaxLatency:          50 * time.Millisecond,
+		FailureRate:         0.015,
+		RecencyHalfLifeDays: defaultRecencyHalfLifeDays,
+	}
+}
+
+type Client struct {
+	cfg ModelConfig
+	// Components of the genre_weighted score, summed in order
+	pipeline []ScoreComponent
+}
+
+var (
+	_ Scorer    = (*Client)(nil)
+	_ Explainer = (*Client)(nil)
+)
+
+// Options are applied over the default scoring pipeline
+func NewClient(cfg ModelConfig, opts ...Option) *Client {
+	c := &Client{cfg: cfg, pipeline: DefaultPipeline()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 type ModelInferenceError struct {
@@ -23,94 +97,363 @@ func (e *ModelInferenceError) Error() string {
 	return e.Msg
 }
 
+// Version of the scoring algorithm, reported with every recommendation list.
+// Bump it whenever a change to scoring alters results;
+// TestModelVersionPinsScoringOutput pins the scores for the current version.
+const ModelVersion = "1.3.0"
+
+const (
+	regionalPopularityWeight   = 0.7
+	defaultRecencyHalfLifeDays = 365.0
+	// Age in days at which a watch counts half as much towards genre
+	// preferences; fixed, unlike the content recency half-life
+	watchHalfLifeDays = 365.0
+)
+
 type ScoreInput struct {
 	User *domain.User
 	WatchHistory []domain.WatchHistoryItem
 	Candidates []domain.Content
+	// Popularity of candidates in the user's country, keyed by content ID
+	RegionalPopularity map[int64]float64
 	Limit int
+	// Min-max normalize final scores to 0-1 across all candidates
+	Normalize bool
+	// Scoring strategy; empty uses domain.DefaultStrategy
+	Strategy domain.Strategy
+	// Per-request multipliers on the genre component, keyed by genre
+	GenreBoosts map[string]float64
+	// Component weights for genre_weighted scoring; nil uses DefaultWeights.
+	// Other strategies keep their own weights
+	Weights *domain.ScoreWeights
+	// Annotate results with the score components that drove them
+	WithReasons bool
 }
 
-func (c *Client) Score(input ScoreInput) ([]domain.ScoredRecommendation, error) {
-	//  Set model latency: 30-50ms
-	delay := time.Duration(30+rand.Intn(21)) * time.Millisecond
-	time.Sleep(delay)
+func (c *Client) Score(ctx context.Context, input ScoreInput) ([]domain.ScoredRecommendation, bool, error) {
+	candidates := input.Candidates
+	truncated := false
 
-	// Set random fail: 1.5% rate
-	if rand.Float64() < 0.015 {
-		return nil, &ModelInferenceError{Msg: "model inference failed"}
+	// Simulate model latency. The model works through candidates at an even
+	// rate, so a deadline part-way leaves the share it got through, and at
+	// least one
+	if delay := c.latency(); delay > 0 {
+		start := time.Now()
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, false, ctx.Err()
+			}
+			done := int(float64(len(candidates)) * float64(time.Since(start)) / float64(delay))
+			if done = max(done, 1); done < len(candidates) {
+				candidates = candidates[:done]
+				truncated = true
+			}
+		}
 	}
 
-	// Calculate preference
-	genrePreferences := calculateGenrePreferenceWeights(input.WatchHistory)
+	// Simulate random failures
+	if rand.Float64() < c.cfg.FailureRate {
+		return nil, false, &ModelInferenceError{Msg: "model inference failed"}
+	}
 
-	// Score each candidate
+	// Calculate preference, weighting recent watches more heavily and crediting
+	// genres similar to the watched ones
 	now := time.Now()
-	scored := make([]domain.ScoredRecommendation, 0, len(input.Candidates))
+	genrePreferences := c.boostGenrePreferences(c.genrePreferences(input.WatchHistory, now), input.GenreBoosts)
+	scoreFn := scoringFor(input.Strategy)
+	if input.Weights != nil && resolveStrategy(input.Strategy) == domain.StrategyGenreWeighted {
+		weights := *input.Weights
+		scoreFn = func(c *Client, content domain.Content, popularity float64, genrePrefs map[string]float64, now time.Time) domain.ScoreBreakdown {
+			return c.computeWeightedScore(content, popularity, genrePrefs, now, weights)
+		}
+	}
 
-	for _, content := range input.Candidates {
-		score := computeFinalScore(content, genrePreferences, now)
-		scored = append(scored, domain.ScoredRecommendation{
+	// Score each candidate until the deadline, always scoring the first
+	scored := make([]domain.ScoredRecommendation, 0, len(candidates))
+
+	for _, content := range candidates {
+		if err := ctx.Err(); err != nil && len(scored) > 0 && !truncated {
+			if !errors.Is(err, context.DeadlineExceeded) {
+				return nil, false, err
+			}
+			truncated = true
+			break
+		}
+		popularity := blendPopularity(content, input.RegionalPopularity)
+		breakdown := scoreFn(c, content, popularity, genrePreferences, now)
+		rec := domain.ScoredRecommendation{
 			ContentID:       content.ID,
 			Title:           content.Title,
 			Genre:           content.Genre,
 			PopularityScore: content.PopularityScore,
-			Score:           math.Round(score*1000) / 1000, // 3 decimal places
-		})
+			DurationMinutes: content.DurationMinutes,
+			ReleaseYear:     content.ReleaseYear,
+			Score:           math.Round(breakdown.Final*1000) / 1000, // 3 decimal places
+		}
+		if input.WithReasons {
+			rec.Reasons = scoreReasons(breakdown, content.Genre, genrePreferences)
+		}
+		scored = append(scored, rec)
 	}
 
-	// Sort by score descending
-	sort.Slice(scored, func(i, j int) bool {
-		return scored[i].Score > scored[j].Score
-	})
+	if input.Normalize {
+		normalizeScores(scored)
+	}
+
+	sortByScore(scored)
+	scored = dedupeByContentID(scored)
 
 	// Take top N
 	if len(scored) > input.Limit {
 		scored = scored[:input.Limit]
 	}
 
-	return scored, nil
+	return scored, truncated, nil
+}
+
+// Sort by score descending, compared at the 3 decimal places scores are
+// reported with. Tied scores fall back to popularity, then content ID, so
+// identical requests always return the same order; repeats of a content ID
+// keep their highest score first for dedupeByContentID.
+func sortByScore(scored []domain.ScoredRecommendation) {
+	sort.Slice(scored, func(i, j int) bool {
+		a, b := scored[i], scored[j]
+		if qa, qb := quantizeScore(a.Score), quantizeScore(b.Score); qa != qb {
+			return qa > qb
+		}
+		if a.PopularityScore != b.PopularityScore {
+			return a.PopularityScore > b.PopularityScore
+		}
+		return a.ContentID < b.ContentID
+	})
+}
+
+// A score in thousandths, its reported precision
+func quantizeScore(score float64) int64 {
+	return int64(math.Round(score * 1000))
+}
+
+// Drop repeated content IDs from a list sorted by score, keeping the first and
+// so highest-scoring instance. Filters in place.
+func dedupeByContentID(scored []domain.ScoredRecommendation) []domain.ScoredRecommendation {
+	seen := make(map[int64]bool, len(scored))
+	unique := scored[:0]
+	for _, rec := range scored {
+		if !seen[rec.ContentID] {
+			seen[rec.ContentID] = true
+			unique = append(unique, rec)
+		}
+	}
+	return unique
+}
+
+// Random latency between the configured bounds
+func (c *Client) latency() time.Duration {
+	spread := c.cfg.MaxLatency - c.cfg.MinLatency
+	if spread <= 0 {
+		return c.cfg.MinLatency
+	}
+	return c.cfg.MinLatency + time.Duration(rand.Int63n(int64(spread)+1))
+}
+
+// Min-max normalize scores in place to 0-1; ordering is preserved.
+// When every score is equal they all normalize to 1.0.
+func normalizeScores(scored []domain.ScoredRecommendation) {
+	if len(scored) == 0 {
+		return
+	}
+
+	minScore, maxScore := scored[0].Score, scored[0].Score
+	for _, s := range scored {
+		minScore = math.Min(minScore, s.Score)
+		maxScore = math.Max(maxScore, s.Score)
+	}
+
+	spread := maxScore - minScore
+	for i := range scored {
+		if spread == 0 {
+			scored[i].Score = 1.0
+			continue
+		}
+		normalized := (scored[i].Score - minScore) / spread
+		scored[i].Score = math.Round(normalized*1000) / 1000
+	}
+}
+
+// Decayed genre preferences with similarity credit, capped when a genre cap
+// is configured. The cap comes last so credit can't lift a genre past it.
+func (c *Client) genrePreferences(history []domain.WatchHistoryItem, now time.Time) map[string]float64 {
+	prefs := c.genreAffinities(calculateDecayedGenrePreferenceWeights(history, now))
+	if c.cfg.GenreCap > 0 {
+		capGenrePreferences(prefs, c.cfg.GenreCap)
+	}
+	return prefs
+}
+
+// Cap each genre's weight at maxWeight in place, redistributing the excess to
+// the remaining genres in proportion to their weights. When no genre
+// can absorb more, the excess is dropped.
+func capGenrePreferences(prefs map[string]float64, maxWeight float64) {
+	for {
+		excess := 0.0
+		uncapped := 0.0
+		for genre, weight := range prefs {
+			if weight > maxWeight {
+				excess += weight - maxWeight
+				prefs[genre] = maxWeight
+			} else if weight < maxWeight {
+				uncapped += weight
+			}
+		}
+		if excess < 1e-12 || uncapped == 0 {
+			return
+		}
+		for genre, weight := range prefs {
+			if weight < maxWeight {
+				prefs[genre] = weight + excess*weight/uncapped
+			}
+		}
+	}
+}
+
+// Copy of prefs with boosted genres multiplied; a boosted genre the user
+// hasn't watched is multiplied from the fallback preference
+func (c *Client) boostGenrePreferences(prefs map[string]float64, boosts map[string]float64) map[string]float64 {
+	if len(boosts) == 0 {
+		return prefs
+	}
+	boosted := maps.Clone(prefs)
+	for genre, multiplier := range boosts {
+		boosted[genre] = c.genrePreference(prefs, genre) * multiplier
+	}
+	return boosted
 }
 
+// Share of the history in each genre, weighted by completion, without time decay
+func GenrePreferenceWeights(history []domain.WatchHistoryItem) map[string]float64 {
+	return calculateGenrePreferenceWeights(history)
+}
+
+// Each watch counts by how much of it was watched, so a finished title says
+// more about taste than an abandoned one
 func calculateGenrePreferenceWeights(history []domain.WatchHistoryItem) map[string]float64 {
-	genreCounts := make(map[string]int)
+	genreWeights := make(map[string]float64)
+	total := 0.0
 	for _, item := range history {
-		genreCounts[item.Genre]++
+		weight := completionWeight(item.CompletionRatio)
+		genreWeights[item.Genre] += weight
+		total += weight
 	}
 
-	total := float64(len(history))
-	prefs := make(map[string]float64, len(genreCounts))
+	prefs := make(map[string]float64, len(genreWeights))
 
 	if total == 0 {
 		return prefs
 	}
 	
-	for genre, count := range genreCounts {
-		prefs[genre] = float64(count) / total
+	for genre, weight := range genreWeights {
+		prefs[genre] = weight / total
 	}
 
 	return prefs
 }
 
+// Weight of a watch by its completion ratio; unknown (zero) counts as finished
+func completionWeight(ratio float64) float64 {
+	if ratio <= 0 {
+		return 1
+	}
+	return min(ratio, 1)
+}
 
-func calculateRecencyFactor(createdAt, now time.Time) float64 {
+// Time-decayed variant: each watch counts by its recency factor times its
+// completion before normalizing, so a watch watchHalfLifeDays (365) old counts
+// half as much as one from today
+func calculateDecayedGenrePreferenceWeights(history []domain.WatchHistoryItem, now time.Time) map[string]float64 {
+	genreWeights := make(map[string]float64)
+	total := 0.0
+	for _, item := range history {
+		weight := calculateRecencyFactor(item.WatchedAt, now, watchHalfLifeDays) * completionWeight(item.CompletionRatio)
+		genreWeights[item.Genre] += weight
+		total += weight
+	}
+
+	prefs := make(map[string]float64, len(genreWeights))
+
+	if total == 0 {
+		return prefs
+	}
+
+	for genre, weight := range genreWeights {
+		prefs[genre] = weight / total
+	}
+
+	return prefs
+}
+
+// 1.0 for brand new content, halving once it is halfLifeDays old
+func calculateRecencyFactor(createdAt, now time.Time, halfLifeDays float64) float64 {
 	daysSinceCreation := now.Sub(createdAt).Hours() / 24.0
-	return 1.0 / (1.0 + daysSinceCreation/365.0)
+	return 1.0 / (1.0 + daysSinceCreation/halfLifeDays)
 }
 
-func computeFinalScore(content domain.Content, genrePrefs map[string]float64, now time.Time) float64 {
-	popularityComponent := content.PopularityScore * 0.4
+// Recency factor for content using the configured half-life
+func (c *Client) contentRecencyFactor(createdAt, now time.Time) float64 {
+	halfLife := c.cfg.RecencyHalfLifeDays
+	if halfLife <= 0 {
+		halfLife = defaultRecencyHalfLifeDays
+	}
+	return calculateRecencyFactor(createdAt, now, halfLife)
+}
 
-	genrePref, ok := genrePrefs[content.Genre]
+// Blend regional popularity with global popularity (70/30) when regional data exists
+func blendPopularity(content domain.Content, regional map[int64]float64) float64 {
+	regionalScore, ok := regional[content.ID]
 	if !ok {
-		genrePref = 0.1
+		return content.PopularityScore
 	}
-	genreBoost := genrePref * 0.35
-	
-	// Recency component
-	recencyFactor := calculateRecencyFactor(content.CreatedAt, now)
-	recencyComponent := recencyFactor * 0.15
+	return regionalScore*regionalPopularityWeight + content.PopularityScore*(1-regionalPopularityWeight)
+}
+
+// Score breakdown for a single candidate without simulated latency or failures
+func (c *Client) Breakdown(content domain.Content, history []domain.WatchHistoryItem, regional map[int64]float64) domain.ScoreBreakdown {
+	now := time.Now()
+	popularity := blendPopularity(content, regional)
+	return c.computeFinalScore(content, popularity, c.genrePreferences(history, now), now)
+}
+
+// genre_weighted score from the client's pipeline
+func (c *Client) computeFinalScore(content domain.Content, popularity float64, genrePrefs map[string]float64, now time.Time) domain.ScoreBreakdown {
+	return c.computeWeightedScore(content, popularity, genrePrefs, now, DefaultWeights())
+}
 
-	randomNoise := (rand.Float64()*0.1 - 0.05) * 0.1
+// Like computeFinalScore with the built-in components weighted by weights
+func (c *Client) computeWeightedScore(content domain.Content, popularity float64, genrePrefs map[string]float64, now time.Time, weights domain.ScoreWeights) domain.ScoreBreakdown {
+	return c.runPipeline(ScoreContext{
+		Content:    content,
+		Popularity: popularity,
+		GenrePrefs: genrePrefs,
+		Now:        now,
+		Weights:    weights,
+	})
+}
 
-	return popularityComponent + genreBoost + recencyComponent + randomNoise
-}
\ No newline at end of file
+// Preference for a genre, falling back to the configured floor for genres the user hasn't watched
+func (c *Client) genrePreference(genrePrefs map[string]float64, genre string) float64 {
+	if pref, ok := genrePrefs[genre]; ok {
+		return pref
+	}
+	return c.cfg.GenreFallback
+}
+
+// Exploration noise, or zero when disabled
+func (c *Client) scoreNoise() float64 {
+	if c.cfg.DisableNoise {
+		return 0
+	}
+	return (rand.Float64()*0.1 - 0.05) * 0.1
+}
@@ -1,7 +1,12 @@
 package model
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
 	"testing"
 	"time"
 
@@ -9,7 +14,7 @@ import (
 )
 
 func TestScore(t *testing.T) {
-	client := NewClient()
+	client := NewClient(DefaultModelConfig())
 
 	input := ScoreInput{
 		User: &domain.User{
@@ -32,10 +37,10 @@ func TestScore(t *testing.T) {
 		Limit: 2,
 	}
 
-	results, err := client.Score(input)
+	results, _, err := client.Score(context.Background(), input)
 	if err != nil {
 		// 1.5% random failure -> retry
-		results, err = client.Score(input)
+		results, _, err = client.Score(context.Background(), input)
 		if err != nil {
 			t.Fatalf("Score failed twice: %v", err)
 		}
@@ -88,30 +93,908 @@ func TestGenrePreferences(t *testing.T) {
 	}
 }
 
+func TestDecayedGenrePreferences(t *testing.T) {
+	now := time.Now()
+	threeYearsAgo := now.AddDate(-3, 0, 0)
+
+	// drama watched more often but long ago, action watched recently
+	history := []domain.WatchHistoryItem{
+		{Genre: "drama", WatchedAt: threeYearsAgo},
+		{Genre: "drama", WatchedAt: threeYearsAgo},
+		{Genre: "drama", WatchedAt: threeYearsAgo},
+		{Genre: "action", WatchedAt: now},
+		{Genre: "action", WatchedAt: now},
+	}
+
+	// Un-weighted: drama dominates by count
+	plain := calculateGenrePreferenceWeights(history)
+	if plain["drama"] <= plain["action"] {
+		t.Errorf("expected drama > action without decay, got drama=%f action=%f", plain["drama"], plain["action"])
+	}
+
+	// Decayed: recent action outweighs older drama
+	decayed := calculateDecayedGenrePreferenceWeights(history, now)
+	if decayed["action"] <= decayed["drama"] {
+		t.Errorf("expected action > drama with decay, got action=%f drama=%f", decayed["action"], decayed["drama"])
+	}
+
+	// Weights still normalize to 1
+	if sum := decayed["action"] + decayed["drama"]; sum < 0.999 || sum > 1.001 {
+		t.Errorf("expected weights to sum to 1, got %f", sum)
+	}
+}
+
+func TestGenrePreferencesWeightedByCompletion(t *testing.T) {
+	now := time.Now()
+	// One finished action watch against two barely-started drama watches
+	history := []domain.WatchHistoryItem{
+		{Genre: "action", WatchedAt: now, CompletionRatio: 1},
+		{Genre: "drama", WatchedAt: now, CompletionRatio: 0.1},
+		{Genre: "drama", WatchedAt: now, CompletionRatio: 0.05},
+	}
+
+	for name, prefs := range map[string]map[string]float64{
+		"plain":   calculateGenrePreferenceWeights(history),
+		"decayed": calculateDecayedGenrePreferenceWeights(history, now),
+	} {
+		if prefs["action"] <= prefs["drama"] {
+			t.Errorf("%s: expected the finished action watch to outweigh abandoned drama, got action=%f drama=%f", name, prefs["action"], prefs["drama"])
+		}
+		// 1 / (1 + 0.1 + 0.05)
+		if math.Abs(prefs["action"]-1/1.15) > 1e-9 {
+			t.Errorf("%s: expected action=%f, got %f", name, 1/1.15, prefs["action"])
+		}
+	}
+
+	// Unknown completion counts as a finished watch
+	unknown := calculateGenrePreferenceWeights([]domain.WatchHistoryItem{
+		{Genre: "action"},
+		{Genre: "drama", CompletionRatio: 1},
+	})
+	if unknown["action"] != 0.5 || unknown["drama"] != 0.5 {
+		t.Errorf("expected unknown completion to count as finished, got %v", unknown)
+	}
+}
+
+func TestScoreFavorsCompletedWatches(t *testing.T) {
+	client := NewClient(noiselessConfig())
+	now := time.Now()
+
+	results, _, err := client.Score(context.Background(), ScoreInput{
+		User: &domain.User{ID: 1},
+		WatchHistory: []domain.WatchHistoryItem{
+			{ContentID: 1, Genre: "action", WatchedAt: now, CompletionRatio: 1},
+			{ContentID: 2, Genre: "drama", WatchedAt: now, CompletionRatio: 0.05},
+		},
+		Candidates: []domain.Content{
+			{ID: 10, Genre: "drama", PopularityScore: 0.5, CreatedAt: now},
+			{ID: 11, Genre: "action", PopularityScore: 0.5, CreatedAt: now},
+		},
+		Limit: 2,
+	})
+	if err != nil {
+		t.Fatalf("score: %v", err)
+	}
+	if results[0].ContentID != 11 {
+		t.Errorf("expected the action candidate first, got %+v", results)
+	}
+}
+
 func TestEmptyWatchHistory(t *testing.T) {
 	prefs := calculateGenrePreferenceWeights([]domain.WatchHistoryItem{})
 
 	if len(prefs) != 0 {
 		t.Errorf("expected empty prefs, got %v", prefs)
 	}
+
+	decayed := calculateDecayedGenrePreferenceWeights([]domain.WatchHistoryItem{}, time.Now())
+	if len(decayed) != 0 {
+		t.Errorf("expected empty decayed prefs, got %v", decayed)
+	}
+}
+
+func TestDecayedGenrePreferencesHalfLife(t *testing.T) {
+	now := time.Now()
+
+	// A watch one half-life old counts half as much as today's
+	history := []domain.WatchHistoryItem{
+		{Genre: "action", WatchedAt: now},
+		{Genre: "drama", WatchedAt: now.Add(-watchHalfLifeDays * 24 * time.Hour)},
+	}
+	prefs := calculateDecayedGenrePreferenceWeights(history, now)
+	if math.Abs(prefs["action"]-2.0/3) > 1e-9 || math.Abs(prefs["drama"]-1.0/3) > 1e-9 {
+		t.Errorf("expected action 2/3 and drama 1/3 at the half-life, got %v", prefs)
+	}
+
+	// The content half-life setting doesn't move it
+	cfg := DefaultModelConfig()
+	cfg.RecencyHalfLifeDays = 30
+	if got := NewClient(cfg).genrePreferences(history, now); math.Abs(got["drama"]-1.0/3) > 1e-9 {
+		t.Errorf("expected drama 1/3 with a 30-day content half-life, got %v", got)
+	}
 }
 
 func TestRecencyFactor(t *testing.T) {
 	now := time.Now()
 
-	// Content today -> factor close to 1.0
-	recent := calculateRecencyFactor(now, now)
-	if recent < 0.99 {
-		t.Errorf("expected ~1.0 for today, got %f", recent)
+	tests := []struct {
+		halfLifeDays float64
+		age          time.Duration
+		expected     float64
+	}{
+		{365, 0, 1.0},
+		{365, 365 * 24 * time.Hour, 0.5},
+		{365, 730 * 24 * time.Hour, 1.0 / 3},
+		{180, 0, 1.0},
+		{180, 180 * 24 * time.Hour, 0.5},
+		{180, 365 * 24 * time.Hour, 1.0 / (1 + 365.0/180)},
+		{30, 30 * 24 * time.Hour, 0.5},
+	}
+
+	for _, tc := range tests {
+		got := calculateRecencyFactor(now.Add(-tc.age), now, tc.halfLifeDays)
+		if math.Abs(got-tc.expected) > 1e-9 {
+			t.Errorf("half-life %g, age %s: expected %f, got %f", tc.halfLifeDays, tc.age, tc.expected, got)
+		}
+	}
+}
+
+func TestShorterRecencyHalfLifeDecaysFaster(t *testing.T) {
+	now := time.Now()
+	defaultClient := NewClient(DefaultModelConfig())
+	cfg := DefaultModelConfig()
+	cfg.RecencyHalfLifeDays = 180
+	shortClient := NewClient(cfg)
+
+	fresh := now.AddDate(0, 0, -1)
+	old := now.AddDate(0, 0, -270)
+
+	if shortClient.contentRecencyFactor(old, now) >= defaultClient.contentRecencyFactor(old, now) {
+		t.Error("expected older content to decay faster with a 180-day half-life")
+	}
+	oldFactor := shortClient.contentRecencyFactor(old, now)
+	if oldFactor >= 0.5 {
+		t.Errorf("expected content older than the half-life below 0.5, got %f", oldFactor)
+	}
+	// Fresh content is barely affected
+	if diff := defaultClient.contentRecencyFactor(fresh, now) - shortClient.contentRecencyFactor(fresh, now); diff > 0.01 {
+		t.Errorf("expected fresh content to stay near 1.0, differs by %f", diff)
+	}
+
+	// Zero falls back to the default half-life
+	if got, want := NewClient(ModelConfig{}).contentRecencyFactor(old, now), defaultClient.contentRecencyFactor(old, now); got != want {
+		t.Errorf("expected unset half-life to use the default %f, got %f", want, got)
+	}
+}
+
+func TestScoreBreakdownComponents(t *testing.T) {
+	now := time.Now()
+	content := domain.Content{ID: 10, Genre: "action", PopularityScore: 0.8, CreatedAt: now.AddDate(0, -6, 0)}
+	prefs := map[string]float64{"action": 0.6, "drama": 0.4}
+	client := NewClient(DefaultModelConfig())
+
+	for range 100 {
+		b := client.computeFinalScore(content, content.PopularityScore, prefs, now)
+
+		// Components sum to the final score
+		sum := b.PopularityComponent + b.GenreBoost + b.RecencyComponent + b.Noise
+		if math.Abs(sum-b.Final) > 1e-9 {
+			t.Fatalf("components sum %f != final %f", sum, b.Final)
+		}
+
+		// Noise stays within ±0.005
+		if b.Noise < -0.005 || b.Noise > 0.005 {
+			t.Fatalf("noise out of bounds: %f", b.Noise)
+		}
+
+		// Final without noise matches the deterministic components
+		expected := 0.8*0.4 + 0.6*0.35 + calculateRecencyFactor(content.CreatedAt, now, 365)*0.15
+		if math.Abs(b.Final-b.Noise-expected) > 1e-9 {
+			t.Fatalf("expected deterministic score %f, got %f", expected, b.Final-b.Noise)
+		}
+	}
+}
+
+// Score, retrying once on the simulated 1.5% failure
+func scoreWithRetry(t *testing.T, client *Client, input ScoreInput) []domain.ScoredRecommendation {
+	t.Helper()
+	results, _, err := client.Score(context.Background(), input)
+	if err != nil {
+		results, _, err = client.Score(context.Background(), input)
+		if err != nil {
+			t.Fatalf("Score failed twice: %v", err)
+		}
+	}
+	return results
+}
+
+func TestBlendPopularity(t *testing.T) {
+	content := domain.Content{ID: 1, PopularityScore: 0.5}
+
+	// No regional data -> global popularity
+	if got := blendPopularity(content, nil); got != 0.5 {
+		t.Errorf("expected 0.5 without regional data, got %f", got)
+	}
+
+	// 0.9*0.7 + 0.5*0.3 = 0.78
+	got := blendPopularity(content, map[int64]float64{1: 0.9})
+	if math.Abs(got-0.78) > 1e-9 {
+		t.Errorf("expected 0.78 blended popularity, got %f", got)
+	}
+}
+
+func TestRegionalPopularityRanking(t *testing.T) {
+	client := NewClient(DefaultModelConfig())
+	now := time.Now()
+
+	candidates := []domain.Content{
+		{ID: 10, Title: "Hollywood Hit", Genre: "action", PopularityScore: 0.5, CreatedAt: now},
+		{ID: 11, Title: "Anime Hit", Genre: "action", PopularityScore: 0.5, CreatedAt: now},
+	}
+	regional := map[string]map[int64]float64{
+		"US": {10: 0.95, 11: 0.05},
+		"JP": {10: 0.05, 11: 0.95},
+	}
+
+	for country, expectedFirst := range map[string]int64{"US": 10, "JP": 11} {
+		user := &domain.User{ID: 1, Country: country}
+		results := scoreWithRetry(t, client, ScoreInput{
+			User:               user,
+			Candidates:         candidates,
+			RegionalPopularity: regional[user.Country],
+			Limit:              2,
+		})
+
+		if results[0].ContentID != expectedFirst {
+			t.Errorf("%s: expected content %d first, got %d", country, expectedFirst, results[0].ContentID)
+		}
+		// Response keeps the global popularity
+		if results[0].PopularityScore != 0.5 {
+			t.Errorf("%s: expected global popularity 0.5, got %f", country, results[0].PopularityScore)
+		}
+	}
+}
+
+func TestScoreNormalization(t *testing.T) {
+	client := NewClient(DefaultModelConfig())
+	now := time.Now()
+
+	candidates := []domain.Content{
+		{ID: 1, Genre: "action", PopularityScore: 0.9, CreatedAt: now},
+		{ID: 2, Genre: "drama", PopularityScore: 0.5, CreatedAt: now},
+		{ID: 3, Genre: "comedy", PopularityScore: 0.1, CreatedAt: now},
+	}
+	input := ScoreInput{
+		User:       &domain.User{ID: 1},
+		Candidates: candidates,
+		Limit:      3,
+		Normalize:  true,
+	}
+
+	// On: top is 1.0, bottom is 0.0, order preserved
+	normalized := scoreWithRetry(t, client, input)
+	if normalized[0].Score != 1.0 {
+		t.Errorf("expected top score 1.0, got %f", normalized[0].Score)
+	}
+	if normalized[2].Score != 0.0 {
+		t.Errorf("expected bottom score 0.0, got %f", normalized[2].Score)
+	}
+	if normalized[0].ContentID != 1 || normalized[2].ContentID != 3 {
+		t.Errorf("expected order 1..3, got %d..%d", normalized[0].ContentID, normalized[2].ContentID)
+	}
+
+	// Off: raw scores
+	input.Normalize = false
+	raw := scoreWithRetry(t, client, input)
+	if raw[0].Score >= 1.0 || raw[2].Score <= 0.0 {
+		t.Errorf("expected raw scores, got top=%f bottom=%f", raw[0].Score, raw[2].Score)
+	}
+}
+
+func TestSortByScoreBreaksTiesByContentID(t *testing.T) {
+	for run := 0; run < 20; run++ {
+		scored := []domain.ScoredRecommendation{
+			{ContentID: 7, Score: 0.5},
+			{ContentID: 3, Score: 0.5},
+			{ContentID: 9, Score: 0.8},
+			{ContentID: 1, Score: 0.5},
+		}
+		// Shuffle the input so ties can't rely on their original order
+		rand.Shuffle(len(scored), func(i, j int) { scored[i], scored[j] = scored[j], scored[i] })
+		sortByScore(scored)
+
+		var ids []int64
+		for _, s := range scored {
+			ids = append(ids, s.ContentID)
+		}
+		if fmt.Sprint(ids) != "[9 1 3 7]" {
+			t.Fatalf("run %d: expected order [9 1 3 7], got %v", run, ids)
+		}
+	}
+}
+
+func TestScoreEqualScoresOrderedByContentID(t *testing.T) {
+	cfg := DefaultModelConfig()
+	cfg.FailureRate = 0
+	cfg.MinLatency, cfg.MaxLatency = 0, 0
+	// Without noise identical candidates always tie
+	cfg.DisableNoise = true
+	client := NewClient(cfg)
+
+	created := time.Now().AddDate(0, -1, 0)
+	input := ScoreInput{
+		User:  &domain.User{ID: 1},
+		Limit: 2,
+	}
+	for _, id := range []int64{5, 2} {
+		input.Candidates = append(input.Candidates, domain.Content{ID: id, Genre: "drama", PopularityScore: 0.5, CreatedAt: created})
+	}
+
+	for run := 0; run < 50; run++ {
+		results, _, err := client.Score(context.Background(), input)
+		if err != nil {
+			t.Fatalf("score: %v", err)
+		}
+		if results[0].ContentID != 2 {
+			t.Fatalf("run %d: expected content 2 first on a tie, got %+v", run, results)
+		}
+	}
+}
+
+func TestSortByScoreTiesUsePopularity(t *testing.T) {
+	scored := []domain.ScoredRecommendation{
+		{ContentID: 1, Score: 0.5004, PopularityScore: 0.4},
+		{ContentID: 2, Score: 0.4996, PopularityScore: 0.6},
+		{ContentID: 3, Score: 0.700, PopularityScore: 0.1},
+		{ContentID: 4, Score: 0.498, PopularityScore: 0.6},
+	}
+	sortByScore(scored)
+
+	var ids []int64
+	for _, s := range scored {
+		ids = append(ids, s.ContentID)
+	}
+	// 1 and 2 both report 0.500; 4 is lower despite its popularity
+	if fmt.Sprint(ids) != "[3 2 1 4]" {
+		t.Errorf("expected order [3 2 1 4], got %v", ids)
+	}
+}
+
+func TestSortByScoreIsTransitive(t *testing.T) {
+	// Neighbours are close in score but the ends are far apart, which a
+	// closeness threshold would order inconsistently
+	want := []int64{1, 2, 3, 4, 5, 6}
+	for run := 0; run < 50; run++ {
+		var scored []domain.ScoredRecommendation
+		for i, id := range want {
+			scored = append(scored, domain.ScoredRecommendation{
+				ContentID: id, Score: 0.55 - float64(i)*0.006, PopularityScore: float64(i) * 0.1,
+			})
+		}
+		rand.Shuffle(len(scored), func(i, j int) { scored[i], scored[j] = scored[j], scored[i] })
+		sortByScore(scored)
+
+		var ids []int64
+		for _, s := range scored {
+			ids = append(ids, s.ContentID)
+		}
+		if !reflect.DeepEqual(ids, want) {
+			t.Fatalf("run %d: expected order %v, got %v", run, want, ids)
+		}
+	}
+}
+
+func TestScoreNoHistoryOrderIsStable(t *testing.T) {
+	cfg := DefaultModelConfig()
+	cfg.FailureRate = 0
+	cfg.MinLatency, cfg.MaxLatency = 0, 0
+	cfg.DisableNoise = true
+	client := NewClient(cfg)
+
+	// Without history every genre scores the same, so candidates sharing a
+	// popularity tie
+	created := time.Now().AddDate(0, -1, 0)
+	input := ScoreInput{User: &domain.User{ID: 1}, Limit: 12}
+	for id := int64(1); id <= 12; id++ {
+		input.Candidates = append(input.Candidates, domain.Content{
+			ID: id, Genre: domain.Genres[id%5], PopularityScore: 0.3 + float64(id%3)*0.2, CreatedAt: created,
+		})
+	}
+
+	want := []int64{2, 5, 8, 11, 1, 4, 7, 10, 3, 6, 9, 12}
+	for run := 0; run < 200; run++ {
+		rand.Shuffle(len(input.Candidates), func(i, j int) {
+			input.Candidates[i], input.Candidates[j] = input.Candidates[j], input.Candidates[i]
+		})
+		results, _, err := client.Score(context.Background(), input)
+		if err != nil {
+			t.Fatalf("run %d: score: %v", run, err)
+		}
+		var ids []int64
+		for _, r := range results {
+			ids = append(ids, r.ContentID)
+		}
+		if !reflect.DeepEqual(ids, want) {
+			t.Fatalf("run %d: expected order %v, got %v", run, want, ids)
+		}
+	}
+}
+
+func TestNormalizeScoresEqual(t *testing.T) {
+	scored := []domain.ScoredRecommendation{{Score: 0.4}, {Score: 0.4}}
+	normalizeScores(scored)
+	for _, s := range scored {
+		if s.Score != 1.0 {
+			t.Errorf("expected equal scores to normalize to 1.0, got %f", s.Score)
+		}
+	}
+
+	// Empty input is a no-op
+	normalizeScores(nil)
+}
+
+func TestStrategiesOrderDifferently(t *testing.T) {
+	client := NewClient(DefaultModelConfig())
+	now := time.Now()
+
+	input := ScoreInput{
+		User: &domain.User{ID: 1},
+		WatchHistory: []domain.WatchHistoryItem{
+			{ContentID: 10, Genre: "comedy", WatchedAt: now},
+			{ContentID: 11, Genre: "comedy", WatchedAt: now},
+		},
+		Candidates: []domain.Content{
+			{ID: 1, Genre: "drama", PopularityScore: 0.9, CreatedAt: now.AddDate(-3, 0, 0)},
+			{ID: 2, Genre: "action", PopularityScore: 0.3, CreatedAt: now.AddDate(0, 0, -2)},
+			{ID: 3, Genre: "comedy", PopularityScore: 0.5, CreatedAt: now.AddDate(-1, 0, 0)},
+		},
+		Limit: 3,
+	}
+
+	tests := []struct {
+		strategy domain.Strategy
+		expected []int64
+	}{
+		{domain.StrategyPopularityOnly, []int64{1, 3, 2}},
+		{domain.StrategyGenreWeighted, []int64{3, 1, 2}},
+		{domain.StrategyRecencyFirst, []int64{2, 3, 1}},
+		{"", []int64{3, 1, 2}},
+	}
+
+	for _, tc := range tests {
+		input.Strategy = tc.strategy
+		results := scoreWithRetry(t, client, input)
+		for i, id := range tc.expected {
+			if results[i].ContentID != id {
+				t.Errorf("%q: expected content %d at position %d, got %d", tc.strategy, id, i, results[i].ContentID)
+			}
+		}
+	}
+}
+
+func TestGenreFallbackLiftsUnseenGenres(t *testing.T) {
+	now := time.Now()
+	input := ScoreInput{
+		User: &domain.User{ID: 1},
+		WatchHistory: []domain.WatchHistoryItem{
+			{ContentID: 10, Genre: "comedy", WatchedAt: now},
+			{ContentID: 11, Genre: "comedy", WatchedAt: now},
+			{ContentID: 12, Genre: "action", WatchedAt: now},
+		},
+		Candidates: []domain.Content{
+			{ID: 1, Genre: "comedy", PopularityScore: 0.5, CreatedAt: now},
+			{ID: 2, Genre: "drama", PopularityScore: 0.5, CreatedAt: now},
+		},
+		Limit: 2,
+	}
+
+	// Default floor: the watched genre ranks first
+	results := scoreWithRetry(t, NewClient(DefaultModelConfig()), input)
+	if results[0].ContentID != 1 {
+		t.Errorf("expected watched-genre content first with default fallback, got %d", results[0].ContentID)
+	}
+
+	// A high floor lifts the unseen genre above it
+	results = scoreWithRetry(t, NewClient(ModelConfig{GenreFallback: 0.9}), input)
+	if results[0].ContentID != 2 {
+		t.Errorf("expected unseen-genre content first with fallback 0.9, got %d", results[0].ContentID)
+	}
+}
+
+func TestCapGenrePreferences(t *testing.T) {
+	now := time.Now()
+
+	// All action: the weight is capped, nothing else to spread to
+	allAction := []domain.WatchHistoryItem{
+		{Genre: "action", WatchedAt: now},
+		{Genre: "action", WatchedAt: now},
+		{Genre: "action", WatchedAt: now},
+	}
+	prefs := NewClient(ModelConfig{GenreFallback: 0.1, GenreCap: 0.7}).genrePreferences(allAction, now)
+	if math.Abs(prefs["action"]-0.7) > 1e-9 {
+		t.Errorf("expected action capped at 0.7, got %f", prefs["action"])
+	}
+
+	// Action-heavy with a secondary genre: the excess moves to drama
+	history := append(allAction,
+		domain.WatchHistoryItem{Genre: "action", WatchedAt: now},
+		domain.WatchHistoryItem{Genre: "action", WatchedAt: now},
+		domain.WatchHistoryItem{Genre: "action", WatchedAt: now},
+		domain.WatchHistoryItem{Genre: "action", WatchedAt: now},
+		domain.WatchHistoryItem{Genre: "action", WatchedAt: now},
+		domain.WatchHistoryItem{Genre: "action", WatchedAt: now},
+		domain.WatchHistoryItem{Genre: "drama", WatchedAt: now},
+	)
+	uncapped := NewClient(DefaultModelConfig()).genrePreferences(history, now)
+	capped := NewClient(ModelConfig{GenreFallback: 0.1, GenreCap: 0.7}).genrePreferences(history, now)
+
+	if math.Abs(uncapped["action"]-0.9) > 1e-9 {
+		t.Fatalf("expected uncapped action weight 0.9, got %f", uncapped["action"])
+	}
+	if math.Abs(capped["action"]-0.7) > 1e-9 {
+		t.Errorf("expected action capped at 0.7, got %f", capped["action"])
+	}
+	if math.Abs(capped["drama"]-0.3) > 1e-9 {
+		t.Errorf("expected drama to absorb the excess (0.3), got %f", capped["drama"])
+	}
+}
+
+func TestCapGenrePreferencesMultipleRounds(t *testing.T) {
+	// Redistribution pushes comedy over the cap, which then spills to drama
+	prefs := map[string]float64{"action": 0.6, "comedy": 0.3, "drama": 0.1}
+	capGenrePreferences(prefs, 0.35)
+
+	total := 0.0
+	for genre, weight := range prefs {
+		if weight > 0.35+1e-9 {
+			t.Errorf("%s: weight %f exceeds cap", genre, weight)
+		}
+		total += weight
+	}
+	if math.Abs(total-1.0) > 1e-9 {
+		t.Errorf("expected weights to still sum to 1, got %f", total)
+	}
+}
+
+func TestScoreFailureRate(t *testing.T) {
+	input := ScoreInput{
+		User:       &domain.User{ID: 1},
+		Candidates: []domain.Content{{ID: 1, Genre: "action", PopularityScore: 0.5, CreatedAt: time.Now()}},
+		Limit:      1,
+	}
+
+	// Always fails, and with no latency the calls return immediately
+	failing := NewClient(ModelConfig{GenreFallback: 0.1, FailureRate: 1.0})
+	start := time.Now()
+	for range 50 {
+		_, _, err := failing.Score(context.Background(), input)
+		var inferenceErr *ModelInferenceError
+		if !errors.As(err, &inferenceErr) {
+			t.Fatalf("expected ModelInferenceError, got %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected zero-latency calls, took %v", elapsed)
+	}
+
+	// Never fails
+	reliable := NewClient(ModelConfig{GenreFallback: 0.1, FailureRate: 0})
+	for range 200 {
+		if _, _, err := reliable.Score(context.Background(), input); err != nil {
+			t.Fatalf("expected no failures with rate 0, got %v", err)
+		}
+	}
+}
+
+func TestLatencyBounds(t *testing.T) {
+	client := NewClient(ModelConfig{MinLatency: 10 * time.Millisecond, MaxLatency: 20 * time.Millisecond})
+	for range 100 {
+		if d := client.latency(); d < 10*time.Millisecond || d > 20*time.Millisecond {
+			t.Fatalf("latency %v outside [10ms, 20ms]", d)
+		}
+	}
+
+	fixed := NewClient(ModelConfig{MinLatency: 5 * time.Millisecond, MaxLatency: 5 * time.Millisecond})
+	if d := fixed.latency(); d != 5*time.Millisecond {
+		t.Errorf("expected fixed 5ms latency, got %v", d)
+	}
+}
+
+func TestGenreBoostRaisesRanking(t *testing.T) {
+	// Exact genre matches only, so drama starts from the fallback
+	client := NewClient(DefaultModelConfig())
+	now := time.Now()
+
+	input := ScoreInput{
+		User: &domain.User{ID: 1},
+		WatchHistory: []domain.WatchHistoryItem{
+			{ContentID: 10, Genre: "comedy", WatchedAt: now},
+			{ContentID: 11, Genre: "sci-fi", WatchedAt: now},
+			{ContentID: 12, Genre: "comedy", WatchedAt: now},
+		},
+		Candidates: []domain.Content{
+			{ID: 1, Genre: "comedy", PopularityScore: 0.5, CreatedAt: now},
+			{ID: 2, Genre: "sci-fi", PopularityScore: 0.5, CreatedAt: now},
+			{ID: 3, Genre: "drama", PopularityScore: 0.5, CreatedAt: now},
+		},
+		Limit: 3,
+	}
+
+	results := scoreWithRetry(t, client, input)
+	if results[0].ContentID != 1 {
+		t.Fatalf("expected the most watched genre first without boosts, got %d", results[0].ContentID)
+	}
+
+	input.GenreBoosts = map[string]float64{"sci-fi": 3}
+	results = scoreWithRetry(t, client, input)
+	if results[0].ContentID != 2 {
+		t.Errorf("expected boosted sci-fi first, got %d", results[0].ContentID)
+	}
+
+	// An unwatched genre is boosted from the fallback preference
+	input.GenreBoosts = map[string]float64{"drama": 5}
+	results = scoreWithRetry(t, client, input)
+	if results[1].ContentID != 3 {
+		t.Errorf("expected boosted drama to overtake sci-fi, got order %d, %d, %d",
+			results[0].ContentID, results[1].ContentID, results[2].ContentID)
+	}
+}
+
+func TestScoreDeduplicatesContentIDs(t *testing.T) {
+	cfg := DefaultModelConfig()
+	cfg.FailureRate = 0
+	cfg.MinLatency, cfg.MaxLatency = 0, 0
+	client := NewClient(cfg)
+
+	created := time.Now().AddDate(0, -1, 0)
+	input := ScoreInput{User: &domain.User{ID: 1}, Limit: 2}
+	// Content 7 appears three times with different popularity; the most
+	// popular copy scores highest
+	for _, c := range []struct {
+		id         int64
+		popularity float64
+	}{{7, 0.2}, {8, 0.3}, {7, 0.9}, {7, 0.5}} {
+		input.Candidates = append(input.Candidates, domain.Content{ID: c.id, Genre: "drama", PopularityScore: c.popularity, CreatedAt: created})
+	}
+
+	results, _, err := client.Score(context.Background(), input)
+	if err != nil {
+		t.Fatalf("score: %v", err)
+	}
+	// Duplicates are dropped before the limit, so both titles fit
+	if len(results) != 2 || results[0].ContentID != 7 || results[1].ContentID != 8 {
+		t.Fatalf("expected unique content 7 then 8, got %+v", results)
+	}
+	if results[0].PopularityScore != 0.9 {
+		t.Errorf("expected the highest-scoring copy of content 7, got popularity %g", results[0].PopularityScore)
+	}
+}
+
+func TestDedupeByContentID(t *testing.T) {
+	scored := []domain.ScoredRecommendation{
+		{ContentID: 3, Score: 0.9}, {ContentID: 1, Score: 0.8}, {ContentID: 3, Score: 0.5}, {ContentID: 1, Score: 0.2},
+	}
+	got := dedupeByContentID(scored)
+	if len(got) != 2 || got[0].ContentID != 3 || got[0].Score != 0.9 || got[1].ContentID != 1 || got[1].Score != 0.8 {
+		t.Errorf("expected first instance of each ID, got %+v", got)
+	}
+}
+
+func TestScoreWeightOverrides(t *testing.T) {
+	client := NewClient(noiselessConfig())
+	now := time.Now()
+	input := ScoreInput{
+		User:         &domain.User{ID: 1},
+		// A weak drama preference, which only wins once genre outweighs popularity
+		WatchHistory: []domain.WatchHistoryItem{
+			{ContentID: 1, Genre: "drama", WatchedAt: now},
+			{ContentID: 2, Genre: "comedy", WatchedAt: now},
+			{ContentID: 3, Genre: "comedy", WatchedAt: now},
+		},
+		Candidates: []domain.Content{
+			{ID: 10, Genre: "action", PopularityScore: 0.9, CreatedAt: now.AddDate(-5, 0, 0)},
+			{ID: 11, Genre: "drama", PopularityScore: 0.2, CreatedAt: now.AddDate(-5, 0, 0)},
+		},
+		Limit: 2,
+	}
+	first := func(input ScoreInput) int64 {
+		t.Helper()
+		results, _, err := client.Score(context.Background(), input)
+		if err != nil {
+			t.Fatalf("score: %v", err)
+		}
+		return results[0].ContentID
+	}
+
+	if got := first(input); got != 10 {
+		t.Errorf("default weights: expected popular content first, got %d", got)
+	}
+	input.Weights = &domain.ScoreWeights{Popularity: 0.1, Genre: 0.9}
+	if got := first(input); got != 11 {
+		t.Errorf("genre-heavy weights: expected the watched genre first, got %d", got)
 	}
 
-	// Content 1 year -> factor close to 0.5
-	oneYearAgo := now.AddDate(-1, 0, 0)
-	old := calculateRecencyFactor(oneYearAgo, now)
-	if old < 0.45 || old > 0.55 {
-		t.Errorf("expected ~0.5 for 1 year, got %f", old)
+	// Other strategies keep their own weights
+	input.Strategy = domain.StrategyPopularityOnly
+	if got := first(input); got != 10 {
+		t.Errorf("popularity_only: expected overrides to be ignored, got %d first", got)
 	}
+}
 
-	fmt.Printf("  Today: %.3f\n", recent)
-	fmt.Printf("  1 year ago: %.3f\n", old)
-}
\ No newline at end of file
+func TestScoreWithoutNoiseIsReproducible(t *testing.T) {
+	cfg := DefaultModelConfig()
+	cfg.MinLatency, cfg.MaxLatency, cfg.FailureRate = 0, 0, 0
+	cfg.DisableNoise = true
+	client := NewClient(cfg)
+
+	now := time.Now()
+	input := ScoreInput{
+		User:         &domain.User{ID: 1},
+		WatchHistory: []domain.WatchHistoryItem{{ContentID: 1, Genre: "action", WatchedAt: now}},
+		Limit:        20,
+	}
+	for id := int64(10); id < 30; id++ {
+		input.Candidates = append(input.Candidates, domain.Content{ID: id, Genre: domain.Genres[id%5], PopularityScore: 0.5, CreatedAt: now.AddDate(0, 0, -int(id))})
+	}
+
+	for _, strategy := range []domain.Strategy{domain.StrategyGenreWeighted, domain.StrategyRecencyFirst} {
+		input.Strategy = strategy
+		first, _, err := client.Score(context.Background(), input)
+		if err != nil {
+			t.Fatalf("%s: score: %v", strategy, err)
+		}
+		for i := 0; i < 5; i++ {
+			again, _, err := client.Score(context.Background(), input)
+			if err != nil {
+				t.Fatalf("%s: score again: %v", strategy, err)
+			}
+			if !reflect.DeepEqual(first, again) {
+				t.Fatalf("%s: expected identical scores without noise, got %v and %v", strategy, first, again)
+			}
+		}
+	}
+
+	if b := client.Breakdown(input.Candidates[0], input.WatchHistory, nil); b.Noise != 0 {
+		t.Errorf("expected zero noise, got %g", b.Noise)
+	}
+}
+
+func TestScoreStopsAtDeadline(t *testing.T) {
+	cfg := DefaultModelConfig()
+	cfg.MinLatency, cfg.MaxLatency, cfg.FailureRate = 0, 0, 0
+	cfg.DisableNoise = true
+	// Each candidate takes a millisecond, so the deadline passes partway through
+	slow := ScoreComponent{Name: "slow", Score: func(c *Client, sc ScoreContext) float64 {
+		time.Sleep(time.Millisecond)
+		return 0
+	}}
+	client := NewClient(cfg, WithScoreComponent(slow))
+
+	now := time.Now()
+	input := ScoreInput{User: &domain.User{ID: 1}, Limit: 1000}
+	for id := int64(1); id <= 1000; id++ {
+		input.Candidates = append(input.Candidates, domain.Content{ID: id, Genre: domain.Genres[id%5], PopularityScore: float64(id%100) / 100, CreatedAt: now})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	results, truncated, err := client.Score(ctx, input)
+	if err != nil {
+		t.Fatalf("expected partial results, got %v", err)
+	}
+	if !truncated {
+		t.Error("expected the result to be flagged truncated")
+	}
+	if len(results) == 0 || len(results) >= len(input.Candidates) {
+		t.Fatalf("expected a partial result, got %d of %d", len(results), len(input.Candidates))
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Score > results[i-1].Score {
+			t.Fatalf("expected results sorted by score, got %g after %g", results[i].Score, results[i-1].Score)
+		}
+	}
+
+	// Scoring with time to spare is complete
+	results, truncated, err = client.Score(context.Background(), ScoreInput{User: input.User, Limit: 10, Candidates: input.Candidates[:10]})
+	if err != nil || truncated || len(results) != 10 {
+		t.Errorf("expected 10 untruncated results, got %d truncated=%v err=%v", len(results), truncated, err)
+	}
+}
+
+func TestScoreDeadlineShorterThanLatencyReturnsPartialRanking(t *testing.T) {
+	cfg := DefaultModelConfig()
+	cfg.MinLatency, cfg.MaxLatency, cfg.FailureRate = 100*time.Millisecond, 100*time.Millisecond, 0
+	cfg.DisableNoise = true
+	client := NewClient(cfg)
+
+	now := time.Now()
+	input := ScoreInput{User: &domain.User{ID: 1}, Limit: 100}
+	for id := int64(1); id <= 100; id++ {
+		input.Candidates = append(input.Candidates, domain.Content{ID: id, Genre: domain.Genres[id%5], PopularityScore: float64(id%10) / 10, CreatedAt: now})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	results, truncated, err := client.Score(ctx, input)
+	if err != nil {
+		t.Fatalf("expected a partial ranking, got %v", err)
+	}
+	if !truncated || len(results) == 0 || len(results) >= len(input.Candidates) {
+		t.Fatalf("expected a truncated partial ranking, got %d of %d truncated=%v", len(results), len(input.Candidates), truncated)
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Score > results[i-1].Score {
+			t.Fatalf("expected results sorted by score, got %g after %g", results[i].Score, results[i-1].Score)
+		}
+	}
+}
+
+func TestScoreCancelledFails(t *testing.T) {
+	cfg := DefaultModelConfig()
+	cfg.MinLatency, cfg.MaxLatency, cfg.FailureRate = 50*time.Millisecond, 50*time.Millisecond, 0
+	client := NewClient(cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	input := ScoreInput{User: &domain.User{ID: 1}, Limit: 5, Candidates: []domain.Content{{ID: 1}}}
+	if _, _, err := client.Score(ctx, input); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled, got %v", err)
+	}
+}
+
+// Scores a fixed input under the default config. A change here means scoring
+// output changed: bump ModelVersion and update the expected scores together.
+func TestModelVersionPinsScoringOutput(t *testing.T) {
+	const version = "1.3.0"
+	expected := []struct {
+		id    int64
+		score float64
+	}{
+		{1, 0.566},
+		{4, 0.564},
+		{2, 0.536},
+		{3, 0.517},
+		{5, 0.158},
+	}
+
+	cfg := DefaultModelConfig()
+	cfg.MinLatency, cfg.MaxLatency, cfg.FailureRate = 0, 0, 0
+	cfg.DisableNoise = true
+	now := time.Now()
+	days := func(n int) time.Time { return now.AddDate(0, 0, -n) }
+	input := ScoreInput{
+		User: &domain.User{ID: 1, Age: 30, Country: "US", SubscriptionType: "basic"},
+		WatchHistory: []domain.WatchHistoryItem{
+			{ContentID: 100, Genre: "action", WatchedAt: days(1), CompletionRatio: 1},
+			{ContentID: 101, Genre: "action", WatchedAt: days(20), CompletionRatio: 0.5},
+			{ContentID: 102, Genre: "thriller", WatchedAt: days(5), CompletionRatio: 1},
+			{ContentID: 103, Genre: "drama", WatchedAt: days(60), CompletionRatio: 1},
+		},
+		Candidates: []domain.Content{
+			{ID: 1, Genre: "action", PopularityScore: 0.4, CreatedAt: days(30)},
+			{ID: 2, Genre: "thriller", PopularityScore: 0.9, CreatedAt: days(400)},
+			{ID: 3, Genre: "drama", PopularityScore: 0.7, CreatedAt: days(10)},
+			{ID: 4, Genre: "comedy", PopularityScore: 0.95, CreatedAt: days(2)},
+			{ID: 5, Genre: "sci-fi", PopularityScore: 0.2, CreatedAt: days(900)},
+		},
+		RegionalPopularity: map[int64]float64{1: 0.8},
+		Limit:              5,
+	}
+
+	results, _, err := NewClient(cfg).Score(context.Background(), input)
+	if err != nil {
+		t.Fatalf("score: %v", err)
+	}
+	if ModelVersion != version {
+		t.Fatalf("ModelVersion is %s but the expected scores are for %s; update them", ModelVersion, version)
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(results))
+	}
+	for i, r := range results {
+		if r.ContentID != expected[i].id || math.Abs(r.Score-expected[i].score) > 5e-4 {
+			t.Errorf("position %d: expected content %d scoring %.3f, got %d scoring %.3f; bump ModelVersion if scoring changed",
+				i, expected[i].id, expected[i].score, r.ContentID, r.Score)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+// What a score component sees of one candidate
+type ScoreContext struct {
+	Content domain.Content
+	// Global popularity blended with the user's regional popularity
+	Popularity float64
+	// The user's genre preferences after decay, similarity credit and boosts
+	GenrePrefs map[string]float64
+	Now        time.Time
+	// Weights for the built-in components: DefaultWeights unless the request
+	// overrides them
+	Weights domain.ScoreWeights
+}
+
+// A named, weighted contribution to a genre_weighted score. The built-in
+// names fill their own breakdown fields; any other name is reported under the
+// breakdown's Extra.
+type ScoreComponent struct {
+	Name  string
+	Score func(c *Client, sc ScoreContext) float64
+}
+
+// Names of the built-in components
+const (
+	ComponentPopularity = "popularity"
+	ComponentGenre      = "genre"
+	ComponentRecency    = "recency"
+	ComponentNoise      = "noise"
+)
+
+// Popularity 40%, genre preference 35%, recency 15%
+func DefaultWeights() domain.ScoreWeights {
+	return domain.ScoreWeights{Popularity: 0.4, Genre: 0.35, Recency: 0.15}
+}
+
+// Popularity, genre preference and recency by the context's weights, plus
+// exploration noise
+func DefaultPipeline() []ScoreComponent {
+	return []ScoreComponent{
+		{Name: ComponentPopularity, Score: func(c *Client, sc ScoreContext) float64 {
+			return sc.Popularity * sc.Weights.Popularity
+		}},
+		{Name: ComponentGenre, Score: func(c *Client, sc ScoreContext) float64 {
+			return c.genrePreference(sc.GenrePrefs, sc.Content.Genre) * sc.Weights.Genre
+		}},
+		{Name: ComponentRecency, Score: func(c *Client, sc ScoreContext) float64 {
+			return c.contentRecencyFactor(sc.Content.CreatedAt, sc.Now) * sc.Weights.Recency
+		}},
+		{Name: ComponentNoise, Score: func(c *Client, sc ScoreContext) float64 {
+			return c.scoreNoise()
+		}},
+	}
+}
+
+// Configures a Client beyond its ModelConfig
+type Option func(*Client)
+
+// Score genre_weighted with exactly these components instead of DefaultPipeline
+func WithPipeline(components ...ScoreComponent) Option {
+	return func(c *Client) {
+		c.pipeline = components
+	}
+}
+
+// Add a component after the rest of the pipeline
+func WithScoreComponent(component ScoreComponent) Option {
+	return func(c *Client) {
+		c.pipeline = append(c.pipeline, component)
+	}
+}
+
+// Run the pipeline over a candidate; Final is the sum of every contribution
+func (c *Client) runPipeline(sc ScoreContext) domain.ScoreBreakdown {
+	var b domain.ScoreBreakdown
+	for _, component := range c.pipeline {
+		contribution := component.Score(c, sc)
+		switch component.Name {
+		case ComponentPopularity:
+			b.PopularityComponent += contribution
+		case ComponentGenre:
+			b.GenreBoost += contribution
+		case ComponentRecency:
+			b.RecencyComponent += contribution
+		case ComponentNoise:
+			b.Noise += contribution
+		default:
+			if b.Extra == nil {
+				b.Extra = make(map[string]float64)
+			}
+			b.Extra[component.Name] += contribution
+		}
+		b.Final += contribution
+	}
+	return b
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+func noiselessConfig() ModelConfig {
+	cfg := DefaultModelConfig()
+	cfg.MinLatency, cfg.MaxLatency, cfg.FailureRate = 0, 0, 0
+	cfg.DisableNoise = true
+	return cfg
+}
+
+func TestDefaultPipelineMatchesFormula(t *testing.T) {
+	now := time.Now()
+	content := domain.Content{ID: 1, Genre: "action", PopularityScore: 0.8, CreatedAt: now.AddDate(0, -6, 0)}
+	prefs := map[string]float64{"action": 0.6}
+
+	b := NewClient(noiselessConfig()).computeFinalScore(content, content.PopularityScore, prefs, now)
+
+	// Bit-for-bit the sum the monolithic scorer computed, at runtime precision
+	popularity := content.PopularityScore * 0.4
+	genre := prefs["action"] * 0.35
+	recency := calculateRecencyFactor(content.CreatedAt, now, defaultRecencyHalfLifeDays) * 0.15
+	noise := 0.0
+	want := domain.ScoreBreakdown{
+		PopularityComponent: popularity,
+		GenreBoost:          genre,
+		RecencyComponent:    recency,
+		Final:               popularity + genre + recency + noise,
+	}
+	if b.PopularityComponent != want.PopularityComponent || b.GenreBoost != want.GenreBoost ||
+		b.RecencyComponent != want.RecencyComponent || b.Noise != 0 || b.Final != want.Final || b.Extra != nil {
+		t.Errorf("expected %+v, got %+v", want, b)
+	}
+}
+
+func TestCustomScoreComponent(t *testing.T) {
+	dramaBonus := ScoreComponent{Name: "drama_bonus", Score: func(c *Client, sc ScoreContext) float64 {
+		if sc.Content.Genre == "drama" {
+			return 0.5
+		}
+		return 0
+	}}
+	plain := NewClient(noiselessConfig())
+	custom := NewClient(noiselessConfig(), WithScoreComponent(dramaBonus))
+
+	now := time.Now()
+	input := ScoreInput{
+		User:  &domain.User{ID: 1},
+		Limit: 2,
+		Candidates: []domain.Content{
+			{ID: 1, Genre: "action", PopularityScore: 0.9, CreatedAt: now},
+			{ID: 2, Genre: "drama", PopularityScore: 0.1, CreatedAt: now},
+		},
+	}
+	plainTop, _, err := plain.Score(context.Background(), input)
+	if err != nil {
+		t.Fatalf("score: %v", err)
+	}
+	customTop, _, err := custom.Score(context.Background(), input)
+	if err != nil {
+		t.Fatalf("score with custom component: %v", err)
+	}
+	if plainTop[0].ContentID != 1 || customTop[0].ContentID != 2 {
+		t.Errorf("expected the bonus to lift drama to the top, got %d without and %d with", plainTop[0].ContentID, customTop[0].ContentID)
+	}
+
+	// The extra contribution is reported on top of the default components
+	drama := input.Candidates[1]
+	base := plain.computeFinalScore(drama, drama.PopularityScore, nil, now)
+	b := custom.computeFinalScore(drama, drama.PopularityScore, nil, now)
+	if b.Extra["drama_bonus"] != 0.5 || b.Final != base.Final+0.5 {
+		t.Errorf("expected a 0.5 drama_bonus on top of %f, got %+v", base.Final, b)
+	}
+	if b.PopularityComponent != base.PopularityComponent || b.GenreBoost != base.GenreBoost {
+		t.Errorf("expected default components unchanged, got %+v vs %+v", b, base)
+	}
+}
+
+func TestWithPipelineReplacesComponents(t *testing.T) {
+	popularityOnly := DefaultPipeline()[:1]
+	client := NewClient(noiselessConfig(), WithPipeline(popularityOnly...))
+
+	content := domain.Content{ID: 1, Genre: "action", PopularityScore: 0.5, CreatedAt: time.Now()}
+	b := client.Breakdown(content, nil, nil)
+	if b.Final != 0.5*0.4 || b.GenreBoost != 0 || b.RecencyComponent != 0 {
+		t.Errorf("expected only the popularity component, got %+v", b)
+	}
+}
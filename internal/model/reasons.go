@@ -0,0 +1,46 @@
+package model
+
+import (
+	"sort"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+// A component earns a reason when it contributes at least this share of the
+// largest component
+const reasonShareOfDominant = 0.5
+
+// Human-readable reasons for a score, one per component that drove it, largest
+// first. A genre component from the fallback for unwatched genres still counts
+// towards dominance but gives no reason, since it is not an interest.
+func scoreReasons(b domain.ScoreBreakdown, genre string, genrePrefs map[string]float64) []string {
+	type factor struct {
+		contribution float64
+		reason       string
+	}
+	genreReason := ""
+	if _, ok := genrePrefs[genre]; ok {
+		genreReason = "matches your interest in " + genre
+	}
+	factors := []factor{
+		{b.PopularityComponent, "highly popular"},
+		{b.RecencyComponent, "new release"},
+		{b.GenreBoost, genreReason},
+	}
+	// Stable so equal contributions keep the order above
+	sort.SliceStable(factors, func(i, j int) bool {
+		return factors[i].contribution > factors[j].contribution
+	})
+
+	dominant := factors[0].contribution
+	if dominant <= 0 {
+		return nil
+	}
+	var reasons []string
+	for _, f := range factors {
+		if f.reason != "" && f.contribution >= dominant*reasonShareOfDominant {
+			reasons = append(reasons, f.reason)
+		}
+	}
+	return reasons
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+func TestScoreReasonsDominantComponent(t *testing.T) {
+	prefs := map[string]float64{"action": 0.8}
+	cases := []struct {
+		name      string
+		breakdown domain.ScoreBreakdown
+		genre     string
+		want      []string
+	}{
+		{"popularity", domain.ScoreBreakdown{PopularityComponent: 0.36, GenreBoost: 0.035, RecencyComponent: 0.05}, "comedy",
+			[]string{"highly popular"}},
+		{"genre", domain.ScoreBreakdown{PopularityComponent: 0.08, GenreBoost: 0.28, RecencyComponent: 0.02}, "action",
+			[]string{"matches your interest in action"}},
+		{"recency", domain.ScoreBreakdown{PopularityComponent: 0.1, GenreBoost: 0.03, RecencyComponent: 0.6}, "comedy",
+			[]string{"new release"}},
+		// Components within half of the largest are reasons too, largest first
+		{"several", domain.ScoreBreakdown{PopularityComponent: 0.2, GenreBoost: 0.3, RecencyComponent: 0.05}, "action",
+			[]string{"matches your interest in action", "highly popular"}},
+		// The fallback for an unwatched genre is not an interest
+		{"unwatched genre", domain.ScoreBreakdown{PopularityComponent: 0.02, GenreBoost: 0.3}, "drama",
+			nil},
+		{"empty", domain.ScoreBreakdown{}, "action", nil},
+	}
+	for _, tc := range cases {
+		got := scoreReasons(tc.breakdown, tc.genre, prefs)
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestScoreWithReasons(t *testing.T) {
+	client := NewClient(ModelConfig{GenreFallback: 0.1})
+	now := time.Now()
+	old := now.AddDate(-10, 0, 0)
+	input := ScoreInput{
+		User: &domain.User{ID: 1},
+		WatchHistory: []domain.WatchHistoryItem{
+			{ContentID: 1, Genre: "action", WatchedAt: now},
+			{ContentID: 2, Genre: "action", WatchedAt: now},
+		},
+		Candidates: []domain.Content{
+			// Niche title in the user's genre: the genre component dominates
+			{ID: 10, Genre: "action", PopularityScore: 0.1, CreatedAt: old},
+			// Blockbuster outside it: popularity dominates
+			{ID: 11, Genre: "comedy", PopularityScore: 1.0, CreatedAt: old},
+		},
+		Limit:       2,
+		WithReasons: true,
+	}
+
+	results, _, err := client.Score(context.Background(), input)
+	if err != nil {
+		t.Fatalf("score: %v", err)
+	}
+	want := map[int64]string{10: "matches your interest in action", 11: "highly popular"}
+	for _, rec := range results {
+		if len(rec.Reasons) == 0 || rec.Reasons[0] != want[rec.ContentID] {
+			t.Errorf("content %d: expected %q first, got %v", rec.ContentID, want[rec.ContentID], rec.Reasons)
+		}
+	}
+
+	// Recency dominates under recency_first for brand new content
+	input.Strategy = domain.StrategyRecencyFirst
+	input.Candidates = []domain.Content{{ID: 12, Genre: "drama", PopularityScore: 0.2, CreatedAt: now}}
+	if results, _, err = client.Score(context.Background(), input); err != nil {
+		t.Fatalf("score recency_first: %v", err)
+	}
+	if got := results[0].Reasons; len(got) != 1 || got[0] != "new release" {
+		t.Errorf("expected only new release, got %v", got)
+	}
+
+	input.WithReasons = false
+	if results, _, err = client.Score(context.Background(), input); err != nil {
+		t.Fatalf("score without reasons: %v", err)
+	}
+	if results[0].Reasons != nil {
+		t.Errorf("expected no reasons unless requested, got %v", results[0].Reasons)
+	}
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+// Symmetric similarity between pairs of genres in [0,1], keyed by genre. A
+// pair may be listed under either genre; unlisted pairs have no similarity.
+type GenreSimilarity map[string]map[string]float64
+
+// Built-in matrix of related genres, used when GENRE_SIMILARITY_JSON is
+// "default"
+func DefaultGenreSimilarity() GenreSimilarity {
+	return GenreSimilarity{
+		"action":   {"thriller": 0.5, "sci-fi": 0.4},
+		"thriller": {"sci-fi": 0.3, "drama": 0.3},
+		"drama":    {"comedy": 0.2},
+	}
+}
+
+// Parse a JSON object such as {"thriller": {"action": 0.5}}. Genres must be
+// known, similarities in [0,1], and a pair listed both ways must agree.
+func ParseGenreSimilarity(data string) (GenreSimilarity, error) {
+	var m GenreSimilarity
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		return nil, fmt.Errorf("parse genre similarity: %w", err)
+	}
+	for a, row := range m {
+		for b, sim := range row {
+			if !domain.IsValidGenre(a) || !domain.IsValidGenre(b) {
+				return nil, fmt.Errorf("unknown genre pair %s/%s", a, b)
+			}
+			if a == b {
+				return nil, fmt.Errorf("genre %s cannot list itself", a)
+			}
+			if sim < 0 || sim > 1 {
+				return nil, fmt.Errorf("similarity %s/%s must be between 0 and 1, got %g", a, b, sim)
+			}
+			if other, ok := m[b][a]; ok && other != sim {
+				return nil, fmt.Errorf("similarity %s/%s listed both ways with %g and %g", a, b, sim, other)
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m GenreSimilarity) between(a, b string) float64 {
+	if sim, ok := m[a][b]; ok {
+		return sim
+	}
+	return m[b][a]
+}
+
+// Preference for candidateGenre: its own weight plus partial credit from every
+// other watched genre, scaled by similarity. Capped at 1.
+func genreAffinity(candidateGenre string, prefs map[string]float64, matrix GenreSimilarity) float64 {
+	affinity := prefs[candidateGenre]
+	for genre, weight := range prefs {
+		if genre != candidateGenre {
+			affinity += weight * matrix.between(candidateGenre, genre)
+		}
+	}
+	return min(affinity, 1)
+}
+
+// Resolve similarity credit into the preferences for every genre, so scoring
+// can look genres up directly. Unwatched genres only get an entry when their
+// credit beats the fallback.
+func (c *Client) genreAffinities(prefs map[string]float64) map[string]float64 {
+	matrix := c.cfg.GenreSimilarity
+	if len(matrix) == 0 || len(prefs) == 0 {
+		return prefs
+	}
+	resolved := make(map[string]float64, len(domain.Genres))
+	for _, genre := range domain.Genres {
+		affinity := genreAffinity(genre, prefs, matrix)
+		if _, watched := prefs[genre]; watched || affinity > c.cfg.GenreFallback {
+			resolved[genre] = affinity
+		}
+	}
+	// Watched genres outside the catalog list keep their own weight
+	for genre, weight := range prefs {
+		if _, ok := resolved[genre]; !ok {
+			resolved[genre] = weight
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,142 @@
+package model
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+func TestGenreAffinity(t *testing.T) {
+	matrix := GenreSimilarity{"thriller": {"action": 0.5}}
+	prefs := map[string]float64{"thriller": 0.8, "comedy": 0.2}
+
+	tests := []struct {
+		genre    string
+		expected float64
+	}{
+		{"thriller", 0.8},
+		// Listed under thriller, looked up from action
+		{"action", 0.4},
+		{"comedy", 0.2},
+		{"drama", 0},
+	}
+	for _, tc := range tests {
+		if got := genreAffinity(tc.genre, prefs, matrix); math.Abs(got-tc.expected) > 1e-9 {
+			t.Errorf("%s: expected %g, got %g", tc.genre, tc.expected, got)
+		}
+	}
+
+	// Credit never pushes a preference above 1
+	full := GenreSimilarity{"thriller": {"action": 1}}
+	if got := genreAffinity("action", map[string]float64{"action": 0.5, "thriller": 0.5}, full); got != 1 {
+		t.Errorf("expected affinity capped at 1, got %g", got)
+	}
+}
+
+func TestSimilarityLiftsRelatedGenre(t *testing.T) {
+	now := time.Now()
+	input := ScoreInput{
+		User: &domain.User{ID: 1},
+		WatchHistory: []domain.WatchHistoryItem{
+			{ContentID: 10, Genre: "thriller", WatchedAt: now},
+			{ContentID: 11, Genre: "thriller", WatchedAt: now},
+			{ContentID: 12, Genre: "thriller", WatchedAt: now},
+		},
+		Candidates: []domain.Content{
+			{ID: 1, Genre: "action", PopularityScore: 0.5, CreatedAt: now},
+			{ID: 2, Genre: "comedy", PopularityScore: 0.5, CreatedAt: now},
+		},
+		Limit: 2,
+	}
+	scores := func(cfg ModelConfig) map[int64]float64 {
+		byID := make(map[int64]float64)
+		for _, r := range scoreWithRetry(t, NewClient(cfg), input) {
+			byID[r.ContentID] = r.Score
+		}
+		return byID
+	}
+
+	exact := ModelConfig{GenreFallback: 0.1}
+	similar := ModelConfig{GenreFallback: 0.1, GenreSimilarity: GenreSimilarity{"thriller": {"action": 0.6}}}
+	without, with := scores(exact), scores(similar)
+
+	// Action gains 0.35 * (0.6 - 0.1) over the fallback, well above the noise
+	if with[1]-without[1] < 0.15 {
+		t.Errorf("expected action to score higher with similarity, got %.3f vs %.3f", with[1], without[1])
+	}
+	if with[1] <= with[2] {
+		t.Errorf("expected action above unrelated comedy, got %.3f vs %.3f", with[1], with[2])
+	}
+	if math.Abs(with[2]-without[2]) > 0.02 {
+		t.Errorf("expected unrelated comedy unchanged, got %.3f vs %.3f", with[2], without[2])
+	}
+}
+
+func TestSimilarityKeepsFallbackFloor(t *testing.T) {
+	c := NewClient(ModelConfig{GenreFallback: 0.1, GenreSimilarity: GenreSimilarity{"thriller": {"action": 0.05}}})
+	prefs := c.genreAffinities(map[string]float64{"thriller": 1})
+
+	// Weak credit below the fallback leaves the genre on the fallback
+	if got := c.genrePreference(prefs, "action"); got != 0.1 {
+		t.Errorf("expected fallback 0.1, got %g", got)
+	}
+	if got := c.genrePreference(prefs, "thriller"); got != 1 {
+		t.Errorf("expected watched genre unchanged, got %g", got)
+	}
+}
+
+func TestGenreCapHoldsWithSimilarity(t *testing.T) {
+	now := time.Now()
+	history := []domain.WatchHistoryItem{
+		{Genre: "thriller", WatchedAt: now},
+		{Genre: "thriller", WatchedAt: now},
+	}
+	c := NewClient(ModelConfig{
+		GenreFallback:   0.1,
+		GenreCap:        0.7,
+		GenreSimilarity: GenreSimilarity{"thriller": {"action": 1}},
+	})
+
+	// Full credit would lift action to 1; the cap applies after the credit
+	prefs := c.genrePreferences(history, now)
+	for genre, weight := range prefs {
+		if weight > 0.7+1e-9 {
+			t.Errorf("expected %s capped at 0.7, got %f", genre, weight)
+		}
+	}
+	if prefs["action"] <= 0.1 {
+		t.Errorf("expected action to keep similarity credit, got %f", prefs["action"])
+	}
+}
+
+func TestDefaultConfigHasNoSimilarity(t *testing.T) {
+	if cfg := DefaultModelConfig(); cfg.GenreSimilarity != nil {
+		t.Errorf("expected similarity to be opt-in, got %v", cfg.GenreSimilarity)
+	}
+}
+
+func TestParseGenreSimilarity(t *testing.T) {
+	m, err := ParseGenreSimilarity(`{"thriller": {"action": 0.6}, "drama": {"comedy": 0.2}}`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if m.between("action", "thriller") != 0.6 || m.between("comedy", "drama") != 0.2 {
+		t.Errorf("unexpected matrix %v", m)
+	}
+
+	invalid := []string{
+		`not json`,
+		`{"thriller": {"western": 0.5}}`,
+		`{"thriller": {"thriller": 0.5}}`,
+		`{"thriller": {"action": 1.5}}`,
+		`{"thriller": {"action": -0.1}}`,
+		`{"thriller": {"action": 0.5}, "action": {"thriller": 0.4}}`,
+	}
+	for _, data := range invalid {
+		if _, err := ParseGenreSimilarity(data); err == nil {
+			t.Errorf("%s: expected error", data)
+		}
+	}
+}
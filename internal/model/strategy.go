@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+// Scores a single candidate given its blended popularity and the user's genre preferences
+type scoringFunc func(c *Client, content domain.Content, popularity float64, genrePrefs map[string]float64, now time.Time) domain.ScoreBreakdown
+
+var strategies = map[domain.Strategy]scoringFunc{
+	domain.StrategyPopularityOnly: (*Client).scorePopularityOnly,
+	domain.StrategyGenreWeighted:  (*Client).computeFinalScore,
+	domain.StrategyRecencyFirst:   (*Client).scoreRecencyFirst,
+}
+
+// Scoring function for a strategy; unknown or empty strategies use the default
+func scoringFor(strategy domain.Strategy) scoringFunc {
+	return strategies[resolveStrategy(strategy)]
+}
+
+// The strategy actually scored: unknown or empty strategies are the default
+func resolveStrategy(strategy domain.Strategy) domain.Strategy {
+	if _, ok := strategies[strategy]; ok {
+		return strategy
+	}
+	return domain.DefaultStrategy
+}
+
+// Ranks purely by popularity, ignoring the user's history and content age
+func (c *Client) scorePopularityOnly(content domain.Content, popularity float64, genrePrefs map[string]float64, now time.Time) domain.ScoreBreakdown {
+	return domain.ScoreBreakdown{
+		PopularityComponent: popularity,
+		Final:               popularity,
+	}
+}
+
+// Favours new content, with popularity and genre preference as tie-breakers
+func (c *Client) scoreRecencyFirst(content domain.Content, popularity float64, genrePrefs map[string]float64, now time.Time) domain.ScoreBreakdown {
+	recencyComponent := c.contentRecencyFactor(content.CreatedAt, now) * 0.6
+	popularityComponent := popularity * 0.25
+	genreBoost := c.genrePreference(genrePrefs, content.Genre) * 0.15
+	randomNoise := c.scoreNoise()
+
+	return domain.ScoreBreakdown{
+		PopularityComponent: popularityComponent,
+		GenreBoost:          genreBoost,
+		RecencyComponent:    recencyComponent,
+		Noise:               randomNoise,
+		Final:               popularityComponent + genreBoost + recencyComponent + randomNoise,
+	}
+}
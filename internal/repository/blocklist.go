@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+)
+
+// Block content for a user, reporting whether a new entry was added
+func (r *Repository) BlockContent(ctx context.Context, userID, contentID int64) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Exec(ctx,
+		`INSERT INTO user_blocked_content (user_id, content_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`,
+		userID, contentID,
+	)
+	if err != nil {
+		return false, repoError(mapConstraintError(err), "insert blocked content for user %d", userID)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Unblock content for a user, reporting whether an entry existed
+func (r *Repository) UnblockContent(ctx context.Context, userID, contentID int64) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Exec(ctx,
+		`DELETE FROM user_blocked_content WHERE user_id = $1 AND content_id = $2`,
+		userID, contentID,
+	)
+	if err != nil {
+		return false, repoError(err, "delete blocked content for user %d", userID)
+	}
+	return tag.RowsAffected() > 0, nil
+}
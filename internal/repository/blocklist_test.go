@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+func TestBlockContentFiltersCandidates(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	// Block the top candidate so it would otherwise be recommended first
+	unwatched, err := repo.GetUnwatchedContent(ctx, 1, 1000, domain.DefaultCandidateOrder, domain.ContentRatings, 0)
+	if err != nil || len(unwatched) == 0 {
+		t.Fatalf("expected unwatched content for user 1: %v", err)
+	}
+	blockedID := unwatched[0].ID
+	if _, err := repo.pool.Exec(ctx,
+		`INSERT INTO featured_content (content_id, priority) VALUES ($1, 10)`, blockedID,
+	); err != nil {
+		t.Fatalf("feature content: %v", err)
+	}
+
+	added, err := repo.BlockContent(ctx, 1, blockedID)
+	if err != nil || !added {
+		t.Fatalf("expected content to be blocked, got added=%v err=%v", added, err)
+	}
+	if added, err := repo.BlockContent(ctx, 1, blockedID); err != nil || added {
+		t.Errorf("expected repeat block to be a no-op, got added=%v err=%v", added, err)
+	}
+
+	contains := func(items []domain.Content) bool {
+		for _, c := range items {
+			if c.ID == blockedID {
+				return true
+			}
+		}
+		return false
+	}
+	unwatched, err = repo.GetUnwatchedContent(ctx, 1, 1000, domain.DefaultCandidateOrder, domain.ContentRatings, 0)
+	if err != nil || contains(unwatched) {
+		t.Errorf("expected blocked content excluded from unwatched candidates (err=%v)", err)
+	}
+	all, _, err := repo.GetAllContent(ctx, 1, 1000, domain.DefaultCandidateOrder, domain.ContentRatings, 0)
+	if err != nil || contains(all) {
+		t.Errorf("expected blocked content excluded from all candidates (err=%v)", err)
+	}
+	featured, err := repo.GetFeaturedContent(ctx, 1, 10, domain.ContentRatings)
+	if err != nil || contains(featured) {
+		t.Errorf("expected blocked content excluded from featured content (err=%v)", err)
+	}
+
+	// Other users are unaffected
+	if others, _, err := repo.GetAllContent(ctx, 2, 1000, domain.DefaultCandidateOrder, domain.ContentRatings, 0); err != nil || !contains(others) {
+		t.Errorf("expected content to stay available to user 2 (err=%v)", err)
+	}
+
+	removed, err := repo.UnblockContent(ctx, 1, blockedID)
+	if err != nil || !removed {
+		t.Fatalf("expected content to be unblocked, got removed=%v err=%v", removed, err)
+	}
+	if removed, _ := repo.UnblockContent(ctx, 1, blockedID); removed {
+		t.Error("expected second unblock to affect nothing")
+	}
+	if unwatched, _ = repo.GetUnwatchedContent(ctx, 1, 1000, domain.DefaultCandidateOrder, domain.ContentRatings, 0); !contains(unwatched) {
+		t.Error("expected unblocked content back among candidates")
+	}
+}
+
+func TestBlockContentUnknownReferences(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	if _, err := repo.BlockContent(ctx, 99999, 1); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+	if _, err := repo.BlockContent(ctx, 1, 99999); !errors.Is(err, domain.ErrContentNotFound) {
+		t.Errorf("expected ErrContentNotFound, got %v", err)
+	}
+}
@@ -2,39 +2,394 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/jackc/pgx/v5"
 )
 
-func (r *Repository) GetUnwatchedContent(ctx context.Context, userID int64, limit int) ([]domain.Content, error) {
-	rows, err := r.pool.Query(ctx,
-		`SELECT c.id, c.title, c.genre, c.popularity_score, c.created_at
+// Get single content item
+func (r *Repository) GetContentByID(ctx context.Context, contentID int64) (*domain.Content, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	c := &domain.Content{}
+
+	err := r.timedQueryRow(ctx, r.db, "GetContentByID",
+		`SELECT id, title, genre, popularity_score, created_at, available_from, available_until, duration_minutes, release_year, content_rating
+		 FROM content WHERE id = $1 AND deleted_at IS NULL`,
+		contentID,
+	).Scan(&c.ID, &c.Title, &c.Genre, &c.PopularityScore, &c.CreatedAt, &c.AvailableFrom, &c.AvailableUntil, &c.DurationMinutes, &c.ReleaseYear, &c.ContentRating)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrContentNotFound
+		}
+		return nil, repoError(err, "query content id=%d", contentID)
+	}
+
+	return c, nil
+}
+
+// Soft-delete a content item, keeping the row for audit. Reports whether a
+// live item was deleted.
+func (r *Repository) SoftDeleteContent(ctx context.Context, contentID int64) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Exec(ctx,
+		`UPDATE content SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`,
+		contentID,
+	)
+	if err != nil {
+		return false, repoError(err, "soft-delete content id=%d", contentID)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Get available content the user hasn't watched or blocked with one of the
+// given ratings and at least minPopularity, in the given order. Read from the
+// primary, like every input to a list that gets cached
+func (r *Repository) GetUnwatchedContent(ctx context.Context, userID int64, limit int, order domain.CandidateOrder, ratings []string, minPopularity float64) ([]domain.Content, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.timedQuery(ctx, r.db, "GetUnwatchedContent",
+		`SELECT c.id, c.title, c.genre, c.popularity_score, c.created_at, c.available_from, c.available_until, c.duration_minutes, c.release_year, c.content_rating
 		FROM content c
 		LEFT JOIN user_watch_history uwh
     		ON uwh.content_id = c.id AND uwh.user_id = $1
     	WHERE uwh.content_id IS NULL
-     	ORDER BY c.popularity_score DESC
-     	LIMIT $2`, userID, limit,
+    		AND c.deleted_at IS NULL
+    		AND c.id NOT IN (SELECT content_id FROM user_blocked_content WHERE user_id = $1)
+    		AND (c.available_from IS NULL OR c.available_from <= NOW())
+    		AND (c.available_until IS NULL OR c.available_until >= NOW())
+    		AND c.content_rating = ANY($3)
+    		AND c.popularity_score >= $4
+     	ORDER BY `+candidateOrderBy(order)+`
+     	LIMIT $2`, userID, limit, ratings, minPopularity,
 	)
 	
 	if err != nil {
-		return nil, fmt.Errorf("query unwatched content for user %d: %w", userID, err)
+		return nil, repoError(err, "query unwatched content for user %d", userID)
 	}
 	defer rows.Close()
 	
 	var items []domain.Content
 	for rows.Next() {
 		var c domain.Content
-		err := rows.Scan(&c.ID, &c.Title, &c.Genre, &c.PopularityScore, &c.CreatedAt)
+		err := rows.Scan(&c.ID, &c.Title, &c.Genre, &c.PopularityScore, &c.CreatedAt, &c.AvailableFrom, &c.AvailableUntil, &c.DurationMinutes, &c.ReleaseYear, &c.ContentRating)
 		if err != nil {
-			return nil, fmt.Errorf("scan content: %w", err)
+			return nil, repoError(err, "scan content")
 		}
 		items = append(items, c)
 	}
 	
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate over content: %w", err)
+		return nil, repoError(err, "iterate over content")
+	}
+	return items, nil
+}
+
+// Get active featured content the user hasn't watched or blocked and that is
+// currently available with one of the given ratings, highest priority first
+func (r *Repository) GetFeaturedContent(ctx context.Context, userID int64, limit int, ratings []string) ([]domain.Content, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.timedQuery(ctx, r.db, "GetFeaturedContent",
+		`SELECT c.id, c.title, c.genre, c.popularity_score, c.created_at, c.available_from, c.available_until, c.duration_minutes, c.release_year, c.content_rating
+		FROM featured_content f
+		JOIN content c ON c.id = f.content_id
+		LEFT JOIN user_watch_history uwh
+			ON uwh.content_id = c.id AND uwh.user_id = $1
+		WHERE f.active
+			AND uwh.content_id IS NULL
+			AND c.deleted_at IS NULL
+			AND c.id NOT IN (SELECT content_id FROM user_blocked_content WHERE user_id = $1)
+			AND (c.available_from IS NULL OR c.available_from <= NOW())
+			AND (c.available_until IS NULL OR c.available_until >= NOW())
+			AND c.content_rating = ANY($3)
+		ORDER BY f.priority DESC, c.id
+		LIMIT $2`, userID, limit, ratings,
+	)
+	if err != nil {
+		return nil, repoError(err, "query featured content for user %d", userID)
+	}
+	defer rows.Close()
+
+	var items []domain.Content
+	for rows.Next() {
+		var c domain.Content
+		err := rows.Scan(&c.ID, &c.Title, &c.Genre, &c.PopularityScore, &c.CreatedAt, &c.AvailableFrom, &c.AvailableUntil, &c.DurationMinutes, &c.ReleaseYear, &c.ContentRating)
+		if err != nil {
+			return nil, repoError(err, "scan featured content")
+		}
+		items = append(items, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, repoError(err, "iterate over featured content")
 	}
 	return items, nil
-}
\ No newline at end of file
+}
+
+// Insert a content item, returning its generated ID
+func (r *Repository) InsertContent(ctx context.Context, c domain.Content) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var id int64
+	err := r.timedQueryRow(ctx, r.db, "InsertContent",
+		`INSERT INTO content (title, genre, popularity_score, created_at, duration_minutes, release_year, content_rating)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		c.Title, c.Genre, c.PopularityScore, c.CreatedAt, c.DurationMinutes, c.ReleaseYear, c.ContentRating,
+	).Scan(&id)
+	if err != nil {
+		return 0, repoError(err, "insert content %q", c.Title)
+	}
+	return id, nil
+}
+
+// Get available, unblocked content with one of the given ratings and at least
+// minPopularity including items the user already watched, in the given order;
+// the map holds the IDs of watched items. Read from the primary
+func (r *Repository) GetAllContent(ctx context.Context, userID int64, limit int, order domain.CandidateOrder, ratings []string, minPopularity float64) ([]domain.Content, map[int64]bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.timedQuery(ctx, r.db, "GetAllContent",
+		`SELECT c.id, c.title, c.genre, c.popularity_score, c.created_at, c.available_from, c.available_until, c.duration_minutes, c.release_year, c.content_rating,
+			uwh.content_id IS NOT NULL AS watched
+		FROM content c
+		LEFT JOIN user_watch_history uwh
+			ON uwh.content_id = c.id AND uwh.user_id = $1
+		WHERE c.deleted_at IS NULL
+			AND (c.available_from IS NULL OR c.available_from <= NOW())
+			AND (c.available_until IS NULL OR c.available_until >= NOW())
+			AND c.id NOT IN (SELECT content_id FROM user_blocked_content WHERE user_id = $1)
+			AND c.content_rating = ANY($3)
+			AND c.popularity_score >= $4
+		ORDER BY `+candidateOrderBy(order)+`
+		LIMIT $2`, userID, limit, ratings, minPopularity,
+	)
+	if err != nil {
+		return nil, nil, repoError(err, "query all content for user %d", userID)
+	}
+	defer rows.Close()
+
+	var items []domain.Content
+	watched := make(map[int64]bool)
+	for rows.Next() {
+		var c domain.Content
+		var isWatched bool
+		err := rows.Scan(&c.ID, &c.Title, &c.Genre, &c.PopularityScore, &c.CreatedAt, &c.AvailableFrom, &c.AvailableUntil, &c.DurationMinutes, &c.ReleaseYear, &c.ContentRating, &isWatched)
+		if err != nil {
+			return nil, nil, repoError(err, "scan content")
+		}
+		if isWatched {
+			watched[c.ID] = true
+		}
+		items = append(items, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, repoError(err, "iterate over content")
+	}
+	return items, watched, nil
+}
+
+// Get popularity scores in a country for the given content, keyed by content ID
+func (r *Repository) GetRegionalPopularity(ctx context.Context, country string, contentIDs []int64) (map[int64]float64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	scores := make(map[int64]float64)
+	if len(contentIDs) == 0 {
+		return scores, nil
+	}
+
+	rows, err := r.timedQuery(ctx, r.db, "GetRegionalPopularity",
+		`SELECT content_id, popularity_score
+		FROM content_popularity_by_country
+		WHERE country = $1 AND content_id = ANY($2)`,
+		country, contentIDs,
+	)
+	if err != nil {
+		return nil, repoError(err, "query regional popularity for %s", country)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var contentID int64
+		var score float64
+		if err := rows.Scan(&contentID, &score); err != nil {
+			return nil, repoError(err, "scan regional popularity")
+		}
+		scores[contentID] = score
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, repoError(err, "iterate over regional popularity")
+	}
+	return scores, nil
+}
+
+
+// Escapes LIKE wildcards so user input matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Case-insensitive title search ordered by popularity
+func (r *Repository) SearchContentByTitle(ctx context.Context, query string, limit int) ([]domain.Content, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.timedQuery(ctx, r.db, "SearchContentByTitle",
+		`SELECT id, title, genre, popularity_score, created_at, available_from, available_until, duration_minutes, release_year, content_rating
+		FROM content
+		WHERE title ILIKE '%' || $1 || '%' AND deleted_at IS NULL
+		ORDER BY popularity_score DESC, id
+		LIMIT $2`, likeEscaper.Replace(query), limit,
+	)
+	if err != nil {
+		return nil, repoError(err, "search content by title")
+	}
+	defer rows.Close()
+
+	items := []domain.Content{}
+	for rows.Next() {
+		var c domain.Content
+		err := rows.Scan(&c.ID, &c.Title, &c.Genre, &c.PopularityScore, &c.CreatedAt, &c.AvailableFrom, &c.AvailableUntil, &c.DurationMinutes, &c.ReleaseYear, &c.ContentRating)
+		if err != nil {
+			return nil, repoError(err, "scan content")
+		}
+		items = append(items, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, repoError(err, "iterate over content")
+	}
+	return items, nil
+}
+
+// List a page of the catalog matching the filter, with the total number of
+// matching items across all pages
+func (r *Repository) ListContent(ctx context.Context, filter domain.ContentFilter) ([]domain.Content, int, error) {
+	offset, err := pageOffset(filter.Page, filter.Limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	conditions := []string{"deleted_at IS NULL"}
+	var args []any
+	addCondition := func(clause string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(clause, len(args)))
+	}
+	if filter.Genre != "" {
+		addCondition("genre = $%d", filter.Genre)
+	}
+	if filter.MinPopularity > 0 {
+		addCondition("popularity_score >= $%d", filter.MinPopularity)
+	}
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := r.timedQueryRow(ctx, r.db, "ListContent", `SELECT COUNT(*) FROM content`+where, args...).Scan(&total); err != nil {
+		return nil, 0, repoError(err, "count content")
+	}
+
+	query := `SELECT id, title, genre, popularity_score, created_at, available_from, available_until, duration_minutes, release_year, content_rating
+		FROM content` + where
+	args = append(args, filter.Limit, offset)
+	query += fmt.Sprintf(" ORDER BY %s, id LIMIT $%d OFFSET $%d", contentSortBy(filter.Sort), len(args)-1, len(args))
+
+	rows, err := r.timedQuery(ctx, r.db, "ListContent", query, args...)
+	if err != nil {
+		return nil, 0, repoError(err, "list content")
+	}
+	defer rows.Close()
+
+	items := []domain.Content{}
+	for rows.Next() {
+		var c domain.Content
+		err := rows.Scan(&c.ID, &c.Title, &c.Genre, &c.PopularityScore, &c.CreatedAt, &c.AvailableFrom, &c.AvailableUntil, &c.DurationMinutes, &c.ReleaseYear, &c.ContentRating)
+		if err != nil {
+			return nil, 0, repoError(err, "scan content")
+		}
+		items = append(items, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, repoError(err, "iterate over content")
+	}
+	return items, total, nil
+}
+
+// Get available content watched since the given time, most watched first.
+// Ties go to the more popular item.
+func (r *Repository) GetTrendingContent(ctx context.Context, since time.Time, limit int) ([]domain.TrendingContent, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.timedQuery(ctx, r.db, "GetTrendingContent",
+		`SELECT c.id, c.title, c.genre, c.popularity_score, c.created_at, c.available_from, c.available_until, c.duration_minutes, c.release_year, c.content_rating,
+			COUNT(*) AS recent_watches
+		FROM user_watch_history uwh
+		JOIN content c ON c.id = uwh.content_id
+		WHERE uwh.watched_at >= $1
+			AND c.deleted_at IS NULL
+			AND (c.available_from IS NULL OR c.available_from <= NOW())
+			AND (c.available_until IS NULL OR c.available_until >= NOW())
+		GROUP BY c.id
+		ORDER BY recent_watches DESC, c.popularity_score DESC, c.id
+		LIMIT $2`, since, limit,
+	)
+	if err != nil {
+		return nil, repoError(err, "query trending content")
+	}
+	defer rows.Close()
+
+	items := []domain.TrendingContent{}
+	for rows.Next() {
+		var t domain.TrendingContent
+		c := &t.Content
+		err := rows.Scan(&c.ID, &c.Title, &c.Genre, &c.PopularityScore, &c.CreatedAt, &c.AvailableFrom, &c.AvailableUntil, &c.DurationMinutes, &c.ReleaseYear, &c.ContentRating, &t.RecentWatches)
+		if err != nil {
+			return nil, repoError(err, "scan trending content")
+		}
+		items = append(items, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, repoError(err, "iterate over trending content")
+	}
+	return items, nil
+}
+
+// ORDER BY clause for a candidate order; unknown orders sort by popularity
+func candidateOrderBy(order domain.CandidateOrder) string {
+	switch order {
+	case domain.CandidateOrderRecency:
+		return "c.created_at DESC"
+	case domain.CandidateOrderRandom:
+		return "RANDOM()"
+	default:
+		return "c.popularity_score DESC"
+	}
+}
+
+// ORDER BY clause for a catalog sort; unknown sorts order by popularity
+func contentSortBy(sort domain.ContentSort) string {
+	if sort == domain.ContentSortCreatedAt {
+		return "created_at DESC"
+	}
+	return "popularity_score DESC"
+}
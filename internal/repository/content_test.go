@@ -0,0 +1,577 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+func TestGetUnwatchedContentAvailabilityWindow(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	// Highly popular items so they would otherwise rank first
+	var expiredID, upcomingID, openID int64
+	insert := func(title, from, until string) int64 {
+		var id int64
+		err := repo.pool.QueryRow(ctx,
+			`INSERT INTO content (title, genre, popularity_score, available_from, available_until)
+			VALUES ($1, 'action', 5.0, `+from+`, `+until+`) RETURNING id`, title,
+		).Scan(&id)
+		if err != nil {
+			t.Fatalf("insert %s: %v", title, err)
+		}
+		return id
+	}
+	expiredID = insert("Expired", "NOW() - INTERVAL '30 days'", "NOW() - INTERVAL '1 day'")
+	upcomingID = insert("Upcoming", "NOW() + INTERVAL '1 day'", "NULL")
+	openID = insert("Open Window", "NOW() - INTERVAL '1 day'", "NOW() + INTERVAL '1 day'")
+
+	items, err := repo.GetUnwatchedContent(ctx, 1, 1000, domain.DefaultCandidateOrder, domain.ContentRatings, 0)
+	if err != nil {
+		t.Fatalf("get unwatched content: %v", err)
+	}
+
+	found := make(map[int64]bool)
+	for _, c := range items {
+		found[c.ID] = true
+	}
+	if found[expiredID] {
+		t.Error("expired content should be excluded")
+	}
+	if found[upcomingID] {
+		t.Error("not-yet-available content should be excluded")
+	}
+	if !found[openID] {
+		t.Error("content inside its window should be included")
+	}
+}
+
+func TestSearchContentByTitle(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	// Case-insensitive substring match against seeded titles
+	items, err := repo.SearchContentByTitle(ctx, "matrix", 10)
+	if err != nil {
+		t.Fatalf("search content: %v", err)
+	}
+	if len(items) == 0 {
+		t.Fatal("expected seeded title The Matrix to match")
+	}
+	for i, c := range items {
+		if !strings.Contains(strings.ToLower(c.Title), "matrix") {
+			t.Errorf("unexpected match %q", c.Title)
+		}
+		if i > 0 && items[i-1].PopularityScore < c.PopularityScore {
+			t.Error("expected results ordered by popularity")
+		}
+	}
+
+	// Wildcards are matched literally
+	items, err = repo.SearchContentByTitle(ctx, "%%", 10)
+	if err != nil {
+		t.Fatalf("search content: %v", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("expected no matches for literal %%, got %d", len(items))
+	}
+}
+
+func TestGetAllContentMarksWatched(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	history, err := repo.GetUserWatchHistoryWithGenres(ctx, 1, 1000)
+	if err != nil {
+		t.Fatalf("get watch history: %v", err)
+	}
+	if len(history) == 0 {
+		t.Skip("seeded user 1 has no watch history")
+	}
+
+	items, watched, err := repo.GetAllContent(ctx, 1, 1000, domain.DefaultCandidateOrder, domain.ContentRatings, 0)
+	if err != nil {
+		t.Fatalf("get all content: %v", err)
+	}
+
+	returned := make(map[int64]bool)
+	for _, c := range items {
+		returned[c.ID] = true
+	}
+	for _, h := range history {
+		if !returned[h.ContentID] {
+			continue // outside its availability window
+		}
+		if !watched[h.ContentID] {
+			t.Errorf("content %d: expected watched flag", h.ContentID)
+		}
+	}
+	if len(watched) > len(history) {
+		t.Errorf("expected at most %d watched items, got %d", len(history), len(watched))
+	}
+}
+
+func TestInsertContent(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	id, err := repo.InsertContent(ctx, domain.Content{
+		Title:           "Fresh Release",
+		Genre:           "comedy",
+		PopularityScore: 0.7,
+		CreatedAt:       time.Now().UTC(),
+		DurationMinutes: 104,
+		ReleaseYear:     2024,
+		ContentRating:   "PG-13",
+	})
+	if err != nil {
+		t.Fatalf("insert content: %v", err)
+	}
+
+	got, err := repo.GetContentByID(ctx, id)
+	if err != nil {
+		t.Fatalf("get inserted content: %v", err)
+	}
+	if got.Title != "Fresh Release" || got.Genre != "comedy" || got.PopularityScore != 0.7 {
+		t.Errorf("unexpected stored content: %+v", got)
+	}
+	if got.DurationMinutes != 104 || got.ReleaseYear != 2024 || got.ContentRating != "PG-13" {
+		t.Errorf("expected metadata 104 min / 2024 / PG-13, got %d / %d / %s", got.DurationMinutes, got.ReleaseYear, got.ContentRating)
+	}
+
+	// The recommendation candidate query carries the metadata too
+	items, err := repo.GetUnwatchedContent(ctx, 1, 1000, domain.DefaultCandidateOrder, domain.ContentRatings, 0)
+	if err != nil {
+		t.Fatalf("get unwatched content: %v", err)
+	}
+	found := false
+	for _, c := range items {
+		if c.ID == id {
+			found = true
+			if c.DurationMinutes != 104 || c.ReleaseYear != 2024 {
+				t.Errorf("expected metadata on candidate, got %+v", c)
+			}
+		}
+	}
+	if !found {
+		t.Error("expected inserted content among unwatched candidates")
+	}
+
+	// The schema rejects negative popularity
+	if _, err := repo.InsertContent(ctx, domain.Content{Title: "Bad", Genre: "comedy", PopularityScore: -1, CreatedAt: time.Now()}); err == nil {
+		t.Error("expected error for negative popularity")
+	}
+}
+
+func TestGetFeaturedContent(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	var watchedID int64
+	if err := repo.pool.QueryRow(ctx,
+		`SELECT content_id FROM user_watch_history WHERE user_id = 1 LIMIT 1`,
+	).Scan(&watchedID); err != nil {
+		t.Fatalf("find watched content: %v", err)
+	}
+	var unwatched []int64
+	rows, err := repo.pool.Query(ctx,
+		`SELECT id FROM content
+		WHERE id NOT IN (SELECT content_id FROM user_watch_history WHERE user_id = 1)
+			AND available_from IS NULL AND available_until IS NULL
+		ORDER BY id LIMIT 3`)
+	if err != nil {
+		t.Fatalf("find unwatched content: %v", err)
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("scan content id: %v", err)
+		}
+		unwatched = append(unwatched, id)
+	}
+	rows.Close()
+	if len(unwatched) < 3 {
+		t.Fatalf("expected 3 unwatched content items, got %d", len(unwatched))
+	}
+
+	// priority 1 and 5 active, one inactive, one watched
+	for _, f := range []struct {
+		id       int64
+		priority int
+		active   bool
+	}{
+		{unwatched[0], 1, true},
+		{unwatched[1], 5, true},
+		{unwatched[2], 9, false},
+		{watchedID, 10, true},
+	} {
+		if _, err := repo.pool.Exec(ctx,
+			`INSERT INTO featured_content (content_id, priority, active) VALUES ($1, $2, $3)`,
+			f.id, f.priority, f.active,
+		); err != nil {
+			t.Fatalf("insert featured content: %v", err)
+		}
+	}
+
+	items, err := repo.GetFeaturedContent(ctx, 1, 10, domain.ContentRatings)
+	if err != nil {
+		t.Fatalf("get featured content: %v", err)
+	}
+	if len(items) != 2 || items[0].ID != unwatched[1] || items[1].ID != unwatched[0] {
+		t.Errorf("expected active unwatched featured items by priority, got %+v", items)
+	}
+}
+
+func TestGetUnwatchedContentOrdering(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	popular, err := repo.GetUnwatchedContent(ctx, 1, 1000, domain.CandidateOrderPopularity, domain.ContentRatings, 0)
+	if err != nil {
+		t.Fatalf("get unwatched content by popularity: %v", err)
+	}
+	if len(popular) < 2 {
+		t.Skip("not enough seeded content to compare orderings")
+	}
+	for i := 1; i < len(popular); i++ {
+		if popular[i-1].PopularityScore < popular[i].PopularityScore {
+			t.Fatal("expected popularity order to be most popular first")
+		}
+	}
+
+	recent, err := repo.GetUnwatchedContent(ctx, 1, 1000, domain.CandidateOrderRecency, domain.ContentRatings, 0)
+	if err != nil {
+		t.Fatalf("get unwatched content by recency: %v", err)
+	}
+	for i := 1; i < len(recent); i++ {
+		if recent[i-1].CreatedAt.Before(recent[i].CreatedAt) {
+			t.Fatal("expected recency order to be newest first")
+		}
+	}
+
+	// Random order changes which items come first, not which are eligible
+	random, err := repo.GetUnwatchedContent(ctx, 1, 1000, domain.CandidateOrderRandom, domain.ContentRatings, 0)
+	if err != nil {
+		t.Fatalf("get unwatched content in random order: %v", err)
+	}
+	for _, got := range [][]domain.Content{recent, random} {
+		if len(got) != len(popular) {
+			t.Fatalf("expected every ordering to return %d items, got %d", len(popular), len(got))
+		}
+		ids := make(map[int64]bool, len(got))
+		for _, c := range got {
+			ids[c.ID] = true
+		}
+		for _, c := range popular {
+			if !ids[c.ID] {
+				t.Errorf("content %d missing from reordered candidates", c.ID)
+			}
+		}
+	}
+
+	// The limit applies after ordering
+	newest, err := repo.GetUnwatchedContent(ctx, 1, 1, domain.CandidateOrderRecency, domain.ContentRatings, 0)
+	if err != nil || len(newest) != 1 || newest[0].ID != recent[0].ID {
+		t.Errorf("expected newest item %d with limit 1, got %+v err=%v", recent[0].ID, newest, err)
+	}
+}
+
+func TestGetAllContentRecencyOrder(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	items, _, err := repo.GetAllContent(ctx, 1, 1000, domain.CandidateOrderRecency, domain.ContentRatings, 0)
+	if err != nil {
+		t.Fatalf("get all content by recency: %v", err)
+	}
+	for i := 1; i < len(items); i++ {
+		if items[i-1].CreatedAt.Before(items[i].CreatedAt) {
+			t.Fatal("expected recency order to be newest first")
+		}
+	}
+}
+
+func TestCandidateOrderBy(t *testing.T) {
+	cases := map[domain.CandidateOrder]string{
+		"":                              "c.popularity_score DESC",
+		domain.CandidateOrderPopularity: "c.popularity_score DESC",
+		domain.CandidateOrderRecency:    "c.created_at DESC",
+		domain.CandidateOrderRandom:     "RANDOM()",
+		"bogus":                         "c.popularity_score DESC",
+	}
+	for order, want := range cases {
+		if got := candidateOrderBy(order); got != want {
+			t.Errorf("candidateOrderBy(%q) = %q, want %q", order, got, want)
+		}
+	}
+}
+
+func TestGetTrendingContent(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	if _, err := repo.pool.Exec(ctx, "TRUNCATE user_watch_history"); err != nil {
+		t.Fatalf("truncate watch history: %v", err)
+	}
+	// Within the last week: content 2 three times, content 1 twice, content 3
+	// once. Content 4 was watched most, but a month ago.
+	if _, err := repo.pool.Exec(ctx, `
+		INSERT INTO user_watch_history (user_id, content_id, watched_at) VALUES
+			(1, 2, NOW() - INTERVAL '1 hour'), (2, 2, NOW() - INTERVAL '2 days'), (3, 2, NOW() - INTERVAL '6 days'),
+			(1, 1, NOW() - INTERVAL '3 days'), (2, 1, NOW()),
+			(3, 3, NOW() - INTERVAL '5 days'),
+			(1, 4, NOW() - INTERVAL '30 days'), (2, 4, NOW() - INTERVAL '31 days'),
+			(3, 4, NOW() - INTERVAL '32 days'), (4, 4, NOW() - INTERVAL '33 days')
+	`); err != nil {
+		t.Fatalf("insert watch history: %v", err)
+	}
+
+	items, err := repo.GetTrendingContent(ctx, time.Now().AddDate(0, 0, -7), 10)
+	if err != nil {
+		t.Fatalf("get trending content: %v", err)
+	}
+	var got []string
+	for _, item := range items {
+		got = append(got, fmt.Sprintf("%d:%d", item.ID, item.RecentWatches))
+	}
+	if strings.Join(got, " ") != "2:3 1:2 3:1" {
+		t.Errorf("expected [2:3 1:2 3:1], got %v", got)
+	}
+	if items[0].Title == "" {
+		t.Error("expected trending items to carry content fields")
+	}
+
+	// A longer window picks up the older watches
+	items, err = repo.GetTrendingContent(ctx, time.Now().AddDate(0, 0, -40), 1)
+	if err != nil {
+		t.Fatalf("get trending content: %v", err)
+	}
+	if len(items) != 1 || items[0].ID != 4 || items[0].RecentWatches != 4 {
+		t.Errorf("expected content 4 with 4 watches, got %+v", items)
+	}
+
+	// Nothing watched in the window is an empty list, not nil
+	items, err = repo.GetTrendingContent(ctx, time.Now().Add(time.Hour), 10)
+	if err != nil || items == nil || len(items) != 0 {
+		t.Errorf("expected empty list, got %v err=%v", items, err)
+	}
+}
+
+func TestGetUnwatchedContentRatingFilter(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	minor, err := repo.GetUnwatchedContent(ctx, 1, 1000, domain.DefaultCandidateOrder, domain.DefaultRatingPolicy().AllowedRatings(15), 0)
+	if err != nil {
+		t.Fatalf("get unwatched content for a minor: %v", err)
+	}
+	for _, c := range minor {
+		if c.ContentRating == "R" {
+			t.Errorf("expected no R content for a minor, got %d", c.ID)
+		}
+	}
+
+	adult, err := repo.GetUnwatchedContent(ctx, 1, 1000, domain.DefaultCandidateOrder, domain.DefaultRatingPolicy().AllowedRatings(30), 0)
+	if err != nil {
+		t.Fatalf("get unwatched content for an adult: %v", err)
+	}
+	rated := 0
+	for _, c := range adult {
+		if c.ContentRating == "R" {
+			rated++
+		}
+	}
+	// Seeding rates every genre with each rating
+	if rated == 0 || len(adult) != len(minor)+rated {
+		t.Errorf("expected an adult to see the minor's %d items plus R content, got %d with %d R", len(minor), len(adult), rated)
+	}
+}
+
+func TestGetCandidatesPopularityFloor(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	id, err := repo.InsertContent(ctx, domain.Content{
+		Title:           "Obscure Gem",
+		Genre:           "drama",
+		PopularityScore: 0.01,
+		CreatedAt:       time.Now().UTC(),
+		ContentRating:   "PG",
+	})
+	if err != nil {
+		t.Fatalf("insert content: %v", err)
+	}
+	contains := func(items []domain.Content) bool {
+		return slices.ContainsFunc(items, func(c domain.Content) bool { return c.ID == id })
+	}
+
+	floored, err := repo.GetUnwatchedContent(ctx, 1, 1000, domain.DefaultCandidateOrder, domain.ContentRatings, domain.DefaultMinPopularity)
+	if err != nil {
+		t.Fatalf("get unwatched content: %v", err)
+	}
+	if contains(floored) {
+		t.Error("expected content below the default popularity floor to be excluded")
+	}
+	for _, c := range floored {
+		if c.PopularityScore < domain.DefaultMinPopularity {
+			t.Errorf("expected no content below %g, got %d at %g", domain.DefaultMinPopularity, c.ID, c.PopularityScore)
+		}
+	}
+	all, _, err := repo.GetAllContent(ctx, 1, 1000, domain.DefaultCandidateOrder, domain.ContentRatings, domain.DefaultMinPopularity)
+	if err != nil {
+		t.Fatalf("get all content: %v", err)
+	}
+	if contains(all) {
+		t.Error("expected the popularity floor to apply when including watched content")
+	}
+
+	unfloored, err := repo.GetUnwatchedContent(ctx, 1, 1000, domain.DefaultCandidateOrder, domain.ContentRatings, 0)
+	if err != nil {
+		t.Fatalf("get unwatched content without a floor: %v", err)
+	}
+	if !contains(unfloored) {
+		t.Error("expected low-popularity content without a floor")
+	}
+}
+
+func TestListContent(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	if _, err := repo.pool.Exec(ctx, "TRUNCATE content RESTART IDENTITY CASCADE"); err != nil {
+		t.Fatalf("truncate content: %v", err)
+	}
+	if _, err := repo.pool.Exec(ctx, `
+		INSERT INTO content (title, genre, popularity_score, created_at) VALUES
+			('A', 'action', 0.9, NOW() - INTERVAL '5 days'),
+			('B', 'action', 0.4, NOW() - INTERVAL '1 day'),
+			('C', 'drama', 0.7, NOW() - INTERVAL '3 days'),
+			('D', 'action', 0.6, NOW() - INTERVAL '2 days'),
+			('E', 'comedy', 0.2, NOW() - INTERVAL '4 days')
+	`); err != nil {
+		t.Fatalf("insert content: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		filter   domain.ContentFilter
+		expected []int64
+		total    int
+	}{
+		{"no filters", domain.ContentFilter{}, []int64{1, 3, 4, 2, 5}, 5},
+		{"genre", domain.ContentFilter{Genre: "action"}, []int64{1, 4, 2}, 3},
+		{"min popularity", domain.ContentFilter{MinPopularity: 0.6}, []int64{1, 3, 4}, 3},
+		{"genre and min popularity", domain.ContentFilter{Genre: "action", MinPopularity: 0.5}, []int64{1, 4}, 2},
+		{"created_at sort", domain.ContentFilter{Sort: domain.ContentSortCreatedAt}, []int64{2, 4, 3, 5, 1}, 5},
+		{"genre and created_at sort", domain.ContentFilter{Genre: "action", Sort: domain.ContentSortCreatedAt}, []int64{2, 4, 1}, 3},
+		{"no matches", domain.ContentFilter{Genre: "sci-fi"}, []int64{}, 0},
+		{"second page", domain.ContentFilter{Page: 2, Limit: 2}, []int64{4, 2}, 5},
+		{"page past the end", domain.ContentFilter{Genre: "action", Page: 3, Limit: 2}, []int64{}, 3},
+	}
+
+	for _, tc := range tests {
+		filter := tc.filter
+		if filter.Page == 0 {
+			filter.Page = 1
+		}
+		if filter.Limit == 0 {
+			filter.Limit = 10
+		}
+		items, total, err := repo.ListContent(ctx, filter)
+		if err != nil {
+			t.Fatalf("%s: list content: %v", tc.name, err)
+		}
+		ids := make([]int64, 0, len(items))
+		for _, c := range items {
+			ids = append(ids, c.ID)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, ids)
+		}
+		if total != tc.total {
+			t.Errorf("%s: expected total %d, got %d", tc.name, tc.total, total)
+		}
+	}
+}
+
+func TestSoftDeleteContent(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	var id int64
+	err := repo.pool.QueryRow(ctx,
+		`INSERT INTO content (title, genre, popularity_score) VALUES ('Pulled Title', 'drama', 5.0) RETURNING id`,
+	).Scan(&id)
+	if err != nil {
+		t.Fatalf("insert content: %v", err)
+	}
+	if _, err := repo.pool.Exec(ctx, `INSERT INTO featured_content (content_id) VALUES ($1)`, id); err != nil {
+		t.Fatalf("feature content: %v", err)
+	}
+
+	deleted, err := repo.SoftDeleteContent(ctx, id)
+	if err != nil || !deleted {
+		t.Fatalf("soft-delete content: deleted=%v err=%v", deleted, err)
+	}
+	if deleted, err := repo.SoftDeleteContent(ctx, id); err != nil || deleted {
+		t.Errorf("expected deleting twice to report nothing deleted, got deleted=%v err=%v", deleted, err)
+	}
+
+	contains := func(items []domain.Content) bool {
+		return slices.ContainsFunc(items, func(c domain.Content) bool { return c.ID == id })
+	}
+	unwatched, err := repo.GetUnwatchedContent(ctx, 1, 1000, domain.DefaultCandidateOrder, domain.ContentRatings, 0)
+	if err != nil {
+		t.Fatalf("get unwatched content: %v", err)
+	}
+	if contains(unwatched) {
+		t.Error("expected soft-deleted content to be excluded from unwatched candidates")
+	}
+	all, _, err := repo.GetAllContent(ctx, 1, 1000, domain.DefaultCandidateOrder, domain.ContentRatings, 0)
+	if err != nil {
+		t.Fatalf("get all content: %v", err)
+	}
+	if contains(all) {
+		t.Error("expected soft-deleted content to be excluded from all candidates")
+	}
+	featured, err := repo.GetFeaturedContent(ctx, 1, 10, domain.ContentRatings)
+	if err != nil {
+		t.Fatalf("get featured content: %v", err)
+	}
+	if contains(featured) {
+		t.Error("expected soft-deleted content to be excluded from featured content")
+	}
+	found, err := repo.SearchContentByTitle(ctx, "Pulled", 10)
+	if err != nil {
+		t.Fatalf("search content: %v", err)
+	}
+	if contains(found) {
+		t.Error("expected soft-deleted content to be excluded from search")
+	}
+	listed, _, err := repo.ListContent(ctx, domain.ContentFilter{Genre: "drama", Page: 1, Limit: 100})
+	if err != nil {
+		t.Fatalf("list content: %v", err)
+	}
+	if contains(listed) {
+		t.Error("expected soft-deleted content to be excluded from listings")
+	}
+	if _, err := repo.GetContentByID(ctx, id); !errors.Is(err, domain.ErrContentNotFound) {
+		t.Errorf("expected ErrContentNotFound, got %v", err)
+	}
+
+	// The row is kept for audit
+	var deletedAt *time.Time
+	if err := repo.pool.QueryRow(ctx, `SELECT deleted_at FROM content WHERE id = $1`, id).Scan(&deletedAt); err != nil {
+		t.Fatalf("expected the row to remain: %v", err)
+	}
+	if deletedAt == nil {
+		t.Error("expected deleted_at to be set")
+	}
+}
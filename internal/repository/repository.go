@@ -1,13 +1,224 @@
 package repository
 
-import "github.com/jackc/pgx/v5/pgxpool"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+)
+
+// Deepest OFFSET a paginated query may use: the batch endpoint's 10000 pages
+// of 100. Past this, OFFSET scans make the query too slow to be useful.
+const maxPageOffset = 1_000_000
+
+// Read side of a connection pool, satisfied by *pgxpool.Pool
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Reads and writes on the primary, satisfied by both *pgxpool.Pool and pgx.Tx
+type dbtx interface {
+	querier
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
 
 type Repository struct {
+	// Primary pool, used for seeding
 	pool *pgxpool.Pool
+	// Primary pool, or the transaction inside WithTx. Used for writes and
+	// anything that must read its own writes
+	db dbtx
+	// Pool for batch user paging: the replica when configured, otherwise the
+	// primary. Inside WithTx, the transaction. Recommendation inputs stay on
+	// the primary: a write clears the user's cache, and a list scored from a
+	// lagging replica would refill it with stale results for the whole TTL
+	reader       querier
+	queryTimeout time.Duration
+	// Queries taking at least this long are logged; zero disables logging
+	slowQueryThreshold time.Duration
+	logger             *slog.Logger
+}
+
+// replica may be nil to serve every read from the primary. queryTimeout bounds
+// each repository call; zero disables the per-call timeout
+func NewRepository(pool, replica *pgxpool.Pool, queryTimeout, slowQueryThreshold time.Duration) *Repository {
+	r := &Repository{
+		pool:               pool,
+		db:                 pool,
+		reader:             pool,
+		queryTimeout:       queryTimeout,
+		slowQueryThreshold: slowQueryThreshold,
+		logger:             slog.Default(),
+	}
+	if replica != nil {
+		r.reader = replica
+	}
+	return r
+}
+
+// Run fn with a repository whose calls all go through one transaction,
+// committing when fn returns nil and rolling back when it fails or panics.
+// fn's error is returned as is. Nested calls use a savepoint.
+func (r *Repository) WithTx(ctx context.Context, fn func(txRepo *Repository) error) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return repoError(err, "begin transaction")
+	}
+	// A no-op once committed
+	defer tx.Rollback(ctx)
+
+	txRepo := *r
+	txRepo.db, txRepo.reader = tx, tx
+	if err := fn(&txRepo); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return repoError(err, "commit transaction")
+	}
+	return nil
+}
+
+// Derive a context bounded by the per-call query timeout
+func (r *Repository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.queryTimeout)
+}
+
+// Run a query on q, timed from the call until the rows are closed so that
+// reading the results counts. Slow queries are logged under name.
+func (r *Repository) timedQuery(ctx context.Context, q querier, name, sql string, args ...any) (pgx.Rows, error) {
+	start := time.Now()
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		r.logIfSlow(name, start)
+		return nil, err
+	}
+	return &timedRows{Rows: rows, done: func() { r.logIfSlow(name, start) }}, nil
+}
+
+// Like timedQuery for a single row, timed until it is scanned
+func (r *Repository) timedQueryRow(ctx context.Context, q querier, name, sql string, args ...any) pgx.Row {
+	start := time.Now()
+	row := q.QueryRow(ctx, sql, args...)
+	return timedRow{row: row, done: func() { r.logIfSlow(name, start) }}
+}
+
+func (r *Repository) logIfSlow(name string, start time.Time) {
+	if r.slowQueryThreshold <= 0 {
+		return
+	}
+	if elapsed := time.Since(start); elapsed >= r.slowQueryThreshold {
+		r.logger.Warn("slow query", "query", name, "duration", elapsed, "threshold", r.slowQueryThreshold)
+	}
+}
+
+type timedRows struct {
+	pgx.Rows
+	done   func()
+	closed bool
+}
+
+func (t *timedRows) Close() {
+	t.Rows.Close()
+	if !t.closed {
+		t.closed = true
+		t.done()
+	}
 }
 
-func NewRepository(pool *pgxpool.Pool) *Repository {
-	return &Repository{
-		pool: pool,
+type timedRow struct {
+	row  pgx.Row
+	done func()
+}
+
+func (t timedRow) Scan(dest ...any) error {
+	err := t.row.Scan(dest...)
+	t.done()
+	return err
+}
+
+// Row offset of a 1-based page, checked before multiplying so huge inputs can't
+// overflow. Fails with domain.ErrInvalidPage for a page or limit below 1 or an
+// offset beyond maxPageOffset.
+func pageOffset(page, limit int) (int, error) {
+	if page < 1 || limit < 1 {
+		return 0, fmt.Errorf("%w: page %d and limit %d must be positive", domain.ErrInvalidPage, page, limit)
+	}
+	if page-1 > maxPageOffset/limit {
+		return 0, fmt.Errorf("%w: page %d with limit %d is past the maximum offset %d", domain.ErrInvalidPage, page, limit, maxPageOffset)
+	}
+	return (page - 1) * limit, nil
+}
+
+// Wrap a failed operation as a domain.RepositoryError, flagging errors that
+// mean the database couldn't be reached
+func repoError(err error, format string, args ...any) error {
+	return &domain.RepositoryError{
+		Op:          fmt.Sprintf(format, args...),
+		Err:         err,
+		Unavailable: isConnectionError(err),
+	}
+}
+
+// Whether err means the database couldn't be reached, as opposed to a failed
+// query. Timeouts are excluded; they are reported as request timeouts.
+func isConnectionError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Class 08 connection exceptions, plus server shutdown and startup
+		return strings.HasPrefix(pgErr.Code, "08") || pgErr.Code == "57P01" || pgErr.Code == "57P02" || pgErr.Code == "57P03"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// Map constraint violations on watch history and blocklist writes to domain sentinels
+func mapConstraintError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	if pgErr.Code == uniqueViolation {
+		return domain.ErrAlreadyWatched
+	}
+	if pgErr.Code != foreignKeyViolation {
+		return err
+	}
+	switch pgErr.ConstraintName {
+	case "user_watch_history_user_id_fkey", "user_blocked_content_user_id_fkey":
+		return domain.ErrUserNotFound
+	case "user_watch_history_content_id_fkey", "user_blocked_content_content_id_fkey":
+		return domain.ErrContentNotFound
 	}
+	return err
 }
@@ -0,0 +1,355 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/actuallystonmai/recommendation-service/seeds"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Connect to TEST_DATABASE_URL, reset the schema and seed it.
+// Skips the test when no test database is configured.
+func newTestRepository(t *testing.T) *Repository {
+	t.Helper()
+
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL not set, skipping repository test")
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		t.Fatalf("connect test database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	for _, file := range []string{"create_tables.down.sql", "create_tables.up.sql"} {
+		sql, err := os.ReadFile("../../migrations/" + file)
+		if err != nil {
+			t.Fatalf("read migration %s: %v", file, err)
+		}
+		if _, err := pool.Exec(ctx, string(sql)); err != nil {
+			t.Fatalf("apply migration %s: %v", file, err)
+		}
+	}
+
+	if _, err := seeds.Setup(ctx, pool, seeds.DefaultSeedConfig()); err != nil {
+		t.Fatalf("seed test database: %v", err)
+	}
+
+	return NewRepository(pool, nil, 3*time.Second, 100*time.Millisecond)
+}
+
+func TestQueryTimeout(t *testing.T) {
+	repo := newTestRepository(t)
+	repo.queryTimeout = 100 * time.Millisecond
+
+	ctx, cancel := repo.withTimeout(context.Background())
+	defer cancel()
+
+	start := time.Now()
+	_, err := repo.pool.Exec(ctx, "SELECT pg_sleep(1)")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("query was not cancelled at the deadline, took %v", elapsed)
+	}
+}
+
+func TestRepoErrorClassifiesConnectionErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		unavailable bool
+	}{
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"connection failure", &pgconn.PgError{Code: "08006"}, true},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"unique violation", &pgconn.PgError{Code: uniqueViolation}, false},
+		{"syntax error", &pgconn.PgError{Code: "42601"}, false},
+		{"timeout", fmt.Errorf("query: %w", context.DeadlineExceeded), false},
+		{"other", errors.New("boom"), false},
+	}
+
+	for _, tc := range tests {
+		err := repoError(tc.err, "query user id=%d", 1)
+
+		var repoErr *domain.RepositoryError
+		if !errors.As(err, &repoErr) || repoErr.Op != "query user id=1" {
+			t.Errorf("%s: expected RepositoryError with op, got %v", tc.name, err)
+		}
+		if got := errors.Is(err, domain.ErrDatabaseUnavailable); got != tc.unavailable {
+			t.Errorf("%s: expected unavailable=%v, got %v", tc.name, tc.unavailable, got)
+		}
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected the cause to stay wrapped", tc.name)
+		}
+	}
+
+	// Domain sentinels stay matchable through the wrapper
+	if err := repoError(domain.ErrAlreadyWatched, "insert watch history"); !errors.Is(err, domain.ErrAlreadyWatched) {
+		t.Errorf("expected ErrAlreadyWatched, got %v", err)
+	}
+}
+
+var errReplica = errors.New("replica query")
+
+// Reader that records each query and fails it
+type recordingQuerier struct {
+	queries int
+	// Arguments of the last query
+	args []any
+	// How long each query takes before failing
+	delay time.Duration
+}
+
+func (q *recordingQuerier) Query(_ context.Context, _ string, args ...any) (pgx.Rows, error) {
+	q.queries++
+	q.args = args
+	time.Sleep(q.delay)
+	return nil, errReplica
+}
+
+func (q *recordingQuerier) QueryRow(context.Context, string, ...any) pgx.Row {
+	q.queries++
+	time.Sleep(q.delay)
+	return failedRow{}
+}
+
+type failedRow struct{}
+
+func (failedRow) Scan(...any) error { return errReplica }
+
+// Pool that never connects: nothing listens on port 1
+func newUnreachablePool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	pool, err := pgxpool.New(context.Background(), "postgres://localhost:1/recommendations?connect_timeout=1")
+	if err != nil {
+		t.Fatalf("create pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestNewRepositoryReaderFallsBackToPrimary(t *testing.T) {
+	primary, replica := newUnreachablePool(t), newUnreachablePool(t)
+
+	if repo := NewRepository(primary, nil, 0, 0); repo.reader != primary {
+		t.Error("expected reads to use the primary without a replica")
+	}
+	if repo := NewRepository(primary, replica, 0, 0); repo.reader != replica {
+		t.Error("expected reads to use the replica when configured")
+	}
+}
+
+func TestReadsUseReplica(t *testing.T) {
+	replica := &recordingQuerier{}
+	repo := NewRepository(newUnreachablePool(t), nil, time.Second, 0)
+	repo.reader = replica
+	ctx := context.Background()
+
+	reads := map[string]func() error{
+		"GetUserIDsPaginated": func() error { _, err := repo.GetUserIDsPaginated(ctx, 1, 10); return err },
+		"CountUsers":          func() error { _, err := repo.CountUsers(ctx); return err },
+	}
+	for name, read := range reads {
+		before := replica.queries
+		if err := read(); !errors.Is(err, errReplica) {
+			t.Errorf("%s: expected the replica's error, got %v", name, err)
+		}
+		if replica.queries != before+1 {
+			t.Errorf("%s: expected one replica query, got %d", name, replica.queries-before)
+		}
+	}
+}
+
+func TestRecommendationReadsUsePrimary(t *testing.T) {
+	replica := &recordingQuerier{}
+	repo := NewRepository(newUnreachablePool(t), nil, time.Second, 0)
+	repo.reader = replica
+	ctx := context.Background()
+
+	// A list scored from a lagging replica would be cached as if fresh
+	if _, err := repo.GetUserWatchHistoryWithGenres(ctx, 1, 10); !errors.Is(err, domain.ErrDatabaseUnavailable) {
+		t.Errorf("watch history: expected the primary to be unavailable, got %v", err)
+	}
+	if _, err := repo.GetUnwatchedContent(ctx, 1, 10, domain.DefaultCandidateOrder, domain.ContentRatings, 0); !errors.Is(err, domain.ErrDatabaseUnavailable) {
+		t.Errorf("unwatched content: expected the primary to be unavailable, got %v", err)
+	}
+	if _, _, err := repo.GetAllContent(ctx, 1, 10, domain.DefaultCandidateOrder, domain.ContentRatings, 0); !errors.Is(err, domain.ErrDatabaseUnavailable) {
+		t.Errorf("all content: expected the primary to be unavailable, got %v", err)
+	}
+	if replica.queries != 0 {
+		t.Errorf("expected recommendation reads not to query the replica, got %d queries", replica.queries)
+	}
+}
+
+func TestWritesUsePrimary(t *testing.T) {
+	replica := &recordingQuerier{}
+	repo := NewRepository(newUnreachablePool(t), nil, time.Second, 0)
+	repo.reader = replica
+	ctx := context.Background()
+
+	// The unreachable primary fails the write without touching the replica
+	if err := repo.AddWatchHistory(ctx, 1, 1); !errors.Is(err, domain.ErrDatabaseUnavailable) {
+		t.Errorf("expected the primary to be unavailable, got %v", err)
+	}
+	if _, err := repo.RemoveWatchHistory(ctx, 1, 1); !errors.Is(err, domain.ErrDatabaseUnavailable) {
+		t.Errorf("expected the primary to be unavailable, got %v", err)
+	}
+	if replica.queries != 0 {
+		t.Errorf("expected writes not to query the replica, got %d queries", replica.queries)
+	}
+}
+
+func TestUserLookupsUsePrimary(t *testing.T) {
+	replica := &recordingQuerier{}
+	repo := NewRepository(newUnreachablePool(t), nil, time.Second, 0)
+	repo.reader = replica
+	ctx := context.Background()
+
+	// Read on the primary so a newly created user is found straight away
+	if _, err := repo.GetUserByID(ctx, 1); !errors.Is(err, domain.ErrDatabaseUnavailable) {
+		t.Errorf("expected the primary to be unavailable, got %v", err)
+	}
+	if _, err := repo.MaxUserID(ctx); !errors.Is(err, domain.ErrDatabaseUnavailable) {
+		t.Errorf("expected the primary to be unavailable, got %v", err)
+	}
+	if replica.queries != 0 {
+		t.Errorf("expected user lookups not to query the replica, got %d queries", replica.queries)
+	}
+}
+
+// Point the repository's slow-query log at a buffer
+func captureSlowQueries(repo *Repository, threshold time.Duration) *bytes.Buffer {
+	var buf bytes.Buffer
+	repo.slowQueryThreshold = threshold
+	repo.logger = slog.New(slog.NewTextHandler(&buf, nil))
+	return &buf
+}
+
+func TestSlowQueryLogged(t *testing.T) {
+	repo := newTestRepository(t)
+	logs := captureSlowQueries(repo, 50*time.Millisecond)
+	ctx := context.Background()
+
+	if err := repo.timedQueryRow(ctx, repo.pool, "sleep", "SELECT pg_sleep(0.1)").Scan(nil); err != nil {
+		t.Fatalf("slow query: %v", err)
+	}
+	if !strings.Contains(logs.String(), `msg="slow query" query=sleep`) {
+		t.Errorf("expected a slow query log, got %q", logs.String())
+	}
+
+	logs.Reset()
+	if _, err := repo.CountUsers(ctx); err != nil {
+		t.Fatalf("count users: %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("expected fast queries not to be logged, got %q", logs.String())
+	}
+}
+
+func TestSlowQueryLogNamesRepositoryMethod(t *testing.T) {
+	repo := NewRepository(newUnreachablePool(t), nil, time.Second, 0)
+	repo.reader = &recordingQuerier{delay: 20 * time.Millisecond}
+	ctx := context.Background()
+
+	logs := captureSlowQueries(repo, 10*time.Millisecond)
+	repo.CountUsers(ctx)
+	repo.GetUserIDsPaginated(ctx, 1, 10)
+	for _, name := range []string{"CountUsers", "GetUserIDsPaginated"} {
+		if !strings.Contains(logs.String(), `msg="slow query" query=`+name+" duration=") {
+			t.Errorf("expected a slow query log for %s, got %q", name, logs.String())
+		}
+	}
+
+	// A zero threshold disables logging
+	logs = captureSlowQueries(repo, 0)
+	repo.CountUsers(ctx)
+	if logs.Len() != 0 {
+		t.Errorf("expected no logs with logging disabled, got %q", logs.String())
+	}
+}
+
+func TestWithTxRollsBackOnError(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	unwatched, err := repo.GetUnwatchedContent(ctx, 1, 2, domain.DefaultCandidateOrder, domain.ContentRatings, 0)
+	if err != nil || len(unwatched) < 2 {
+		t.Fatalf("expected 2 unwatched items for user 1: %v", err)
+	}
+	before, err := repo.GetUserWatchHistoryWithGenres(ctx, 1, 100)
+	if err != nil {
+		t.Fatalf("get watch history: %v", err)
+	}
+
+	failed := errors.New("import failed")
+	err = repo.WithTx(ctx, func(tx *Repository) error {
+		if err := tx.AddWatchHistory(ctx, 1, unwatched[0].ID); err != nil {
+			return err
+		}
+		if _, err := tx.AddWatchHistoryBatch(ctx, 1, []int64{unwatched[1].ID}); err != nil {
+			return err
+		}
+		// Writes are visible inside the transaction
+		inside, err := tx.GetUserWatchHistoryWithGenres(ctx, 1, 100)
+		if err != nil {
+			return err
+		}
+		if len(inside) != len(before)+2 {
+			t.Errorf("expected %d history items inside the transaction, got %d", len(before)+2, len(inside))
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("expected fn's error back, got %v", err)
+	}
+
+	after, err := repo.GetUserWatchHistoryWithGenres(ctx, 1, 100)
+	if err != nil {
+		t.Fatalf("get watch history: %v", err)
+	}
+	if len(after) != len(before) {
+		t.Errorf("expected writes rolled back to %d history items, got %d", len(before), len(after))
+	}
+}
+
+func TestWithTxCommits(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	unwatched, err := repo.GetUnwatchedContent(ctx, 1, 1, domain.DefaultCandidateOrder, domain.ContentRatings, 0)
+	if err != nil || len(unwatched) == 0 {
+		t.Fatalf("expected unwatched content for user 1: %v", err)
+	}
+	err = repo.WithTx(ctx, func(tx *Repository) error {
+		return tx.AddWatchHistory(ctx, 1, unwatched[0].ID)
+	})
+	if err != nil {
+		t.Fatalf("with tx: %v", err)
+	}
+
+	removed, err := repo.RemoveWatchHistory(ctx, 1, unwatched[0].ID)
+	if err != nil || !removed {
+		t.Errorf("expected the committed watch to exist, removed=%t err=%v", removed, err)
+	}
+}
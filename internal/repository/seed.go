@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/actuallystonmai/recommendation-service/seeds"
+)
+
+// Truncate all tables and reseed them in one transaction, so a failure part
+// way through leaves the old data in place. Not bounded by the per-call query
+// timeout, since a full reseed can take far longer than a single query.
+func (r *Repository) Reseed(ctx context.Context, cfg seeds.SeedConfig) (seeds.SeedSummary, error) {
+	var summary seeds.SeedSummary
+	err := r.WithTx(ctx, func(txRepo *Repository) error {
+		var err error
+		summary, err = seeds.Setup(ctx, txRepo.db, cfg)
+		return err
+	})
+	if err != nil {
+		return seeds.SeedSummary{}, err
+	}
+	return summary, nil
+}
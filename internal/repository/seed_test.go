@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actuallystonmai/recommendation-service/seeds"
+)
+
+func TestReseed(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	summary, err := repo.Reseed(ctx, seeds.SeedConfig{Users: 5, Content: 10, WatchEvents: 20, Seed: 7})
+	if err != nil {
+		t.Fatalf("reseed: %v", err)
+	}
+	if summary.Users != 5 || summary.Content != 10 {
+		t.Errorf("expected 5 users and 10 content, got %+v", summary)
+	}
+	if maxID, err := repo.MaxUserID(ctx); err != nil || maxID != 5 {
+		t.Errorf("expected ids restarted at 1 up to 5, got %d err=%v", maxID, err)
+	}
+}
+
+func TestReseedRollsBackOnFailure(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	before, err := repo.MaxUserID(ctx)
+	if err != nil {
+		t.Fatalf("max user id: %v", err)
+	}
+	// Reject every new watch, so reseeding fails after the truncate and the
+	// user and content inserts
+	if _, err := repo.pool.Exec(ctx, `
+		ALTER TABLE user_watch_history ADD CONSTRAINT reseed_test_reject CHECK (false) NOT VALID
+	`); err != nil {
+		t.Fatalf("add constraint: %v", err)
+	}
+
+	_, err = repo.Reseed(ctx, seeds.SeedConfig{Users: int(before) + 5, Content: 10, WatchEvents: 20, Seed: 7})
+	if err == nil {
+		t.Fatal("expected the reseed to fail")
+	}
+	if after, err := repo.MaxUserID(ctx); err != nil || after != before {
+		t.Errorf("expected the old %d users kept, got %d err=%v", before, after, err)
+	}
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+)
+
+// Count content items
+func (r *Repository) CountContent(ctx context.Context) (int, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var total int
+	if err := r.timedQueryRow(ctx, r.db, "CountContent", `SELECT COUNT(*) FROM content`).Scan(&total); err != nil {
+		return 0, repoError(err, "count content")
+	}
+	return total, nil
+}
+
+// Count watch history events
+func (r *Repository) CountWatchEvents(ctx context.Context) (int, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var total int
+	if err := r.timedQueryRow(ctx, r.db, "CountWatchEvents", `SELECT COUNT(*) FROM user_watch_history`).Scan(&total); err != nil {
+		return 0, repoError(err, "count watch events")
+	}
+	return total, nil
+}
+
+// Count content items per genre
+func (r *Repository) GenreCounts(ctx context.Context) (map[string]int, error) {
+	return r.groupCounts(ctx, "genre", `SELECT genre, COUNT(*) FROM content GROUP BY genre`)
+}
+
+// Count users per subscription type
+func (r *Repository) SubscriptionCounts(ctx context.Context) (map[string]int, error) {
+	return r.groupCounts(ctx, "subscription type", `SELECT subscription_type, COUNT(*) FROM users GROUP BY subscription_type`)
+}
+
+// Run a (label, count) GROUP BY query into a map
+func (r *Repository) groupCounts(ctx context.Context, name, query string) (map[string]int, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.timedQuery(ctx, r.db, name+" counts", query)
+	if err != nil {
+		return nil, repoError(err, "query %s counts", name)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var label string
+		var count int
+		if err := rows.Scan(&label, &count); err != nil {
+			return nil, repoError(err, "scan %s count", name)
+		}
+		counts[label] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, repoError(err, "iterate over %s counts", name)
+	}
+	return counts, nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/actuallystonmai/recommendation-service/seeds"
+)
+
+func TestStatsAggregates(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	cfg := seeds.DefaultSeedConfig()
+
+	content, err := repo.CountContent(ctx)
+	if err != nil {
+		t.Fatalf("count content: %v", err)
+	}
+	if content != cfg.Content {
+		t.Errorf("expected %d content items, got %d", cfg.Content, content)
+	}
+
+	// Duplicate (user, content) pairs are skipped by the seeder
+	events, err := repo.CountWatchEvents(ctx)
+	if err != nil {
+		t.Fatalf("count watch events: %v", err)
+	}
+	if events == 0 || events > cfg.WatchEvents {
+		t.Errorf("expected between 1 and %d watch events, got %d", cfg.WatchEvents, events)
+	}
+
+	genres, err := repo.GenreCounts(ctx)
+	if err != nil {
+		t.Fatalf("genre counts: %v", err)
+	}
+	genreTotal := 0
+	for _, n := range genres {
+		genreTotal += n
+	}
+	if len(genres) != 5 || genreTotal != content {
+		t.Errorf("expected 5 genres covering %d items, got %v", content, genres)
+	}
+
+	subs, err := repo.SubscriptionCounts(ctx)
+	if err != nil {
+		t.Fatalf("subscription counts: %v", err)
+	}
+	subTotal := 0
+	for _, n := range subs {
+		subTotal += n
+	}
+	if subTotal != cfg.Users {
+		t.Errorf("expected subscription counts to cover %d users, got %v", cfg.Users, subs)
+	}
+}
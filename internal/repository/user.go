@@ -4,16 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/actuallystonmai/recommendation-service/internal/domain"
 	"github.com/jackc/pgx/v5"
 )
 
-// Get single user
+// Get single user. Read from the primary, so a user is found as soon as it is
+// created rather than once the replica catches up
 func (r *Repository) GetUserByID(ctx context.Context, userID int64) (*domain.User, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	user := &domain.User{}
 
-	err := r.pool.QueryRow(ctx,
+	err := r.timedQueryRow(ctx, r.db, "GetUserByID",
 		`SELECT id, age, country, subscription_type, created_at
 		 FROM users WHERE id = $1`,
 		userID,
@@ -23,20 +29,115 @@ func (r *Repository) GetUserByID(ctx context.Context, userID int64) (*domain.Use
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, domain.ErrUserNotFound
 		}
-		return nil, fmt.Errorf("query user id=%d: %w", userID, err)
+		return nil, repoError(err, "query user id=%d", userID)
 	}
 
 	return user, nil
 }
 
+// Highest user ID, 0 when there are no users. Read from the primary, since a
+// lagging bound would reject users that were just created
+func (r *Repository) MaxUserID(ctx context.Context) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var maxID int64
+	err := r.timedQueryRow(ctx, r.db, "MaxUserID",
+		`SELECT COALESCE(MAX(id), 0) FROM users`,
+	).Scan(&maxID)
+	if err != nil {
+		return 0, repoError(err, "query max user id")
+	}
+	return maxID, nil
+}
+
+// Insert a user, returning its generated ID
+func (r *Repository) InsertUser(ctx context.Context, u domain.User) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var id int64
+	err := r.timedQueryRow(ctx, r.db, "InsertUser",
+		`INSERT INTO users (age, country, subscription_type, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`,
+		u.Age, u.Country, u.SubscriptionType, u.CreatedAt,
+	).Scan(&id)
+	if err != nil {
+		return 0, repoError(err, "insert user")
+	}
+	return id, nil
+}
+
+// Find users matching every set filter, ordered by ID and paginated
+func (r *Repository) FindUsers(ctx context.Context, filter domain.UserFilter) ([]domain.User, error) {
+	offset, err := pageOffset(filter.Page, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var conditions []string
+	var args []any
+	addCondition := func(clause string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(clause, len(args)))
+	}
+	if filter.Country != "" {
+		addCondition("country = $%d", filter.Country)
+	}
+	if filter.SubscriptionType != "" {
+		addCondition("subscription_type = $%d", filter.SubscriptionType)
+	}
+	if filter.MinAge > 0 {
+		addCondition("age >= $%d", filter.MinAge)
+	}
+
+	query := `SELECT id, age, country, subscription_type, created_at FROM users`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit, offset)
+	query += fmt.Sprintf(" ORDER BY id LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.timedQuery(ctx, r.db, "FindUsers", query, args...)
+	if err != nil {
+		return nil, repoError(err, "query users")
+	}
+	defer rows.Close()
+
+	var users []domain.User
+	for rows.Next() {
+		var u domain.User
+		if err := rows.Scan(&u.ID, &u.Age, &u.Country, &u.SubscriptionType, &u.CreatedAt); err != nil {
+			return nil, repoError(err, "scan user")
+		}
+		users = append(users, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, repoError(err, "iterate over users")
+	}
+	return users, nil
+}
+
 // Get user ids for page
 func (r *Repository) GetUserIDsPaginated(ctx context.Context, page, limit int) ([]int64, error) {
-	offset := (page - 1) * limit
-	rows, err := r.pool.Query(ctx,
+	offset, err := pageOffset(page, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.timedQuery(ctx, r.reader, "GetUserIDsPaginated",
 		`SELECT id FROM users ORDER BY id LIMIT $1 OFFSET $2`, limit, offset,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("query user ids for page %d: %w", page, err)
+		return nil, repoError(err, "query user ids for page %d", page)
 	}
 	defer rows.Close()
 
@@ -44,26 +145,62 @@ func (r *Repository) GetUserIDsPaginated(ctx context.Context, page, limit int) (
 	for rows.Next() {
 		var id int64
 		if err := rows.Scan(&id); err != nil {
-			return nil, fmt.Errorf("scan user id: %w", err)
+			return nil, repoError(err, "scan user id")
 		}
 		ids = append(ids, id)
 	}
 	
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate user ids: %w", err)
+		return nil, repoError(err, "iterate user ids")
 	}
 	return ids, nil
 }
 
 // Count total users
 func (r *Repository) CountUsers(ctx context.Context) (int, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	var total int
-	err := r.pool.QueryRow(ctx,
+	err := r.timedQueryRow(ctx, r.reader, "CountUsers",
 		`SELECT COUNT(*) FROM users`,
 	).Scan(&total)
 
 	if err != nil {
-		return 0, fmt.Errorf("count users: %w", err)
+		return 0, repoError(err, "count users")
 	}
 	return total, nil
-}
\ No newline at end of file
+}
+
+// Get the users with the most watch events since the given time, most active first
+func (r *Repository) GetMostActiveUsers(ctx context.Context, since time.Time, limit int) ([]int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.timedQuery(ctx, r.db, "GetMostActiveUsers",
+		`SELECT user_id
+		FROM user_watch_history
+		WHERE watched_at >= $1
+		GROUP BY user_id
+		ORDER BY COUNT(*) DESC, user_id
+		LIMIT $2`, since, limit,
+	)
+	if err != nil {
+		return nil, repoError(err, "query most active users")
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, repoError(err, "scan user id")
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, repoError(err, "iterate over user ids")
+	}
+	return ids, nil
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+func TestGetMostActiveUsers(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	if _, err := repo.pool.Exec(ctx, "TRUNCATE user_watch_history"); err != nil {
+		t.Fatalf("truncate watch history: %v", err)
+	}
+
+	// User 3: 3 recent, user 1: 2 recent, user 2: 4 old
+	if _, err := repo.pool.Exec(ctx, `
+		INSERT INTO user_watch_history (user_id, content_id, watched_at) VALUES
+			(3, 1, NOW()), (3, 2, NOW()), (3, 3, NOW()),
+			(1, 1, NOW()), (1, 2, NOW()),
+			(2, 1, NOW() - INTERVAL '60 days'), (2, 2, NOW() - INTERVAL '60 days'),
+			(2, 3, NOW() - INTERVAL '60 days'), (2, 4, NOW() - INTERVAL '60 days')
+	`); err != nil {
+		t.Fatalf("insert watch history: %v", err)
+	}
+
+	ids, err := repo.GetMostActiveUsers(ctx, time.Now().AddDate(0, 0, -7), 10)
+	if err != nil {
+		t.Fatalf("get most active users: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != 3 || ids[1] != 1 {
+		t.Errorf("expected [3 1], got %v", ids)
+	}
+
+	ids, err = repo.GetMostActiveUsers(ctx, time.Now().AddDate(0, 0, -7), 1)
+	if err != nil {
+		t.Fatalf("get most active users: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != 3 {
+		t.Errorf("expected [3] with limit 1, got %v", ids)
+	}
+}
+
+func TestFindUsers(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	if _, err := repo.pool.Exec(ctx, "TRUNCATE users RESTART IDENTITY CASCADE"); err != nil {
+		t.Fatalf("truncate users: %v", err)
+	}
+	if _, err := repo.pool.Exec(ctx, `
+		INSERT INTO users (age, country, subscription_type) VALUES
+			(20, 'US', 'premium'),
+			(30, 'US', 'premium'),
+			(40, 'US', 'free'),
+			(35, 'GB', 'premium'),
+			(50, 'GB', 'basic')
+	`); err != nil {
+		t.Fatalf("insert users: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		filter   domain.UserFilter
+		expected []int64
+	}{
+		{"no filters", domain.UserFilter{}, []int64{1, 2, 3, 4, 5}},
+		{"country", domain.UserFilter{Country: "US"}, []int64{1, 2, 3}},
+		{"subscription", domain.UserFilter{SubscriptionType: "premium"}, []int64{1, 2, 4}},
+		{"min age", domain.UserFilter{MinAge: 35}, []int64{3, 4, 5}},
+		{"country and subscription", domain.UserFilter{Country: "US", SubscriptionType: "premium"}, []int64{1, 2}},
+		{"country and min age", domain.UserFilter{Country: "GB", MinAge: 40}, []int64{5}},
+		{"subscription and min age", domain.UserFilter{SubscriptionType: "premium", MinAge: 25}, []int64{2, 4}},
+		{"all filters", domain.UserFilter{Country: "US", SubscriptionType: "premium", MinAge: 25}, []int64{2}},
+		{"no match", domain.UserFilter{Country: "JP"}, nil},
+		{"second page", domain.UserFilter{Page: 2, Limit: 2}, []int64{3, 4}},
+		{"filtered page", domain.UserFilter{Country: "US", Page: 2, Limit: 2}, []int64{3}},
+	}
+
+	for _, tc := range tests {
+		filter := tc.filter
+		if filter.Page == 0 {
+			filter.Page, filter.Limit = 1, 50
+		}
+		users, err := repo.FindUsers(ctx, filter)
+		if err != nil {
+			t.Fatalf("%s: find users: %v", tc.name, err)
+		}
+		ids := make([]int64, 0, len(users))
+		for _, u := range users {
+			ids = append(ids, u.ID)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, ids)
+		}
+	}
+}
+
+func TestPageOffset(t *testing.T) {
+	cases := []struct {
+		page, limit int
+		want        int
+		ok          bool
+	}{
+		{1, 20, 0, true},
+		{3, 10, 20, true},
+		{10000, 100, 999_900, true},
+		{maxPageOffset + 1, 1, maxPageOffset, true},
+		{0, 20, 0, false},
+		{-1, 20, 0, false},
+		{1, 0, 0, false},
+		{1, -5, 0, false},
+		{maxPageOffset + 2, 1, 0, false},
+		{10001, 101, 0, false},
+		// Would overflow if multiplied first
+		{math.MaxInt, math.MaxInt, 0, false},
+		{math.MaxInt / 2, 4, 0, false},
+	}
+	for _, tc := range cases {
+		got, err := pageOffset(tc.page, tc.limit)
+		if tc.ok != (err == nil) || got != tc.want {
+			t.Errorf("page %d limit %d: expected %d ok=%v, got %d err=%v", tc.page, tc.limit, tc.want, tc.ok, got, err)
+		}
+		if err != nil && !errors.Is(err, domain.ErrInvalidPage) {
+			t.Errorf("page %d limit %d: expected ErrInvalidPage, got %v", tc.page, tc.limit, err)
+		}
+	}
+}
+
+func TestPaginatedQueriesRejectBadPages(t *testing.T) {
+	replica := &recordingQuerier{}
+	repo := NewRepository(newUnreachablePool(t), nil, time.Second, 0)
+	repo.reader = replica
+	ctx := context.Background()
+
+	for _, p := range []struct{ page, limit int }{{0, 20}, {-3, 20}, {2, -1}, {math.MaxInt, 100}} {
+		if _, err := repo.GetUserIDsPaginated(ctx, p.page, p.limit); !errors.Is(err, domain.ErrInvalidPage) {
+			t.Errorf("GetUserIDsPaginated(%d, %d): expected ErrInvalidPage, got %v", p.page, p.limit, err)
+		}
+		filter := domain.UserFilter{Page: p.page, Limit: p.limit}
+		if _, err := repo.FindUsers(ctx, filter); !errors.Is(err, domain.ErrInvalidPage) {
+			t.Errorf("FindUsers(%d, %d): expected ErrInvalidPage, got %v", p.page, p.limit, err)
+		}
+	}
+	if replica.queries != 0 {
+		t.Errorf("expected invalid pages to fail before querying, got %d queries", replica.queries)
+	}
+
+	// A valid page reaches the database with its offset
+	repo.GetUserIDsPaginated(ctx, 3, 10)
+	if len(replica.args) != 2 || replica.args[1] != 20 {
+		t.Errorf("expected limit 10 offset 20, got args %v", replica.args)
+	}
+}
+
+func TestInsertUser(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	created := time.Now().UTC().Truncate(time.Second)
+	id, err := repo.InsertUser(ctx, domain.User{Age: 16, Country: "GB", SubscriptionType: "premium", CreatedAt: created})
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	got, err := repo.GetUserByID(ctx, id)
+	if err != nil {
+		t.Fatalf("get inserted user: %v", err)
+	}
+	if got.Age != 16 || got.Country != "GB" || got.SubscriptionType != "premium" || !got.CreatedAt.Equal(created) {
+		t.Errorf("unexpected stored user: %+v", got)
+	}
+
+	// The schema rejects a non-positive age and an unknown subscription type
+	bad := []domain.User{
+		{Age: 0, Country: "US", SubscriptionType: "free", CreatedAt: created},
+		{Age: 30, Country: "US", SubscriptionType: "gold", CreatedAt: created},
+	}
+	for _, u := range bad {
+		if _, err := repo.InsertUser(ctx, u); err == nil {
+			t.Errorf("expected error inserting %+v", u)
+		}
+	}
+}
+
+func TestMaxUserID(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	before, err := repo.MaxUserID(ctx)
+	if err != nil {
+		t.Fatalf("max user id: %v", err)
+	}
+	if before == 0 {
+		t.Fatal("expected seeded users")
+	}
+	id, err := repo.InsertUser(ctx, domain.User{Age: 30, Country: "US", SubscriptionType: "basic"})
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	if after, err := repo.MaxUserID(ctx); err != nil || after != id {
+		t.Errorf("expected max id %d after insert, got %d err=%v", id, after, err)
+	}
+}
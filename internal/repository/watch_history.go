@@ -3,13 +3,20 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/actuallystonmai/recommendation-service/internal/domain"
 )
 
+// A user's most recent watches with their genres. Read from the primary, so a
+// list scored right after a watch is recorded includes it
 func (r *Repository) GetUserWatchHistoryWithGenres(ctx context.Context, userID int64, limit int) ([]domain.WatchHistoryItem, error) {
-	row, err := r.pool.Query(ctx,
-		`SELECT c.id, c.genre, uwh.watched_at
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	row, err := r.timedQuery(ctx, r.db, "GetUserWatchHistoryWithGenres",
+		`SELECT c.id, c.genre, uwh.watched_at, uwh.completion_ratio
 		FROM user_watch_history uwh
 		JOIN content c ON uwh.content_id = c.id
 		WHERE uwh.user_id = $1
@@ -19,7 +26,7 @@ func (r *Repository) GetUserWatchHistoryWithGenres(ctx context.Context, userID i
 	)
 	
 	if err != nil {
-		return nil, fmt.Errorf("get watch history for user %d: %w", userID, err)
+		return nil, repoError(err, "get watch history for user %d", userID)
 	}
 	
 	defer row.Close()
@@ -27,27 +34,103 @@ func (r *Repository) GetUserWatchHistoryWithGenres(ctx context.Context, userID i
 	var items []domain.WatchHistoryItem
 	for row.Next() {
 		var item domain.WatchHistoryItem
-		if err := row.Scan(&item.ContentID, &item.Genre, &item.WatchedAt); err != nil {
-			return nil, fmt.Errorf("scan watch history item: %w", err)
+		if err := row.Scan(&item.ContentID, &item.Genre, &item.WatchedAt, &item.CompletionRatio); err != nil {
+			return nil, repoError(err, "scan watch history item")
 		}
 		items = append(items, item)
 	}
 	
 	if err := row.Err(); err != nil {
-		return nil, fmt.Errorf("iterate over watch history items: %w", err)
+		return nil, repoError(err, "iterate over watch history items")
 	}
 	
 	return items, nil
 }
 
 func (r *Repository) AddWatchHistory(ctx context.Context, userID, contentID int64) error {
-    _, err := r.pool.Exec(ctx,
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+    _, err := r.db.Exec(ctx,
         `INSERT INTO user_watch_history (user_id, content_id, watched_at) 
          VALUES ($1, $2, NOW())`,
         userID, contentID,
     )
     if err != nil {
-        return fmt.Errorf("insert watch history: %w", err)
+        return repoError(mapConstraintError(err), "insert watch history")
     }
     return nil
-}
\ No newline at end of file
+}
+
+// Delete a watch record, reporting whether one existed
+func (r *Repository) RemoveWatchHistory(ctx context.Context, userID, contentID int64) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := r.db.Exec(ctx,
+		`DELETE FROM user_watch_history WHERE user_id = $1 AND content_id = $2`,
+		userID, contentID,
+	)
+	if err != nil {
+		return false, repoError(err, "delete watch history")
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Insert many watch events for a user in one transaction, skipping duplicates.
+// Returns the number of rows actually inserted.
+func (r *Repository) AddWatchHistoryBatch(ctx context.Context, userID int64, contentIDs []int64) (int, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if len(contentIDs) == 0 {
+		return 0, nil
+	}
+
+	rows := make([]string, 0, len(contentIDs))
+	args := []any{userID}
+	for _, contentID := range contentIDs {
+		args = append(args, contentID)
+		rows = append(rows, fmt.Sprintf("($1, $%d, NOW())", len(args)))
+	}
+
+	var inserted int64
+	err := r.WithTx(ctx, func(tx *Repository) error {
+		tag, err := tx.db.Exec(ctx,
+			`INSERT INTO user_watch_history (user_id, content_id, watched_at) VALUES `+
+				strings.Join(rows, ", ")+
+				` ON CONFLICT DO NOTHING`,
+			args...,
+		)
+		if err != nil {
+			return repoError(mapConstraintError(err), "insert watch history batch for user %d", userID)
+		}
+		inserted = tag.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(inserted), nil
+}
+
+// Time of the most recent watch event by any of the given users; zero when
+// none of them has watched anything. Read from the primary, like the history
+// it validates.
+func (r *Repository) MaxWatchedAtForUsers(ctx context.Context, userIDs []int64) (time.Time, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var latest *time.Time
+	err := r.timedQueryRow(ctx, r.db, "MaxWatchedAtForUsers",
+		`SELECT MAX(watched_at) FROM user_watch_history WHERE user_id = ANY($1)`,
+		userIDs,
+	).Scan(&latest)
+	if err != nil {
+		return time.Time{}, repoError(err, "query latest watch event for %d users", len(userIDs))
+	}
+	if latest == nil {
+		return time.Time{}, nil
+	}
+	return *latest, nil
+}
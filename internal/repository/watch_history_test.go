@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+func TestAddWatchHistoryBatchSkipsDuplicates(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	history, err := repo.GetUserWatchHistoryWithGenres(ctx, 1, 50)
+	if err != nil {
+		t.Fatalf("get watch history: %v", err)
+	}
+	if len(history) == 0 {
+		t.Fatal("expected seeded watch history for user 1")
+	}
+
+	// Find content the user has not watched yet
+	unwatched, err := repo.GetUnwatchedContent(ctx, 1, 2, domain.DefaultCandidateOrder, domain.ContentRatings, 0)
+	if err != nil {
+		t.Fatalf("get unwatched content: %v", err)
+	}
+	if len(unwatched) < 2 {
+		t.Fatal("expected at least 2 unwatched items for user 1")
+	}
+
+	// One already-watched item, two new items and a repeat within the request
+	contentIDs := []int64{history[0].ContentID, unwatched[0].ID, unwatched[1].ID, unwatched[1].ID}
+
+	inserted, err := repo.AddWatchHistoryBatch(ctx, 1, contentIDs)
+	if err != nil {
+		t.Fatalf("add watch history batch: %v", err)
+	}
+	if inserted != 2 {
+		t.Errorf("expected 2 inserted, got %d", inserted)
+	}
+
+	after, err := repo.GetUserWatchHistoryWithGenres(ctx, 1, 100)
+	if err != nil {
+		t.Fatalf("get watch history: %v", err)
+	}
+	if len(after) != len(history)+2 {
+		t.Errorf("expected %d history items, got %d", len(history)+2, len(after))
+	}
+}
+
+func TestAddWatchHistoryBatchUnknownContent(t *testing.T) {
+	repo := newTestRepository(t)
+
+	_, err := repo.AddWatchHistoryBatch(context.Background(), 1, []int64{999999})
+	if !errors.Is(err, domain.ErrContentNotFound) {
+		t.Errorf("expected ErrContentNotFound, got %v", err)
+	}
+}
+
+func TestRemoveWatchHistory(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	history, err := repo.GetUserWatchHistoryWithGenres(ctx, 1, 50)
+	if err != nil || len(history) == 0 {
+		t.Fatalf("expected seeded watch history for user 1: %v", err)
+	}
+	contentID := history[0].ContentID
+
+	removed, err := repo.RemoveWatchHistory(ctx, 1, contentID)
+	if err != nil {
+		t.Fatalf("remove watch history: %v", err)
+	}
+	if !removed {
+		t.Error("expected existing record to be removed")
+	}
+
+	// Second delete affects nothing
+	removed, err = repo.RemoveWatchHistory(ctx, 1, contentID)
+	if err != nil {
+		t.Fatalf("remove watch history: %v", err)
+	}
+	if removed {
+		t.Error("expected no record on second delete")
+	}
+}
+
+func TestMaxWatchedAtForUsers(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	before, err := repo.MaxWatchedAtForUsers(ctx, []int64{1, 2})
+	if err != nil {
+		t.Fatalf("max watched at: %v", err)
+	}
+	if before.IsZero() {
+		t.Fatal("expected seeded watch history for users 1 and 2")
+	}
+
+	// Unchanged history keeps the same timestamp
+	again, err := repo.MaxWatchedAtForUsers(ctx, []int64{1, 2})
+	if err != nil || !again.Equal(before) {
+		t.Errorf("expected %s again, got %s err=%v", before, again, err)
+	}
+
+	unwatched, err := repo.GetUnwatchedContent(ctx, 2, 1, domain.DefaultCandidateOrder, domain.ContentRatings, 0)
+	if err != nil || len(unwatched) == 0 {
+		t.Fatalf("expected unwatched content for user 2, got %v err=%v", unwatched, err)
+	}
+	if err := repo.AddWatchHistory(ctx, 2, unwatched[0].ID); err != nil {
+		t.Fatalf("add watch history: %v", err)
+	}
+	after, err := repo.MaxWatchedAtForUsers(ctx, []int64{1, 2})
+	if err != nil {
+		t.Fatalf("max watched at: %v", err)
+	}
+	if !after.After(before) {
+		t.Errorf("expected a new watch event to advance %s, got %s", before, after)
+	}
+
+	// Users without history have no timestamp
+	id, err := repo.InsertUser(ctx, domain.User{Age: 30, Country: "US", SubscriptionType: "basic"})
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	if latest, err := repo.MaxWatchedAtForUsers(ctx, []int64{id}); err != nil || !latest.IsZero() {
+		t.Errorf("expected zero time for a user without history, got %s err=%v", latest, err)
+	}
+}
+
+func TestGetUserWatchHistoryCompletionRatio(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	if _, err := repo.pool.Exec(ctx, "DELETE FROM user_watch_history WHERE user_id = 1"); err != nil {
+		t.Fatalf("clear watch history: %v", err)
+	}
+	if _, err := repo.pool.Exec(ctx,
+		`INSERT INTO user_watch_history (user_id, content_id, watched_at, completion_ratio)
+		VALUES (1, 1, NOW(), 0.25)`,
+	); err != nil {
+		t.Fatalf("insert partial watch: %v", err)
+	}
+	// Watches added through the API carry no ratio and count as finished
+	if err := repo.AddWatchHistory(ctx, 1, 2); err != nil {
+		t.Fatalf("add watch history: %v", err)
+	}
+
+	items, err := repo.GetUserWatchHistoryWithGenres(ctx, 1, 10)
+	if err != nil {
+		t.Fatalf("get watch history: %v", err)
+	}
+	ratios := make(map[int64]float64)
+	for _, item := range items {
+		ratios[item.ContentID] = item.CompletionRatio
+	}
+	if ratios[1] != 0.25 || ratios[2] != 1 {
+		t.Errorf("expected ratios 0.25 and 1, got %v", ratios)
+	}
+}
@@ -6,21 +6,92 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/actuallystonmai/recommendation-service/internal/config"
 	"github.com/actuallystonmai/recommendation-service/internal/handler"
 )
 
-func Setup(h *handler.Handler) http.Handler {
+// batchLimiter throttles the batch endpoints; nil disables rate limiting
+func Setup(h *handler.Handler, cfg *config.Config, batchLimiter handler.RateLimiter) http.Handler {
 	r := chi.NewRouter()
 
 	// Middleware
+	r.Use(middleware.RequestID)
+	r.Use(handler.EchoRequestID)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(30 * time.Second))
 
-	// Routes
-	r.Get("/users/{userID}/recommendations", h.GetRecommendations)
-	r.Get("/recommendations/batch", h.GetBatchRecommendations)
-	r.Get("/health", healthCheck)
+	// CORS: only enabled for configured origins, since go-chi/cors allows
+	// every origin when the list is empty
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		r.Use(cors.Handler(cors.Options{
+			AllowedOrigins: cfg.CORSAllowedOrigins,
+			AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodDelete},
+			AllowedHeaders: []string{"Accept", "Content-Type", "Idempotency-Key", "Range", "X-API-Key", "X-Request-ID"},
+			ExposedHeaders: []string{"Content-Range", "Idempotent-Replayed", "X-Request-ID"},
+			MaxAge:         300,
+		}))
+	}
+
+	// API key auth, after CORS so preflights don't need a key
+	r.Use(h.APIKeyAuth(cfg.APIKeys))
+
+	// Reseeding truncates every table, so it is opt-in. It sets its own,
+	// longer deadline instead of the request timeout below
+	if cfg.SeedEndpointEnabled {
+		r.Post("/admin/seed", h.ReseedData)
+	}
+
+	// Streamed batches run as long as the batch does, so they skip the request
+	// timeout below and extend their write deadline as they write
+	r.Group(func(r chi.Router) {
+		if batchLimiter != nil {
+			r.Use(h.RateLimit(batchLimiter, len(cfg.APIKeys) > 0))
+		}
+		r.Get("/recommendations/batch/stream", h.StreamBatchRecommendations)
+		r.Get("/recommendations/batch/progress", h.StreamBatchProgress)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(30 * time.Second))
+
+		// Routes
+		r.Get("/users", h.FindUsers)
+		r.Post("/users", h.CreateUser)
+		r.Get("/users/{userID}/recommendations", h.GetRecommendations)
+		r.Get("/users/{userID}/recommendations/by-genre", h.GetRecommendationsByGenre)
+		r.Get("/users/{userID}/watch-history", h.GetWatchHistory)
+		r.Post("/users/{userID}/watch-history", h.AddWatchHistory)
+		r.Post("/users/{userID}/watch-history/bulk", h.AddWatchHistoryBulk)
+		r.Delete("/users/{userID}/watch-history/{contentID}", h.RemoveWatchHistory)
+		r.Post("/users/{userID}/blocklist", h.BlockContent)
+		r.Delete("/users/{userID}/blocklist/{contentID}", h.UnblockContent)
+		r.Group(func(r chi.Router) {
+			if batchLimiter != nil {
+				r.Use(h.RateLimit(batchLimiter, len(cfg.APIKeys) > 0))
+			}
+			r.Get("/recommendations/batch", h.GetBatchRecommendations)
+			r.Post("/recommendations/bulk", h.GetBulkRecommendations)
+		})
+		r.Get("/content", h.ListContent)
+		r.Get("/content/search", h.SearchContent)
+		r.Get("/content/trending", h.GetTrendingContent)
+		r.Post("/content", h.CreateContent)
+		r.Delete("/content/{contentID}", h.DeleteContent)
+		r.Post("/admin/users/{userID}/cache/invalidate", h.InvalidateUserCache)
+		r.Get("/admin/failed-users", h.GetFailedUsers)
+		r.Delete("/admin/failed-users/{userID}", h.ClearFailedUser)
+		r.Get("/stats", h.GetStats)
+		r.Get("/health", healthCheck)
+		r.Handle("/metrics", promhttp.Handler())
+
+		// Debug routes
+		if cfg.DebugEndpointsEnabled {
+			r.Get("/debug/score", h.GetScoreBreakdown)
+			r.Get("/users/{userID}/recommendations/cached", h.GetCachedRecommendations)
+		}
+	})
 
 	return r
 }
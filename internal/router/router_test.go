@@ -0,0 +1,152 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/config"
+	"github.com/actuallystonmai/recommendation-service/internal/handler"
+)
+
+// Send a CORS preflight for the recommendations endpoint
+func preflight(r http.Handler, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, "/users/1/recommendations", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSPreflight(t *testing.T) {
+	r := Setup(handler.NewHandler(nil, handler.Config{}), &config.Config{
+		CORSAllowedOrigins: []string{"https://app.example.com"},
+	}, nil)
+
+	rec := preflight(r, "https://app.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("expected allowed origin header, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != http.MethodGet {
+		t.Errorf("expected allowed method GET, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type" {
+		t.Errorf("expected allowed header Content-Type, got %q", got)
+	}
+
+	rec = preflight(r, "https://evil.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no allow-origin for disallowed origin, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "" {
+		t.Errorf("expected no allow-methods for disallowed origin, got %q", got)
+	}
+}
+
+func TestCORSDeniedByDefault(t *testing.T) {
+	r := Setup(handler.NewHandler(nil, handler.Config{}), &config.Config{}, nil)
+
+	rec := preflight(r, "https://app.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no CORS headers when no origins are configured, got %q", got)
+	}
+}
+
+func TestSeedRouteDisabledByDefault(t *testing.T) {
+	r := Setup(handler.NewHandler(nil, handler.Config{}), &config.Config{}, nil)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/seed", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 when the seed endpoint is disabled, got %d", rec.Code)
+	}
+}
+
+func TestMetricsRoute(t *testing.T) {
+	r := Setup(handler.NewHandler(nil, handler.Config{}), &config.Config{}, nil)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 from /metrics, got %d", rec.Code)
+	}
+}
+
+func TestMetricsRouteSkipsAPIKey(t *testing.T) {
+	r := Setup(handler.NewHandler(nil, handler.Config{}), &config.Config{APIKeys: []string{"secret"}}, nil)
+
+	// Prometheus scrapes without a key
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 from /metrics without a key, got %d", rec.Code)
+	}
+
+	// Other routes still need one
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 from /stats without a key, got %d", rec.Code)
+	}
+}
+
+type denyLimiter struct{}
+
+func (denyLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	return false, time.Second, nil
+}
+
+func TestBatchRoutesRateLimited(t *testing.T) {
+	r := Setup(handler.NewHandler(nil, handler.Config{}), &config.Config{}, denyLimiter{})
+
+	for _, path := range []string{"/recommendations/batch", "/recommendations/batch/stream"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusTooManyRequests {
+			t.Errorf("%s: expected 429, got %d", path, rec.Code)
+		}
+	}
+
+	// Other routes are not limited
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 from /health, got %d", rec.Code)
+	}
+}
+
+func TestCachedRecommendationsRouteRequiresDebug(t *testing.T) {
+	r := Setup(handler.NewHandler(nil, handler.Config{}), &config.Config{}, nil)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/1/recommendations/cached", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 when debug endpoints are disabled, got %d", rec.Code)
+	}
+}
+
+func TestRequestIDEchoed(t *testing.T) {
+	r := Setup(handler.NewHandler(nil, handler.Config{}), &config.Config{APIKeys: []string{"secret"}}, nil)
+
+	// Unauthorized responses carry the ID too
+	req := httptest.NewRequest(http.MethodGet, "/users/1/recommendations", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("X-Request-ID"); got != "req-42" {
+		t.Errorf("expected echoed request ID req-42, got %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if got := rec.Header().Get("X-Request-ID"); got == "" {
+		t.Error("expected a generated request ID when none is provided")
+	}
+}
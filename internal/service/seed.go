@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/actuallystonmai/recommendation-service/seeds"
+)
+
+// Seed sizes used when a reseed request doesn't specify them
+func (s *Service) SeedDefaults() seeds.SeedConfig {
+	return s.seedDefaults
+}
+
+// Replace all data with freshly seeded rows. Every cached recommendation,
+// trending list and stats summary is dropped afterwards, since it refers to
+// the old users and content.
+func (s *Service) Reseed(ctx context.Context, cfg seeds.SeedConfig) (seeds.SeedSummary, error) {
+	summary, err := s.repo.Reseed(ctx, cfg)
+	if err != nil {
+		return summary, fmt.Errorf("reseed: %w", err)
+	}
+	s.resetUserIDBound()
+	s.catalogChanged(ctx)
+	return summary, nil
+}
@@ -5,47 +5,212 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/actuallystonmai/recommendation-service/internal/cache"
 	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/actuallystonmai/recommendation-service/internal/experiment"
+	"github.com/actuallystonmai/recommendation-service/internal/metrics"
 	"github.com/actuallystonmai/recommendation-service/internal/model"
 	"github.com/actuallystonmai/recommendation-service/internal/repository"
+	"github.com/actuallystonmai/recommendation-service/seeds"
 )
 
 const (
 	defaultLimit        = 10
-	maxLimit            = 50
+	// Largest limit served; the cache checks up to it for superset entries
+	MaxLimit            = 50
 	watchHistoryLimit   = 50
 	candidatePoolSize   = 100
-	batchConcurrency    = 10
+	candidatePoolPerWatch = 5
+	maxCandidatePoolSize  = 300
+	defaultBatchConcurrency = 10
+	// Recommendations per user when a batch doesn't ask for a number
 	batchRecLimit       = 10
+	// Genres explaining each user's batch results
+	batchTopGenres      = 3
+	// Cache limit holding a user's full scored list for paging; no candidate
+	// pool is larger, and no request limit reaches it
+	fullListLimit       = maxCandidatePoolSize
 )
 
+type Config struct {
+	// Max users processed concurrently across all batch requests
+	BatchConcurrency int
+	// Strategy split for users that don't request a strategy; nil uses the default
+	Experiment *experiment.Split
+	// Seed sizes for POST /admin/seed; zero uses seeds.DefaultSeedConfig
+	SeedDefaults seeds.SeedConfig
+	// Reuse a user's last generation for any limit within this interval; zero
+	// regenerates on every cache miss
+	MinRegenInterval time.Duration
+	// Minimum ages for content ratings; nil uses domain.DefaultRatingPolicy
+	RatingPolicy domain.RatingPolicy
+	// Content below this popularity score is never a candidate, unless a
+	// request opts out of the floor
+	MinPopularity float64
+	// User IDs up to this are in range without looking up the highest
+	// existing user ID; zero always looks it up
+	MaxUserID int64
+	// Counts recommendation cache hits, misses and errors; nil counts nothing
+	CacheMetrics *metrics.CacheMetrics
+	// Users failing batch processing more than this many times within
+	// BatchFailureWindow are flagged as failed users; zero disables tracking
+	BatchFailureThreshold int
+	BatchFailureWindow time.Duration
+	// Stop scoring candidates after this long and serve the best scored so
+	// far; zero scores every candidate
+	ScoringTimeout time.Duration
+}
+
+// Repo is the data access the service depends on
+type Repo interface {
+	GetUserByID(ctx context.Context, userID int64) (*domain.User, error)
+	MaxUserID(ctx context.Context) (int64, error)
+	GetUserWatchHistoryWithGenres(ctx context.Context, userID int64, limit int) ([]domain.WatchHistoryItem, error)
+	GetUnwatchedContent(ctx context.Context, userID int64, limit int, order domain.CandidateOrder, ratings []string, minPopularity float64) ([]domain.Content, error)
+	GetAllContent(ctx context.Context, userID int64, limit int, order domain.CandidateOrder, ratings []string, minPopularity float64) ([]domain.Content, map[int64]bool, error)
+	GetFeaturedContent(ctx context.Context, userID int64, limit int, ratings []string) ([]domain.Content, error)
+	GetContentByID(ctx context.Context, contentID int64) (*domain.Content, error)
+	GetRegionalPopularity(ctx context.Context, country string, contentIDs []int64) (map[int64]float64, error)
+	SearchContentByTitle(ctx context.Context, query string, limit int) ([]domain.Content, error)
+	ListContent(ctx context.Context, filter domain.ContentFilter) ([]domain.Content, int, error)
+	GetTrendingContent(ctx context.Context, since time.Time, limit int) ([]domain.TrendingContent, error)
+	InsertContent(ctx context.Context, c domain.Content) (int64, error)
+	SoftDeleteContent(ctx context.Context, contentID int64) (bool, error)
+	GetUserIDsPaginated(ctx context.Context, page, limit int) ([]int64, error)
+	MaxWatchedAtForUsers(ctx context.Context, userIDs []int64) (time.Time, error)
+	FindUsers(ctx context.Context, filter domain.UserFilter) ([]domain.User, error)
+	InsertUser(ctx context.Context, u domain.User) (int64, error)
+	CountUsers(ctx context.Context) (int, error)
+	GetMostActiveUsers(ctx context.Context, since time.Time, limit int) ([]int64, error)
+	CountContent(ctx context.Context) (int, error)
+	CountWatchEvents(ctx context.Context) (int, error)
+	GenreCounts(ctx context.Context) (map[string]int, error)
+	SubscriptionCounts(ctx context.Context) (map[string]int, error)
+	AddWatchHistory(ctx context.Context, userID, contentID int64) error
+	AddWatchHistoryBatch(ctx context.Context, userID int64, contentIDs []int64) (int, error)
+	RemoveWatchHistory(ctx context.Context, userID, contentID int64) (bool, error)
+	BlockContent(ctx context.Context, userID, contentID int64) (bool, error)
+	UnblockContent(ctx context.Context, userID, contentID int64) (bool, error)
+	Reseed(ctx context.Context, cfg seeds.SeedConfig) (seeds.SeedSummary, error)
+}
+
+var _ Repo = (*repository.Repository)(nil)
+
+// RecommendationCache stores generated recommendations and idempotency outcomes
+type RecommendationCache interface {
+	Get(ctx context.Context, userID int64, limit int, variant string) (*domain.CachedRecommendations, bool, error)
+	Set(ctx context.Context, userID int64, limit int, variant string, entry domain.CachedRecommendations) error
+	GetLastGenerated(ctx context.Context, userID int64, variant string) (*domain.Generation, bool, error)
+	SetLastGenerated(ctx context.Context, userID int64, variant string, gen domain.Generation, ttl time.Duration) error
+	ClearUserCache(ctx context.Context, userID int64) (int, error)
+	ClearCatalogCache(ctx context.Context) (int, error)
+	MarkUserChanged(ctx context.Context, userID int64) error
+	MarkCatalogChanged(ctx context.Context) error
+	LastChanged(ctx context.Context, userIDs []int64) (time.Time, error)
+	ReserveIdempotency(ctx context.Context, userID int64, key, fingerprint string) (domain.IdempotencyRecord, bool, error)
+	StoreIdempotency(ctx context.Context, userID int64, key string, record domain.IdempotencyRecord) error
+	ReleaseIdempotency(ctx context.Context, userID int64, key string) error
+	GetStats(ctx context.Context) (*domain.Stats, bool, error)
+	SetStats(ctx context.Context, stats *domain.Stats) error
+	GetTrending(ctx context.Context, window time.Duration, limit int) ([]domain.TrendingContent, bool, error)
+	SetTrending(ctx context.Context, window time.Duration, limit int, items []domain.TrendingContent) error
+	RecordBatchFailure(ctx context.Context, userID int64, threshold int, window time.Duration) (bool, error)
+	GetFailedUsers(ctx context.Context) ([]domain.FailedUser, error)
+	ClearFailedUser(ctx context.Context, userID int64) (bool, error)
+}
+
+var _ RecommendationCache = (*cache.Cache)(nil)
+
 type Service struct {
-	repo *repository.Repository
-	cache *cache.Cache
-	modelClient *model.Client
+	repo Repo
+	cache RecommendationCache
+	modelClient model.Scorer
+	// Workers shared by batch, bulk and streamed batch requests
+	batchPool *workerPool
+	experiment *experiment.Split
+	seedDefaults seeds.SeedConfig
+	minRegenInterval time.Duration
+	ratingPolicy domain.RatingPolicy
+	minPopularity float64
+	maxUserID int64
+	userIDBound userIDBound
+	cacheMetrics *metrics.CacheMetrics
+	batchFailureThreshold int
+	batchFailureWindow time.Duration
+	scoringTimeout time.Duration
 }
 
-func NewService(repo *repository.Repository, cache *cache.Cache, modelClient *model.Client) *Service {
+func NewService(repo Repo, cache RecommendationCache, modelClient model.Scorer, cfg Config) *Service {
+	// A pool without workers would block every batch forever
+	batchConcurrency := cfg.BatchConcurrency
+	if batchConcurrency < 1 {
+		batchConcurrency = defaultBatchConcurrency
+	}
+	seedDefaults := cfg.SeedDefaults
+	if seedDefaults == (seeds.SeedConfig{}) {
+		seedDefaults = seeds.DefaultSeedConfig()
+	}
+	ratingPolicy := cfg.RatingPolicy
+	if ratingPolicy == nil {
+		ratingPolicy = domain.DefaultRatingPolicy()
+	}
+
 	return &Service{
 		repo: repo,
 		cache: cache,
 		modelClient: modelClient,
+		batchPool: newWorkerPool(batchConcurrency),
+		experiment: cfg.Experiment,
+		seedDefaults: seedDefaults,
+		minRegenInterval: cfg.MinRegenInterval,
+		ratingPolicy: ratingPolicy,
+		minPopularity: cfg.MinPopularity,
+		maxUserID: cfg.MaxUserID,
+		cacheMetrics: cfg.CacheMetrics,
+		batchFailureThreshold: cfg.BatchFailureThreshold,
+		batchFailureWindow: cfg.BatchFailureWindow,
+		scoringTimeout: cfg.ScoringTimeout,
 	}
 }
 
-func (s *Service) GetRecommendations(ctx context.Context, userID int64, limit int) (*domain.RecommendationResult, error) {
-	if limit <= 0 {
-		limit = defaultLimit
-	} else if limit > maxLimit {
-		limit = maxLimit
+// Apply default limit and strategy. The bucket is set when the strategy comes
+// from the user's experiment bucket.
+func (s *Service) resolveOptions(userID int64, opts domain.RecommendationOptions) (domain.RecommendationOptions, *int) {
+	if opts.Limit <= 0 {
+		opts.Limit = defaultLimit
+	} else if opts.Limit > MaxLimit {
+		opts.Limit = MaxLimit
+	}
+	// No explicit strategy: use the user's experiment bucket
+	var bucket *int
+	if opts.Strategy == "" {
+		b, strategy := s.experiment.Assign(userID)
+		bucket = &b
+		opts.Strategy = strategy
+	}
+	return opts, bucket
+}
+
+func (s *Service) GetRecommendations(ctx context.Context, userID int64, opts domain.RecommendationOptions) (*domain.RecommendationResult, error) {
+	opts, bucket := s.resolveOptions(userID, opts)
+	if opts.Weights != nil {
+		var err error
+		if opts, err = s.checkWeightOverrides(ctx, userID, opts); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Paged {
+		return s.getRecommendationsPage(ctx, userID, opts, bucket)
 	}
 	
 	// Check Cache
-	cached, found, err := s.cache.Get(ctx, userID, limit)
+	cached, found, err := s.getCached(ctx, userID, opts.Limit, opts.Variant())
 	if err != nil {
 		log.Printf("[service] cache get error for user %d: %v", userID, err)
 	}
@@ -53,127 +218,701 @@ func (s *Service) GetRecommendations(ctx context.Context, userID int64, limit in
 	// Use recommendations from cache if available
 	if found {
 		return &domain.RecommendationResult {
-			Recommendations: cached,
+			Recommendations: cached.Recommendations,
 			CacheHit: true,
+			Strategy: opts.Strategy,
+			ExperimentBucket: bucket,
+			ModelVersion: cached.ModelVersion,
+			ExhaustedCatalog: cached.ExhaustedCatalog,
 		}, nil
 	}
 	
+	// Within the regenerate interval a recent generation serves any limit
+	genOpts := opts
+	if s.minRegenInterval > 0 {
+		if gen := s.recentGeneration(ctx, userID, opts); gen != nil {
+			return &domain.RecommendationResult{
+				Recommendations: truncate(gen.Recommendations, opts.Limit),
+				CacheHit: true,
+				Strategy: opts.Strategy,
+				ExperimentBucket: bucket,
+				ModelVersion: gen.ModelVersion,
+				ExhaustedCatalog: gen.ExhaustedCatalog,
+			}, nil
+		}
+		// Generate the longest list so later requests can take any prefix
+		genOpts.Limit = MaxLimit
+	}
+
 	// Cache miss -> generate recommendations
-	recs, err := s.generateRecommendations(ctx, userID, limit)
+	gen, err := s.generateRecommendations(ctx, userID, genOpts)
 	if err != nil {
 		return nil, err
 	}
+	recs := gen.recs
+	if s.minRegenInterval > 0 {
+		if gen.cacheable() {
+			s.storeGeneration(ctx, userID, opts, gen)
+		}
+		recs = truncate(recs, opts.Limit)
+	}
 	
-	// Store recommendations in cache
-	if cacheErr := s.cache.Set(ctx, userID, limit, recs); cacheErr != nil {
-		log.Printf("[service] cache set error for user %d: %v", userID, cacheErr)
+	// Store recommendations in cache. Degraded and truncated results are not
+	// cached so the next request retries full scoring
+	strategy := opts.Strategy
+	if gen.degraded {
+		strategy = domain.StrategyPopularityOnly
+	}
+	if gen.cacheable() {
+		if cacheErr := s.cache.Set(ctx, userID, opts.Limit, opts.Variant(), cacheEntry(recs, gen.exhausted)); cacheErr != nil {
+			log.Printf("[service] cache set error for user %d: %v", userID, cacheErr)
+		}
 	}
 	
 	return &domain.RecommendationResult{
 		Recommendations: recs,
 		CacheHit: false,
+		Strategy: strategy,
+		ExperimentBucket: bucket,
+		Degraded: gen.degraded,
+		Truncated: gen.truncated,
+		ModelVersion: model.ModelVersion,
+		ExhaustedCatalog: gen.exhausted,
 	}, nil
 }
 
-func (s *Service) generateRecommendations(ctx context.Context, userID int64, limit int) ([]domain.ScoredRecommendation, error) {
-	user, err := s.repo.GetUserByID(ctx, userID)
+// Stop the shared batch workers once queued work finishes. Batches started
+// after Close still complete, one user at a time on the caller's goroutine.
+func (s *Service) Close() {
+	s.batchPool.close()
+}
+
+// Look up cached recommendations, counting the lookup as a hit, a miss or an
+// error. Callers that fall back to scoring on an error still see it here.
+func (s *Service) getCached(ctx context.Context, userID int64, limit int, variant string) (*domain.CachedRecommendations, bool, error) {
+	cached, found, err := s.cache.Get(ctx, userID, limit, variant)
+	s.cacheMetrics.ObserveLookup(found, err)
+	return cached, found, err
+}
+
+// Drop weight overrides unless the user is premium, before the cache lookup so
+// ignored overrides share the default cache entry
+func (s *Service) checkWeightOverrides(ctx context.Context, userID int64, opts domain.RecommendationOptions) (domain.RecommendationOptions, error) {
+	user, err := s.getUser(ctx, userID)
 	if err != nil {
 		if errors.Is(err, domain.ErrUserNotFound) {
-			return nil, err
+			return opts, err
+		}
+		return opts, fmt.Errorf("fetch user: %w", err)
+	}
+	if user.SubscriptionType != premiumSubscription {
+		opts.Weights = nil
+	}
+	return opts, nil
+}
+
+// A page of the user's full scored candidate list. The full list is cached
+// like any other limit, so later pages are sliced from the same scoring run
+// rather than rescored.
+func (s *Service) getRecommendationsPage(ctx context.Context, userID int64, opts domain.RecommendationOptions, bucket *int) (*domain.RecommendationResult, error) {
+	variant := opts.Variant()
+	cached, found, err := s.getCached(ctx, userID, fullListLimit, variant)
+	if err != nil {
+		log.Printf("[service] cache get error for user %d: %v", userID, err)
+	}
+	if found {
+		return &domain.RecommendationResult{
+			Recommendations:  page(cached.Recommendations, opts.Offset, opts.Limit),
+			CacheHit:         true,
+			Strategy:         opts.Strategy,
+			ExperimentBucket: bucket,
+			ModelVersion:     cached.ModelVersion,
+			ExhaustedCatalog: cached.ExhaustedCatalog,
+			Total:            len(cached.Recommendations),
+		}, nil
+	}
+
+	full := opts
+	full.Limit = fullListLimit
+	gen, err := s.generateRecommendations(ctx, userID, full)
+	if err != nil {
+		return nil, err
+	}
+	strategy := opts.Strategy
+	if gen.degraded {
+		strategy = domain.StrategyPopularityOnly
+	}
+	if gen.cacheable() {
+		if cacheErr := s.cache.Set(ctx, userID, fullListLimit, variant, cacheEntry(gen.recs, gen.exhausted)); cacheErr != nil {
+			log.Printf("[service] cache set error for user %d: %v", userID, cacheErr)
 		}
-		return nil, fmt.Errorf("fetch user: %w", err)
 	}
 
+	return &domain.RecommendationResult{
+		Recommendations:  page(gen.recs, opts.Offset, opts.Limit),
+		Strategy:         strategy,
+		ExperimentBucket: bucket,
+		Degraded:         gen.degraded,
+		Truncated:        gen.truncated,
+		ModelVersion:     model.ModelVersion,
+		ExhaustedCatalog: gen.exhausted,
+		Total:            len(gen.recs),
+	}, nil
+}
+
+// Cached recommendations for the user's options, without generating on a miss.
+// Options resolve as in GetRecommendations, so the same variant is looked up.
+func (s *Service) GetCachedRecommendations(ctx context.Context, userID int64, opts domain.RecommendationOptions) (*domain.RecommendationResult, bool, error) {
+	opts, bucket := s.resolveOptions(userID, opts)
+	cached, found, err := s.getCached(ctx, userID, opts.Limit, opts.Variant())
+	if err != nil {
+		return nil, false, fmt.Errorf("get cached recommendations for user %d: %w", userID, err)
+	}
+	if !found {
+		return nil, false, nil
+	}
+	return &domain.RecommendationResult{
+		Recommendations:  cached.Recommendations,
+		CacheHit:         true,
+		Strategy:         opts.Strategy,
+		ExperimentBucket: bucket,
+		ModelVersion:     cached.ModelVersion,
+		ExhaustedCatalog: cached.ExhaustedCatalog,
+	}, true, nil
+}
+
+// Last generation for the user's options if it is younger than the regenerate
+// interval. Cache errors are logged and treated as no generation.
+func (s *Service) recentGeneration(ctx context.Context, userID int64, opts domain.RecommendationOptions) *domain.Generation {
+	gen, found, err := s.cache.GetLastGenerated(ctx, userID, opts.Variant())
+	if err != nil {
+		log.Printf("[service] last generation get error for user %d: %v", userID, err)
+		return nil
+	}
+	if !found || time.Since(gen.GeneratedAt) >= s.minRegenInterval {
+		return nil
+	}
+	return gen
+}
+
+func (s *Service) storeGeneration(ctx context.Context, userID int64, opts domain.RecommendationOptions, generated generated) {
+	gen := domain.Generation{
+		GeneratedAt:      time.Now(),
+		ModelVersion:     model.ModelVersion,
+		Recommendations:  generated.recs,
+		ExhaustedCatalog: generated.exhausted,
+	}
+	if err := s.cache.SetLastGenerated(ctx, userID, opts.Variant(), gen, s.minRegenInterval); err != nil {
+		log.Printf("[service] last generation set error for user %d: %v", userID, err)
+	}
+}
+
+// Up to limit items from offset; empty past the end
+func page(recs []domain.ScoredRecommendation, offset, limit int) []domain.ScoredRecommendation {
+	if offset >= len(recs) {
+		return []domain.ScoredRecommendation{}
+	}
+	return truncate(recs[offset:], limit)
+}
+
+func truncate(recs []domain.ScoredRecommendation, limit int) []domain.ScoredRecommendation {
+	if len(recs) > limit {
+		return recs[:limit]
+	}
+	return recs
+}
+
+// Cache entry for freshly generated recommendations
+func cacheEntry(recs []domain.ScoredRecommendation, exhausted bool) domain.CachedRecommendations {
+	return domain.CachedRecommendations{
+		ModelVersion:     model.ModelVersion,
+		Recommendations:  recs,
+		ExhaustedCatalog: exhausted,
+	}
+}
+
+// Freshly generated recommendations for a user
+type generated struct {
+	recs []domain.ScoredRecommendation
+	// Watch history failed to load, so scoring was popularity only
+	degraded bool
+	// The user exists but there were no candidates to score
+	exhausted bool
+	// Scoring hit its deadline, so only some candidates were ranked
+	truncated bool
+	// Watch history the user was scored with
+	history []domain.WatchHistoryItem
+}
+
+// Only complete results are cached; the rest are retried on the next request
+func (g generated) cacheable() bool {
+	return !g.degraded && !g.truncated
+}
+
+// Generate recommendations for a user. When the watch history can't be
+// fetched it falls back to popularity-only scoring and reports degraded.
+func (s *Service) generateRecommendations(ctx context.Context, userID int64, opts domain.RecommendationOptions) (generated, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return generated{}, err
+		}
+		return generated{}, fmt.Errorf("fetch user: %w", err)
+	}
+
+	// Weight overrides are a premium perk
+	if user.SubscriptionType != premiumSubscription {
+		opts.Weights = nil
+	}
+
+	degraded := false
 	watchHistory, err := s.repo.GetUserWatchHistoryWithGenres(ctx, userID, watchHistoryLimit)
 	if err != nil {
-		return nil, fmt.Errorf("fetch watch history: %w", err)
+		log.Printf("[service] watch history error for user %d, using popularity only: %v", userID, err)
+		degraded = true
+		watchHistory = nil
+		opts.Strategy = domain.StrategyPopularityOnly
 	}
 
-	candidates, err := s.repo.GetUnwatchedContent(ctx, userID, candidatePoolSize)
+	// Only content rated for the user's age and above the popularity floor is
+	// a candidate
+	ratings := s.ratingPolicy.AllowedRatings(user.Age)
+	minPopularity := s.minPopularity
+	if opts.IgnorePopularityFloor {
+		minPopularity = 0
+	}
+	var candidates []domain.Content
+	var watched map[int64]bool
+	poolSize := candidatePoolFor(user.SubscriptionType, len(watchHistory))
+	if opts.IncludeWatched {
+		candidates, watched, err = s.repo.GetAllContent(ctx, userID, poolSize, opts.CandidateOrder, ratings, minPopularity)
+	} else {
+		candidates, err = s.repo.GetUnwatchedContent(ctx, userID, poolSize, opts.CandidateOrder, ratings, minPopularity)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("fetch candidates: %w", err)
+		return generated{}, fmt.Errorf("fetch candidates: %w", err)
 	}
 
-	scored, err := s.modelClient.Score(model.ScoreInput{
-		User:         user,
-		WatchHistory: watchHistory,
-		Candidates:   candidates,
-		Limit:        limit,
+	// Regional popularity is optional: fall back to global popularity on error
+	regional, err := s.repo.GetRegionalPopularity(ctx, user.Country, contentIDs(candidates))
+	if err != nil {
+		log.Printf("[service] regional popularity error for user %d: %v", userID, err)
+	}
+
+	// A genre cap skips over-represented genres while filling to the limit,
+	// so every candidate is scored and ranked before capping
+	scoreLimit := opts.Limit
+	if opts.MaxPerGenre > 0 {
+		scoreLimit = len(candidates)
+	}
+	scoreCtx := ctx
+	if s.scoringTimeout > 0 {
+		var cancel context.CancelFunc
+		scoreCtx, cancel = context.WithTimeout(ctx, s.scoringTimeout)
+		defer cancel()
+	}
+	scored, truncated, err := s.modelClient.Score(scoreCtx, model.ScoreInput{
+		User:               user,
+		WatchHistory:       watchHistory,
+		Candidates:         candidates,
+		RegionalPopularity: regional,
+		Limit:              scoreLimit,
+		Normalize:          opts.Normalize,
+		Strategy:           opts.Strategy,
+		GenreBoosts:        opts.GenreBoosts,
+		Weights:            opts.Weights,
+		WithReasons:        opts.WithReasons,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("score recommendations for user %d: %w", userID, domain.ErrModelUnavailable)
+		return generated{}, fmt.Errorf("score recommendations for user %d: %w: %w", userID, domain.ErrModelUnavailable, err)
+	}
+
+	for i := range scored {
+		scored[i].Watched = watched[scored[i].ContentID]
+	}
+
+	// Featured content is optional: serve the scored list alone on error
+	featured, err := s.repo.GetFeaturedContent(ctx, userID, opts.Limit, ratings)
+	if err != nil {
+		log.Printf("[service] featured content error for user %d: %v", userID, err)
+	}
+
+	// The genre cap applies to the merged list, so featured items count towards
+	// it. Pinned first, they take their genres' slots ahead of scored items
+	var recs []domain.ScoredRecommendation
+	if opts.MaxPerGenre > 0 {
+		recs = capPerGenre(withFeatured(featured, scored, len(featured)+len(scored)), opts.MaxPerGenre, opts.Limit)
+	} else {
+		recs = withFeatured(featured, scored, opts.Limit)
+	}
+
+	return generated{
+		recs:      recs,
+		degraded:  degraded,
+		exhausted: len(candidates) == 0,
+		truncated: truncated,
+		history:   watchHistory,
+	}, nil
+}
+
+// The user's top perGenre scored recommendations in each genre. Every
+// candidate is scored, then grouped by genre; genres are ordered by the user's
+// preference weight, unwatched genres last by name. Featured items are not
+// grouped. Results are not cached.
+func (s *Service) GetRecommendationsByGenre(ctx context.Context, userID int64, perGenre int) (*domain.GenreRecommendationResult, error) {
+	opts, bucket := s.resolveOptions(userID, domain.RecommendationOptions{})
+	opts.Limit = fullListLimit
+	gen, err := s.generateRecommendations(ctx, userID, opts)
+	if err != nil {
+		return nil, err
+	}
+	strategy := opts.Strategy
+	if gen.degraded {
+		strategy = domain.StrategyPopularityOnly
+	}
+
+	return &domain.GenreRecommendationResult{
+		Genres:           groupByGenre(gen.recs, model.GenrePreferenceWeights(gen.history), perGenre),
+		Strategy:         strategy,
+		ExperimentBucket: bucket,
+		Degraded:         gen.degraded,
+		Truncated:        gen.truncated,
+		ModelVersion:     model.ModelVersion,
+		ExhaustedCatalog: gen.exhausted,
+	}, nil
+}
+
+// Up to perGenre of the ranked recommendations per genre, keeping order, with
+// genres ordered by preference weight and then name
+func groupByGenre(recs []domain.ScoredRecommendation, prefs map[string]float64, perGenre int) []domain.GenreRecommendations {
+	byGenre := make(map[string][]domain.ScoredRecommendation)
+	for _, rec := range recs {
+		if rec.Featured || len(byGenre[rec.Genre]) == perGenre {
+			continue
+		}
+		byGenre[rec.Genre] = append(byGenre[rec.Genre], rec)
+	}
+
+	weights := make(map[string]float64, len(byGenre))
+	for genre := range byGenre {
+		weights[genre] = prefs[genre]
+	}
+	ordered := topGenres(weights, len(weights))
+	groups := make([]domain.GenreRecommendations, len(ordered))
+	for i, g := range ordered {
+		groups[i] = domain.GenreRecommendations{
+			Genre:           g.Genre,
+			Weight:          g.Weight,
+			Recommendations: byGenre[g.Genre],
+		}
+	}
+	return groups
+}
+
+// Subscription allowed to override score weights
+const premiumSubscription = "premium"
+
+// Starting candidate pool per subscription tier, larger pools being a perk of
+// the paid tiers. Unknown tiers start at candidatePoolSize.
+var candidatePoolBySubscription = map[string]int{
+	"free":    50,
+	"basic":   candidatePoolSize,
+	"premium": 200,
+}
+
+// Candidates to score for a user on subscription with historyLen watch events:
+// the tier's pool plus 5 per watch, capped at 300. A user without history
+// gets just the tier's pool, and the pool widens as the history grows.
+func candidatePoolFor(subscription string, historyLen int) int {
+	base, ok := candidatePoolBySubscription[subscription]
+	if !ok {
+		base = candidatePoolSize
+	}
+	return min(base+candidatePoolPerWatch*historyLen, maxCandidatePoolSize)
+}
+
+// Up to limit of the ranked recommendations, keeping order and skipping items
+// of genres that already have maxPerGenre in the list
+func capPerGenre(recs []domain.ScoredRecommendation, maxPerGenre, limit int) []domain.ScoredRecommendation {
+	capped := make([]domain.ScoredRecommendation, 0, min(limit, len(recs)))
+	counts := make(map[string]int)
+	for _, rec := range recs {
+		if len(capped) == limit {
+			break
+		}
+		if counts[rec.Genre] < maxPerGenre {
+			counts[rec.Genre]++
+			capped = append(capped, rec)
+		}
+	}
+	return capped
+}
+
+// Prepend featured items to the scored list, dropping their duplicates from
+// the scored tail and truncating to limit
+func withFeatured(featured []domain.Content, scored []domain.ScoredRecommendation, limit int) []domain.ScoredRecommendation {
+	if len(featured) == 0 {
+		return scored
+	}
+
+	recs := make([]domain.ScoredRecommendation, 0, limit)
+	pinned := make(map[int64]bool, len(featured))
+	for _, c := range featured {
+		if len(recs) == limit {
+			break
+		}
+		pinned[c.ID] = true
+		recs = append(recs, domain.ScoredRecommendation{
+			ContentID:       c.ID,
+			Title:           c.Title,
+			Genre:           c.Genre,
+			PopularityScore: c.PopularityScore,
+			DurationMinutes: c.DurationMinutes,
+			ReleaseYear:     c.ReleaseYear,
+			Featured:        true,
+		})
+	}
+	for _, rec := range scored {
+		if len(recs) == limit {
+			break
+		}
+		if !pinned[rec.ContentID] {
+			recs = append(recs, rec)
+		}
+	}
+	return recs
+}
+
+func contentIDs(items []domain.Content) []int64 {
+	ids := make([]int64, 0, len(items))
+	for _, c := range items {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+// Score a page of limit users with perUserLimit recommendations each, with
+// each user's top genres when withExplanations is set. A non-empty status keeps
+// only matching results; the summary always counts the full page.
+func (s *Service) GetBatchRecommendations(ctx context.Context, page, limit, perUserLimit int, status domain.BatchStatus, withExplanations bool) (*domain.BatchResponse, error) {
+	start := time.Now()
+	userIDs, totalUsers, err := s.batchUsers(ctx, page, limit)
+	if err != nil {
+		return nil, err
 	}
 
-	return scored, nil
+	results := s.scoreBatch(ctx, userIDs, perUserLimit, withExplanations, nil)
+	return batchResponse(page, limit, perUserLimit, totalUsers, results, status, start), nil
 }
 
-func (s *Service) GetBatchRecommendations(ctx context.Context, page, limit int) (*domain.BatchResponse, error) {
+// Score a batch page like GetBatchRecommendations, sending progress on the
+// returned channel each time a worker finishes. The channel is closed once the
+// page is processed, and the full response then arrives on the second channel.
+// Neither blocks the workers, so a consumer may stop reading at any point;
+// cancelling ctx marks the users not yet scored as cancelled.
+func (s *Service) GetBatchRecommendationsWithProgress(ctx context.Context, page, limit, perUserLimit int, withExplanations bool) (<-chan domain.BatchProgress, <-chan *domain.BatchResponse, error) {
 	start := time.Now()
+	userIDs, totalUsers, err := s.batchUsers(ctx, page, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Buffered for every update, so sends never wait on the consumer
+	progress := make(chan domain.BatchProgress, len(userIDs))
+	done := make(chan *domain.BatchResponse, 1)
+	go func() {
+		defer close(done)
+		results := s.scoreBatch(ctx, userIDs, perUserLimit, withExplanations, progress)
+		close(progress)
+		done <- batchResponse(page, limit, perUserLimit, totalUsers, results, "", start)
+	}()
+	return progress, done, nil
+}
 
-	// Fetch paginated user IDs
+// User IDs on a batch page and the total user count
+func (s *Service) batchUsers(ctx context.Context, page, limit int) ([]int64, int, error) {
 	userIDs, err := s.repo.GetUserIDsPaginated(ctx, page, limit)
 	if err != nil {
-		return nil, fmt.Errorf("fetch user ids: %w", err)
+		return nil, 0, fmt.Errorf("fetch user ids: %w", err)
 	}
-	
-	// Fetch total user
 	totalUsers, err := s.repo.CountUsers(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("count user: %w", err)
+		return nil, 0, fmt.Errorf("count user: %w", err)
 	}
+	return userIDs, totalUsers, nil
+}
 
-	// Process users concurrently with bounded worker pool
+// Process users concurrently with the bounded worker pool, results in userIDs
+// order. A non-nil progress channel gets the running count after each user,
+// in increasing order; it must have room for one update per user.
+func (s *Service) scoreBatch(ctx context.Context, userIDs []int64, perUserLimit int, withExplanations bool, progress chan<- domain.BatchProgress) []domain.BatchUserResult {
 	results := make([]domain.BatchUserResult, len(userIDs))
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, batchConcurrency) // semaphore
+	var mu sync.Mutex
+	processed := 0
+	s.batchPool.run(len(userIDs), func(idx int) {
+		// Workers still queued when the client disconnects skip their user
+		if ctx.Err() != nil {
+			results[idx] = domain.BatchUserResult{UserID: userIDs[idx], Status: domain.StatusCancelled}
+		} else {
+			results[idx] = s.processUserForBatch(ctx, userIDs[idx], perUserLimit, withExplanations)
+		}
+		if progress != nil {
+			// Counted and sent together so updates arrive in order
+			mu.Lock()
+			processed++
+			progress <- domain.BatchProgress{Processed: processed, Total: len(userIDs)}
+			mu.Unlock()
+		}
+	})
+	return results
+}
 
-	for i, userID := range userIDs {
-		wg.Add(1)
-		go func(idx int, uid int64) {
-			defer wg.Done()
-			sem <- struct{}{}        // acquire
-			defer func() { <-sem }() // release
+// Batch response for a processed page that started at start. A non-empty
+// status keeps only matching results; the summary always counts the full page.
+func batchResponse(page, limit, perUserLimit, totalUsers int, results []domain.BatchUserResult, status domain.BatchStatus, start time.Time) *domain.BatchResponse {
+	summary := summarizeBatch(results, start)
+	if status != "" {
+		results = filterByStatus(results, status)
+	}
 
-			result := s.processUserForBatch(ctx, uid)
-			results[idx] = result
-		}(i, userID)
+	return &domain.BatchResponse{
+		Page:         page,
+		Limit:        limit,
+		PerUserLimit: perUserLimit,
+		TotalUsers:   totalUsers,
+		Results:      results,
+		Summary:      summary,
+		Metadata: domain.BatchMeta{
+			GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		},
 	}
-	wg.Wait()
+}
 
-	// summary
-	successCount := 0
-	failedCount := 0
-	for _, r := range results {
-		if r.Status == domain.StatusSuccess {
-			successCount++
-		} else {
-			failedCount++
+// When anything behind a batch page last changed: the latest watch event,
+// history or blocklist change of its users, or catalog change. Clients can
+// skip recomputing a page that hasn't changed since. Zero when the page is
+// empty.
+func (s *Service) BatchLastModified(ctx context.Context, page, limit int) (time.Time, error) {
+	userIDs, err := s.repo.GetUserIDsPaginated(ctx, page, limit)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fetch user ids: %w", err)
+	}
+	if len(userIDs) == 0 {
+		return time.Time{}, nil
+	}
+	watched, err := s.repo.MaxWatchedAtForUsers(ctx, userIDs)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fetch latest watch event: %w", err)
+	}
+	changed, err := s.cache.LastChanged(ctx, userIDs)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fetch latest change: %w", err)
+	}
+	if watched.After(changed) {
+		return watched, nil
+	}
+	return changed, nil
+}
+
+// Score an explicit list of users, limit recommendations each, with the batch
+// worker pool. Duplicate IDs are scored once; results keep the order of first
+// appearance. Per-user failures are reported in the results, never as an error.
+func (s *Service) GetBulkRecommendations(ctx context.Context, userIDs []int64, limit int) *domain.BulkRecommendationResponse {
+	start := time.Now()
+
+	unique := make([]int64, 0, len(userIDs))
+	seen := make(map[int64]bool, len(userIDs))
+	for _, id := range userIDs {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
 		}
 	}
 
-	elapsed := time.Since(start).Milliseconds()
+	results := make([]domain.BatchUserResult, len(unique))
+	s.batchPool.run(len(unique), func(idx int) {
+		results[idx] = s.processUserForBatch(ctx, unique[idx], limit, false)
+	})
 
-	return &domain.BatchResponse{
-		Page:       page,
-		Limit:      limit,
-		TotalUsers: totalUsers,
-		Results:    results,
-		Summary: domain.BatchSummary{
-			SuccessCount:     successCount,
-			FailedCount:      failedCount,
-			ProcessingTimeMs: elapsed,
-		},
+	return &domain.BulkRecommendationResponse{
+		Limit:   limit,
+		Results: results,
+		Summary: summarizeBatch(results, start),
 		Metadata: domain.BatchMeta{
 			GeneratedAt: time.Now().UTC().Format(time.RFC3339),
 		},
-	}, nil
+	}
 }
 
-// Generates recommendations for a singl user, capturing errors.
-func (s *Service) processUserForBatch(ctx context.Context, userID int64) domain.BatchUserResult {
-	result, err := s.GetRecommendations(ctx, userID, batchRecLimit)
+// Count outcomes for a processed batch that started at start
+func summarizeBatch(results []domain.BatchUserResult, start time.Time) domain.BatchSummary {
+	var summary domain.BatchSummary
+	for _, r := range results {
+		switch r.Status {
+		case domain.StatusSuccess:
+			summary.SuccessCount++
+		case domain.StatusCancelled:
+			summary.CancelledCount++
+		default:
+			summary.FailedCount++
+		}
+	}
+	summary.ProcessingTimeMs = time.Since(start).Milliseconds()
+	return summary
+}
+
+func filterByStatus(results []domain.BatchUserResult, status domain.BatchStatus) []domain.BatchUserResult {
+	filtered := make([]domain.BatchUserResult, 0, len(results))
+	for _, r := range results {
+		if r.Status == status {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// Stream per-user batch results as each worker finishes. The channel is closed
+// once the page is processed; cancelling ctx stops remaining sends.
+func (s *Service) StreamBatchRecommendations(ctx context.Context, page, limit, perUserLimit int, withExplanations bool) (<-chan domain.BatchUserResult, error) {
+	userIDs, err := s.repo.GetUserIDsPaginated(ctx, page, limit)
+	if err != nil {
+		return nil, fmt.Errorf("fetch user ids: %w", err)
+	}
+
+	// Buffered for the whole page so a slow reader never holds shared workers
+	out := make(chan domain.BatchUserResult, len(userIDs))
+	go func() {
+		defer close(out)
+		s.batchPool.run(len(userIDs), func(idx int) {
+			result := s.processUserForBatch(ctx, userIDs[idx], perUserLimit, withExplanations)
+			select {
+			case out <- result:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	return out, nil
+}
+
+// Generates recommendations for a singl user, capturing errors. With
+// explanations, a successful result also carries the user's top genres. Once
+// ctx is done the user is marked cancelled without doing any work.
+func (s *Service) processUserForBatch(ctx context.Context, userID int64, limit int, withExplanations bool) domain.BatchUserResult {
+	cancelled := domain.BatchUserResult{UserID: userID, Status: domain.StatusCancelled}
+	if ctx.Err() != nil {
+		return cancelled
+	}
+	result, err := s.GetRecommendations(ctx, userID, domain.RecommendationOptions{Limit: limit})
+	// Interrupted by the client going away rather than failing on its own
+	if err != nil && errors.Is(err, context.Canceled) && ctx.Err() != nil {
+		return cancelled
+	}
 	if err != nil {
 		log.Printf("[service] batch: failed for user %d: %v", userID, err)
+		if userSpecificFailure(err) {
+			s.recordBatchFailure(ctx, userID)
+		}
 		code, msg := categorizeError(err)
 		return domain.BatchUserResult{
 			UserID:  userID,
@@ -183,11 +922,278 @@ func (s *Service) processUserForBatch(ctx context.Context, userID int64) domain.
 		}
 	}
 
-	return domain.BatchUserResult{
+	batchResult := domain.BatchUserResult{
 		UserID:          userID,
 		Recommendations: result.Recommendations,
 		Status:          domain.StatusSuccess,
 	}
+	if withExplanations {
+		// Explanations are optional: the recommendations are served without them on error
+		history, err := s.repo.GetUserWatchHistoryWithGenres(ctx, userID, watchHistoryLimit)
+		if err != nil {
+			log.Printf("[service] batch: watch history error for user %d, skipping explanation: %v", userID, err)
+		} else {
+			batchResult.TopGenres = topGenres(model.GenrePreferenceWeights(history), batchTopGenres)
+		}
+	}
+	return batchResult
+}
+
+// Count a batch failure towards flagging the user. Tracking errors are logged
+// and never fail the batch.
+func (s *Service) recordBatchFailure(ctx context.Context, userID int64) {
+	if s.batchFailureThreshold <= 0 {
+		return
+	}
+	flagged, err := s.cache.RecordBatchFailure(ctx, userID, s.batchFailureThreshold, s.batchFailureWindow)
+	if err != nil {
+		log.Printf("[service] batch: failure tracking error for user %d: %v", userID, err)
+		return
+	}
+	if flagged {
+		log.Printf("[service] batch: user %d failed more than %d times within %s, flagged as failed", userID, s.batchFailureThreshold, s.batchFailureWindow)
+	}
+}
+
+// Whether a batch failure may be down to the user's own data. Outages and
+// timeouts fail every user alike, so counting them would flag healthy users.
+func userSpecificFailure(err error) bool {
+	return !errors.Is(err, domain.ErrModelUnavailable) &&
+		!errors.Is(err, domain.ErrDatabaseUnavailable) &&
+		!errors.Is(err, context.DeadlineExceeded) &&
+		!errors.Is(err, context.Canceled)
+}
+
+// Users flagged for repeatedly failing batch processing, most recent first
+func (s *Service) GetFailedUsers(ctx context.Context) ([]domain.FailedUser, error) {
+	users, err := s.cache.GetFailedUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get failed users: %w", err)
+	}
+	return users, nil
+}
+
+// Stop listing a user as failing and restart its failure count
+func (s *Service) ClearFailedUser(ctx context.Context, userID int64) error {
+	removed, err := s.cache.ClearFailedUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("clear failed user %d: %w", userID, err)
+	}
+	if !removed {
+		return domain.ErrFailedUserNotFound
+	}
+	return nil
+}
+
+// The n heaviest genre preferences, ties broken by genre name
+func topGenres(prefs map[string]float64, n int) []domain.GenreWeight {
+	genres := make([]domain.GenreWeight, 0, len(prefs))
+	for genre, weight := range prefs {
+		genres = append(genres, domain.GenreWeight{Genre: genre, Weight: math.Round(weight*1000) / 1000})
+	}
+	sort.Slice(genres, func(i, j int) bool {
+		if genres[i].Weight != genres[j].Weight {
+			return genres[i].Weight > genres[j].Weight
+		}
+		return genres[i].Genre < genres[j].Genre
+	})
+	if len(genres) > n {
+		genres = genres[:n]
+	}
+	return genres
+}
+
+// Look up a user, failing fast with ErrUserNotFound for IDs past every user
+func (s *Service) getUser(ctx context.Context, userID int64) (*domain.User, error) {
+	if s.userIDOutOfRange(ctx, userID) {
+		return nil, domain.ErrUserNotFound
+	}
+	return s.repo.GetUserByID(ctx, userID)
+}
+
+// Score breakdown for a single user/content pair, used by the debug endpoint
+func (s *Service) GetScoreBreakdown(ctx context.Context, userID, contentID int64) (*domain.ScoreBreakdown, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := s.repo.GetContentByID(ctx, contentID)
+	if err != nil {
+		return nil, err
+	}
+
+	watchHistory, err := s.repo.GetUserWatchHistoryWithGenres(ctx, userID, watchHistoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("fetch watch history: %w", err)
+	}
+
+	regional, err := s.repo.GetRegionalPopularity(ctx, user.Country, []int64{contentID})
+	if err != nil {
+		log.Printf("[service] regional popularity error for user %d: %v", userID, err)
+	}
+
+	explainer, ok := model.AsExplainer(s.modelClient)
+	if !ok {
+		return nil, errors.New("model does not support score breakdowns")
+	}
+	breakdown := explainer.Breakdown(*content, watchHistory, regional)
+	return &breakdown, nil
+}
+
+// Search content by title
+func (s *Service) SearchContent(ctx context.Context, query string, limit int) ([]domain.Content, error) {
+	items, err := s.repo.SearchContentByTitle(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("search content: %w", err)
+	}
+	return items, nil
+}
+
+// List a page of the catalog matching the filter, with the total match count
+func (s *Service) ListContent(ctx context.Context, filter domain.ContentFilter) ([]domain.Content, int, error) {
+	items, total, err := s.repo.ListContent(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list content: %w", err)
+	}
+	return items, total, nil
+}
+
+// Find a page of users matching the filter
+func (s *Service) FindUsers(ctx context.Context, filter domain.UserFilter) ([]domain.User, error) {
+	users, err := s.repo.FindUsers(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("find users: %w", err)
+	}
+	return users, nil
+}
+
+// Add a user; the handler validates age, country and subscription type
+func (s *Service) CreateUser(ctx context.Context, age int, country, subscriptionType string) (*domain.User, error) {
+	u := domain.User{
+		Age:              age,
+		Country:          country,
+		SubscriptionType: subscriptionType,
+		CreatedAt:        time.Now().UTC(),
+	}
+	id, err := s.repo.InsertUser(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("create user: %w", err)
+	}
+	u.ID = id
+	s.extendUserIDBound(id)
+	return &u, nil
+}
+
+// Content with the most watches within window, served briefly from cache
+func (s *Service) GetTrendingContent(ctx context.Context, window time.Duration, limit int) ([]domain.TrendingContent, error) {
+	cached, found, err := s.cache.GetTrending(ctx, window, limit)
+	if err != nil {
+		log.Printf("[service] trending cache get error: %v", err)
+	}
+	if found {
+		return cached, nil
+	}
+
+	items, err := s.repo.GetTrendingContent(ctx, time.Now().Add(-window), limit)
+	if err != nil {
+		return nil, fmt.Errorf("get trending content: %w", err)
+	}
+	if err := s.cache.SetTrending(ctx, window, limit, items); err != nil {
+		log.Printf("[service] trending cache set error: %v", err)
+	}
+	return items, nil
+}
+
+// Add a content item to the catalog. Cached recommendations are left to expire
+// with their TTL: new content competes for every user, so invalidating would mean
+// dropping the whole recommendation cache on each insert. Genres outside
+// domain.Genres are rejected with ErrInvalidGenre.
+func (s *Service) CreateContent(ctx context.Context, title, genre string, popularity float64) (*domain.Content, error) {
+	if !domain.IsValidGenre(genre) {
+		return nil, fmt.Errorf("create content %q: %w %q", title, domain.ErrInvalidGenre, genre)
+	}
+	c := domain.Content{
+		Title:           title,
+		Genre:           genre,
+		PopularityScore: popularity,
+		CreatedAt:       time.Now().UTC(),
+		ContentRating:   domain.DefaultContentRating,
+	}
+	id, err := s.repo.InsertContent(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("create content: %w", err)
+	}
+	c.ID = id
+	// Cached lists just miss the new item until they expire, but batch pages
+	// must no longer validate as unchanged
+	if err := s.cache.MarkCatalogChanged(ctx); err != nil {
+		log.Printf("[service] catalog change stamp error after creating content %d: %v", id, err)
+	}
+	return &c, nil
+}
+
+// Pull a content item from every candidate, featured, search and listing
+// query. The row is kept for audit. Any user's cached list may hold the item,
+// so every cached recommendation and trending list is dropped.
+func (s *Service) DeleteContent(ctx context.Context, contentID int64) error {
+	deleted, err := s.repo.SoftDeleteContent(ctx, contentID)
+	if err != nil {
+		return fmt.Errorf("delete content %d: %w", contentID, err)
+	}
+	if !deleted {
+		return domain.ErrContentNotFound
+	}
+	s.catalogChanged(ctx)
+	return nil
+}
+
+// Drop a user's cached lists after a history or blocklist write and stamp the
+// change for batch Last-Modified
+func (s *Service) userChanged(ctx context.Context, userID int64) {
+	if _, err := s.cache.ClearUserCache(ctx, userID); err != nil {
+		log.Printf("[service] cache invalidation error for user %d: %v", userID, err)
+	}
+	if err := s.cache.MarkUserChanged(ctx, userID); err != nil {
+		log.Printf("[service] change stamp error for user %d: %v", userID, err)
+	}
+}
+
+// Drop every cached list after content is removed or the catalog replaced,
+// and stamp the change for batch Last-Modified
+func (s *Service) catalogChanged(ctx context.Context) {
+	if _, err := s.cache.ClearCatalogCache(ctx); err != nil {
+		log.Printf("[service] cache invalidation error after catalog change: %v", err)
+	}
+	if err := s.cache.MarkCatalogChanged(ctx); err != nil {
+		log.Printf("[service] catalog change stamp error: %v", err)
+	}
+}
+
+// Most recent watch history for a user with its genre breakdown
+func (s *Service) GetWatchHistory(ctx context.Context, userID int64, limit int) ([]domain.WatchHistoryItem, *domain.WatchHistoryBreakdown, error) {
+	if _, err := s.getUser(ctx, userID); err != nil {
+		return nil, nil, err
+	}
+
+	items, err := s.repo.GetUserWatchHistoryWithGenres(ctx, userID, limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetch watch history: %w", err)
+	}
+
+	breakdown := &domain.WatchHistoryBreakdown{
+		TotalCount:       len(items),
+		GenreCounts:      make(map[string]int),
+		GenrePercentages: make(map[string]float64),
+	}
+	for _, item := range items {
+		breakdown.GenreCounts[item.Genre]++
+	}
+	// Shares of items, unlike preferences which weight items by completion
+	for genre, count := range breakdown.GenreCounts {
+		breakdown.GenrePercentages[genre] = math.Round(float64(count)/float64(len(items))*1000) / 10
+	}
+	return items, breakdown, nil
 }
 
 // Add watch history for a user and clear user's cache
@@ -195,17 +1201,141 @@ func (s *Service) AddWatchHistory(ctx context.Context, userID, contentID int64)
     if err := s.repo.AddWatchHistory(ctx, userID, contentID); err != nil {
         return err
     }
-    if err := s.cache.ClearUserCache(ctx, userID); err != nil {
-        log.Printf("[service] cache invalidation error for user %d: %v", userID, err)
-    }
+    s.userChanged(ctx, userID)
     return nil
 }
 
+// Add watch history guarded by an idempotency key. The key is reserved before
+// the write, so concurrent requests with it get ErrIdempotencyInProgress
+// rather than writing twice. A repeated key returns the stored outcome without
+// re-executing the write; replayed reports whether it did. Reusing a key for
+// another content ID fails with ErrIdempotencyMismatch.
+func (s *Service) AddWatchHistoryIdempotent(ctx context.Context, key string, userID, contentID int64) (replayed bool, err error) {
+	if key == "" {
+		return false, s.AddWatchHistory(ctx, userID, contentID)
+	}
+
+	fingerprint := watchFingerprint(contentID)
+	record, reserved, err := s.cache.ReserveIdempotency(ctx, userID, key, fingerprint)
+	if err != nil {
+		// Without the cache the write goes ahead unguarded
+		log.Printf("[service] idempotency reserve error for user %d: %v", userID, err)
+		return false, s.AddWatchHistory(ctx, userID, contentID)
+	}
+	if !reserved {
+		switch {
+		case record.Fingerprint != fingerprint:
+			return false, domain.ErrIdempotencyMismatch
+		case record.Outcome == "":
+			return false, domain.ErrIdempotencyInProgress
+		}
+		return true, outcomeError(record.Outcome)
+	}
+
+	err = s.AddWatchHistory(ctx, userID, contentID)
+	switch {
+	case err == nil:
+		record.Outcome = domain.WatchRecorded
+	case errors.Is(err, domain.ErrAlreadyWatched):
+		record.Outcome = domain.WatchDuplicate
+	default:
+		// Other failures are not stored so the client can retry them
+		if releaseErr := s.cache.ReleaseIdempotency(ctx, userID, key); releaseErr != nil {
+			log.Printf("[service] idempotency release error for user %d: %v", userID, releaseErr)
+		}
+		return false, err
+	}
+
+	if storeErr := s.cache.StoreIdempotency(ctx, userID, key, record); storeErr != nil {
+		log.Printf("[service] idempotency store error for user %d: %v", userID, storeErr)
+	}
+	return false, err
+}
+
+// Fingerprint of a watch history request; its body is just the content ID
+func watchFingerprint(contentID int64) string {
+	return "content_id=" + strconv.FormatInt(contentID, 10)
+}
+
+func outcomeError(outcome domain.WatchHistoryOutcome) error {
+	if outcome == domain.WatchDuplicate {
+		return domain.ErrAlreadyWatched
+	}
+	return nil
+}
+
+// Remove a watch record for a user and clear user's cache
+func (s *Service) RemoveWatchHistory(ctx context.Context, userID, contentID int64) error {
+	removed, err := s.repo.RemoveWatchHistory(ctx, userID, contentID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return domain.ErrWatchNotFound
+	}
+	s.userChanged(ctx, userID)
+	return nil
+}
+
+// Add many watch events for a user, clearing the user's cache once afterward
+func (s *Service) AddWatchHistoryBatch(ctx context.Context, userID int64, contentIDs []int64) (*domain.BulkWatchHistoryResult, error) {
+	inserted, err := s.repo.AddWatchHistoryBatch(ctx, userID, contentIDs)
+	if err != nil {
+		return nil, err
+	}
+	s.userChanged(ctx, userID)
+	return &domain.BulkWatchHistoryResult{
+		Inserted: inserted,
+		Skipped:  len(contentIDs) - inserted,
+	}, nil
+}
+
+// Hide content from a user's recommendations and clear the user's cache.
+// Reports whether the content was newly blocked.
+func (s *Service) BlockContent(ctx context.Context, userID, contentID int64) (bool, error) {
+	added, err := s.repo.BlockContent(ctx, userID, contentID)
+	if err != nil {
+		return false, err
+	}
+	s.userChanged(ctx, userID)
+	return added, nil
+}
+
+// Remove a blocklist entry and clear the user's cache
+func (s *Service) UnblockContent(ctx context.Context, userID, contentID int64) error {
+	removed, err := s.repo.UnblockContent(ctx, userID, contentID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return domain.ErrBlockNotFound
+	}
+	s.userChanged(ctx, userID)
+	return nil
+}
+
+// Drop every cached recommendation list for a user, returning the number of keys removed
+func (s *Service) InvalidateUserCache(ctx context.Context, userID int64) (int, error) {
+	deleted, err := s.cache.ClearUserCache(ctx, userID)
+	if err != nil {
+		return deleted, fmt.Errorf("clear cache for user %d: %w", userID, err)
+	}
+	return deleted, nil
+}
+
 // Handle response error for batch processing
 func categorizeError(err error) (string, string) {
 	if errors.Is(err, domain.ErrUserNotFound) {
 		return "user_not_found", "user not found"
 	}
+	if errors.Is(err, domain.ErrDatabaseUnavailable) {
+		return "database_unavailable", "database is temporarily unavailable"
+	}
+	// Before the model check: scoring errors wrap the context error that
+	// interrupted them
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return "request_timeout", "request timed out"
+	}
 	if errors.Is(err, domain.ErrModelUnavailable) {
 		return "model_inference_error", "recommendation model failed to generate a response"
 	}
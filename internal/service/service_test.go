@@ -0,0 +1,1915 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/actuallystonmai/recommendation-service/internal/experiment"
+	"github.com/actuallystonmai/recommendation-service/internal/metrics"
+	"github.com/actuallystonmai/recommendation-service/internal/model"
+	"github.com/actuallystonmai/recommendation-service/internal/service/servicetest"
+	"github.com/actuallystonmai/recommendation-service/seeds"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Service over a fake repository with users 1..5 and content 1..20
+func newFakeService(t *testing.T, scorer model.Scorer) (*Service, *servicetest.FakeRepo) {
+	repo := servicetest.NewFakeRepoWith(5, 20)
+	return newTestService(t, repo, servicetest.NewFakeCache(), scorer, Config{BatchConcurrency: 10}), repo
+}
+
+// Service whose batch workers are stopped when the test ends
+func newTestService(t *testing.T, repo Repo, c RecommendationCache, scorer model.Scorer, cfg Config) *Service {
+	t.Helper()
+	svc := NewService(repo, c, scorer, cfg)
+	t.Cleanup(svc.Close)
+	return svc
+}
+
+func TestAddWatchHistoryIdempotentReplay(t *testing.T) {
+	svc, repo := newFakeService(t, &servicetest.FakeScorer{})
+	ctx := context.Background()
+
+	unwatched, err := repo.GetUnwatchedContent(ctx, 1, 1, domain.DefaultCandidateOrder, domain.ContentRatings, 0)
+	if err != nil || len(unwatched) == 0 {
+		t.Fatalf("get unwatched content: %v", err)
+	}
+	contentID := unwatched[0].ID
+
+	before, err := repo.GetUserWatchHistoryWithGenres(ctx, 1, 1000)
+	if err != nil {
+		t.Fatalf("get watch history: %v", err)
+	}
+
+	// First call performs the write
+	replayed, err := svc.AddWatchHistoryIdempotent(ctx, "retry-key", 1, contentID)
+	if err != nil || replayed {
+		t.Fatalf("first call: replayed=%v err=%v", replayed, err)
+	}
+
+	// Same key replays the original success instead of conflicting
+	replayed, err = svc.AddWatchHistoryIdempotent(ctx, "retry-key", 1, contentID)
+	if err != nil || !replayed {
+		t.Fatalf("replayed call: replayed=%v err=%v", replayed, err)
+	}
+
+	// A new key re-executes and hits the duplicate
+	_, err = svc.AddWatchHistoryIdempotent(ctx, "other-key", 1, contentID)
+	if !errors.Is(err, domain.ErrAlreadyWatched) {
+		t.Errorf("expected ErrAlreadyWatched for new key, got %v", err)
+	}
+
+	after, err := repo.GetUserWatchHistoryWithGenres(ctx, 1, 1000)
+	if err != nil {
+		t.Fatalf("get watch history: %v", err)
+	}
+	if len(after) != len(before)+1 {
+		t.Errorf("expected exactly one insert, got %d new rows", len(after)-len(before))
+	}
+}
+
+func TestAddWatchHistoryIdempotentMismatchedReplay(t *testing.T) {
+	svc, repo := newFakeService(t, &servicetest.FakeScorer{})
+	ctx := context.Background()
+	unwatched, err := repo.GetUnwatchedContent(ctx, 1, 2, domain.DefaultCandidateOrder, domain.ContentRatings, 0)
+	if err != nil || len(unwatched) < 2 {
+		t.Fatalf("get unwatched content: %v", err)
+	}
+
+	if _, err := svc.AddWatchHistoryIdempotent(ctx, "retry-key", 1, unwatched[0].ID); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	replayed, err := svc.AddWatchHistoryIdempotent(ctx, "retry-key", 1, unwatched[1].ID)
+	if !errors.Is(err, domain.ErrIdempotencyMismatch) || replayed {
+		t.Fatalf("expected ErrIdempotencyMismatch, got replayed=%v err=%v", replayed, err)
+	}
+	history, err := repo.GetUserWatchHistoryWithGenres(ctx, 1, 1000)
+	if err != nil {
+		t.Fatalf("get watch history: %v", err)
+	}
+	for _, item := range history {
+		if item.ContentID == unwatched[1].ID {
+			t.Error("expected the mismatched request not to be written")
+		}
+	}
+}
+
+// Repo whose watch history writes take a while, counting them
+type slowWatchRepo struct {
+	*servicetest.FakeRepo
+	writes atomic.Int32
+}
+
+func (r *slowWatchRepo) AddWatchHistory(ctx context.Context, userID, contentID int64) error {
+	r.writes.Add(1)
+	time.Sleep(20 * time.Millisecond)
+	return r.FakeRepo.AddWatchHistory(ctx, userID, contentID)
+}
+
+func TestAddWatchHistoryIdempotentConcurrentReplay(t *testing.T) {
+	fake := servicetest.NewFakeRepoWith(1, 5)
+	repo := &slowWatchRepo{FakeRepo: fake}
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{}, Config{})
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = svc.AddWatchHistoryIdempotent(context.Background(), "same-key", 1, 3)
+		}()
+	}
+	wg.Wait()
+
+	if writes := repo.writes.Load(); writes != 1 {
+		t.Errorf("expected one write for concurrent requests with one key, got %d", writes)
+	}
+	for i, err := range errs {
+		if err != nil && !errors.Is(err, domain.ErrIdempotencyInProgress) {
+			t.Errorf("request %d: expected success or in progress, got %v", i, err)
+		}
+	}
+}
+
+// Run a fixed-delay task n times, returning elapsed time and peak concurrency
+func measureBounded(n, concurrency int, delay time.Duration) (time.Duration, int32) {
+	var inFlight, peak atomic.Int32
+
+	pool := newWorkerPool(concurrency)
+	defer pool.close()
+	start := time.Now()
+	pool.run(n, func(int) {
+		current := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if current <= p || peak.CompareAndSwap(p, current) {
+				break
+			}
+		}
+		time.Sleep(delay)
+		inFlight.Add(-1)
+	})
+	return time.Since(start), peak.Load()
+}
+
+func TestWorkerPoolConcurrency(t *testing.T) {
+	const n = 8
+	const delay = 20 * time.Millisecond
+
+	// concurrency=1 -> serialized
+	serialElapsed, serialPeak := measureBounded(n, 1, delay)
+	if serialPeak != 1 {
+		t.Errorf("expected peak concurrency 1, got %d", serialPeak)
+	}
+	if serialElapsed < n*delay {
+		t.Errorf("expected serialized run to take at least %v, took %v", n*delay, serialElapsed)
+	}
+
+	// concurrency=4 -> bounded and faster
+	parallelElapsed, parallelPeak := measureBounded(n, 4, delay)
+	if parallelPeak > 4 {
+		t.Errorf("expected peak concurrency <= 4, got %d", parallelPeak)
+	}
+	if parallelElapsed >= serialElapsed {
+		t.Errorf("expected concurrency=4 (%v) to be faster than concurrency=1 (%v)", parallelElapsed, serialElapsed)
+	}
+}
+
+func TestWorkerPoolDegenerateSize(t *testing.T) {
+	// size=0 must not deadlock
+	var calls atomic.Int32
+	single := newWorkerPool(0)
+	defer single.close()
+	single.run(3, func(int) { calls.Add(1) })
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 calls, got %d", calls.Load())
+	}
+
+	// No work -> returns immediately
+	idle := newWorkerPool(10)
+	defer idle.close()
+	idle.run(0, func(int) { t.Error("unexpected call") })
+}
+
+func TestWorkerPoolSharedAcrossRuns(t *testing.T) {
+	pool := newWorkerPool(3)
+	defer pool.close()
+	var inFlight, peak atomic.Int32
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.run(6, func(int) {
+				current := inFlight.Add(1)
+				for {
+					p := peak.Load()
+					if current <= p || peak.CompareAndSwap(p, current) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				inFlight.Add(-1)
+			})
+		}()
+	}
+	wg.Wait()
+	if peak.Load() > 3 {
+		t.Errorf("expected at most 3 tasks in flight across runs, got %d", peak.Load())
+	}
+}
+
+func TestWorkerPoolClose(t *testing.T) {
+	pool := newWorkerPool(2)
+	var calls atomic.Int32
+
+	// Close waits for queued work, then the workers exit
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pool.run(6, func(int) {
+			time.Sleep(5 * time.Millisecond)
+			calls.Add(1)
+		})
+	}()
+	time.Sleep(time.Millisecond)
+	pool.close()
+	<-done
+	if calls.Load() != 6 {
+		t.Errorf("expected every queued call to run before close returned, got %d", calls.Load())
+	}
+
+	// Runs after close still complete, on the caller's goroutine
+	pool.run(3, func(int) { calls.Add(1) })
+	if calls.Load() != 9 {
+		t.Errorf("expected 3 more calls after close, got %d", calls.Load()-6)
+	}
+	pool.close()
+}
+
+func TestNewServiceDefaultsBatchConcurrency(t *testing.T) {
+	svc := newTestService(t, nil, nil, nil, Config{BatchConcurrency: 0})
+	if svc.batchPool.size != defaultBatchConcurrency {
+		t.Errorf("expected default concurrency %d, got %d", defaultBatchConcurrency, svc.batchPool.size)
+	}
+}
+
+// Records the most Score calls ever in flight at once
+type concurrencyScorer struct {
+	servicetest.FakeScorer
+	delay          time.Duration
+	inFlight, peak atomic.Int32
+}
+
+func (s *concurrencyScorer) Score(ctx context.Context, input model.ScoreInput) ([]domain.ScoredRecommendation, bool, error) {
+	current := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	for {
+		p := s.peak.Load()
+		if current <= p || s.peak.CompareAndSwap(p, current) {
+			break
+		}
+	}
+	time.Sleep(s.delay)
+	return s.FakeScorer.Score(ctx, input)
+}
+
+func TestConcurrentBatchesShareModelCallLimit(t *testing.T) {
+	const limit = 3
+	repo := servicetest.NewFakeRepoWith(12, 20)
+	scorer := &concurrencyScorer{delay: 5 * time.Millisecond}
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), scorer, Config{BatchConcurrency: limit})
+	ctx := context.Background()
+
+	// Batch pages, bulk requests and streams all at once, each alone big
+	// enough to fill the pool
+	var wg sync.WaitGroup
+	for page := 1; page <= 3; page++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			resp, err := svc.GetBatchRecommendations(ctx, page, 4, batchRecLimit, "", false)
+			if err != nil || resp.Summary.SuccessCount != 4 {
+				t.Errorf("page %d: expected 4 successes, got %+v, %v", page, resp, err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			resp := svc.GetBulkRecommendations(ctx, []int64{1, 2, 3, 4, 5, 6}, 5)
+			if resp.Summary.SuccessCount != 6 {
+				t.Errorf("bulk: expected 6 successes, got %+v", resp.Summary)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			results, err := svc.StreamBatchRecommendations(ctx, page, 4, batchRecLimit, false)
+			if err != nil {
+				t.Errorf("stream page %d: %v", page, err)
+				return
+			}
+			for range results {
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak := scorer.peak.Load(); peak > limit {
+		t.Errorf("expected at most %d model calls in flight across batches, got %d", limit, peak)
+	}
+}
+
+func TestStreamBatchRecommendationsMatchesPage(t *testing.T) {
+	svc, _ := newFakeService(t, &servicetest.FakeScorer{})
+	ctx := context.Background()
+
+	results, err := svc.StreamBatchRecommendations(ctx, 1, 3, batchRecLimit, false)
+	if err != nil {
+		t.Fatalf("stream batch: %v", err)
+	}
+
+	seen := make(map[int64]bool)
+	for result := range results {
+		if seen[result.UserID] {
+			t.Errorf("user %d streamed twice", result.UserID)
+		}
+		seen[result.UserID] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("expected 3 streamed results, got %d", len(seen))
+	}
+}
+
+func TestGetRecommendationsCacheHitSkipsScorer(t *testing.T) {
+	c := servicetest.NewFakeCache()
+	scorer := &servicetest.FakeScorer{}
+	svc := newTestService(t, servicetest.NewFakeRepo(), c, scorer, Config{})
+	ctx := context.Background()
+
+	cached := domain.CachedRecommendations{Recommendations: []domain.ScoredRecommendation{{ContentID: 7, Title: "Cached", Score: 0.9}}}
+	if err := c.Set(ctx, 1, 5, "", cached); err != nil {
+		t.Fatalf("seed cache: %v", err)
+	}
+
+	result, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	if !result.CacheHit {
+		t.Error("expected cache hit")
+	}
+	if len(result.Recommendations) != 1 || result.Recommendations[0].ContentID != 7 {
+		t.Errorf("expected cached recommendations, got %+v", result.Recommendations)
+	}
+	if scorer.Calls() != 0 {
+		t.Errorf("expected no scorer calls on cache hit, got %d", scorer.Calls())
+	}
+}
+
+func TestGetRecommendationsCacheMissUsesScorer(t *testing.T) {
+	scorer := &servicetest.FakeScorer{}
+	svc, _ := newFakeService(t, scorer)
+	ctx := context.Background()
+
+	// Miss -> scored by the fake
+	first, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 3})
+	if err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if first.CacheHit {
+		t.Error("expected cache miss on first call")
+	}
+	if len(first.Recommendations) != 3 {
+		t.Errorf("expected 3 recommendations, got %d", len(first.Recommendations))
+	}
+
+	// Hit -> scorer not called again
+	second, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 3})
+	if err != nil {
+		t.Fatalf("second call: %v", err)
+	}
+	if !second.CacheHit {
+		t.Error("expected cache hit on second call")
+	}
+	if scorer.Calls() != 1 {
+		t.Errorf("expected 1 scorer call, got %d", scorer.Calls())
+	}
+}
+
+func TestGetRecommendationsModelFailure(t *testing.T) {
+	svc, _ := newFakeService(t, &servicetest.FakeScorer{Err: &model.ModelInferenceError{Msg: "boom"}})
+
+	_, err := svc.GetRecommendations(context.Background(), 1, domain.RecommendationOptions{Limit: 3})
+	if !errors.Is(err, domain.ErrModelUnavailable) {
+		t.Errorf("expected ErrModelUnavailable, got %v", err)
+	}
+}
+
+func TestGetBatchRecommendationsCountsFailures(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(6, 20)
+	repo.UserErrors[2] = errors.New("connection reset")
+	scorer := &servicetest.FakeScorer{FailUsers: map[int64]bool{4: true, 5: true}}
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), scorer, Config{BatchConcurrency: 3})
+
+	result, err := svc.GetBatchRecommendations(context.Background(), 1, 10, batchRecLimit, "", false)
+	if err != nil {
+		t.Fatalf("get batch: %v", err)
+	}
+
+	if result.TotalUsers != 6 || len(result.Results) != 6 {
+		t.Fatalf("expected 6 users, got total=%d results=%d", result.TotalUsers, len(result.Results))
+	}
+	if result.Summary.SuccessCount != 3 || result.Summary.FailedCount != 3 {
+		t.Errorf("expected 3 success / 3 failed, got %+v", result.Summary)
+	}
+
+	// Results keep page order and carry categorized errors
+	expected := map[int64]string{1: "", 2: "internal_error", 3: "", 4: "model_inference_error", 5: "model_inference_error", 6: ""}
+	for i, r := range result.Results {
+		if r.UserID != int64(i+1) {
+			t.Errorf("result %d: expected user %d, got %d", i, i+1, r.UserID)
+		}
+		if r.Error != expected[r.UserID] {
+			t.Errorf("user %d: expected error %q, got %q", r.UserID, expected[r.UserID], r.Error)
+		}
+		if r.Error == "" && len(r.Recommendations) != batchRecLimit {
+			t.Errorf("user %d: expected %d recommendations, got %d", r.UserID, batchRecLimit, len(r.Recommendations))
+		}
+	}
+}
+
+func TestGetBatchRecommendationsPagination(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(5, 10)
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{}, Config{})
+
+	result, err := svc.GetBatchRecommendations(context.Background(), 2, 3, batchRecLimit, "", false)
+	if err != nil {
+		t.Fatalf("get batch: %v", err)
+	}
+
+	// Page 2 of size 3 holds users 4 and 5
+	if len(result.Results) != 2 || result.Results[0].UserID != 4 || result.Results[1].UserID != 5 {
+		t.Errorf("expected users 4 and 5, got %+v", result.Results)
+	}
+	if result.Summary.SuccessCount != 2 || result.Summary.FailedCount != 0 {
+		t.Errorf("expected 2 success, got %+v", result.Summary)
+	}
+}
+
+func TestGetBatchRecommendationsSetupFailure(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	repo.AddUsers(3)
+	repo.Errors["GetUserIDsPaginated"] = errors.New("connection refused")
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{}, Config{})
+
+	if _, err := svc.GetBatchRecommendations(context.Background(), 1, 10, batchRecLimit, "", false); err == nil {
+		t.Error("expected error when user ids cannot be fetched")
+	}
+}
+
+func TestGetRecommendationsFakeCacheHitOnSecondCall(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 10)
+	c := servicetest.NewFakeCache()
+	svc := newTestService(t, repo, c, &servicetest.FakeScorer{}, Config{})
+	ctx := context.Background()
+
+	first, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if first.CacheHit || c.Len() != 1 {
+		t.Fatalf("expected miss that stores one entry, got hit=%v entries=%d", first.CacheHit, c.Len())
+	}
+
+	second, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("second call: %v", err)
+	}
+	if !second.CacheHit {
+		t.Error("expected cache hit on second call")
+	}
+	if !reflect.DeepEqual(second.Recommendations, first.Recommendations) {
+		t.Errorf("expected cached recommendations to match, got %+v", second.Recommendations)
+	}
+}
+
+func TestGetRecommendationsCacheErrorFallsThrough(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 10)
+	c := servicetest.NewFakeCache()
+	c.Err = errors.New("redis down")
+	svc := newTestService(t, repo, c, &servicetest.FakeScorer{}, Config{})
+
+	result, err := svc.GetRecommendations(context.Background(), 1, domain.RecommendationOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("expected cache errors not to fail the request, got %v", err)
+	}
+	if result.CacheHit || len(result.Recommendations) != 5 {
+		t.Errorf("expected 5 freshly generated recommendations, got hit=%v len=%d", result.CacheHit, len(result.Recommendations))
+	}
+}
+
+func TestGetRecommendationsCountsCacheErrorsApartFromMisses(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 10)
+	c := servicetest.NewFakeCache()
+	cacheMetrics := metrics.NewCacheMetrics()
+	svc := newTestService(t, repo, c, &servicetest.FakeScorer{}, Config{CacheMetrics: cacheMetrics})
+	ctx := context.Background()
+
+	expectLookups := func(hit, miss, failed int) {
+		t.Helper()
+		want := fmt.Sprintf(`# HELP recommendation_cache_lookups_total Recommendation cache lookups by result: hit, miss or error
+# TYPE recommendation_cache_lookups_total counter
+recommendation_cache_lookups_total{result="error"} %d
+recommendation_cache_lookups_total{result="hit"} %d
+recommendation_cache_lookups_total{result="miss"} %d
+`, failed, hit, miss)
+		if err := promtest.CollectAndCompare(cacheMetrics, strings.NewReader(want)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// A miss, then a hit on the entry it stored
+	for range 2 {
+		if _, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5}); err != nil {
+			t.Fatalf("get recommendations: %v", err)
+		}
+	}
+	expectLookups(1, 1, 0)
+
+	c.Err = errors.New("redis down")
+	if _, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5}); err != nil {
+		t.Fatalf("expected cache errors not to fail the request, got %v", err)
+	}
+	expectLookups(1, 1, 1)
+}
+
+func TestGetRecommendationsWeightOverrides(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	repo.AddUsers(2)
+	repo.Users[1].SubscriptionType = "premium"
+	repo.Users[2].SubscriptionType = "free"
+	repo.AddContent(10)
+	// The most popular content is the oldest, so recency ranks it last
+	for i := range repo.Content {
+		repo.Content[i].CreatedAt = time.Now().AddDate(i-len(repo.Content), 0, 0)
+	}
+	cfg := model.DefaultModelConfig()
+	cfg.MinLatency, cfg.MaxLatency, cfg.FailureRate, cfg.DisableNoise = 0, 0, 0, true
+	c := servicetest.NewFakeCache()
+	svc := newTestService(t, repo, c, model.NewClient(cfg), Config{})
+	ctx := context.Background()
+
+	recencyOnly := domain.RecommendationOptions{Limit: 5, Weights: &domain.ScoreWeights{Recency: 1}}
+	top := func(userID int64, opts domain.RecommendationOptions) *domain.RecommendationResult {
+		t.Helper()
+		result, err := svc.GetRecommendations(ctx, userID, opts)
+		if err != nil {
+			t.Fatalf("user %d: get recommendations: %v", userID, err)
+		}
+		return result
+	}
+
+	if got := top(1, domain.RecommendationOptions{Limit: 5}).Recommendations[0].ContentID; got != 1 {
+		t.Errorf("premium defaults: expected the most popular content first, got %d", got)
+	}
+	if got := top(1, recencyOnly).Recommendations[0].ContentID; got != 10 {
+		t.Errorf("premium overrides: expected the newest content first, got %d", got)
+	}
+
+	// A free user's overrides are ignored, and share the default cache entry
+	if got := top(2, recencyOnly).Recommendations[0].ContentID; got != 1 {
+		t.Errorf("free overrides: expected the default ranking, got %d first", got)
+	}
+	if !top(2, domain.RecommendationOptions{Limit: 5}).CacheHit {
+		t.Error("expected the free user's default request to hit the entry cached by the ignored overrides")
+	}
+}
+
+func TestGetRecommendationsExperimentAssignment(t *testing.T) {
+	split, err := experiment.ParseSplit("recency_first:100")
+	if err != nil {
+		t.Fatalf("parse split: %v", err)
+	}
+	repo := servicetest.NewFakeRepoWith(1, 5)
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{}, Config{Experiment: split})
+	ctx := context.Background()
+
+	// No explicit strategy: assigned from the user's bucket
+	result, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	if result.Strategy != domain.StrategyRecencyFirst {
+		t.Errorf("expected recency_first, got %s", result.Strategy)
+	}
+	if result.ExperimentBucket == nil || *result.ExperimentBucket != experiment.AssignBucket(1) {
+		t.Errorf("expected bucket %d, got %v", experiment.AssignBucket(1), result.ExperimentBucket)
+	}
+
+	// Explicit strategy overrides the experiment
+	result, err = svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5, Strategy: domain.StrategyPopularityOnly})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	if result.Strategy != domain.StrategyPopularityOnly || result.ExperimentBucket != nil {
+		t.Errorf("expected explicit popularity_only without bucket, got %s bucket=%v", result.Strategy, result.ExperimentBucket)
+	}
+}
+
+func TestGetRecommendationsIncludeWatched(t *testing.T) {
+	svc, repo := newFakeService(t, &servicetest.FakeScorer{})
+	ctx := context.Background()
+
+	// Most popular item is content 1; watching it hides it by default
+	if err := repo.AddWatchHistory(ctx, 1, 1); err != nil {
+		t.Fatalf("add watch history: %v", err)
+	}
+
+	result, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 20})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	for _, rec := range result.Recommendations {
+		if rec.ContentID == 1 || rec.Watched {
+			t.Fatalf("expected watched content to be excluded by default, got %+v", rec)
+		}
+	}
+
+	result, err = svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 20, IncludeWatched: true})
+	if err != nil {
+		t.Fatalf("get recommendations with watched: %v", err)
+	}
+	if result.CacheHit {
+		t.Error("expected include_watched to use its own cache entry")
+	}
+	if len(result.Recommendations) != 20 {
+		t.Errorf("expected the full catalog of 20, got %d", len(result.Recommendations))
+	}
+	for _, rec := range result.Recommendations {
+		if rec.Watched != (rec.ContentID == 1) {
+			t.Errorf("content %d: expected watched=%v, got %v", rec.ContentID, rec.ContentID == 1, rec.Watched)
+		}
+	}
+}
+
+// Scorer that records how many candidates each call received
+type candidateCounter struct {
+	servicetest.FakeScorer
+	counts []int
+}
+
+func (c *candidateCounter) Score(ctx context.Context, input model.ScoreInput) ([]domain.ScoredRecommendation, bool, error) {
+	c.counts = append(c.counts, len(input.Candidates))
+	return c.FakeScorer.Score(ctx, input)
+}
+
+func TestCandidatePoolGrowsWithWatchHistory(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(2, 400)
+	scorer := &candidateCounter{}
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), scorer, Config{BatchConcurrency: 10})
+	ctx := context.Background()
+
+	watchedIDs := make([]int64, 40)
+	for i := range watchedIDs {
+		watchedIDs[i] = int64(i + 1)
+	}
+	if _, err := repo.AddWatchHistoryBatch(ctx, 2, watchedIDs); err != nil {
+		t.Fatalf("add watch history: %v", err)
+	}
+
+	for _, userID := range []int64{1, 2} {
+		if _, err := svc.GetRecommendations(ctx, userID, domain.RecommendationOptions{Limit: 10}); err != nil {
+			t.Fatalf("get recommendations for user %d: %v", userID, err)
+		}
+	}
+	if len(scorer.counts) != 2 || scorer.counts[0] != 100 || scorer.counts[1] != 300 {
+		t.Fatalf("expected pools of 100 for a new user and 300 after 40 watches, got %v", scorer.counts)
+	}
+}
+
+func TestCandidatePoolFor(t *testing.T) {
+	cases := map[int]int{0: 100, 1: 105, 20: 200, 40: 300, 50: 300}
+	for historyLen, want := range cases {
+		if got := candidatePoolFor("basic", historyLen); got != want {
+			t.Errorf("candidatePoolFor(basic, %d) = %d, want %d", historyLen, got, want)
+		}
+	}
+
+	tiers := []struct {
+		subscription string
+		historyLen   int
+		want         int
+	}{
+		{"free", 0, 50},
+		{"free", 10, 100},
+		{"premium", 0, 200},
+		{"premium", 30, 300},
+		{"unknown", 0, 100},
+	}
+	for _, tc := range tiers {
+		if got := candidatePoolFor(tc.subscription, tc.historyLen); got != tc.want {
+			t.Errorf("candidatePoolFor(%s, %d) = %d, want %d", tc.subscription, tc.historyLen, got, tc.want)
+		}
+	}
+}
+
+func TestCandidatePoolBySubscription(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(2, 400)
+	repo.Users[1].SubscriptionType = "free"
+	repo.Users[2].SubscriptionType = "premium"
+	scorer := &candidateCounter{}
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), scorer, Config{BatchConcurrency: 10})
+
+	for _, userID := range []int64{1, 2} {
+		if _, err := svc.GetRecommendations(context.Background(), userID, domain.RecommendationOptions{Limit: 10}); err != nil {
+			t.Fatalf("get recommendations for user %d: %v", userID, err)
+		}
+	}
+	if len(scorer.counts) != 2 || scorer.counts[0] != 50 || scorer.counts[1] != 200 {
+		t.Fatalf("expected pools of 50 for a free user and 200 for a premium user, got %v", scorer.counts)
+	}
+}
+
+func TestGetBatchRecommendationsStatusFilter(t *testing.T) {
+	scorer := &servicetest.FakeScorer{FailUsers: map[int64]bool{2: true, 4: true}}
+	svc, _ := newFakeService(t, scorer)
+	ctx := context.Background()
+
+	tests := []struct {
+		status   domain.BatchStatus
+		expected int
+	}{
+		{"", 5},
+		{domain.StatusSuccess, 3},
+		{domain.StatusFailed, 2},
+	}
+
+	for _, tc := range tests {
+		result, err := svc.GetBatchRecommendations(ctx, 1, 10, batchRecLimit, tc.status, false)
+		if err != nil {
+			t.Fatalf("%q: batch: %v", tc.status, err)
+		}
+		if len(result.Results) != tc.expected {
+			t.Errorf("%q: expected %d results, got %d", tc.status, tc.expected, len(result.Results))
+		}
+		for _, r := range result.Results {
+			if tc.status != "" && r.Status != tc.status {
+				t.Errorf("%q: unexpected result status %s for user %d", tc.status, r.Status, r.UserID)
+			}
+		}
+		// Summary reflects the full, unfiltered page
+		if result.Summary.SuccessCount != 3 || result.Summary.FailedCount != 2 {
+			t.Errorf("%q: expected summary 3/2, got %d/%d", tc.status, result.Summary.SuccessCount, result.Summary.FailedCount)
+		}
+	}
+}
+
+func TestGetRecommendationsBoostsUseSeparateCacheEntry(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 10)
+	c := servicetest.NewFakeCache()
+	svc := newTestService(t, repo, c, &servicetest.FakeScorer{}, Config{})
+	ctx := context.Background()
+
+	if _, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5}); err != nil {
+		t.Fatalf("unboosted call: %v", err)
+	}
+	boosted, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{
+		Limit:       5,
+		GenreBoosts: map[string]float64{"sci-fi": 2},
+	})
+	if err != nil {
+		t.Fatalf("boosted call: %v", err)
+	}
+	if boosted.CacheHit || c.Len() != 2 {
+		t.Errorf("expected boosted request to miss and add its own entry, got hit=%v entries=%d", boosted.CacheHit, c.Len())
+	}
+}
+
+func TestGetRecommendationsOpenBreakerFastFails(t *testing.T) {
+	scorer := &servicetest.FakeScorer{Err: &model.ModelInferenceError{Msg: "boom"}}
+	svc, _ := newFakeService(t, model.NewBreakerScorer(scorer, 2, time.Minute))
+	ctx := context.Background()
+
+	// Distinct users so each call misses the cache
+	for userID := int64(1); userID <= 3; userID++ {
+		_, err := svc.GetRecommendations(ctx, userID, domain.RecommendationOptions{Limit: 3})
+		if !errors.Is(err, domain.ErrModelUnavailable) {
+			t.Fatalf("user %d: expected ErrModelUnavailable, got %v", userID, err)
+		}
+	}
+	if scorer.Calls() != 2 {
+		t.Errorf("expected the open breaker to skip the model, got %d calls", scorer.Calls())
+	}
+}
+
+func TestGetRecommendationsFeaturedFirst(t *testing.T) {
+	svc, repo := newFakeService(t, &servicetest.FakeScorer{})
+	ctx := context.Background()
+	// Content 1 is also the top scored candidate; 4 is watched so it is skipped
+	repo.Featured = []int64{7, 1, 4}
+	if err := repo.AddWatchHistory(ctx, 1, 4); err != nil {
+		t.Fatalf("seed watch history: %v", err)
+	}
+
+	result, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+
+	recs := result.Recommendations
+	if len(recs) != 5 {
+		t.Fatalf("expected 5 recommendations, got %d", len(recs))
+	}
+	if recs[0].ContentID != 7 || recs[1].ContentID != 1 || !recs[0].Featured || !recs[1].Featured {
+		t.Errorf("expected featured 7 and 1 first, got %+v", recs[:2])
+	}
+	seen := make(map[int64]bool)
+	for _, rec := range recs {
+		if seen[rec.ContentID] {
+			t.Errorf("content %d appears more than once", rec.ContentID)
+		}
+		seen[rec.ContentID] = true
+		if rec.ContentID == 4 {
+			t.Error("watched featured content should be skipped")
+		}
+	}
+	for _, rec := range recs[2:] {
+		if rec.Featured {
+			t.Errorf("expected scored tail to be unfeatured, got %+v", rec)
+		}
+	}
+}
+
+func TestGetRecommendationsFeaturedErrorFallsBack(t *testing.T) {
+	svc, repo := newFakeService(t, &servicetest.FakeScorer{})
+	repo.Featured = []int64{7}
+	repo.Errors["GetFeaturedContent"] = errors.New("featured unavailable")
+
+	result, err := svc.GetRecommendations(context.Background(), 1, domain.RecommendationOptions{Limit: 3})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	if len(result.Recommendations) != 3 || result.Recommendations[0].Featured {
+		t.Errorf("expected scored recommendations only, got %+v", result.Recommendations)
+	}
+}
+
+func TestWithFeaturedTruncatesToLimit(t *testing.T) {
+	featured := []domain.Content{{ID: 1}, {ID: 2}, {ID: 3}}
+	scored := []domain.ScoredRecommendation{{ContentID: 4}}
+
+	recs := withFeatured(featured, scored, 2)
+	if len(recs) != 2 || recs[0].ContentID != 1 || recs[1].ContentID != 2 {
+		t.Errorf("expected only the first 2 featured items, got %+v", recs)
+	}
+}
+
+func TestGetRecommendationsDegradedNotCached(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 10)
+	repo.Errors["GetUserWatchHistoryWithGenres"] = errors.New("connection reset")
+	c := servicetest.NewFakeCache()
+	svc := newTestService(t, repo, c, &servicetest.FakeScorer{}, Config{})
+	ctx := context.Background()
+
+	result, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("expected degraded success, got %v", err)
+	}
+	if !result.Degraded || result.Strategy != domain.StrategyPopularityOnly {
+		t.Errorf("expected degraded popularity_only result, got degraded=%v strategy=%q", result.Degraded, result.Strategy)
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected degraded result not to be cached, got %d entries", c.Len())
+	}
+
+	// Recovered history scores normally again
+	delete(repo.Errors, "GetUserWatchHistoryWithGenres")
+	result, err = svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	if result.Degraded || result.CacheHit {
+		t.Errorf("expected fresh non-degraded result, got degraded=%v hit=%v", result.Degraded, result.CacheHit)
+	}
+}
+
+func TestGetRecommendationsTruncatedNotCached(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 10)
+	c := servicetest.NewFakeCache()
+	scorer := &servicetest.FakeScorer{Truncated: true}
+	svc := newTestService(t, repo, c, scorer, Config{ScoringTimeout: time.Second})
+	ctx := context.Background()
+
+	result, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("expected truncated success, got %v", err)
+	}
+	if !result.Truncated || len(result.Recommendations) != 5 {
+		t.Errorf("expected 5 truncated results, got truncated=%v len=%d", result.Truncated, len(result.Recommendations))
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected truncated result not to be cached, got %d entries", c.Len())
+	}
+
+	// A complete scoring run is cached again
+	scorer.Truncated = false
+	result, err = svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	if result.Truncated || c.Len() != 1 {
+		t.Errorf("expected a complete, cached result, got truncated=%v entries=%d", result.Truncated, c.Len())
+	}
+}
+
+func TestGetRecommendationsUserNotFoundStillFails(t *testing.T) {
+	svc, repo := newFakeService(t, &servicetest.FakeScorer{})
+	repo.Errors["GetUserWatchHistoryWithGenres"] = errors.New("connection reset")
+
+	_, err := svc.GetRecommendations(context.Background(), 99, domain.RecommendationOptions{Limit: 3})
+	if !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestGetBatchRecommendationsDatabaseUnavailable(t *testing.T) {
+	svc, repo := newFakeService(t, &servicetest.FakeScorer{})
+	repo.UserErrors[2] = &domain.RepositoryError{
+		Op: "query user id=2", Err: errors.New("connection reset"), Unavailable: true,
+	}
+
+	resp, err := svc.GetBatchRecommendations(context.Background(), 1, 5, batchRecLimit, "", false)
+	if err != nil {
+		t.Fatalf("get batch recommendations: %v", err)
+	}
+	for _, result := range resp.Results {
+		if result.UserID == 2 && result.Error != "database_unavailable" {
+			t.Errorf("expected database_unavailable for user 2, got %q", result.Error)
+		}
+	}
+}
+
+func TestCreateContentRejectsUnknownGenre(t *testing.T) {
+	svc, repo := newFakeService(t, &servicetest.FakeScorer{})
+	before := len(repo.Content)
+
+	_, err := svc.CreateContent(context.Background(), "Stagecoach", "western", 0.5)
+	if !errors.Is(err, domain.ErrInvalidGenre) {
+		t.Fatalf("expected ErrInvalidGenre, got %v", err)
+	}
+	if len(repo.Content) != before {
+		t.Error("expected content with an unknown genre not to be stored")
+	}
+
+	if _, err := svc.CreateContent(context.Background(), "Unforgiven", "drama", 0.5); err != nil {
+		t.Fatalf("create content with a canonical genre: %v", err)
+	}
+}
+
+func TestGetRecommendationsModelVersionThroughCache(t *testing.T) {
+	svc, _ := newFakeService(t, &servicetest.FakeScorer{})
+	ctx := context.Background()
+
+	miss, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 3})
+	if err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if miss.CacheHit || miss.ModelVersion != model.ModelVersion {
+		t.Fatalf("expected miss with version %q, got hit=%v version=%q", model.ModelVersion, miss.CacheHit, miss.ModelVersion)
+	}
+
+	hit, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 3})
+	if err != nil {
+		t.Fatalf("second call: %v", err)
+	}
+	if !hit.CacheHit || hit.ModelVersion != model.ModelVersion {
+		t.Errorf("expected hit with version %q, got hit=%v version=%q", model.ModelVersion, hit.CacheHit, hit.ModelVersion)
+	}
+}
+
+func TestGetRecommendationsCacheHitReportsStoredVersion(t *testing.T) {
+	c := servicetest.NewFakeCache()
+	svc := newTestService(t, servicetest.NewFakeRepo(), c, &servicetest.FakeScorer{}, Config{})
+	ctx := context.Background()
+
+	// An entry generated by an older scoring version keeps reporting it
+	entry := domain.CachedRecommendations{
+		ModelVersion:    "0.9.0",
+		Recommendations: []domain.ScoredRecommendation{{ContentID: 7, Score: 0.9}},
+	}
+	if err := c.Set(ctx, 1, 5, "", entry); err != nil {
+		t.Fatalf("seed cache: %v", err)
+	}
+
+	result, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	if !result.CacheHit || result.ModelVersion != "0.9.0" {
+		t.Errorf("expected hit with stored version 0.9.0, got hit=%v version=%q", result.CacheHit, result.ModelVersion)
+	}
+}
+
+func TestGetRecommendationsReusesRecentGeneration(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 20)
+	scorer := &servicetest.FakeScorer{}
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), scorer, Config{MinRegenInterval: 5 * time.Minute})
+	ctx := context.Background()
+
+	first, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 3})
+	if err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if first.CacheHit || len(first.Recommendations) != 3 {
+		t.Fatalf("expected 3 fresh recommendations, got hit=%v len=%d", first.CacheHit, len(first.Recommendations))
+	}
+
+	// A different limit misses its cache entry but reuses the generation
+	second, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 7})
+	if err != nil {
+		t.Fatalf("second call: %v", err)
+	}
+	if scorer.Calls() != 1 {
+		t.Errorf("expected the generation to be reused, got %d scorer calls", scorer.Calls())
+	}
+	if !second.CacheHit || len(second.Recommendations) != 7 {
+		t.Fatalf("expected 7 reused recommendations, got hit=%v len=%d", second.CacheHit, len(second.Recommendations))
+	}
+	for i, rec := range first.Recommendations {
+		if second.Recommendations[i].ContentID != rec.ContentID {
+			t.Errorf("position %d: expected shorter list to be a prefix, got %d and %d", i, rec.ContentID, second.Recommendations[i].ContentID)
+		}
+	}
+
+	// Other options are generated separately
+	if _, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 7, Normalize: true}); err != nil {
+		t.Fatalf("normalized call: %v", err)
+	}
+	if scorer.Calls() != 2 {
+		t.Errorf("expected a new generation for other options, got %d scorer calls", scorer.Calls())
+	}
+
+	// Invalidating the user drops the generation too
+	if _, err := svc.InvalidateUserCache(ctx, 1); err != nil {
+		t.Fatalf("invalidate: %v", err)
+	}
+	if _, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 7}); err != nil {
+		t.Fatalf("call after invalidation: %v", err)
+	}
+	if scorer.Calls() != 3 {
+		t.Errorf("expected regeneration after invalidation, got %d scorer calls", scorer.Calls())
+	}
+}
+
+func TestGetRecommendationsRegeneratesAfterInterval(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 20)
+	c := servicetest.NewFakeCache()
+	scorer := &servicetest.FakeScorer{}
+	svc := newTestService(t, repo, c, scorer, Config{MinRegenInterval: 5 * time.Minute})
+	ctx := context.Background()
+
+	opts, _ := svc.resolveOptions(1, domain.RecommendationOptions{Limit: 3})
+	stale := domain.Generation{
+		GeneratedAt:     time.Now().Add(-10 * time.Minute),
+		ModelVersion:    model.ModelVersion,
+		Recommendations: []domain.ScoredRecommendation{{ContentID: 99}},
+	}
+	if err := c.SetLastGenerated(ctx, 1, opts.Variant(), stale, time.Minute); err != nil {
+		t.Fatalf("seed generation: %v", err)
+	}
+
+	result, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 3})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	if result.CacheHit || scorer.Calls() != 1 {
+		t.Fatalf("expected regeneration, got hit=%v calls=%d", result.CacheHit, scorer.Calls())
+	}
+	if len(result.Recommendations) != 3 || result.Recommendations[0].ContentID == 99 {
+		t.Errorf("expected fresh recommendations, got %+v", result.Recommendations)
+	}
+
+	// The new generation replaces the stale one
+	gen, found, err := c.GetLastGenerated(ctx, 1, opts.Variant())
+	if err != nil || !found || time.Since(gen.GeneratedAt) > time.Minute {
+		t.Fatalf("expected a fresh stored generation, got %+v found=%v err=%v", gen, found, err)
+	}
+	if len(gen.Recommendations) != 20 {
+		t.Errorf("expected the generation to hold up to %d items, got %d", MaxLimit, len(gen.Recommendations))
+	}
+}
+
+func TestGetRecommendationsExhaustedCatalog(t *testing.T) {
+	svc, repo := newFakeService(t, &servicetest.FakeScorer{})
+	ctx := context.Background()
+
+	// User 1 watches every seeded item, user 2 nothing
+	for contentID := int64(1); contentID <= 20; contentID++ {
+		if err := repo.AddWatchHistory(ctx, 1, contentID); err != nil {
+			t.Fatalf("add watch history: %v", err)
+		}
+	}
+
+	result, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 10})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	if len(result.Recommendations) != 0 || !result.ExhaustedCatalog {
+		t.Fatalf("expected an empty exhausted result, got %d recommendations exhausted=%v",
+			len(result.Recommendations), result.ExhaustedCatalog)
+	}
+
+	// The flag survives the cache
+	cached, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 10})
+	if err != nil {
+		t.Fatalf("cached call: %v", err)
+	}
+	if !cached.CacheHit || !cached.ExhaustedCatalog {
+		t.Errorf("expected exhausted cache hit, got hit=%v exhausted=%v", cached.CacheHit, cached.ExhaustedCatalog)
+	}
+
+	other, err := svc.GetRecommendations(ctx, 2, domain.RecommendationOptions{Limit: 10})
+	if err != nil {
+		t.Fatalf("get recommendations for user 2: %v", err)
+	}
+	if other.ExhaustedCatalog {
+		t.Error("expected a user with unwatched content not to be exhausted")
+	}
+}
+
+func TestGetBatchRecommendationsPerUserLimit(t *testing.T) {
+	svc, _ := newFakeService(t, &servicetest.FakeScorer{})
+	ctx := context.Background()
+
+	for _, perUser := range []int{1, 3, 20} {
+		result, err := svc.GetBatchRecommendations(ctx, 1, 5, perUser, "", false)
+		if err != nil {
+			t.Fatalf("per user %d: %v", perUser, err)
+		}
+		if result.PerUserLimit != perUser {
+			t.Errorf("per user %d: expected it echoed, got %d", perUser, result.PerUserLimit)
+		}
+		for _, r := range result.Results {
+			if len(r.Recommendations) != perUser {
+				t.Errorf("per user %d: user %d got %d recommendations", perUser, r.UserID, len(r.Recommendations))
+			}
+		}
+	}
+
+	results, err := svc.StreamBatchRecommendations(ctx, 1, 5, 2, false)
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	for r := range results {
+		if len(r.Recommendations) != 2 {
+			t.Errorf("stream: user %d got %d recommendations", r.UserID, len(r.Recommendations))
+		}
+	}
+}
+
+func TestGetRecommendationsSupersetReuse(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 20)
+	c := servicetest.NewFakeCache()
+	c.SupersetMaxLimit = MaxLimit
+	scorer := &servicetest.FakeScorer{}
+	svc := newTestService(t, repo, c, scorer, Config{})
+	ctx := context.Background()
+
+	full, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 10})
+	if err != nil {
+		t.Fatalf("limit 10: %v", err)
+	}
+
+	small, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 3})
+	if err != nil {
+		t.Fatalf("limit 3: %v", err)
+	}
+	if scorer.Calls() != 1 || !small.CacheHit {
+		t.Fatalf("expected limit 3 served from the limit 10 entry, got %d model calls, hit=%v", scorer.Calls(), small.CacheHit)
+	}
+	if !reflect.DeepEqual(small.Recommendations, full.Recommendations[:3]) {
+		t.Errorf("expected the first 3 of the limit 10 list, got %+v", small.Recommendations)
+	}
+}
+
+func TestGetRecommendationsFiltersContentByAge(t *testing.T) {
+	svc, repo := newFakeService(t, &servicetest.FakeScorer{})
+	ctx := context.Background()
+
+	// Every other item is R-rated; user 1 is a minor, user 2 an adult
+	for i := range repo.Content {
+		if i%2 == 0 {
+			repo.Content[i].ContentRating = "R"
+		}
+	}
+	repo.Featured = []int64{1, 2}
+	repo.Users[1].Age = 15
+
+	minor, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 20})
+	if err != nil {
+		t.Fatalf("get recommendations for a minor: %v", err)
+	}
+	if len(minor.Recommendations) != 10 {
+		t.Errorf("expected the 10 non-R items, got %d", len(minor.Recommendations))
+	}
+	for _, rec := range minor.Recommendations {
+		if rec.ContentID%2 == 1 {
+			t.Errorf("expected no R content for a minor, got %d (featured=%v)", rec.ContentID, rec.Featured)
+		}
+	}
+
+	adult, err := svc.GetRecommendations(ctx, 2, domain.RecommendationOptions{Limit: 20})
+	if err != nil {
+		t.Fatalf("get recommendations for an adult: %v", err)
+	}
+	if len(adult.Recommendations) != 20 {
+		t.Errorf("expected an adult to see all 20 items, got %d", len(adult.Recommendations))
+	}
+}
+
+func TestGetRecommendationsCustomRatingPolicy(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 4)
+	repo.Content[0].ContentRating = "PG-13"
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{}, Config{
+		RatingPolicy: domain.RatingPolicy{"PG-13": 13, "R": 18},
+	})
+	repo.Users[1].Age = 12
+
+	result, err := svc.GetRecommendations(context.Background(), 1, domain.RecommendationOptions{Limit: 10, IncludeWatched: true})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	if len(result.Recommendations) != 3 {
+		t.Fatalf("expected the 3 PG items, got %d", len(result.Recommendations))
+	}
+	for _, rec := range result.Recommendations {
+		if rec.ContentID == 1 {
+			t.Error("expected PG-13 content to be kept from a 12-year-old")
+		}
+	}
+}
+
+func TestGetBatchRecommendationsExplanations(t *testing.T) {
+	svc, repo := newFakeService(t, &servicetest.FakeScorer{})
+	ctx := context.Background()
+
+	// User 1 watches two action items and one drama; user 2 watches nothing
+	for _, contentID := range []int64{1, 6, 2} {
+		if err := repo.AddWatchHistory(ctx, 1, contentID); err != nil {
+			t.Fatalf("add watch history: %v", err)
+		}
+	}
+
+	plain, err := svc.GetBatchRecommendations(ctx, 1, 2, batchRecLimit, "", false)
+	if err != nil {
+		t.Fatalf("batch without explanations: %v", err)
+	}
+	for _, r := range plain.Results {
+		if r.TopGenres != nil {
+			t.Errorf("expected no explanation for user %d unless requested, got %v", r.UserID, r.TopGenres)
+		}
+	}
+
+	explained, err := svc.GetBatchRecommendations(ctx, 1, 2, batchRecLimit, "", true)
+	if err != nil {
+		t.Fatalf("batch with explanations: %v", err)
+	}
+	want := []domain.GenreWeight{{Genre: "action", Weight: 0.667}, {Genre: "drama", Weight: 0.333}}
+	for _, r := range explained.Results {
+		if r.UserID == 1 && !reflect.DeepEqual(r.TopGenres, want) {
+			t.Errorf("expected top genres %v for user 1, got %v", want, r.TopGenres)
+		}
+		if r.UserID == 2 && len(r.TopGenres) != 0 {
+			t.Errorf("expected no genres for a user without history, got %v", r.TopGenres)
+		}
+		if len(r.Recommendations) == 0 {
+			t.Errorf("expected recommendations alongside the explanation for user %d", r.UserID)
+		}
+	}
+
+	// A history failure drops the explanation, not the user
+	repo.Errors = map[string]error{"GetUserWatchHistoryWithGenres": errors.New("boom")}
+	results, err := svc.StreamBatchRecommendations(ctx, 1, 2, batchRecLimit, true)
+	if err != nil {
+		t.Fatalf("stream with explanations: %v", err)
+	}
+	for r := range results {
+		if r.Status != domain.StatusSuccess || r.TopGenres != nil {
+			t.Errorf("expected user %d to succeed without an explanation, got %s with %v", r.UserID, r.Status, r.TopGenres)
+		}
+	}
+}
+
+func TestTopGenres(t *testing.T) {
+	prefs := map[string]float64{"drama": 0.25, "action": 0.25, "comedy": 0.4, "sci-fi": 0.1}
+	want := []domain.GenreWeight{{Genre: "comedy", Weight: 0.4}, {Genre: "action", Weight: 0.25}, {Genre: "drama", Weight: 0.25}}
+	if got := topGenres(prefs, 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestGroupByGenre(t *testing.T) {
+	recs := []domain.ScoredRecommendation{
+		{ContentID: 1, Genre: "action", Featured: true},
+		{ContentID: 2, Genre: "action", Score: 0.9},
+		{ContentID: 3, Genre: "drama", Score: 0.8},
+		{ContentID: 4, Genre: "action", Score: 0.7},
+		{ContentID: 5, Genre: "comedy", Score: 0.6},
+		{ContentID: 6, Genre: "action", Score: 0.5},
+		{ContentID: 7, Genre: "drama", Score: 0.4},
+		{ContentID: 8, Genre: "drama", Score: 0.3},
+	}
+	prefs := map[string]float64{"drama": 0.7, "action": 0.3}
+
+	groups := groupByGenre(recs, prefs, 2)
+
+	want := []struct {
+		genre string
+		ids   []int64
+	}{
+		{"drama", []int64{3, 7}},
+		{"action", []int64{2, 4}},
+		{"comedy", []int64{5}},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("expected %d genres, got %+v", len(want), groups)
+	}
+	for i, w := range want {
+		g := groups[i]
+		var ids []int64
+		for _, r := range g.Recommendations {
+			ids = append(ids, r.ContentID)
+		}
+		if g.Genre != w.genre || !reflect.DeepEqual(ids, w.ids) {
+			t.Errorf("group %d: expected %s %v, got %s %v", i, w.genre, w.ids, g.Genre, ids)
+		}
+	}
+	if groups[0].Weight != 0.7 || groups[2].Weight != 0 {
+		t.Errorf("expected preference weights on groups, got %+v", groups)
+	}
+}
+
+// Cancels its context once it has scored `after` users
+type cancellingScorer struct {
+	servicetest.FakeScorer
+	after  int
+	cancel context.CancelFunc
+}
+
+func (s *cancellingScorer) Score(ctx context.Context, input model.ScoreInput) ([]domain.ScoredRecommendation, bool, error) {
+	recs, truncated, err := s.FakeScorer.Score(ctx, input)
+	if s.Calls() == s.after {
+		s.cancel()
+	}
+	return recs, truncated, err
+}
+
+func TestBatchRepeatedFailuresFlagUser(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(3, 10)
+	repo.UserErrors[2] = errors.New("missing profile")
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{}, Config{
+		BatchFailureThreshold: 2,
+		BatchFailureWindow:    time.Hour,
+	})
+	ctx := context.Background()
+
+	flaggedAfter := func(run int) []domain.FailedUser {
+		t.Helper()
+		resp, err := svc.GetBatchRecommendations(ctx, 1, 3, batchRecLimit, "", false)
+		if err != nil {
+			t.Fatalf("run %d: batch: %v", run, err)
+		}
+		if resp.Summary.FailedCount != 1 {
+			t.Fatalf("run %d: expected user 2 to fail, got %+v", run, resp.Summary)
+		}
+		users, err := svc.GetFailedUsers(ctx)
+		if err != nil {
+			t.Fatalf("run %d: get failed users: %v", run, err)
+		}
+		return users
+	}
+
+	for run := 1; run <= 2; run++ {
+		if users := flaggedAfter(run); len(users) != 0 {
+			t.Fatalf("run %d: expected no failed users within the threshold, got %+v", run, users)
+		}
+	}
+	users := flaggedAfter(3)
+	if len(users) != 1 || users[0].UserID != 2 || users[0].RecentFailures != 3 {
+		t.Errorf("expected only user 2 flagged after 3 failures, got %+v", users)
+	}
+}
+
+func TestBatchOutageFailuresNotCounted(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(2, 10)
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{Err: errors.New("model down")}, Config{
+		BatchFailureThreshold: 1,
+		BatchFailureWindow:    time.Hour,
+	})
+	ctx := context.Background()
+
+	for range 3 {
+		if resp, err := svc.GetBatchRecommendations(ctx, 1, 2, batchRecLimit, "", false); err != nil || resp.Summary.FailedCount != 2 {
+			t.Fatalf("expected every user to fail, got %+v, %v", resp, err)
+		}
+	}
+	if users, err := svc.GetFailedUsers(ctx); err != nil || len(users) != 0 {
+		t.Errorf("expected model failures not to flag users, got %+v, %v", users, err)
+	}
+}
+
+func TestClearFailedUser(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	repo.AddUsers(1)
+	repo.UserErrors[1] = errors.New("missing profile")
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{}, Config{
+		BatchFailureThreshold: 1,
+		BatchFailureWindow:    time.Hour,
+	})
+	ctx := context.Background()
+
+	for range 2 {
+		svc.GetBulkRecommendations(ctx, []int64{1}, 5)
+	}
+	if err := svc.ClearFailedUser(ctx, 1); err != nil {
+		t.Fatalf("clear failed user: %v", err)
+	}
+	if users, err := svc.GetFailedUsers(ctx); err != nil || len(users) != 0 {
+		t.Errorf("expected no failed users, got %+v, %v", users, err)
+	}
+	if err := svc.ClearFailedUser(ctx, 1); !errors.Is(err, domain.ErrFailedUserNotFound) {
+		t.Errorf("expected ErrFailedUserNotFound, got %v", err)
+	}
+}
+
+func TestBatchFailureTrackingDisabled(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	repo.AddUsers(1)
+	repo.UserErrors[1] = errors.New("missing profile")
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{}, Config{})
+	ctx := context.Background()
+
+	for range 5 {
+		svc.GetBulkRecommendations(ctx, []int64{1}, 5)
+	}
+	if users, err := svc.GetFailedUsers(ctx); err != nil || len(users) != 0 {
+		t.Errorf("expected no failed users without a threshold, got %+v, %v", users, err)
+	}
+}
+
+func TestGetBatchRecommendationsStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := servicetest.NewFakeRepoWith(8, 20)
+	scorer := &cancellingScorer{after: 3, cancel: cancel}
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), scorer, Config{BatchConcurrency: 1})
+
+	result, err := svc.GetBatchRecommendations(ctx, 1, 10, batchRecLimit, "", false)
+	if err != nil {
+		t.Fatalf("get batch: %v", err)
+	}
+
+	if len(result.Results) != 8 {
+		t.Fatalf("expected a result for all 8 users, got %d", len(result.Results))
+	}
+	if result.Summary.SuccessCount != 3 || result.Summary.CancelledCount != 5 || result.Summary.FailedCount != 0 {
+		t.Errorf("expected 3 success / 5 cancelled, got %+v", result.Summary)
+	}
+	scored := map[int64]bool{}
+	for _, id := range scorer.ScoredUsers() {
+		scored[id] = true
+	}
+	for _, r := range result.Results {
+		if scored[r.UserID] != (r.Status == domain.StatusSuccess) {
+			t.Errorf("user %d: status %s does not match whether it was scored", r.UserID, r.Status)
+		}
+		if r.Status == domain.StatusCancelled && (r.Error != "" || r.Recommendations != nil) {
+			t.Errorf("expected cancelled user %d to carry no error or recommendations, got %+v", r.UserID, r)
+		}
+	}
+	if scorer.Calls() != 3 {
+		t.Errorf("expected no model calls after cancellation, got %d calls", scorer.Calls())
+	}
+}
+
+func TestGetBatchRecommendationsWithProgressCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := servicetest.NewFakeRepoWith(8, 20)
+	scorer := &cancellingScorer{after: 3, cancel: cancel}
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), scorer, Config{BatchConcurrency: 1})
+
+	progress, done, err := svc.GetBatchRecommendationsWithProgress(ctx, 1, 10, batchRecLimit, false)
+	if err != nil {
+		t.Fatalf("get batch with progress: %v", err)
+	}
+	// Cancelled users still count as processed, so progress reaches the total
+	var last domain.BatchProgress
+	for p := range progress {
+		if p.Processed != last.Processed+1 {
+			t.Errorf("expected progress to count up by one, got %d after %d", p.Processed, last.Processed)
+		}
+		last = p
+	}
+	if last.Processed != 8 || last.Total != 8 {
+		t.Errorf("expected final progress 8/8, got %d/%d", last.Processed, last.Total)
+	}
+
+	result := <-done
+	if result.Summary.SuccessCount != 3 || result.Summary.CancelledCount != 5 {
+		t.Errorf("expected 3 success / 5 cancelled, got %+v", result.Summary)
+	}
+	if scorer.Calls() != 3 {
+		t.Errorf("expected no model calls after cancellation, got %d calls", scorer.Calls())
+	}
+}
+
+func TestGetRecommendationsPopularityFloor(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 4)
+	repo.Content[0].PopularityScore = 0.01
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{}, Config{MinPopularity: domain.DefaultMinPopularity})
+	ctx := context.Background()
+
+	result, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 10})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	if len(result.Recommendations) != 3 {
+		t.Fatalf("expected the 3 items above the floor, got %d", len(result.Recommendations))
+	}
+	for _, rec := range result.Recommendations {
+		if rec.ContentID == 1 {
+			t.Error("expected content below the default popularity floor to be excluded")
+		}
+	}
+
+	// The per-request override sees the whole catalog
+	result, err = svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 10, IgnorePopularityFloor: true})
+	if err != nil {
+		t.Fatalf("get recommendations ignoring the floor: %v", err)
+	}
+	if len(result.Recommendations) != 4 {
+		t.Errorf("expected all 4 items ignoring the floor, got %d", len(result.Recommendations))
+	}
+}
+
+func TestGetRecommendationsMaxPerGenre(t *testing.T) {
+	svc, repo := newFakeService(t, &servicetest.FakeScorer{})
+	ctx := context.Background()
+	// The eight highest scoring items are all action
+	for i := range 8 {
+		repo.Content[i].Genre = "action"
+	}
+
+	result, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5, MaxPerGenre: 1})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	if len(result.Recommendations) != 5 {
+		t.Fatalf("expected the limit to be filled from other genres, got %d items", len(result.Recommendations))
+	}
+	seen := make(map[string]bool)
+	for _, rec := range result.Recommendations {
+		if seen[rec.Genre] {
+			t.Errorf("expected at most one %s item, got %+v", rec.Genre, result.Recommendations)
+		}
+		seen[rec.Genre] = true
+	}
+	if result.Recommendations[0].ContentID != 1 {
+		t.Errorf("expected the top action item to lead, got content %d", result.Recommendations[0].ContentID)
+	}
+
+	// Without the cap the top of the list is all action
+	result, err = svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("get uncapped recommendations: %v", err)
+	}
+	for _, rec := range result.Recommendations {
+		if rec.Genre != "action" {
+			t.Errorf("expected only action items without a cap, got %s", rec.Genre)
+		}
+	}
+}
+
+func TestGetRecommendationsMaxPerGenreCountsFeatured(t *testing.T) {
+	svc, repo := newFakeService(t, &servicetest.FakeScorer{})
+	ctx := context.Background()
+	// The top scored items and a featured one are all action
+	for i := range 8 {
+		repo.Content[i].Genre = "action"
+	}
+	repo.Content[9].Genre = "action"
+	repo.Featured = []int64{10}
+
+	result, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5, MaxPerGenre: 1})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	recs := result.Recommendations
+	if len(recs) != 5 {
+		t.Fatalf("expected 5 recommendations, got %d", len(recs))
+	}
+	// The featured item is still pinned first and takes the only action slot
+	if recs[0].ContentID != 10 || !recs[0].Featured {
+		t.Errorf("expected featured content 10 first, got %+v", recs[0])
+	}
+	seen := make(map[string]bool)
+	for _, rec := range recs {
+		if seen[rec.Genre] {
+			t.Errorf("expected at most one %s item including featured ones, got %+v", rec.Genre, recs)
+		}
+		seen[rec.Genre] = true
+	}
+}
+
+func TestCapPerGenre(t *testing.T) {
+	recs := []domain.ScoredRecommendation{
+		{ContentID: 1, Genre: "action"},
+		{ContentID: 2, Genre: "action"},
+		{ContentID: 3, Genre: "action"},
+		{ContentID: 4, Genre: "drama"},
+		{ContentID: 5, Genre: "action"},
+		{ContentID: 6, Genre: "drama"},
+		{ContentID: 7, Genre: "comedy"},
+	}
+	cases := []struct {
+		maxPerGenre, limit int
+		want               []int64
+	}{
+		{1, 10, []int64{1, 4, 7}},
+		{2, 10, []int64{1, 2, 4, 6, 7}},
+		{2, 3, []int64{1, 2, 4}},
+		{5, 10, []int64{1, 2, 3, 4, 5, 6, 7}},
+	}
+	for _, tc := range cases {
+		got := capPerGenre(recs, tc.maxPerGenre, tc.limit)
+		ids := make([]int64, 0, len(got))
+		for _, rec := range got {
+			ids = append(ids, rec.ContentID)
+		}
+		if !reflect.DeepEqual(ids, tc.want) {
+			t.Errorf("capPerGenre(max %d, limit %d) = %v, want %v", tc.maxPerGenre, tc.limit, ids, tc.want)
+		}
+	}
+}
+
+func TestGetRecommendationsUserIDOutOfRange(t *testing.T) {
+	svc, repo := newFakeService(t, &servicetest.FakeScorer{})
+	ctx := context.Background()
+	// A user query for this ID would fail with something other than not found
+	repo.UserErrors[999] = errors.New("user query for an out-of-range id")
+
+	if _, err := svc.GetRecommendations(ctx, 999, domain.RecommendationOptions{}); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound without a user query, got %v", err)
+	}
+	if _, _, err := svc.GetWatchHistory(ctx, 999, 10); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound for watch history, got %v", err)
+	}
+
+	// The bound is cached, so users added since stay out of range...
+	repo.AddUsers(8)
+	repo.Errors["MaxUserID"] = errors.New("lookup after the bound was cached")
+	if _, err := svc.GetRecommendations(ctx, 7, domain.RecommendationOptions{}); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected the cached bound to reject user 7, got %v", err)
+	}
+	// ...except users created through the service, which extend it
+	user, err := svc.CreateUser(ctx, 30, "US", "basic")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := svc.GetRecommendations(ctx, user.ID, domain.RecommendationOptions{}); err != nil {
+		t.Errorf("expected the created user %d to be found, got %v", user.ID, err)
+	}
+}
+
+func TestUserIDBoundLookupFailure(t *testing.T) {
+	svc, repo := newFakeService(t, &servicetest.FakeScorer{})
+	repo.Errors["MaxUserID"] = errors.New("boom")
+
+	// Without a bound every ID goes to the user query
+	if _, err := svc.GetRecommendations(context.Background(), 2, domain.RecommendationOptions{}); err != nil {
+		t.Errorf("expected user 2 to be found when the bound lookup fails, got %v", err)
+	}
+}
+
+func TestConfiguredMaxUserID(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(5, 5)
+	repo.Errors["MaxUserID"] = errors.New("no lookup within MAX_USER_ID")
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{}, Config{MaxUserID: 3})
+	ctx := context.Background()
+
+	if _, err := svc.GetRecommendations(ctx, 3, domain.RecommendationOptions{}); err != nil {
+		t.Errorf("expected user 3 within MAX_USER_ID to be found, got %v", err)
+	}
+
+	// Past the configured floor the highest user ID decides
+	delete(repo.Errors, "MaxUserID")
+	if _, err := svc.GetRecommendations(ctx, 5, domain.RecommendationOptions{}); err != nil {
+		t.Errorf("expected user 5 past MAX_USER_ID to be found, got %v", err)
+	}
+	if _, err := svc.GetRecommendations(ctx, 6, domain.RecommendationOptions{}); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected user 6 past every user to be not found, got %v", err)
+	}
+	user, err := svc.CreateUser(ctx, 30, "US", "basic")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := svc.GetRecommendations(ctx, user.ID, domain.RecommendationOptions{}); err != nil {
+		t.Errorf("expected the created user %d to be found, got %v", user.ID, err)
+	}
+}
+
+// Repo whose MaxUserID blocks until release is closed, counting calls
+type slowMaxUserIDRepo struct {
+	*servicetest.FakeRepo
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (r *slowMaxUserIDRepo) MaxUserID(ctx context.Context) (int64, error) {
+	r.calls.Add(1)
+	<-r.release
+	return r.FakeRepo.MaxUserID(ctx)
+}
+
+func TestUserIDBoundLookupSharedAcrossCallers(t *testing.T) {
+	fake := servicetest.NewFakeRepo()
+	fake.AddUsers(3)
+	repo := &slowMaxUserIDRepo{FakeRepo: fake, release: make(chan struct{})}
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{}, Config{})
+
+	var wg sync.WaitGroup
+	outOfRange := make([]bool, 10)
+	for i := range outOfRange {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			outOfRange[i] = svc.userIDOutOfRange(context.Background(), int64(i))
+		}()
+	}
+	// The lock isn't held across the lookup, so creating a user doesn't wait on it
+	time.Sleep(10 * time.Millisecond)
+	svc.extendUserIDBound(3)
+	close(repo.release)
+	wg.Wait()
+
+	if calls := repo.calls.Load(); calls != 1 {
+		t.Errorf("expected concurrent callers to share one lookup, got %d", calls)
+	}
+	for i, got := range outOfRange {
+		if want := i > 3; got != want {
+			t.Errorf("user %d: expected out of range %v, got %v", i, want, got)
+		}
+	}
+}
+
+func TestGetRecommendationsPagesFullList(t *testing.T) {
+	scorer := &servicetest.FakeScorer{}
+	svc, _ := newFakeService(t, scorer)
+	ctx := context.Background()
+
+	first, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5, Paged: true})
+	if err != nil {
+		t.Fatalf("page 1: %v", err)
+	}
+	second, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5, Paged: true, Offset: 5})
+	if err != nil {
+		t.Fatalf("page 2: %v", err)
+	}
+	if len(first.Recommendations) != 5 || len(second.Recommendations) != 5 {
+		t.Fatalf("expected two pages of 5, got %d and %d", len(first.Recommendations), len(second.Recommendations))
+	}
+	if first.CacheHit || !second.CacheHit {
+		t.Errorf("expected page 1 to score and page 2 to come from cache, got cache hits %t/%t", first.CacheHit, second.CacheHit)
+	}
+	if scorer.Calls() != 1 {
+		t.Errorf("expected one scoring run for both pages, got %d", scorer.Calls())
+	}
+	// Both report the full list's length, scored or cached
+	if first.Total != 20 || second.Total != 20 {
+		t.Errorf("expected a total of 20 on both pages, got %d and %d", first.Total, second.Total)
+	}
+
+	// The pages are adjacent windows: no overlap, scores descending across them
+	both := append(append([]domain.ScoredRecommendation(nil), first.Recommendations...), second.Recommendations...)
+	seen := map[int64]bool{}
+	for i, rec := range both {
+		if seen[rec.ContentID] {
+			t.Errorf("content %d is on both pages", rec.ContentID)
+		}
+		seen[rec.ContentID] = true
+		if i > 0 && rec.Score > both[i-1].Score {
+			t.Errorf("expected descending scores, item %d scored %f after %f", i, rec.Score, both[i-1].Score)
+		}
+	}
+
+	// Past the end of the 20 candidates is an empty page
+	past, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5, Paged: true, Offset: 40})
+	if err != nil || len(past.Recommendations) != 0 {
+		t.Errorf("expected an empty page past the end, got %v err=%v", past, err)
+	}
+}
+
+// Cancels the request mid-scoring, as a disconnecting client does
+type cancelDuringScore struct {
+	servicetest.FakeScorer
+	cancel context.CancelFunc
+}
+
+func (s *cancelDuringScore) Score(ctx context.Context, input model.ScoreInput) ([]domain.ScoredRecommendation, bool, error) {
+	s.cancel()
+	return nil, false, ctx.Err()
+}
+
+func TestBatchUserCancelledDuringScoring(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 5)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), &cancelDuringScore{cancel: cancel}, Config{
+		BatchFailureThreshold: 1,
+		BatchFailureWindow:    time.Hour,
+	})
+
+	if result := svc.processUserForBatch(ctx, 1, 5, false); result.Status != domain.StatusCancelled {
+		t.Errorf("expected cancelled, got %+v", result)
+	}
+	users, err := svc.GetFailedUsers(context.Background())
+	if err != nil {
+		t.Fatalf("get failed users: %v", err)
+	}
+	if len(users) != 0 {
+		t.Errorf("expected a cancelled user not to count as failing, got %+v", users)
+	}
+}
+
+func TestCategorizeScoringTimeout(t *testing.T) {
+	err := fmt.Errorf("score recommendations for user 1: %w: %w", domain.ErrModelUnavailable, context.DeadlineExceeded)
+	if code, _ := categorizeError(err); code != "request_timeout" {
+		t.Errorf("expected request_timeout, got %q", code)
+	}
+	if code, _ := categorizeError(fmt.Errorf("score: %w", domain.ErrModelUnavailable)); code != "model_inference_error" {
+		t.Errorf("expected model_inference_error, got %q", code)
+	}
+}
+
+func TestReseedClearsCachedRecommendations(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 10)
+	c := servicetest.NewFakeCache()
+	svc := newTestService(t, repo, c, &servicetest.FakeScorer{}, Config{})
+	ctx := context.Background()
+
+	if _, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5}); err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	if _, err := svc.Reseed(ctx, seeds.SeedConfig{Users: 1, Content: 3}); err != nil {
+		t.Fatalf("reseed: %v", err)
+	}
+	if c.Len() != 0 {
+		t.Fatalf("expected the cache cleared by the reseed, got %d entries", c.Len())
+	}
+
+	result, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	if result.CacheHit || len(result.Recommendations) != 3 {
+		t.Errorf("expected a fresh list over the 3 reseeded items, got hit=%v %d items", result.CacheHit, len(result.Recommendations))
+	}
+}
+
+func TestDeleteContentClearsCachedRecommendations(t *testing.T) {
+	repo := servicetest.NewFakeRepoWith(1, 10)
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{}, Config{})
+	ctx := context.Background()
+
+	first, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5})
+	if err != nil || len(first.Recommendations) == 0 {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	deleted := first.Recommendations[0].ContentID
+	if err := svc.DeleteContent(ctx, deleted); err != nil {
+		t.Fatalf("delete content: %v", err)
+	}
+
+	second, err := svc.GetRecommendations(ctx, 1, domain.RecommendationOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	if second.CacheHit {
+		t.Error("expected the cached list dropped by the delete")
+	}
+	for _, rec := range second.Recommendations {
+		if rec.ContentID == deleted {
+			t.Errorf("expected deleted content %d left out, got %+v", deleted, second.Recommendations)
+		}
+	}
+}
@@ -0,0 +1,999 @@
+// Package servicetest provides in-memory fakes for service dependencies.
+package servicetest
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/actuallystonmai/recommendation-service/internal/model"
+	"github.com/actuallystonmai/recommendation-service/seeds"
+)
+
+// FakeRepo is an in-memory repository. Errors in Errors are returned by the
+// method of the same name; UserErrors fail GetUserByID for specific users.
+type FakeRepo struct {
+	mu       sync.Mutex
+	Users    map[int64]*domain.User
+	Content  []domain.Content
+	History  map[int64][]domain.WatchHistoryItem
+	Regional map[string]map[int64]float64
+	// Active featured content IDs, highest priority first
+	Featured []int64
+	// Blocked content IDs per user
+	Blocked map[int64]map[int64]bool
+	// Soft-deleted content IDs; the items stay in Content
+	Deleted    map[int64]bool
+	Errors     map[string]error
+	UserErrors map[int64]error
+}
+
+func NewFakeRepo() *FakeRepo {
+	return &FakeRepo{
+		Users:      make(map[int64]*domain.User),
+		History:    make(map[int64][]domain.WatchHistoryItem),
+		Blocked:    make(map[int64]map[int64]bool),
+		Deleted:    make(map[int64]bool),
+		Regional:   make(map[string]map[int64]float64),
+		Errors:     make(map[string]error),
+		UserErrors: make(map[int64]error),
+	}
+}
+
+// NewFakeRepoWith returns a repository holding users 1..users and content
+// 1..content, as registered by AddUsers and AddContent
+func NewFakeRepoWith(users, content int) *FakeRepo {
+	f := NewFakeRepo()
+	f.AddUsers(users)
+	f.AddContent(content)
+	return f
+}
+
+// AddUsers registers users with IDs 1..n
+func (f *FakeRepo) AddUsers(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := 1; i <= n; i++ {
+		id := int64(i)
+		f.Users[id] = &domain.User{ID: id, Age: 30, Country: "US", SubscriptionType: "basic"}
+	}
+}
+
+// AddContent registers content with IDs 1..n, rotating genres, rated PG and
+// with popularity decreasing by ID
+func (f *FakeRepo) AddContent(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	genres := domain.Genres
+	for i := 1; i <= n; i++ {
+		f.Content = append(f.Content, domain.Content{
+			ID:              int64(i),
+			Title:           fmt.Sprintf("Title %d", i),
+			Genre:           genres[(i-1)%len(genres)],
+			PopularityScore: 1.0 - float64(i)/float64(n+1),
+			CreatedAt:       time.Now().AddDate(0, 0, -i),
+			DurationMinutes: 90 + i,
+			ReleaseYear:     2000 + i,
+			ContentRating:   "PG",
+		})
+	}
+}
+
+func (f *FakeRepo) contentByID(contentID int64) (domain.Content, bool) {
+	for _, c := range f.Content {
+		if c.ID == contentID {
+			return c, true
+		}
+	}
+	return domain.Content{}, false
+}
+
+func (f *FakeRepo) watched(userID, contentID int64) bool {
+	for _, item := range f.History[userID] {
+		if item.ContentID == contentID {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FakeRepo) GetUserByID(_ context.Context, userID int64) (*domain.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["GetUserByID"]; err != nil {
+		return nil, err
+	}
+	if err := f.UserErrors[userID]; err != nil {
+		return nil, err
+	}
+	user, ok := f.Users[userID]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (f *FakeRepo) GetUserWatchHistoryWithGenres(_ context.Context, userID int64, limit int) ([]domain.WatchHistoryItem, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["GetUserWatchHistoryWithGenres"]; err != nil {
+		return nil, err
+	}
+	items := append([]domain.WatchHistoryItem(nil), f.History[userID]...)
+	sort.Slice(items, func(i, j int) bool { return items[i].WatchedAt.After(items[j].WatchedAt) })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+func (f *FakeRepo) GetUnwatchedContent(_ context.Context, userID int64, limit int, order domain.CandidateOrder, ratings []string, minPopularity float64) ([]domain.Content, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["GetUnwatchedContent"]; err != nil {
+		return nil, err
+	}
+	var items []domain.Content
+	for _, c := range f.Content {
+		if !f.Deleted[c.ID] && !f.watched(userID, c.ID) && !f.Blocked[userID][c.ID] && slices.Contains(ratings, c.ContentRating) && c.PopularityScore >= minPopularity {
+			items = append(items, c)
+		}
+	}
+	sortCandidates(items, order)
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+// Sort candidates the way the repository's ORDER BY does
+func sortCandidates(items []domain.Content, order domain.CandidateOrder) {
+	switch order {
+	case domain.CandidateOrderRecency:
+		sort.SliceStable(items, func(i, j int) bool { return items[i].CreatedAt.After(items[j].CreatedAt) })
+	case domain.CandidateOrderRandom:
+		rand.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+	default:
+		sort.SliceStable(items, func(i, j int) bool { return items[i].PopularityScore > items[j].PopularityScore })
+	}
+}
+
+func (f *FakeRepo) GetFeaturedContent(_ context.Context, userID int64, limit int, ratings []string) ([]domain.Content, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["GetFeaturedContent"]; err != nil {
+		return nil, err
+	}
+	var items []domain.Content
+	for _, id := range f.Featured {
+		if c, ok := f.contentByID(id); ok && !f.Deleted[id] && !f.watched(userID, id) && !f.Blocked[userID][id] && slices.Contains(ratings, c.ContentRating) {
+			items = append(items, c)
+		}
+	}
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+func (f *FakeRepo) InsertContent(_ context.Context, c domain.Content) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["InsertContent"]; err != nil {
+		return 0, err
+	}
+	c.ID = int64(len(f.Content) + 1)
+	f.Content = append(f.Content, c)
+	return c.ID, nil
+}
+
+// Reseed replaces users and content with cfg.Users users and cfg.Content
+// items; watch history, regional popularity and blocklists are cleared.
+func (f *FakeRepo) Reseed(_ context.Context, cfg seeds.SeedConfig) (seeds.SeedSummary, error) {
+	f.mu.Lock()
+	if err := f.Errors["Reseed"]; err != nil {
+		f.mu.Unlock()
+		return seeds.SeedSummary{}, err
+	}
+	f.Users = make(map[int64]*domain.User)
+	f.Content = nil
+	f.History = make(map[int64][]domain.WatchHistoryItem)
+	f.Regional = make(map[string]map[int64]float64)
+	f.Blocked = make(map[int64]map[int64]bool)
+	f.Deleted = make(map[int64]bool)
+	f.mu.Unlock()
+
+	f.AddUsers(cfg.Users)
+	f.AddContent(cfg.Content)
+	return seeds.SeedSummary{Users: cfg.Users, Content: cfg.Content}, nil
+}
+
+func (f *FakeRepo) GetAllContent(_ context.Context, userID int64, limit int, order domain.CandidateOrder, ratings []string, minPopularity float64) ([]domain.Content, map[int64]bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["GetAllContent"]; err != nil {
+		return nil, nil, err
+	}
+	var items []domain.Content
+	for _, c := range f.Content {
+		if !f.Deleted[c.ID] && !f.Blocked[userID][c.ID] && slices.Contains(ratings, c.ContentRating) && c.PopularityScore >= minPopularity {
+			items = append(items, c)
+		}
+	}
+	sortCandidates(items, order)
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	watched := make(map[int64]bool)
+	for _, c := range items {
+		if f.watched(userID, c.ID) {
+			watched[c.ID] = true
+		}
+	}
+	return items, watched, nil
+}
+
+func (f *FakeRepo) GetContentByID(_ context.Context, contentID int64) (*domain.Content, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["GetContentByID"]; err != nil {
+		return nil, err
+	}
+	c, ok := f.contentByID(contentID)
+	if !ok || f.Deleted[contentID] {
+		return nil, domain.ErrContentNotFound
+	}
+	return &c, nil
+}
+
+func (f *FakeRepo) SoftDeleteContent(_ context.Context, contentID int64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["SoftDeleteContent"]; err != nil {
+		return false, err
+	}
+	if _, ok := f.contentByID(contentID); !ok || f.Deleted[contentID] {
+		return false, nil
+	}
+	f.Deleted[contentID] = true
+	return true, nil
+}
+
+func (f *FakeRepo) GetRegionalPopularity(_ context.Context, country string, contentIDs []int64) (map[int64]float64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["GetRegionalPopularity"]; err != nil {
+		return nil, err
+	}
+	scores := make(map[int64]float64)
+	for _, id := range contentIDs {
+		if score, ok := f.Regional[country][id]; ok {
+			scores[id] = score
+		}
+	}
+	return scores, nil
+}
+
+func (f *FakeRepo) SearchContentByTitle(_ context.Context, query string, limit int) ([]domain.Content, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["SearchContentByTitle"]; err != nil {
+		return nil, err
+	}
+	items := []domain.Content{}
+	for _, c := range f.Content {
+		if !f.Deleted[c.ID] && strings.Contains(strings.ToLower(c.Title), strings.ToLower(query)) {
+			items = append(items, c)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].PopularityScore > items[j].PopularityScore })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+func (f *FakeRepo) ListContent(_ context.Context, filter domain.ContentFilter) ([]domain.Content, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["ListContent"]; err != nil {
+		return nil, 0, err
+	}
+	items := []domain.Content{}
+	for _, c := range f.Content {
+		if !f.Deleted[c.ID] && (filter.Genre == "" || c.Genre == filter.Genre) && c.PopularityScore >= filter.MinPopularity {
+			items = append(items, c)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if filter.Sort == domain.ContentSortCreatedAt {
+			return items[i].CreatedAt.After(items[j].CreatedAt)
+		}
+		return items[i].PopularityScore > items[j].PopularityScore
+	})
+
+	total := len(items)
+	offset := (filter.Page - 1) * filter.Limit
+	if offset >= total {
+		return []domain.Content{}, total, nil
+	}
+	return items[offset:min(offset+filter.Limit, total)], total, nil
+}
+
+func (f *FakeRepo) GetTrendingContent(_ context.Context, since time.Time, limit int) ([]domain.TrendingContent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["GetTrendingContent"]; err != nil {
+		return nil, err
+	}
+	counts := make(map[int64]int)
+	for _, items := range f.History {
+		for _, item := range items {
+			if !item.WatchedAt.Before(since) {
+				counts[item.ContentID]++
+			}
+		}
+	}
+	trending := []domain.TrendingContent{}
+	for _, c := range f.Content {
+		if counts[c.ID] > 0 && !f.Deleted[c.ID] {
+			trending = append(trending, domain.TrendingContent{Content: c, RecentWatches: counts[c.ID]})
+		}
+	}
+	sort.SliceStable(trending, func(i, j int) bool {
+		if trending[i].RecentWatches != trending[j].RecentWatches {
+			return trending[i].RecentWatches > trending[j].RecentWatches
+		}
+		return trending[i].PopularityScore > trending[j].PopularityScore
+	})
+	if len(trending) > limit {
+		trending = trending[:limit]
+	}
+	return trending, nil
+}
+
+func (f *FakeRepo) GetUserIDsPaginated(_ context.Context, page, limit int) ([]int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["GetUserIDsPaginated"]; err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(f.Users))
+	for id := range f.Users {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	offset := (page - 1) * limit
+	if offset >= len(ids) {
+		return nil, nil
+	}
+	return ids[offset:min(offset+limit, len(ids))], nil
+}
+
+func (f *FakeRepo) MaxWatchedAtForUsers(_ context.Context, userIDs []int64) (time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["MaxWatchedAtForUsers"]; err != nil {
+		return time.Time{}, err
+	}
+	var latest time.Time
+	for _, userID := range userIDs {
+		for _, item := range f.History[userID] {
+			if item.WatchedAt.After(latest) {
+				latest = item.WatchedAt
+			}
+		}
+	}
+	return latest, nil
+}
+
+func (f *FakeRepo) MaxUserID(_ context.Context) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["MaxUserID"]; err != nil {
+		return 0, err
+	}
+	var maxID int64
+	for id := range f.Users {
+		maxID = max(maxID, id)
+	}
+	return maxID, nil
+}
+
+// InsertUser stores the user under the next free ID
+func (f *FakeRepo) InsertUser(_ context.Context, u domain.User) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["InsertUser"]; err != nil {
+		return 0, err
+	}
+	u.ID = int64(len(f.Users) + 1)
+	for f.Users[u.ID] != nil {
+		u.ID++
+	}
+	f.Users[u.ID] = &u
+	return u.ID, nil
+}
+
+func (f *FakeRepo) FindUsers(_ context.Context, filter domain.UserFilter) ([]domain.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["FindUsers"]; err != nil {
+		return nil, err
+	}
+	var users []domain.User
+	for _, u := range f.Users {
+		if (filter.Country == "" || u.Country == filter.Country) &&
+			(filter.SubscriptionType == "" || u.SubscriptionType == filter.SubscriptionType) &&
+			u.Age >= filter.MinAge {
+			users = append(users, *u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	offset := (filter.Page - 1) * filter.Limit
+	if offset >= len(users) {
+		return nil, nil
+	}
+	return users[offset:min(offset+filter.Limit, len(users))], nil
+}
+
+func (f *FakeRepo) CountUsers(_ context.Context) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["CountUsers"]; err != nil {
+		return 0, err
+	}
+	return len(f.Users), nil
+}
+
+func (f *FakeRepo) CountContent(_ context.Context) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["CountContent"]; err != nil {
+		return 0, err
+	}
+	return len(f.Content), nil
+}
+
+func (f *FakeRepo) CountWatchEvents(_ context.Context) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["CountWatchEvents"]; err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, items := range f.History {
+		total += len(items)
+	}
+	return total, nil
+}
+
+func (f *FakeRepo) GenreCounts(_ context.Context) (map[string]int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["GenreCounts"]; err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	for _, c := range f.Content {
+		counts[c.Genre]++
+	}
+	return counts, nil
+}
+
+func (f *FakeRepo) SubscriptionCounts(_ context.Context) (map[string]int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["SubscriptionCounts"]; err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	for _, u := range f.Users {
+		counts[u.SubscriptionType]++
+	}
+	return counts, nil
+}
+
+func (f *FakeRepo) GetMostActiveUsers(_ context.Context, since time.Time, limit int) ([]int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["GetMostActiveUsers"]; err != nil {
+		return nil, err
+	}
+	counts := make(map[int64]int)
+	var ids []int64
+	for userID, items := range f.History {
+		for _, item := range items {
+			if item.WatchedAt.Before(since) {
+				continue
+			}
+			if counts[userID] == 0 {
+				ids = append(ids, userID)
+			}
+			counts[userID]++
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if counts[ids[i]] != counts[ids[j]] {
+			return counts[ids[i]] > counts[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+func (f *FakeRepo) AddWatchHistory(_ context.Context, userID, contentID int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["AddWatchHistory"]; err != nil {
+		return err
+	}
+	return f.insertWatch(userID, contentID)
+}
+
+func (f *FakeRepo) AddWatchHistoryBatch(_ context.Context, userID int64, contentIDs []int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["AddWatchHistoryBatch"]; err != nil {
+		return 0, err
+	}
+	inserted := 0
+	for _, contentID := range contentIDs {
+		err := f.insertWatch(userID, contentID)
+		if err == domain.ErrAlreadyWatched {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		inserted++
+	}
+	return inserted, nil
+}
+
+func (f *FakeRepo) RemoveWatchHistory(_ context.Context, userID, contentID int64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["RemoveWatchHistory"]; err != nil {
+		return false, err
+	}
+	items := f.History[userID]
+	for i, item := range items {
+		if item.ContentID == contentID {
+			f.History[userID] = append(items[:i:i], items[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *FakeRepo) BlockContent(_ context.Context, userID, contentID int64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["BlockContent"]; err != nil {
+		return false, err
+	}
+	if _, ok := f.Users[userID]; !ok {
+		return false, domain.ErrUserNotFound
+	}
+	if _, ok := f.contentByID(contentID); !ok {
+		return false, domain.ErrContentNotFound
+	}
+	if f.Blocked[userID][contentID] {
+		return false, nil
+	}
+	if f.Blocked[userID] == nil {
+		f.Blocked[userID] = make(map[int64]bool)
+	}
+	f.Blocked[userID][contentID] = true
+	return true, nil
+}
+
+func (f *FakeRepo) UnblockContent(_ context.Context, userID, contentID int64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.Errors["UnblockContent"]; err != nil {
+		return false, err
+	}
+	if !f.Blocked[userID][contentID] {
+		return false, nil
+	}
+	delete(f.Blocked[userID], contentID)
+	return true, nil
+}
+
+// insertWatch mirrors the database constraints on user_watch_history
+func (f *FakeRepo) insertWatch(userID, contentID int64) error {
+	if _, ok := f.Users[userID]; !ok {
+		return domain.ErrUserNotFound
+	}
+	c, ok := f.contentByID(contentID)
+	if !ok {
+		return domain.ErrContentNotFound
+	}
+	if f.watched(userID, contentID) {
+		return domain.ErrAlreadyWatched
+	}
+	f.History[userID] = append(f.History[userID], domain.WatchHistoryItem{
+		ContentID: contentID,
+		Genre:     c.Genre,
+		WatchedAt: time.Now(),
+	})
+	return nil
+}
+
+// FakeScorer ranks candidates in input order with no latency. Err fails every
+// call; FailUsers fails calls for specific users; Truncated reports every
+// result as cut short by its context.
+type FakeScorer struct {
+	Err       error
+	FailUsers map[int64]bool
+	Truncated bool
+	calls     atomic.Int32
+	mu        sync.Mutex
+	users     []int64
+}
+
+var (
+	_ model.Scorer    = (*FakeScorer)(nil)
+	_ model.Explainer = (*FakeScorer)(nil)
+)
+
+// Calls returns how many times Score was called
+func (f *FakeScorer) Calls() int {
+	return int(f.calls.Load())
+}
+
+// ScoredUsers returns the user of each Score call, in call order
+func (f *FakeScorer) ScoredUsers() []int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int64(nil), f.users...)
+}
+
+func (f *FakeScorer) Score(_ context.Context, input model.ScoreInput) ([]domain.ScoredRecommendation, bool, error) {
+	f.calls.Add(1)
+	if input.User != nil {
+		f.mu.Lock()
+		f.users = append(f.users, input.User.ID)
+		f.mu.Unlock()
+	}
+	if f.Err != nil {
+		return nil, false, f.Err
+	}
+	if input.User != nil && f.FailUsers[input.User.ID] {
+		return nil, false, &model.ModelInferenceError{Msg: "model inference failed"}
+	}
+
+	scored := make([]domain.ScoredRecommendation, 0, len(input.Candidates))
+	for i, c := range input.Candidates {
+		if len(scored) == input.Limit {
+			break
+		}
+		scored = append(scored, domain.ScoredRecommendation{
+			ContentID:       c.ID,
+			Title:           c.Title,
+			Genre:           c.Genre,
+			PopularityScore: c.PopularityScore,
+			DurationMinutes: c.DurationMinutes,
+			ReleaseYear:     c.ReleaseYear,
+			Score:           1.0 / float64(i+1),
+		})
+	}
+	return scored, f.Truncated, nil
+}
+
+func (f *FakeScorer) Breakdown(content domain.Content, _ []domain.WatchHistoryItem, _ map[int64]float64) domain.ScoreBreakdown {
+	return domain.ScoreBreakdown{PopularityComponent: content.PopularityScore, Final: content.PopularityScore}
+}
+
+// FakeCache is a map-backed recommendation cache
+type FakeCache struct {
+	mu             sync.Mutex
+	recs           map[string]domain.CachedRecommendations
+	generations    map[string]domain.Generation
+	trending       map[string][]domain.TrendingContent
+	idempotency    map[string]domain.IdempotencyRecord
+	stats          *domain.Stats
+	failures       map[int64][]time.Time
+	failed         map[int64]time.Time
+	changed        map[int64]time.Time
+	catalogChanged time.Time
+	Err            error
+	// Serve a miss from a larger-limit entry up to this limit, as
+	// CACHE_SUPERSET_REUSE does. 0 disables it
+	SupersetMaxLimit int
+}
+
+func NewFakeCache() *FakeCache {
+	return &FakeCache{
+		recs:        make(map[string]domain.CachedRecommendations),
+		generations: make(map[string]domain.Generation),
+		trending:    make(map[string][]domain.TrendingContent),
+		idempotency: make(map[string]domain.IdempotencyRecord),
+		failures:    make(map[int64][]time.Time),
+		failed:      make(map[int64]time.Time),
+		changed:     make(map[int64]time.Time),
+	}
+}
+
+func recKey(userID int64, limit int, variant string) string {
+	return fmt.Sprintf("%d:%d:%s", userID, limit, variant)
+}
+
+// Len returns the number of cached recommendation entries
+func (f *FakeCache) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.recs)
+}
+
+func (f *FakeCache) Get(_ context.Context, userID int64, limit int, variant string) (*domain.CachedRecommendations, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, false, f.Err
+	}
+	entry, ok := f.recs[recKey(userID, limit, variant)]
+	if ok {
+		return &entry, true, nil
+	}
+	if f.SupersetMaxLimit > 0 {
+		for l := limit + 1; l <= f.SupersetMaxLimit; l++ {
+			if entry, ok := f.recs[recKey(userID, l, variant)]; ok {
+				if len(entry.Recommendations) > limit {
+					entry.Recommendations = entry.Recommendations[:limit]
+				}
+				return &entry, true, nil
+			}
+		}
+	}
+	return nil, false, nil
+}
+
+func (f *FakeCache) Set(_ context.Context, userID int64, limit int, variant string, entry domain.CachedRecommendations) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	f.recs[recKey(userID, limit, variant)] = entry
+	return nil
+}
+
+// GetLastGenerated ignores expiry; set an old GeneratedAt to simulate it
+func (f *FakeCache) GetLastGenerated(_ context.Context, userID int64, variant string) (*domain.Generation, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, false, f.Err
+	}
+	gen, ok := f.generations[fmt.Sprintf("%d:last:%s", userID, variant)]
+	if !ok {
+		return nil, false, nil
+	}
+	return &gen, true, nil
+}
+
+func (f *FakeCache) SetLastGenerated(_ context.Context, userID int64, variant string, gen domain.Generation, _ time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	f.generations[fmt.Sprintf("%d:last:%s", userID, variant)] = gen
+	return nil
+}
+
+func (f *FakeCache) GetTrending(_ context.Context, window time.Duration, limit int) ([]domain.TrendingContent, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, false, f.Err
+	}
+	items, ok := f.trending[fmt.Sprintf("%s:%d", window, limit)]
+	return items, ok, nil
+}
+
+func (f *FakeCache) SetTrending(_ context.Context, window time.Duration, limit int, items []domain.TrendingContent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	f.trending[fmt.Sprintf("%s:%d", window, limit)] = items
+	return nil
+}
+
+func (f *FakeCache) GetStats(_ context.Context) (*domain.Stats, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, false, f.Err
+	}
+	return f.stats, f.stats != nil, nil
+}
+
+func (f *FakeCache) SetStats(_ context.Context, stats *domain.Stats) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	f.stats = stats
+	return nil
+}
+
+// RecordBatchFailure counts failures in a window starting at the first one,
+// like the Redis cache
+func (f *FakeCache) RecordBatchFailure(_ context.Context, userID int64, threshold int, window time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return false, f.Err
+	}
+	now := time.Now()
+	if times := f.failures[userID]; len(times) > 0 && now.Sub(times[0]) >= window {
+		delete(f.failures, userID)
+	}
+	f.failures[userID] = append(f.failures[userID], now)
+	if len(f.failures[userID]) <= threshold {
+		return false, nil
+	}
+	f.failed[userID] = now
+	return true, nil
+}
+
+func (f *FakeCache) GetFailedUsers(_ context.Context) ([]domain.FailedUser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	users := make([]domain.FailedUser, 0, len(f.failed))
+	for userID, flaggedAt := range f.failed {
+		users = append(users, domain.FailedUser{UserID: userID, FlaggedAt: flaggedAt, RecentFailures: len(f.failures[userID])})
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].FlaggedAt.Equal(users[j].FlaggedAt) {
+			return users[i].FlaggedAt.After(users[j].FlaggedAt)
+		}
+		return users[i].UserID < users[j].UserID
+	})
+	return users, nil
+}
+
+func (f *FakeCache) ClearFailedUser(_ context.Context, userID int64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return false, f.Err
+	}
+	_, flagged := f.failed[userID]
+	delete(f.failed, userID)
+	delete(f.failures, userID)
+	return flagged, nil
+}
+
+func (f *FakeCache) ClearUserCache(_ context.Context, userID int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return 0, f.Err
+	}
+	prefix := fmt.Sprintf("%d:", userID)
+	deleted := 0
+	for key := range f.recs {
+		if strings.HasPrefix(key, prefix) {
+			delete(f.recs, key)
+			deleted++
+		}
+	}
+	for key := range f.generations {
+		if strings.HasPrefix(key, prefix) {
+			delete(f.generations, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (f *FakeCache) MarkUserChanged(_ context.Context, userID int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	f.changed[userID] = time.Now()
+	return nil
+}
+
+func (f *FakeCache) MarkCatalogChanged(_ context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	f.catalogChanged = time.Now()
+	return nil
+}
+
+// Nothing is evicted here, so a missing stamp means never changed
+func (f *FakeCache) LastChanged(_ context.Context, userIDs []int64) (time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return time.Time{}, f.Err
+	}
+	latest := f.catalogChanged
+	for _, userID := range userIDs {
+		if stamp := f.changed[userID]; stamp.After(latest) {
+			latest = stamp
+		}
+	}
+	return latest, nil
+}
+
+func (f *FakeCache) ClearCatalogCache(_ context.Context) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return 0, f.Err
+	}
+	deleted := len(f.recs) + len(f.generations) + len(f.trending)
+	f.recs = make(map[string]domain.CachedRecommendations)
+	f.generations = make(map[string]domain.Generation)
+	f.trending = make(map[string][]domain.TrendingContent)
+	if f.stats != nil {
+		f.stats = nil
+		deleted++
+	}
+	return deleted, nil
+}
+
+func (f *FakeCache) ReserveIdempotency(_ context.Context, userID int64, key, fingerprint string) (domain.IdempotencyRecord, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return domain.IdempotencyRecord{}, false, f.Err
+	}
+	k := fmt.Sprintf("%d:%s", userID, key)
+	if record, ok := f.idempotency[k]; ok {
+		return record, false, nil
+	}
+	record := domain.IdempotencyRecord{Fingerprint: fingerprint}
+	f.idempotency[k] = record
+	return record, true, nil
+}
+
+func (f *FakeCache) StoreIdempotency(_ context.Context, userID int64, key string, record domain.IdempotencyRecord) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	f.idempotency[fmt.Sprintf("%d:%s", userID, key)] = record
+	return nil
+}
+
+func (f *FakeCache) ReleaseIdempotency(_ context.Context, userID int64, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	delete(f.idempotency, fmt.Sprintf("%d:%s", userID, key))
+	return nil
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+// Catalog and user overview, served from cache when fresh
+func (s *Service) GetStats(ctx context.Context) (*domain.Stats, error) {
+	cached, found, err := s.cache.GetStats(ctx)
+	if err != nil {
+		log.Printf("[service] stats cache get error: %v", err)
+	}
+	if found {
+		return cached, nil
+	}
+
+	stats := &domain.Stats{}
+	if stats.TotalUsers, err = s.repo.CountUsers(ctx); err != nil {
+		return nil, fmt.Errorf("count users: %w", err)
+	}
+	if stats.TotalContent, err = s.repo.CountContent(ctx); err != nil {
+		return nil, fmt.Errorf("count content: %w", err)
+	}
+	if stats.TotalWatchEvents, err = s.repo.CountWatchEvents(ctx); err != nil {
+		return nil, fmt.Errorf("count watch events: %w", err)
+	}
+	if stats.GenreCounts, err = s.repo.GenreCounts(ctx); err != nil {
+		return nil, fmt.Errorf("genre counts: %w", err)
+	}
+	if stats.SubscriptionCounts, err = s.repo.SubscriptionCounts(ctx); err != nil {
+		return nil, fmt.Errorf("subscription counts: %w", err)
+	}
+
+	if err := s.cache.SetStats(ctx, stats); err != nil {
+		log.Printf("[service] stats cache set error: %v", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// How long a looked-up highest user ID is trusted before it is fetched again
+const userIDBoundTTL = 10 * time.Second
+
+// Highest known user ID, so IDs past it are not found without a user query
+type userIDBound struct {
+	mu      sync.Mutex
+	max     int64
+	fetched time.Time
+	// Collapses concurrent lookups into one query, run without holding mu
+	lookup singleflight.Group
+}
+
+// Report whether userID is past every existing user. IDs up to a configured
+// MaxUserID are always in range; past it the highest user ID is looked up at
+// most once per userIDBoundTTL, so users created since are still found. User
+// IDs can have gaps, so the highest ID is used rather than the user count. A
+// failed lookup never rejects an ID.
+func (s *Service) userIDOutOfRange(ctx context.Context, userID int64) bool {
+	if s.maxUserID > 0 && userID <= s.maxUserID {
+		return false
+	}
+
+	b := &s.userIDBound
+	b.mu.Lock()
+	maxID := b.max
+	fresh := !b.fetched.IsZero() && time.Since(b.fetched) <= userIDBoundTTL
+	b.mu.Unlock()
+	if fresh {
+		return userID > maxID
+	}
+
+	v, err, _ := b.lookup.Do("max", func() (any, error) {
+		maxID, err := s.repo.MaxUserID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		// Keep any user created while the query ran
+		b.max, b.fetched = max(b.max, maxID), time.Now()
+		return b.max, nil
+	})
+	if err != nil {
+		log.Printf("[service] max user id lookup error, skipping range check: %v", err)
+		return false
+	}
+	return userID > v.(int64)
+}
+
+// Raise the bound to a newly created user so this instance finds it straight
+// away rather than after the next lookup
+func (s *Service) extendUserIDBound(userID int64) {
+	b := &s.userIDBound
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.max = max(b.max, userID)
+}
+
+// Forget the bound, e.g. after every user was replaced
+func (s *Service) resetUserIDBound() {
+	b := &s.userIDBound
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.max, b.fetched = 0, time.Time{}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+)
+
+// Watch events within this window count towards a user's activity
+const warmCacheActivityWindow = 7 * 24 * time.Hour
+
+// Regenerate and re-cache default recommendations for the topN most active
+// users, bypassing any cached entry. Returns how many users were refreshed.
+func (s *Service) WarmCache(ctx context.Context, topN int) (int, error) {
+	userIDs, err := s.repo.GetMostActiveUsers(ctx, time.Now().Add(-warmCacheActivityWindow), topN)
+	if err != nil {
+		return 0, fmt.Errorf("fetch most active users: %w", err)
+	}
+
+	refreshed := 0
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			return refreshed, ctx.Err()
+		}
+
+		opts, _ := s.resolveOptions(userID, domain.RecommendationOptions{})
+		gen, err := s.generateRecommendations(ctx, userID, opts)
+		if err != nil {
+			log.Printf("[warmer] generate error for user %d: %v", userID, err)
+			continue
+		}
+		if !gen.cacheable() {
+			continue
+		}
+		if err := s.cache.Set(ctx, userID, opts.Limit, opts.Variant(), cacheEntry(gen.recs, gen.exhausted)); err != nil {
+			log.Printf("[warmer] cache set error for user %d: %v", userID, err)
+			continue
+		}
+		refreshed++
+	}
+	return refreshed, nil
+}
+
+// Run WarmCache every interval until stop is closed. The interval should be
+// shorter than the cache TTL so entries are refreshed before they expire.
+func (s *Service) RunCacheWarmer(stop <-chan struct{}, interval time.Duration, topN int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			go func() {
+				// Abort an in-flight pass on shutdown
+				select {
+				case <-stop:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+
+			refreshed, err := s.WarmCache(ctx, topN)
+			cancel()
+			if err != nil {
+				log.Printf("[warmer] pass failed after %d users: %v", refreshed, err)
+				continue
+			}
+			log.Printf("[warmer] refreshed recommendations for %d users", refreshed)
+		}
+	}
+}
+
+// Running totals reported after each page of WarmAllUsers
+type WarmupProgress struct {
+	Page       int
+	Processed  int
+	Failed     int
+	TotalUsers int
+}
+
+// Populate the cache with default recommendations for every user, pageSize
+// users at a time through the batch path. Users with a cached entry keep it.
+// progress, when non-nil, is called after each page; the final totals are
+// returned, along with the ones so far on error.
+func (s *Service) WarmAllUsers(ctx context.Context, pageSize int, progress func(WarmupProgress)) (WarmupProgress, error) {
+	var p WarmupProgress
+	for page := 1; ; page++ {
+		if ctx.Err() != nil {
+			return p, ctx.Err()
+		}
+		batch, err := s.GetBatchRecommendations(ctx, page, pageSize, batchRecLimit, "", false)
+		if err != nil {
+			return p, fmt.Errorf("warm page %d: %w", page, err)
+		}
+		if len(batch.Results) == 0 {
+			return p, nil
+		}
+
+		p.Page = page
+		p.Processed += len(batch.Results) - batch.Summary.CancelledCount
+		p.Failed += batch.Summary.FailedCount
+		p.TotalUsers = batch.TotalUsers
+		if progress != nil {
+			progress(p)
+		}
+		if len(batch.Results) < pageSize {
+			return p, nil
+		}
+	}
+}
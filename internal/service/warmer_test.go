@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/actuallystonmai/recommendation-service/internal/service/servicetest"
+)
+
+// Users 3, 1 and 4 have 3, 2 and 1 recent watches; user 2 only watched long ago
+func newWarmerService(t *testing.T, scorer *servicetest.FakeScorer) (*Service, *servicetest.FakeCache) {
+	repo := servicetest.NewFakeRepoWith(5, 20)
+	now := time.Now()
+	watch := func(userID int64, count int, at time.Time) {
+		for i := 0; i < count; i++ {
+			repo.History[userID] = append(repo.History[userID], domain.WatchHistoryItem{
+				ContentID: int64(i + 1), Genre: "action", WatchedAt: at,
+			})
+		}
+	}
+	watch(3, 3, now)
+	watch(1, 2, now)
+	watch(4, 1, now)
+	watch(2, 5, now.AddDate(0, -1, 0))
+
+	c := servicetest.NewFakeCache()
+	return newTestService(t, repo, c, scorer, Config{}), c
+}
+
+func TestWarmCacheRefreshesMostActiveUsers(t *testing.T) {
+	scorer := &servicetest.FakeScorer{}
+	svc, c := newWarmerService(t, scorer)
+	ctx := context.Background()
+
+	refreshed, err := svc.WarmCache(ctx, 2)
+	if err != nil {
+		t.Fatalf("warm cache: %v", err)
+	}
+	if refreshed != 2 {
+		t.Errorf("expected 2 refreshed users, got %d", refreshed)
+	}
+
+	users := scorer.ScoredUsers()
+	if len(users) != 2 || users[0] != 3 || users[1] != 1 {
+		t.Errorf("expected generate for users [3 1], got %v", users)
+	}
+
+	// The refreshed entries now serve default requests from cache
+	result, err := svc.GetRecommendations(ctx, 3, domain.RecommendationOptions{})
+	if err != nil {
+		t.Fatalf("get recommendations: %v", err)
+	}
+	if !result.CacheHit {
+		t.Error("expected warmed entry to be a cache hit")
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 cache entries, got %d", c.Len())
+	}
+}
+
+func TestRunCacheWarmerStops(t *testing.T) {
+	scorer := &servicetest.FakeScorer{}
+	svc, _ := newWarmerService(t, scorer)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		svc.RunCacheWarmer(stop, 10*time.Millisecond, 3)
+		close(done)
+	}()
+
+	deadline := time.After(time.Second)
+	for scorer.Calls() < 3 {
+		select {
+		case <-deadline:
+			t.Fatal("warmer did not run")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("warmer did not stop")
+	}
+}
+
+func TestWarmAllUsersPages(t *testing.T) {
+	tests := []struct {
+		users     int
+		pageSize  int
+		processed []int
+	}{
+		{5, 2, []int{2, 4, 5}},
+		// A full last page needs one empty page to finish
+		{4, 2, []int{2, 4}},
+		{3, 10, []int{3}},
+		{0, 2, nil},
+	}
+
+	for _, tc := range tests {
+		repo := servicetest.NewFakeRepo()
+		repo.AddUsers(tc.users)
+		repo.AddContent(20)
+		repo.UserErrors[1] = errors.New("boom")
+		c := servicetest.NewFakeCache()
+		svc := newTestService(t, repo, c, &servicetest.FakeScorer{}, Config{BatchConcurrency: 2})
+
+		var processed []int
+		result, err := svc.WarmAllUsers(context.Background(), tc.pageSize, func(p WarmupProgress) {
+			if p.TotalUsers != tc.users {
+				t.Errorf("%d users: expected total %d, got %d", tc.users, tc.users, p.TotalUsers)
+			}
+			processed = append(processed, p.Processed)
+		})
+		if err != nil {
+			t.Fatalf("%d users: warm all users: %v", tc.users, err)
+		}
+		if fmt.Sprint(processed) != fmt.Sprint(tc.processed) {
+			t.Errorf("%d users: expected progress %v, got %v", tc.users, tc.processed, processed)
+		}
+		if result.Processed != tc.users {
+			t.Errorf("%d users: expected %d processed, got %d", tc.users, tc.users, result.Processed)
+		}
+		// User 1 fails; everyone else ends up cached
+		wantFailed := min(tc.users, 1)
+		if result.Failed != wantFailed {
+			t.Errorf("%d users: expected %d failed, got %d", tc.users, wantFailed, result.Failed)
+		}
+		if c.Len() != tc.users-wantFailed {
+			t.Errorf("%d users: expected %d cache entries, got %d", tc.users, tc.users-wantFailed, c.Len())
+		}
+	}
+}
+
+func TestWarmAllUsersPageError(t *testing.T) {
+	repo := servicetest.NewFakeRepo()
+	repo.AddUsers(3)
+	repo.Errors["GetUserIDsPaginated"] = errors.New("boom")
+	svc := newTestService(t, repo, servicetest.NewFakeCache(), &servicetest.FakeScorer{}, Config{})
+
+	if _, err := svc.WarmAllUsers(context.Background(), 2, nil); err == nil {
+		t.Fatal("expected page fetch error")
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import "sync"
+
+// Fixed set of workers shared by every batch request, so batch work is bounded
+// across the process rather than per request. Tasks wait in the queue until a
+// worker is free; submitting blocks once the queue is full too.
+type workerPool struct {
+	size  int
+	tasks chan func()
+	// Held for reading while a run submits, so close never closes tasks
+	// under a sender
+	mu      sync.RWMutex
+	closed  bool
+	workers sync.WaitGroup
+}
+
+// Start size workers, at least one. They run until close.
+func newWorkerPool(size int) *workerPool {
+	size = max(size, 1)
+	p := &workerPool{size: size, tasks: make(chan func(), size)}
+	p.workers.Add(size)
+	for range size {
+		go p.work()
+	}
+	return p
+}
+
+func (p *workerPool) work() {
+	defer p.workers.Done()
+	for task := range p.tasks {
+		task()
+	}
+}
+
+// Run fn for each index in [0, n) on the pool and wait for every call. Calls
+// from concurrent runs share the pool's workers. fn must not itself run tasks
+// on the pool, or it could wait on a worker it holds. Once the pool is closed,
+// calls run one at a time on the caller's goroutine.
+func (p *workerPool) run(n int, fn func(idx int)) {
+	var wg sync.WaitGroup
+	wg.Add(n)
+	p.mu.RLock()
+	for i := range n {
+		task := func() {
+			defer wg.Done()
+			fn(i)
+		}
+		if p.closed {
+			task()
+			continue
+		}
+		p.tasks <- task
+	}
+	p.mu.RUnlock()
+	wg.Wait()
+}
+
+// Stop the workers once every queued task has run, and wait for them to exit.
+// Safe to call more than once.
+func (p *workerPool) close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+	p.workers.Wait()
+}
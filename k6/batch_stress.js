@@ -22,7 +22,7 @@ export default function () {
     );
 
     check(res, {
-        'status is 200': (r) => r.status === 200,
+        'status is 200 or 207': (r) => r.status === 200 || r.status === 207,
         'has results array': (r) => {
             const body = JSON.parse(r.body);
             return Array.isArray(body.results);
@@ -1,3 +1,6 @@
+DROP TABLE IF EXISTS featured_content;
+DROP TABLE IF EXISTS user_blocked_content;
+DROP TABLE IF EXISTS content_popularity_by_country;
 DROP TABLE IF EXISTS user_watch_history;
 DROP TABLE IF EXISTS content;
 DROP TABLE IF EXISTS users;
@@ -6,6 +6,13 @@ CREATE TABLE IF NOT EXISTS users (
     created_at TIMESTAMP NOT NULL DEFAULT NOW()
 );
 
+-- Users can now be created through the API, so the tier is enforced here too
+DO $$ BEGIN
+    ALTER TABLE users ADD CONSTRAINT users_subscription_type_check
+        CHECK (subscription_type IN ('free', 'basic', 'premium'));
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+
 CREATE INDEX IF NOT EXISTS idx_users_country ON users(country);
 CREATE INDEX IF NOT EXISTS idx_users_subscription ON users(subscription_type);
 
@@ -17,6 +24,25 @@ CREATE TABLE IF NOT EXISTS content (
     created_at TIMESTAMP NOT NULL DEFAULT NOW()
 );
 
+-- Licensing window: NULL bounds are open-ended
+ALTER TABLE content ADD COLUMN IF NOT EXISTS available_from TIMESTAMP;
+ALTER TABLE content ADD COLUMN IF NOT EXISTS available_until TIMESTAMP;
+
+-- Descriptive metadata: 0 when unknown
+ALTER TABLE content ADD COLUMN IF NOT EXISTS duration_minutes INT NOT NULL DEFAULT 0 CHECK (duration_minutes >= 0);
+ALTER TABLE content ADD COLUMN IF NOT EXISTS release_year INT NOT NULL DEFAULT 0 CHECK (release_year >= 0);
+
+-- Audience rating used for age filtering. Rows that predate the column were
+-- shown to every age, so they are backfilled with the least restrictive rating
+-- G to stay visible until they are rated; content added later without a
+-- rating is treated as R
+ALTER TABLE content ADD COLUMN IF NOT EXISTS content_rating VARCHAR(5) NOT NULL DEFAULT 'G'
+    CHECK (content_rating IN ('G', 'PG', 'PG-13', 'R'));
+ALTER TABLE content ALTER COLUMN content_rating SET DEFAULT 'R';
+
+-- Set when content is pulled; the row is kept for audit but never served
+ALTER TABLE content ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
 CREATE INDEX IF NOT EXISTS idx_content_genre ON content(genre);
 CREATE INDEX IF NOT EXISTS idx_content_popularity ON content(popularity_score DESC);
 
@@ -27,7 +53,46 @@ CREATE TABLE IF NOT EXISTS user_watch_history (
     watched_at TIMESTAMP NOT NULL DEFAULT NOW()
 );
 
+-- Share of the content watched; watches recorded without one count as finished
+ALTER TABLE user_watch_history ADD COLUMN IF NOT EXISTS completion_ratio DOUBLE PRECISION NOT NULL DEFAULT 1
+    CHECK (completion_ratio > 0 AND completion_ratio <= 1);
+
 CREATE INDEX IF NOT EXISTS idx_watch_history_user ON user_watch_history(user_id);
 CREATE INDEX IF NOT EXISTS idx_watch_history_content ON user_watch_history(content_id);
 CREATE INDEX IF NOT EXISTS idx_watch_history_composite ON user_watch_history(user_id, watched_at DESC);
-CREATE INDEX IF NOT EXISTS idx_watch_history_user_content ON user_watch_history (user_id, content_id);
+-- One watch record per user/content pair: keep each pair's latest watch, then
+-- replace the old non-unique index with a unique one
+DELETE FROM user_watch_history h
+USING user_watch_history newer
+WHERE h.user_id = newer.user_id
+  AND h.content_id = newer.content_id
+  AND (h.watched_at, h.id) < (newer.watched_at, newer.id);
+DROP INDEX IF EXISTS idx_watch_history_user_content;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_watch_history_user_content_unique ON user_watch_history (user_id, content_id);
+CREATE INDEX IF NOT EXISTS idx_watch_history_watched_at ON user_watch_history(watched_at);
+
+CREATE TABLE IF NOT EXISTS content_popularity_by_country (
+    content_id BIGINT NOT NULL REFERENCES content(id) ON DELETE CASCADE,
+    country VARCHAR(2) NOT NULL,
+    popularity_score DOUBLE PRECISION NOT NULL CHECK (popularity_score >= 0),
+    PRIMARY KEY (content_id, country)
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_popularity_country ON content_popularity_by_country(country);
+
+-- Content a user (e.g. via parental controls) never wants recommended
+CREATE TABLE IF NOT EXISTS user_blocked_content (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    content_id BIGINT NOT NULL REFERENCES content(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, content_id)
+);
+
+-- Editorially pinned content, shown ahead of scored recommendations
+CREATE TABLE IF NOT EXISTS featured_content (
+    content_id BIGINT PRIMARY KEY REFERENCES content(id) ON DELETE CASCADE,
+    priority INT NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE INDEX IF NOT EXISTS idx_featured_content_active ON featured_content(priority DESC) WHERE active;
@@ -9,71 +9,104 @@ import (
 	"strings"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/actuallystonmai/recommendation-service/internal/domain"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
-func Setup(ctx context.Context, pool *pgxpool.Pool) error {
-	rng := rand.New(rand.NewSource(42))
+// Postgres allows at most 65535 bind parameters per statement
+const maxInsertParams = 65535
+
+type SeedConfig struct {
+	Users       int
+	Content     int
+	WatchEvents int
+	// Seed for the deterministic random generator
+	Seed int
+}
+
+func DefaultSeedConfig() SeedConfig {
+	return SeedConfig{
+		Users:       20,
+		Content:     50,
+		WatchEvents: 200,
+		Seed:        42,
+	}
+}
+
+// Number of rows inserted into each table
+type SeedSummary struct {
+	Users              int
+	Content            int
+	WatchEvents        int
+	RegionalPopularity int
+}
+
+// Where seeding runs, satisfied by both *pgxpool.Pool and pgx.Tx. Pass a
+// transaction to make the truncate and re-insert all or nothing.
+type Execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func Setup(ctx context.Context, db Execer, cfg SeedConfig) (SeedSummary, error) {
+	rng := rand.New(rand.NewSource(int64(cfg.Seed)))
+	var summary SeedSummary
+	var err error
 
 	// Truncate existing data before insert
 	log.Println("[seed] truncating existing data")
-	if _, err := pool.Exec(ctx, `
-		TRUNCATE user_watch_history, content, users RESTART IDENTITY CASCADE
+	if _, err := db.Exec(ctx, `
+		TRUNCATE content_popularity_by_country, user_watch_history, content, users RESTART IDENTITY CASCADE
 	`); err != nil {
-		return fmt.Errorf("truncate: %w", err)
+		return summary, fmt.Errorf("truncate: %w", err)
 	}
 
 	log.Println("[seed] inserting users")
-	if err := seedUsers(ctx, pool, rng, 20); err != nil {
-		return fmt.Errorf("seed users: %w", err)
+	if summary.Users, err = seedUsers(ctx, db, rng, cfg.Users); err != nil {
+		return summary, fmt.Errorf("seed users: %w", err)
 	}
 
 	log.Println("[seed] inserting content")
-	if err := seedContent(ctx, pool, rng, 50); err != nil {
-		return fmt.Errorf("seed content: %w", err)
+	if summary.Content, err = seedContent(ctx, db, rng, cfg.Content); err != nil {
+		return summary, fmt.Errorf("seed content: %w", err)
 	}
 
 	log.Println("[seed] inserting watch history")
-	if err := seedWatchHistory(ctx, pool, rng, 200); err != nil {
-		return fmt.Errorf("seed watch history: %w", err)
+	if summary.WatchEvents, err = seedWatchHistory(ctx, db, rng, cfg.WatchEvents, cfg.Users, cfg.Content); err != nil {
+		return summary, fmt.Errorf("seed watch history: %w", err)
 	}
 
-	log.Println("[seed] seeding complete")
-	return nil
+	log.Println("[seed] inserting regional popularity")
+	if summary.RegionalPopularity, err = seedRegionalPopularity(ctx, db, rng, cfg.Content); err != nil {
+		return summary, fmt.Errorf("seed regional popularity: %w", err)
+	}
+
+	log.Printf("[seed] seeding complete: %d users, %d content, %d watch events, %d regional popularity rows",
+		summary.Users, summary.Content, summary.WatchEvents, summary.RegionalPopularity)
+	return summary, nil
 }
 
-func seedUsers(ctx context.Context, pool *pgxpool.Pool, rng *rand.Rand, n int) error {
-	countries := []string{"US", "GB", "CA", "AU", "DE", "FR", "JP", "BR"}
-	subscriptionTypes := []string{"free", "basic", "premium"}
+var countries = []string{"US", "GB", "CA", "AU", "DE", "FR", "JP", "BR"}
+
+func seedUsers(ctx context.Context, db Execer, rng *rand.Rand, n int) (int, error) {
+	subscriptionTypes := domain.SubscriptionTypes
 	subscriptionWeights := []float64{0.5, 0.3, 0.2}
 
-	rows := []string{}
-	args := []any{}
+	values := [][]any{}
 
-	for i := range n {
+	for range n {
 		age := rng.Intn(48) + 18
 		country := countries[rng.Intn(len(countries))]
 		subscription := weightedChoice(rng, subscriptionTypes, subscriptionWeights)
 		createdAt := time.Now().AddDate(0, 0, -rng.Intn(365))
 
-		base := i * 4
-		rows = append(rows, fmt.Sprintf("($%d, $%d, $%d, $%d)", base+1, base+2, base+3, base+4))
-
-		args = append(args, age, country, subscription, createdAt)
-	}
-	
-	if len(rows) == 0 {
-		return nil
+		values = append(values, []any{age, country, subscription, createdAt})
 	}
-	
-	query := "INSERT INTO users (age, country, subscription_type, created_at) VALUES " + strings.Join(rows, ", ")
 
-	_, err := pool.Exec(ctx, query, args...)
-	return err
+	return insertRows(ctx, db, "INSERT INTO users (age, country, subscription_type, created_at) VALUES ", values)
 }
 
-func seedContent(ctx context.Context, pool *pgxpool.Pool, rng *rand.Rand, n int) error {
-	genres := []string{"action", "drama", "comedy", "thriller", "sci-fi"}
+func seedContent(ctx context.Context, db Execer, rng *rand.Rand, n int) (int, error) {
+	genres := domain.Genres
 	titles := map[string][]string{
 		"action": {
 			"Die Hard", "Mad Max: Fury Road", "John Wick", "The Dark Knight",
@@ -102,8 +135,7 @@ func seedContent(ctx context.Context, pool *pgxpool.Pool, rng *rand.Rand, n int)
 		},
 	}
 	
-	rows := []string{}
-	args := []any{}
+	values := [][]any{}
 
 	for i := range n {
 		genre := genres[i%len(genres)]
@@ -116,35 +148,51 @@ func seedContent(ctx context.Context, pool *pgxpool.Pool, rng *rand.Rand, n int)
 
 		popularity := powerLawScore(rng)
 		createdAt := time.Now().AddDate(0, 0, -rng.Intn(730))
-
-		base := len(args)
-		rows = append(rows, fmt.Sprintf("($%d, $%d, $%d, $%d)", base+1, base+2, base+3, base+4))
-		args = append(args, title, genre, popularity, createdAt)
+		availableFrom, availableUntil := availabilityWindow(i, createdAt)
+		// 80-179 minutes, released up to 30 years before it was added
+		durationMinutes := 80 + rng.Intn(100)
+		releaseYear := createdAt.Year() - rng.Intn(31)
+		// Each genre cycles through every rating
+		rating := domain.ContentRatings[(i/len(genres))%len(domain.ContentRatings)]
+
+		values = append(values, []any{title, genre, popularity, createdAt, availableFrom, availableUntil, durationMinutes, releaseYear, rating})
 	}
 
-	if len(rows) == 0 {
-		return nil
-	}
-
-	query := "INSERT INTO content (title, genre, popularity_score, created_at) VALUES " +
-		strings.Join(rows, ", ")
+	return insertRows(ctx, db, "INSERT INTO content (title, genre, popularity_score, created_at, available_from, available_until, duration_minutes, release_year, content_rating) VALUES ", values)
+}
 
-	_, err := pool.Exec(ctx, query, args...)
-	return err
+// Most content is always available; a few items get a licensing window that
+// is still open, already expired, or not yet started
+func availabilityWindow(i int, createdAt time.Time) (*time.Time, *time.Time) {
+	now := time.Now()
+	switch {
+	case i%25 == 24:
+		until := now.AddDate(0, 0, -7)
+		return &createdAt, &until
+	case i%25 == 23:
+		from := now.AddDate(0, 0, 30)
+		return &from, nil
+	case i%10 == 7:
+		until := now.AddDate(0, 0, 180)
+		return &createdAt, &until
+	}
+	return nil, nil
 }
 
-func seedWatchHistory(ctx context.Context, pool *pgxpool.Pool, rng *rand.Rand, n int) error {
-	seen := make(map[[2]int64]bool)
+func seedWatchHistory(ctx context.Context, db Execer, rng *rand.Rand, n, userCount, contentCount int) (int, error) {
+	if userCount == 0 || contentCount == 0 {
+		return 0, nil
+	}
 
-	rows := []string{}
-	args := []any{}
+	seen := make(map[[2]int64]bool)
+	values := [][]any{}
 
 	for range n {
-		userID := int64(math.Ceil(math.Pow(rng.Float64(), 1.5) * 20))
-		userID = max(1, min(userID, 20))
+		userID := int64(math.Ceil(math.Pow(rng.Float64(), 1.5) * float64(userCount)))
+		userID = max(1, min(userID, int64(userCount)))
 
-		contentID := int64(math.Ceil(math.Pow(rng.Float64(), 1.3) * 50))
-		contentID = max(1, min(contentID, 50))
+		contentID := int64(math.Ceil(math.Pow(rng.Float64(), 1.3) * float64(contentCount)))
+		contentID = max(1, min(contentID, int64(contentCount)))
 
 		key := [2]int64{userID, contentID}
 		if seen[key] {
@@ -154,22 +202,65 @@ func seedWatchHistory(ctx context.Context, pool *pgxpool.Pool, rng *rand.Rand, n
 
 		watchedAt := time.Now().AddDate(0, 0, -rng.Intn(180))
 
-		base := len(args)
-		rows = append(rows, fmt.Sprintf("($%d, $%d, $%d)", base+1, base+2, base+3))
-		args = append(args, userID, contentID, watchedAt)
+		values = append(values, []any{userID, contentID, watchedAt, completionRatio(rng)})
 	}
 
-	if len(rows) == 0 {
-		return nil
+	return insertRows(ctx, db, "INSERT INTO user_watch_history (user_id, content_id, watched_at, completion_ratio) VALUES ", values)
+}
+
+// Most watches are finished; about a third are abandoned somewhere between 5%
+// and 95% of the way through
+func completionRatio(rng *rand.Rand) float64 {
+	if rng.Float64() >= 0.3 {
+		return 1
 	}
+	return math.Round((0.05+0.9*rng.Float64())*100) / 100
+}
 
-	query := "INSERT INTO user_watch_history (user_id, content_id, watched_at) VALUES " +
-		strings.Join(rows, ", ")
+// Give roughly half of the content a regional popularity in each country
+func seedRegionalPopularity(ctx context.Context, db Execer, rng *rand.Rand, contentCount int) (int, error) {
+	values := [][]any{}
 
-	_, err := pool.Exec(ctx, query, args...)
-	return err
+	for contentID := 1; contentID <= contentCount; contentID++ {
+		for _, country := range countries {
+			if rng.Float64() < 0.5 {
+				continue
+			}
+			values = append(values, []any{contentID, country, powerLawScore(rng)})
+		}
+	}
+
+	return insertRows(ctx, db, "INSERT INTO content_popularity_by_country (content_id, country, popularity_score) VALUES ", values)
 }
 
+// Multi-row insert, split into chunks that stay under the bind parameter limit.
+// Returns the number of rows inserted.
+func insertRows(ctx context.Context, db Execer, prefix string, values [][]any) (int, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	chunkSize := maxInsertParams / len(values[0])
+	for start := 0; start < len(values); start += chunkSize {
+		end := min(start+chunkSize, len(values))
+
+		rows := []string{}
+		args := []any{}
+		for _, row := range values[start:end] {
+			placeholders := make([]string, len(row))
+			for i := range row {
+				placeholders[i] = fmt.Sprintf("$%d", len(args)+i+1)
+			}
+			rows = append(rows, "("+strings.Join(placeholders, ", ")+")")
+			args = append(args, row...)
+		}
+
+		if _, err := db.Exec(ctx, prefix+strings.Join(rows, ", "), args...); err != nil {
+			return 0, err
+		}
+	}
+	return len(values), nil
+}
 
 func powerLawScore(rng *rand.Rand) float64 {
 	u := rng.Float64()
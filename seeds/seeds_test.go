@@ -0,0 +1,74 @@
+package seeds
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestSetupCustomSize(t *testing.T) {
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL not set, skipping seed test")
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		t.Fatalf("connect test database: %v", err)
+	}
+	defer pool.Close()
+
+	for _, file := range []string{"create_tables.down.sql", "create_tables.up.sql"} {
+		sql, err := os.ReadFile("../migrations/" + file)
+		if err != nil {
+			t.Fatalf("read migration %s: %v", file, err)
+		}
+		if _, err := pool.Exec(ctx, string(sql)); err != nil {
+			t.Fatalf("apply migration %s: %v", file, err)
+		}
+	}
+
+	cfg := SeedConfig{Users: 5, Content: 8, WatchEvents: 15, Seed: 7}
+	summary, err := Setup(ctx, pool, cfg)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	count := func(query string) int {
+		var n int
+		if err := pool.QueryRow(ctx, query).Scan(&n); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return n
+	}
+
+	if summary.Users != cfg.Users || summary.Content != cfg.Content {
+		t.Errorf("expected summary of %d users and %d content, got %+v", cfg.Users, cfg.Content, summary)
+	}
+	if n := count("SELECT COUNT(*) FROM user_watch_history"); n != summary.WatchEvents {
+		t.Errorf("expected summary watch events to match table count %d, got %d", n, summary.WatchEvents)
+	}
+	if n := count("SELECT COUNT(*) FROM content_popularity_by_country"); n != summary.RegionalPopularity {
+		t.Errorf("expected summary regional popularity to match table count %d, got %d", n, summary.RegionalPopularity)
+	}
+
+	if n := count("SELECT COUNT(*) FROM users"); n != cfg.Users {
+		t.Errorf("expected %d users, got %d", cfg.Users, n)
+	}
+	if n := count("SELECT COUNT(*) FROM content"); n != cfg.Content {
+		t.Errorf("expected %d content, got %d", cfg.Content, n)
+	}
+
+	// Duplicates are skipped so at most WatchEvents rows
+	if n := count("SELECT COUNT(*) FROM user_watch_history"); n == 0 || n > cfg.WatchEvents {
+		t.Errorf("expected 1-%d watch events, got %d", cfg.WatchEvents, n)
+	}
+
+	// Watch history only references seeded ids
+	if n := count("SELECT COUNT(*) FROM user_watch_history WHERE user_id > 5 OR content_id > 8"); n != 0 {
+		t.Errorf("expected watch history within configured ranges, got %d out of range", n)
+	}
+}